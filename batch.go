@@ -0,0 +1,116 @@
+package imagor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// maxBatchSpecSize maximum bytes allowed for batch endpoint JSON spec
+const maxBatchSpecSize = 1 << 20
+
+var resultSaveContextKey = contextKey{6}
+
+// resultSave result storage save error of batch item,
+// Do waits for the save instead of returning once processed
+type resultSave struct {
+	Err error
+}
+
+// BatchResult result of a batch item
+type BatchResult struct {
+	Path  string `json:"path"`
+	Code  int    `json:"code"`
+	Error string `json:"error,omitempty"`
+}
+
+// Batch processes imagor paths e.g. for prewarming result storages,
+// with bounded concurrency of process concurrency or number of CPUs.
+// fn is called with result of each path once done and saved to result storages,
+// resulting blobs are not retained so that memory stays bounded
+func (app *Imagor) Batch(ctx context.Context, paths []string, fn func(BatchResult)) {
+	n := int(app.ProcessConcurrency)
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	var wg sync.WaitGroup
+	var ch = make(chan string)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range ch {
+				res := app.batchItem(ctx, path)
+				if fn != nil {
+					fn(res)
+				}
+			}
+		}()
+	}
+	for _, path := range paths {
+		ch <- path
+	}
+	close(ch)
+	wg.Wait()
+}
+
+func (app *Imagor) batchItem(ctx context.Context, path string) BatchResult {
+	res := BatchResult{Path: path, Code: http.StatusOK}
+	if err := ctx.Err(); err != nil {
+		return batchError(res, err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // release resources of item once done
+	ctx = withContext(ctx)
+	save := &resultSave{}
+	ctx = context.WithValue(ctx, resultSaveContextKey, save)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return batchError(res, err)
+	}
+	p := imagorpath.Parse(path)
+	if p.Image == "" || p.Path == "" {
+		return batchError(res, ErrInvalid)
+	}
+	if _, err = checkBlob(app.Do(r, p)); err != nil {
+		return batchError(res, err)
+	}
+	if save.Err != nil {
+		return batchError(res, save.Err)
+	}
+	return res
+}
+
+func batchError(res BatchResult, err error) BatchResult {
+	e := WrapError(err)
+	res.Code = e.Code
+	res.Error = e.Message
+	return res
+}
+
+// serveBatch handles POST /batch with JSON array of imagor paths,
+// streaming newline delimited JSON of BatchResult once each path done
+func (app *Imagor) serveBatch(w http.ResponseWriter, r *http.Request) {
+	var paths []string
+	if err := json.NewDecoder(
+		http.MaxBytesReader(w, r.Body, maxBatchSpecSize)).Decode(&paths); err != nil || len(paths) == 0 {
+		app.writeError(w, r, ErrInvalid)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	app.Batch(r.Context(), paths, func(res BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = enc.Encode(res)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+}
@@ -1,10 +1,15 @@
 package imagor
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"github.com/cshum/imagor/fanoutreader"
 	"github.com/cshum/imagor/seekstream"
+	"hash"
 	"io"
 	"net/http"
 	"os"
@@ -36,8 +41,36 @@ const (
 	BlobTypeBMP
 	BlobTypePDF
 	BlobTypeSVG
+	BlobTypeICO
 )
 
+var blobTypeNames = map[BlobType]string{
+	BlobTypeUnknown: "unknown",
+	BlobTypeEmpty:   "empty",
+	BlobTypeMemory:  "memory",
+	BlobTypeJSON:    "json",
+	BlobTypeJPEG:    "jpeg",
+	BlobTypePNG:     "png",
+	BlobTypeGIF:     "gif",
+	BlobTypeWEBP:    "webp",
+	BlobTypeAVIF:    "avif",
+	BlobTypeHEIF:    "heif",
+	BlobTypeTIFF:    "tiff",
+	BlobTypeJP2:     "jp2",
+	BlobTypeBMP:     "bmp",
+	BlobTypePDF:     "pdf",
+	BlobTypeSVG:     "svg",
+	BlobTypeICO:     "ico",
+}
+
+// String returns BlobType name
+func (t BlobType) String() string {
+	if name, ok := blobTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
 // Blob imagor data blob abstraction
 type Blob struct {
 	newReader     func() (r io.ReadCloser, size int64, err error)
@@ -51,9 +84,15 @@ type Blob struct {
 	filepath      string
 	contentType   string
 	memory        *memory
+	buf           []byte
+	release       func()
 
 	Header http.Header
 	Stat   *Stat
+
+	// CacheTTL cache TTL derived from origin, overrides imagor cache header TTL if non-zero.
+	// Negative for no cache
+	CacheTTL time.Duration
 }
 
 // Stat Blob stat attributes
@@ -73,7 +112,13 @@ func NewBlob(newReader func() (reader io.ReadCloser, size int64, err error)) *Bl
 
 // NewBlobFromFile creates imagor Blob from file path and optional file info checks
 func NewBlobFromFile(filepath string, checks ...func(os.FileInfo) error) *Blob {
-	stat, err := os.Stat(filepath)
+	return NewBlobFromFileContext(context.Background(), filepath, checks...)
+}
+
+// NewBlobFromFileContext creates imagor Blob from file path,
+// with file stat and open aborted on context cancellation
+func NewBlobFromFileContext(ctx context.Context, filepath string, checks ...func(os.FileInfo) error) *Blob {
+	stat, err := statFileContext(ctx, filepath)
 	if os.IsNotExist(err) {
 		err = ErrNotFound
 	}
@@ -92,7 +137,7 @@ func NewBlobFromFile(filepath string, checks ...func(os.FileInfo) error) *Blob {
 			if err != nil {
 				return nil, 0, err
 			}
-			reader, err := os.Open(filepath)
+			reader, err := openFileContext(ctx, filepath)
 			return reader, stat.Size(), err
 		},
 	}
@@ -107,6 +152,139 @@ func NewBlobFromFile(filepath string, checks ...func(os.FileInfo) error) *Blob {
 	return blob
 }
 
+func statFileContext(ctx context.Context, filepath string) (os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		stat os.FileInfo
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		stat, err := os.Stat(filepath)
+		ch <- result{stat, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.stat, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func openFileContext(ctx context.Context, filepath string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		file *os.File
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		file, err := os.Open(filepath)
+		ch <- result{file, err}
+	}()
+	select {
+	case res := <-ch:
+		return res.file, res.err
+	case <-ctx.Done():
+		go func() {
+			// close file opened after cancellation
+			if res := <-ch; res.file != nil {
+				_ = res.file.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// NewBlobWithSpill creates imagor Blob from io.ReadCloser and size,
+// spilling source of unknown size or size over threshold to temp file,
+// so that it is seekable and multi-readable without re-opening source.
+// Source within threshold is buffered in memory.
+// Temp file is removed on Release, or when context is done
+func NewBlobWithSpill(
+	ctx context.Context, newReader func() (reader io.ReadCloser, size int64, err error), threshold int64,
+) *Blob {
+	var (
+		once     sync.Once
+		buf      []byte
+		filepath string
+		size     int64
+		err      error
+	)
+	blob := &Blob{fanout: false}
+	var removeOnce sync.Once
+	remove := func() {
+		removeOnce.Do(func() {
+			if filepath != "" {
+				_ = os.Remove(filepath)
+			}
+		})
+	}
+	blob.release = func() {
+		// wait for pending spill so that temp file is not left behind
+		once.Do(func() {
+			err = os.ErrClosed
+		})
+		remove()
+	}
+	blob.newReader = func() (io.ReadCloser, int64, error) {
+		once.Do(func() {
+			var reader io.ReadCloser
+			if reader, size, err = newReader(); err != nil {
+				return
+			}
+			if size > 0 && size < threshold {
+				// within threshold, no spill needed
+				defer func() {
+					_ = reader.Close()
+				}()
+				buf, err = io.ReadAll(reader)
+				return
+			}
+			filepath, size, err = spillTempFile(reader)
+			if err == nil {
+				if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+					r.Defer(remove)
+				} else {
+					context.AfterFunc(ctx, remove)
+				}
+			}
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if filepath == "" {
+			return &readSeekNopCloser{ReadSeeker: bytes.NewReader(buf)}, size, nil
+		}
+		reader, err := os.Open(filepath)
+		return reader, size, err
+	}
+	return blob
+}
+
+func spillTempFile(reader io.ReadCloser) (string, int64, error) {
+	defer func() {
+		_ = reader.Close()
+	}()
+	file, err := os.CreateTemp("", "imagor-spill-")
+	if err != nil {
+		return "", 0, err
+	}
+	size, err := io.Copy(file, reader)
+	if err2 := file.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", 0, err
+	}
+	return file.Name(), size, nil
+}
+
 // NewBlobFromJsonMarshal creates imagor Blob from json marshal of any object
 func NewBlobFromJsonMarshal(v any) *Blob {
 	buf, err := json.Marshal(v)
@@ -127,6 +305,7 @@ func NewBlobFromBytes(buf []byte) *Blob {
 	size := int64(len(buf))
 	return &Blob{
 		fanout: false,
+		buf:    buf,
 		newReader: func() (io.ReadCloser, int64, error) {
 			rs := bytes.NewReader(buf)
 			return &readSeekNopCloser{ReadSeeker: rs}, size, nil
@@ -155,6 +334,7 @@ var webpHeader = []byte("\x57\x45\x42\x50")
 var pngHeader = []byte("\x89\x50\x4E\x47")
 var bmpHeader = []byte("BM")
 var pdfHeader = []byte("\x25\x50\x44\x46")
+var utf8BOM = []byte("\xEF\xBB\xBF")
 
 // https://github.com/strukturag/libheif/blob/master/libheif/heif.cc
 var ftyp = []byte("ftyp")
@@ -172,6 +352,7 @@ var jpx = []byte{0x6a, 0x70, 0x78, 0x20}
 // Jpm matches a JPEG 2000 Image file (ISO 15444-6).
 var jpm = []byte{0x6a, 0x70, 0x6D, 0x20}
 
+var icoHeader = []byte("\x00\x00\x01\x00")
 var tifII = []byte("\x49\x49\x2A\x00")
 var tifMM = []byte("\x4D\x4D\x00\x2A")
 
@@ -278,6 +459,11 @@ func (b *Blob) doInit() {
 					newReadSeeker: newReadSeeker,
 				}, size, nil
 			}
+		} else {
+			// seek over fan-out buffer, only blocks when reading beyond buffered data
+			b.newReadSeeker = func() (io.ReadSeekCloser, int64, error) {
+				return fanout.NewReadSeeker(), size, nil
+			}
 		}
 	} else {
 		b.fanout = false
@@ -327,6 +513,8 @@ func (b *Blob) doInit() {
 			b.blobType = BlobTypePDF
 		} else if bytes.Equal(b.sniffBuf[:2], bmpHeader) {
 			b.blobType = BlobTypeBMP
+		} else if bytes.Equal(b.sniffBuf[:4], icoHeader) && (b.sniffBuf[4] > 0 || b.sniffBuf[5] > 0) {
+			b.blobType = BlobTypeICO
 		}
 	}
 	if b.contentType == "" {
@@ -355,6 +543,8 @@ func (b *Blob) doInit() {
 			b.contentType = "image/bmp"
 		case BlobTypeSVG:
 			b.contentType = "image/svg+xml"
+		case BlobTypeICO:
+			b.contentType = "image/x-icon"
 		default:
 			b.contentType = http.DetectContentType(b.sniffBuf)
 		}
@@ -370,10 +560,12 @@ func (b *Blob) doInit() {
 		detectByHTML := strings.HasPrefix(b.contentType, "text/plain") || strings.HasPrefix(b.contentType, "text/html")
 		detectByXML := strings.HasPrefix(b.contentType, "text/xml")
 		if detectByHTML || detectByXML {
-			dataProcessed := svgComment.ReplaceAll(b.sniffBuf, nil)
+			// leading BOM sniffs as text/plain, which may come with xml declaration
+			hasBOM := bytes.HasPrefix(b.sniffBuf, utf8BOM)
+			dataProcessed := svgComment.ReplaceAll(bytes.TrimPrefix(b.sniffBuf, utf8BOM), nil)
 			dataProcessed = bytes.TrimSpace(dataProcessed)
 			if (detectByHTML && svgTagRegex.Match(dataProcessed)) ||
-				(detectByXML && svgTagInXMLRegex.Match(dataProcessed)) {
+				((detectByXML || hasBOM) && svgTagInXMLRegex.Match(dataProcessed)) {
 				b.blobType = BlobTypeSVG
 				b.contentType = "image/svg+xml"
 			}
@@ -428,6 +620,14 @@ func (b *Blob) Memory() (data []byte, width, height, bands int, ok bool) {
 	return
 }
 
+// SetHeader set Blob response header, forwarded to image response
+func (b *Blob) SetHeader(key, value string) {
+	if b.Header == nil {
+		b.Header = make(http.Header)
+	}
+	b.Header.Set(key, value)
+}
+
 // SetContentType set Blob content type. which overrides default sniffing if this is set
 func (b *Blob) SetContentType(contentType string) {
 	b.contentType = contentType
@@ -471,6 +671,47 @@ func (b *Blob) NewReadSeeker() (io.ReadSeekCloser, int64, error) {
 	return seekstream.New(reader, buffer), size, err
 }
 
+// Clone returns a new Blob of the same source that reads independently,
+// e.g. for multiple processing branches without re-reading the source.
+// Clone initializes the Blob if not yet, guarded by once so that clones
+// made before the first read do not race with init.
+// Clones share the fan-out buffer if the Blob is buffered by fan-out reader,
+// otherwise source readers are re-created by clones
+func (b *Blob) Clone() *Blob {
+	b.init()
+	c := &Blob{
+		newReader:     b.newReader,
+		newReadSeeker: b.newReadSeeker,
+		fanout:        b.fanout,
+		sniffBuf:      b.sniffBuf,
+		err:           b.err,
+		size:          b.size,
+		blobType:      b.blobType,
+		filepath:      b.filepath,
+		contentType:   b.contentType,
+		memory:        b.memory,
+		buf:           b.buf,
+		release:       b.release,
+		Header:        b.Header.Clone(),
+		CacheTTL:      b.CacheTTL,
+	}
+	if b.Stat != nil {
+		stat := *b.Stat
+		c.Stat = &stat
+	}
+	// already initialized from the source Blob
+	c.once.Do(func() {})
+	return c
+}
+
+// Release removes temp resources held by Blob and its clones,
+// such as the spill file of NewBlobWithSpill
+func (b *Blob) Release() {
+	if b.release != nil {
+		b.release()
+	}
+}
+
 // ReadAll real all bytes from Blob
 func (b *Blob) ReadAll() ([]byte, error) {
 	b.init()
@@ -503,6 +744,349 @@ func (b *Blob) ReadAll() ([]byte, error) {
 	return nil, err
 }
 
+// Hash streams Blob data through hash function and returns the checksum.
+// Reads from a new reader, hence does not consume Blob for later use
+func (b *Blob) Hash(alg func() hash.Hash) ([]byte, error) {
+	b.init()
+	h := alg()
+	if m := b.memory; m != nil {
+		_, _ = h.Write(m.data)
+		return h.Sum(nil), nil
+	}
+	if b.buf != nil {
+		_, _ = h.Write(b.buf)
+		return h.Sum(nil), nil
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	reader, _, err := b.NewReader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if _, err = io.Copy(h, reader); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// isInMemory returns true if Blob data is held in memory,
+// so that reading it does not re-open file or storage
+func (b *Blob) isInMemory() bool {
+	return b != nil && (b.memory != nil || b.buf != nil)
+}
+
+// SHA256 returns SHA-256 checksum of Blob data
+func (b *Blob) SHA256() ([]byte, error) {
+	return b.Hash(sha256.New)
+}
+
+// BlobMetadata image dimensions and orientation parsed from headers
+type BlobMetadata struct {
+	Width       int
+	Height      int
+	Orientation int
+	Bands       int
+	BitDepth    int
+}
+
+// DecompressedSize returns the decoded size in bytes of a single frame,
+// accounting for bands and bit depth
+func (m *BlobMetadata) DecompressedSize() int64 {
+	bands, depth := m.Bands, m.BitDepth
+	if bands <= 0 {
+		bands = 3
+	}
+	if depth <= 0 {
+		depth = 8
+	}
+	return int64(m.Width) * int64(m.Height) * int64(bands) * int64((depth+7)/8)
+}
+
+// Swapped returns if width and height are swapped after EXIF orientation applied
+func (m *BlobMetadata) Swapped() bool {
+	return m.Orientation >= 5 && m.Orientation <= 8
+}
+
+// Metadata parses image dimensions and EXIF orientation from headers
+// of JPEG, PNG, GIF, WEBP and TIFF without decoding the image.
+// Returns ErrMetadataNotSupported for other types
+func (b *Blob) Metadata() (*BlobMetadata, error) {
+	b.init()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if m := b.memory; m != nil {
+		return &BlobMetadata{Width: m.width, Height: m.height, Orientation: 1, Bands: m.bands, BitDepth: 8}, nil
+	}
+	var meta *BlobMetadata
+	var err error
+	switch b.blobType {
+	case BlobTypePNG:
+		meta, err = parsePNGMetadata(b.sniffBuf)
+	case BlobTypeGIF:
+		meta, err = parseGIFMetadata(b.sniffBuf)
+	case BlobTypeWEBP:
+		meta, err = parseWEBPMetadata(b.sniffBuf)
+	case BlobTypeJPEG:
+		reader, _, e := b.NewReader()
+		if e != nil {
+			return nil, e
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		meta, err = parseJPEGMetadata(bufio.NewReader(reader))
+	case BlobTypeTIFF:
+		rs, _, e := b.NewReadSeeker()
+		if e != nil {
+			return nil, e
+		}
+		defer func() {
+			_ = rs.Close()
+		}()
+		meta, err = parseTIFFMetadata(&readSeekerAt{rs: rs})
+	default:
+		return nil, ErrMetadataNotSupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta.Width <= 0 || meta.Height <= 0 {
+		return nil, ErrMetadataNotSupported
+	}
+	if meta.Orientation < 1 || meta.Orientation > 8 {
+		meta.Orientation = 1
+	}
+	return meta, nil
+}
+
+// Dimensions returns image width and height parsed from headers without decoding the image.
+// Returns ErrMetadataNotSupported if it cannot be parsed cheaply
+func (b *Blob) Dimensions() (width, height int, err error) {
+	meta, err := b.Metadata()
+	if err != nil {
+		return
+	}
+	return meta.Width, meta.Height, nil
+}
+
+func parsePNGMetadata(buf []byte) (*BlobMetadata, error) {
+	// IHDR chunk is always first
+	if len(buf) < 24 || string(buf[12:16]) != "IHDR" {
+		return nil, ErrMetadataNotSupported
+	}
+	meta := &BlobMetadata{
+		Width:  int(binary.BigEndian.Uint32(buf[16:20])),
+		Height: int(binary.BigEndian.Uint32(buf[20:24])),
+	}
+	if len(buf) >= 26 {
+		meta.BitDepth = int(buf[24])
+		switch buf[25] {
+		case 0: // grayscale
+			meta.Bands = 1
+		case 4: // grayscale alpha
+			meta.Bands = 2
+		case 2: // truecolor
+			meta.Bands = 3
+		case 3: // indexed, expanded to 8 bit RGBA
+			meta.Bands = 4
+			meta.BitDepth = 8
+		case 6: // truecolor alpha
+			meta.Bands = 4
+		}
+	}
+	return meta, nil
+}
+
+func parseGIFMetadata(buf []byte) (*BlobMetadata, error) {
+	if len(buf) < 10 {
+		return nil, ErrMetadataNotSupported
+	}
+	return &BlobMetadata{
+		Width:    int(binary.LittleEndian.Uint16(buf[6:8])),
+		Height:   int(binary.LittleEndian.Uint16(buf[8:10])),
+		Bands:    4,
+		BitDepth: 8,
+	}, nil
+}
+
+func parseWEBPMetadata(buf []byte) (*BlobMetadata, error) {
+	if len(buf) < 30 {
+		return nil, ErrMetadataNotSupported
+	}
+	switch string(buf[12:16]) {
+	case "VP8 ":
+		return &BlobMetadata{
+			Width:    int(binary.LittleEndian.Uint16(buf[26:28]) & 0x3fff),
+			Height:   int(binary.LittleEndian.Uint16(buf[28:30]) & 0x3fff),
+			Bands:    3,
+			BitDepth: 8,
+		}, nil
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(buf[21:25])
+		return &BlobMetadata{
+			Width:    int(bits&0x3fff) + 1,
+			Height:   int((bits>>14)&0x3fff) + 1,
+			Bands:    4,
+			BitDepth: 8,
+		}, nil
+	case "VP8X":
+		bands := 3
+		if buf[20]&0x10 != 0 {
+			// alpha flag
+			bands = 4
+		}
+		return &BlobMetadata{
+			Width:    int(uint32(buf[24])|uint32(buf[25])<<8|uint32(buf[26])<<16) + 1,
+			Height:   int(uint32(buf[27])|uint32(buf[28])<<8|uint32(buf[29])<<16) + 1,
+			Bands:    bands,
+			BitDepth: 8,
+		}, nil
+	}
+	return nil, ErrMetadataNotSupported
+}
+
+// parseJPEGMetadata walks JPEG markers until start of frame,
+// parsing EXIF orientation from APP1 segment on the way
+func parseJPEGMetadata(r *bufio.Reader) (*BlobMetadata, error) {
+	var meta = &BlobMetadata{}
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:2]); err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := io.ReadFull(r, head[:2]); err != nil {
+			return nil, err
+		}
+		if head[0] != 0xFF {
+			return nil, ErrMetadataNotSupported
+		}
+		marker := head[1]
+		if marker == 0xFF {
+			// fill bytes
+			_ = r.UnreadByte()
+			continue
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8) {
+			// standalone markers without length
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			// end of image or start of scan before frame
+			return nil, ErrMetadataNotSupported
+		}
+		if _, err := io.ReadFull(r, head[2:4]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(head[2:4])) - 2
+		if length < 0 {
+			return nil, ErrMetadataNotSupported
+		}
+		switch {
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			// start of frame: precision, height, width, components
+			var sof [6]byte
+			if _, err := io.ReadFull(r, sof[:]); err != nil {
+				return nil, err
+			}
+			meta.BitDepth = int(sof[0])
+			meta.Height = int(binary.BigEndian.Uint16(sof[1:3]))
+			meta.Width = int(binary.BigEndian.Uint16(sof[3:5]))
+			meta.Bands = int(sof[5])
+			return meta, nil
+		case marker == 0xE1 && meta.Orientation == 0:
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			if len(buf) > 6 && string(buf[:6]) == "Exif\x00\x00" {
+				if exif, err := parseTIFFMetadata(bytes.NewReader(buf[6:])); err == nil {
+					meta.Orientation = exif.Orientation
+				}
+			}
+		default:
+			if _, err := r.Discard(length); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parseTIFFMetadata parses dimensions and orientation from TIFF IFD0
+func parseTIFFMetadata(r io.ReaderAt) (*BlobMetadata, error) {
+	var head [8]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch string(head[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, ErrMetadataNotSupported
+	}
+	offset := int64(order.Uint32(head[4:8]))
+	var count [2]byte
+	if _, err := r.ReadAt(count[:], offset); err != nil {
+		return nil, err
+	}
+	n := int(order.Uint16(count[:]))
+	entries := make([]byte, n*12)
+	if _, err := r.ReadAt(entries, offset+2); err != nil {
+		return nil, err
+	}
+	var meta = &BlobMetadata{}
+	for i := 0; i < n; i++ {
+		entry := entries[i*12 : i*12+12]
+		var value int
+		switch order.Uint16(entry[2:4]) {
+		case 3: // SHORT
+			value = int(order.Uint16(entry[8:10]))
+		case 4: // LONG
+			value = int(order.Uint32(entry[8:12]))
+		default:
+			continue
+		}
+		switch order.Uint16(entry[0:2]) {
+		case 0x0100:
+			meta.Width = value
+		case 0x0101:
+			meta.Height = value
+		case 0x0102:
+			meta.BitDepth = value
+			if order.Uint32(entry[4:8]) > 2 && order.Uint16(entry[2:4]) == 3 {
+				// bits per sample stored at offset
+				var bps [2]byte
+				if _, err := r.ReadAt(bps[:], int64(value)); err == nil {
+					meta.BitDepth = int(order.Uint16(bps[:]))
+				}
+			}
+		case 0x0112:
+			meta.Orientation = value
+		case 0x0115:
+			meta.Bands = value
+		}
+	}
+	return meta, nil
+}
+
+type readSeekerAt struct {
+	rs io.ReadSeeker
+}
+
+func (r *readSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.rs, p)
+}
+
 // Err returns Blob error
 func (b *Blob) Err() error {
 	b.init()
@@ -546,6 +1130,8 @@ func getExtension(typ BlobType) (ext string) {
 		ext = ".json"
 	case BlobTypeSVG:
 		ext = ".svg"
+	case BlobTypeICO:
+		ext = ".ico"
 	}
 	return
 }
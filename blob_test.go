@@ -2,14 +2,26 @@ package imagor
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 func doTestBlobReaders(t *testing.T, b *Blob, buf []byte) {
@@ -125,6 +137,20 @@ func TestBlobTypes(t *testing.T) {
 			extension:   ".bmp",
 			bytesType:   BlobTypeBMP,
 		},
+		{
+			name:        "bmp 8bit",
+			path:        "lena_gray.bmp",
+			contentType: "image/bmp",
+			extension:   ".bmp",
+			bytesType:   BlobTypeBMP,
+		},
+		{
+			name:        "ico",
+			path:        "favicon.ico",
+			contentType: "image/x-icon",
+			extension:   ".ico",
+			bytesType:   BlobTypeICO,
+		},
 		{
 			name:        "svg",
 			path:        "test.svg",
@@ -311,6 +337,29 @@ func TestBlobJsonBytes(t *testing.T) {
 	assert.Equal(t, ".json", getExtension(b.BlobType()))
 }
 
+func TestBlobSVGBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+	}{
+		{name: "minimal", svg: `<svg/>`},
+		{name: "leading whitespace", svg: "\n  <svg xmlns=\"http://www.w3.org/2000/svg\" width=\"1\" height=\"1\"></svg>"},
+		{name: "xml declaration", svg: `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`},
+		{name: "bom", svg: "\xEF\xBB\xBF<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"},
+		{name: "bom xml declaration", svg: "\xEF\xBB\xBF<?xml version=\"1.0\"?>\n<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBlobFromBytes([]byte(tt.svg))
+			assert.Equal(t, BlobTypeSVG, b.BlobType())
+			assert.Equal(t, "image/svg+xml", b.ContentType())
+			assert.Equal(t, ".svg", getExtension(b.BlobType()))
+		})
+	}
+	b := NewBlobFromBytes([]byte("\xEF\xBB\xBFfoo bar"))
+	assert.Equal(t, BlobTypeUnknown, b.BlobType())
+}
+
 type readerFunc func(p []byte) (n int, err error)
 
 func (rf readerFunc) Read(p []byte) (n int, err error) { return rf(p) }
@@ -351,3 +400,284 @@ func TestBlobReaderError(t *testing.T) {
 	assert.Equal(t, 500, len(buf))
 	assert.Equal(t, e, err)
 }
+
+func TestNewBlobFromFileContext(t *testing.T) {
+	b := NewBlobFromFileContext(context.Background(), "testdata/gopher.png")
+	require.NoError(t, b.Err())
+	assert.Equal(t, BlobTypePNG, b.BlobType())
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.NotEmpty(t, buf)
+
+	b = NewBlobFromFileContext(context.Background(), "testdata/non-exists.jpg")
+	assert.Equal(t, ErrNotFound, b.Err())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b = NewBlobFromFileContext(ctx, "testdata/gopher.png")
+	assert.ErrorIs(t, b.Err(), context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	b = NewBlobFromFileContext(ctx, "testdata/gopher.png")
+	assert.ErrorIs(t, b.Err(), context.DeadlineExceeded)
+}
+
+func TestNewBlobWithSpill(t *testing.T) {
+	buf, err := os.ReadFile("testdata/demo1.jpg")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = withContext(ctx)
+	var calls int
+	b := NewBlobWithSpill(ctx, func() (io.ReadCloser, int64, error) {
+		calls++
+		if calls > 1 {
+			return nil, 0, errors.New("one-shot source")
+		}
+		// unknown size
+		return io.NopCloser(bytes.NewReader(buf)), 0, nil
+	}, 1024)
+	assert.Equal(t, BlobTypeJPEG, b.BlobType())
+	for i := 0; i < 3; i++ {
+		b2, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, buf, b2)
+	}
+	rs, size, err := b.NewReadSeeker()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(buf)), size)
+	_, err = rs.Seek(10, io.SeekStart)
+	require.NoError(t, err)
+	b2, err := io.ReadAll(rs)
+	require.NoError(t, err)
+	assert.Equal(t, buf[10:], b2)
+	_ = rs.Close()
+	assert.Equal(t, 1, calls)
+
+	assert.False(t, b.fanout)
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, _, err := b.NewReader()
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond*10, "temp file should be removed on context done")
+
+	calls = 0
+	b = NewBlobWithSpill(context.Background(), func() (io.ReadCloser, int64, error) {
+		calls++
+		return io.NopCloser(bytes.NewReader(buf)), int64(len(buf)), nil
+	}, int64(len(buf))+1)
+	for i := 0; i < 3; i++ {
+		b2, err = b.Clone().ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, buf, b2)
+	}
+	assert.Equal(t, 1, calls, "within threshold should buffer in memory")
+	assert.Empty(t, b.FilePath())
+
+	b = NewBlobWithSpill(context.Background(), func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(buf)), 0, nil
+	}, 1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b2, err := b.Clone().ReadAll()
+			assert.NoError(t, err)
+			assert.Equal(t, buf, b2)
+		}()
+	}
+	wg.Wait()
+	b.Release()
+	_, _, err = b.NewReader()
+	assert.True(t, os.IsNotExist(err), "temp file should be removed on release")
+
+	b = NewBlobWithSpill(context.Background(), func() (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(buf)), 0, nil
+	}, 1024)
+	b.Release()
+	_, err = b.ReadAll()
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestBlobMetadata(t *testing.T) {
+	for _, tt := range []struct {
+		path        string
+		orientation int
+		bands       int
+	}{
+		{path: "demo1.jpg", orientation: 1, bands: 3},
+		{path: "Canon_40D.jpg", orientation: 1, bands: 3},
+		{path: "gopher.png", orientation: 1, bands: 4},
+		{path: "dancing-banana.gif", orientation: 1, bands: 4},
+		{path: "demo3.webp", orientation: 1, bands: 4},
+		{path: "gopher.tiff", orientation: 1, bands: 4},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			buf, err := os.ReadFile("testdata/" + tt.path)
+			require.NoError(t, err)
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(buf))
+			require.NoError(t, err)
+			meta, err := NewBlobFromFile("testdata/" + tt.path).Metadata()
+			require.NoError(t, err)
+			assert.Equal(t, cfg.Width, meta.Width)
+			assert.Equal(t, cfg.Height, meta.Height)
+			assert.Equal(t, tt.orientation, meta.Orientation)
+			assert.Equal(t, tt.bands, meta.Bands)
+			assert.Equal(t, 8, meta.BitDepth)
+			assert.Equal(t, int64(cfg.Width*cfg.Height*tt.bands), meta.DecompressedSize())
+			w, h, err := NewBlobFromBytes(buf).Dimensions()
+			require.NoError(t, err)
+			assert.Equal(t, cfg.Width, w)
+			assert.Equal(t, cfg.Height, h)
+		})
+	}
+	t.Run("jpeg exif orientation", func(t *testing.T) {
+		exif := []byte("Exif\x00\x00MM\x00\x2A\x00\x00\x00\x08\x00\x01" +
+			"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+		var buf []byte
+		buf = append(buf, 0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(exif)+2))
+		buf = append(buf, exif...)
+		buf = append(buf, 0xFF, 0xC0, 0, 17, 8, 0x01, 0x2C, 0x00, 0xC8, 3)
+		buf = append(buf, make([]byte, 600)...)
+		meta, err := NewBlobFromBytes(buf).Metadata()
+		require.NoError(t, err)
+		assert.Equal(t, &BlobMetadata{Width: 200, Height: 300, Orientation: 6, Bands: 3, BitDepth: 8}, meta)
+		assert.Equal(t, int64(180000), meta.DecompressedSize())
+		assert.True(t, meta.Swapped())
+	})
+	for _, path := range []string{"gopher-front.avif", "sample.pdf", "test.svg"} {
+		t.Run(path, func(t *testing.T) {
+			_, _, err := NewBlobFromFile("testdata/" + path).Dimensions()
+			assert.Equal(t, ErrMetadataNotSupported, err)
+		})
+	}
+	_, _, err := NewBlobFromFile("testdata/non-exists.jpg").Dimensions()
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBlobHash(t *testing.T) {
+	for _, path := range []string{"demo1.jpg", "gopher.png", "dancing-banana.gif"} {
+		t.Run(path, func(t *testing.T) {
+			b := NewBlobFromFile("testdata/" + path)
+			sum, err := b.SHA256()
+			require.NoError(t, err)
+			buf, err := b.ReadAll()
+			require.NoError(t, err)
+			expected := sha256.Sum256(buf)
+			assert.Equal(t, expected[:], sum)
+
+			sum, err = b.Hash(sha1.New)
+			require.NoError(t, err)
+			expected2 := sha1.Sum(buf)
+			assert.Equal(t, expected2[:], sum)
+
+			buf2, err := b.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, buf, buf2, "hash should not consume blob")
+		})
+	}
+	b := NewBlobFromBytes([]byte("foo"))
+	sum, err := b.SHA256()
+	require.NoError(t, err)
+	expected := sha256.Sum256([]byte("foo"))
+	assert.Equal(t, expected[:], sum)
+
+	b = NewBlobFromMemory([]byte{167, 169}, 2, 1, 1)
+	sum, err = b.SHA256()
+	require.NoError(t, err)
+	expected = sha256.Sum256([]byte{167, 169})
+	assert.Equal(t, expected[:], sum)
+
+	sum, err = NewBlobFromFile("testdata/non-exists.jpg").SHA256()
+	assert.Empty(t, sum)
+	assert.Equal(t, ErrNotFound, err)
+
+	e := errors.New("some error")
+	sum, err = NewBlob(func() (reader io.ReadCloser, size int64, err error) {
+		return nil, 0, e
+	}).SHA256()
+	assert.Empty(t, sum)
+	assert.Equal(t, e, err)
+}
+
+func TestBlobClone(t *testing.T) {
+	buf, err := os.ReadFile("testdata/demo1.jpg")
+	require.NoError(t, err)
+	readAll := func(t *testing.T, blobs ...*Blob) {
+		var wg sync.WaitGroup
+		for _, b := range blobs {
+			wg.Add(1)
+			go func(b *Blob) {
+				defer wg.Done()
+				out, err := b.ReadAll()
+				assert.NoError(t, err)
+				assert.Equal(t, buf, out)
+			}(b)
+		}
+		wg.Wait()
+	}
+	t.Run("fanout", func(t *testing.T) {
+		var called int32
+		b := NewBlob(func() (io.ReadCloser, int64, error) {
+			atomic.AddInt32(&called, 1)
+			return io.NopCloser(bytes.NewReader(buf)), int64(len(buf)), nil
+		})
+		b.SetHeader("Foo", "Bar")
+		b.Stat = &Stat{ETag: "abc"}
+		c1 := b.Clone()
+		c2 := c1.Clone()
+		readAll(t, b, c1, c2, b, c1)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&called), "shares fanout buffer")
+		assert.Equal(t, BlobTypeJPEG, c2.BlobType())
+		assert.Equal(t, "image/jpeg", c2.ContentType())
+		assert.Equal(t, int64(len(buf)), c2.Size())
+		assert.Equal(t, "Bar", c2.Header.Get("Foo"))
+		assert.Equal(t, "abc", c2.Stat.ETag)
+		c2.SetHeader("Foo", "Baz")
+		c2.Stat.ETag = "def"
+		assert.Equal(t, "Bar", b.Header.Get("Foo"))
+		assert.Equal(t, "abc", b.Stat.ETag)
+	})
+	t.Run("non fanout", func(t *testing.T) {
+		var called int32
+		b := NewBlob(func() (io.ReadCloser, int64, error) {
+			atomic.AddInt32(&called, 1)
+			// size unknown
+			return io.NopCloser(bytes.NewReader(buf)), 0, nil
+		})
+		c := b.Clone()
+		readAll(t, b, c)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&called), "re-invokes reader")
+		assert.Equal(t, BlobTypeJPEG, c.BlobType())
+	})
+	t.Run("file", func(t *testing.T) {
+		b := NewBlobFromFile("testdata/demo1.jpg")
+		c := b.Clone()
+		readAll(t, c, b)
+		assert.Equal(t, "testdata/demo1.jpg", c.FilePath())
+		rs, _, err := c.NewReadSeeker()
+		require.NoError(t, err)
+		_ = rs.Close()
+	})
+	t.Run("memory", func(t *testing.T) {
+		b := NewBlobFromMemory([]byte{167, 169}, 2, 1, 1)
+		c := b.Clone()
+		data, w, h, bands, ok := c.Memory()
+		assert.True(t, ok)
+		assert.Equal(t, []byte{167, 169}, data)
+		assert.Equal(t, [3]int{2, 1, 1}, [3]int{w, h, bands})
+		assert.Equal(t, BlobTypeMemory, c.BlobType())
+	})
+	t.Run("empty and error", func(t *testing.T) {
+		assert.True(t, NewEmptyBlob().Clone().IsEmpty())
+		e := errors.New("some error")
+		c := NewBlob(func() (io.ReadCloser, int64, error) {
+			return nil, 0, e
+		}).Clone()
+		assert.Equal(t, e, c.Err())
+		_, err := c.ReadAll()
+		assert.Equal(t, e, err)
+	})
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"github.com/cshum/imagor/config"
 	"github.com/cshum/imagor/config/awsconfig"
+	"github.com/cshum/imagor/config/azureconfig"
 	"github.com/cshum/imagor/config/gcloudconfig"
+	"github.com/cshum/imagor/config/redisconfig"
 	"github.com/cshum/imagor/config/vipsconfig"
 	"os"
 )
@@ -14,6 +16,8 @@ func main() {
 		vipsconfig.WithVips,
 		awsconfig.WithAWS,
 		gcloudconfig.WithGCloud,
+		azureconfig.WithAzure,
+		redisconfig.WithRedis,
 	)
 	if server != nil {
 		server.Run()
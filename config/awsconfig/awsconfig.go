@@ -15,11 +15,11 @@ import (
 func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 	var (
 		awsRegion = fs.String("aws-region", "",
-			"AWS Region. Required if using S3 Loader or storage")
+			"AWS Region. Fallback to AWS shared config or AWS_REGION env if not set")
 		awsAccessKeyID = fs.String("aws-access-key-id", "",
-			"AWS Access Key ID. Required if using S3 Loader or Storage")
+			"AWS Access Key ID. Fallback to default AWS credential chain e.g. env, shared config, IAM role if not set")
 		awsSecretAccessKey = fs.String("aws-secret-access-key", "",
-			"AWS Secret Access Key. Required if using S3 Loader or Storage")
+			"AWS Secret Access Key. Fallback to default AWS credential chain e.g. env, shared config, IAM role if not set")
 		awsSessionToken = fs.String("aws-session-token", "",
 			"AWS Session Token. Optional temporary credentials token")
 		s3Endpoint = fs.String("s3-endpoint", "",
@@ -60,6 +60,8 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 
 		s3ForcePathStyle = fs.Bool("s3-force-path-style", false,
 			"S3 force the request to use path-style addressing s3.amazonaws.com/bucket/key, instead of bucket.s3.amazonaws.com/key")
+		s3Anonymous = fs.Bool("s3-anonymous", false,
+			"S3 use anonymous credentials for unauthenticated access to public buckets")
 		s3SafeChars = fs.String("s3-safe-chars", "",
 			"S3 safe characters to be excluded from image key escape. Set -- for no-op")
 
@@ -80,6 +82,10 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for S3 Storage")
 		s3StorageExpiration = fs.Duration("s3-storage-expiration", 0,
 			"S3 Storage expiration duration e.g. 24h. Default no expiration")
+		s3StorageSSE = fs.String("s3-storage-sse", "",
+			"S3 Storage server-side encryption algorithm e.g. AES256, aws:kms. Default no encryption")
+		s3StorageSSEKMSKeyID = fs.String("s3-storage-sse-kms-key-id", "",
+			"S3 Storage server-side encryption KMS key ID, implies aws:kms if algorithm not set")
 
 		s3ResultStorageBucket = fs.String("s3-result-storage-bucket", "",
 			"S3 Bucket for S3 Result Storage. Enable S3 Result Storage only if this value present")
@@ -91,6 +97,12 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for S3 Result Storage")
 		s3ResultStorageExpiration = fs.Duration("s3-result-storage-expiration", 0,
 			"S3 Result Storage expiration duration e.g. 24h. Default no expiration")
+		s3ResultStorageSSE = fs.String("s3-result-storage-sse", "",
+			"S3 Result Storage server-side encryption algorithm e.g. AES256, aws:kms. Default no encryption")
+		s3ResultStorageSSEKMSKeyID = fs.String("s3-result-storage-sse-kms-key-id", "",
+			"S3 Result Storage server-side encryption KMS key ID, implies aws:kms if algorithm not set")
+		s3MultipartThreshold = fs.Int64("s3-multipart-threshold", 0,
+			"S3 Storage and Result Storage size in bytes below which uploads use single PutObject, otherwise multipart upload with the size as part size. Default always multipart upload manager")
 		s3StorageClass = fs.String("s3-storage-class", "STANDARD",
 			"S3 File Storage Class. Available values: REDUCED_REDUNDANCY, STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING, GLACIER, DEEP_ARCHIVE. Default: STANDARD.")
 
@@ -101,21 +113,23 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			return
 		}
 		var loaderSess, storageSess, resultStorageSess *session.Session
-		var cred = credentials.NewStaticCredentials(
-			*awsAccessKeyID, *awsSecretAccessKey, *awsSessionToken)
+		var cred *credentials.Credentials
+		if *s3Anonymous {
+			cred = credentials.AnonymousCredentials
+		} else if *awsAccessKeyID != "" && *awsSecretAccessKey != "" {
+			cred = credentials.NewStaticCredentials(
+				*awsAccessKeyID, *awsSecretAccessKey, *awsSessionToken)
+		}
+		// nil credentials fallback to default credential chain
+		// i.e. env, shared config, EC2 instance or ECS task role
 		var options = session.Options{
 			SharedConfigState: session.SharedConfigEnable,
-		}
-		if _, err := cred.Get(); err != nil {
-			cred = credentials.NewSharedCredentials("", "")
-		}
-		if _, err := cred.Get(); err == nil {
-			options.Config = aws.Config{
+			Config: aws.Config{
 				Endpoint:         s3Endpoint,
 				Region:           awsRegion,
 				Credentials:      cred,
 				S3ForcePathStyle: s3ForcePathStyle,
-			}
+			},
 		}
 		var sess = session.Must(session.NewSessionWithOptions(options))
 		loaderSess = sess
@@ -164,6 +178,9 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 					s3storage.WithSafeChars(*s3SafeChars),
 					s3storage.WithExpiration(*s3StorageExpiration),
 					s3storage.WithStorageClass(*s3StorageClass),
+					s3storage.WithSSE(*s3StorageSSE),
+					s3storage.WithSSEKMSKeyID(*s3StorageSSEKMSKeyID),
+					s3storage.WithMultipartThreshold(*s3MultipartThreshold),
 				),
 			)
 		}
@@ -187,6 +204,9 @@ func WithAWS(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 					s3storage.WithSafeChars(*s3SafeChars),
 					s3storage.WithExpiration(*s3ResultStorageExpiration),
 					s3storage.WithStorageClass(*s3StorageClass),
+					s3storage.WithSSE(*s3ResultStorageSSE),
+					s3storage.WithSSEKMSKeyID(*s3ResultStorageSSEKMSKeyID),
+					s3storage.WithMultipartThreshold(*s3MultipartThreshold),
 				),
 			)
 		}
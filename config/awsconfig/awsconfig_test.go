@@ -3,6 +3,9 @@ package awsconfig
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/config"
 	"github.com/cshum/imagor/storage/s3storage"
@@ -162,3 +165,54 @@ func TestS3StorageClass(t *testing.T) {
 	storage = app.Storages[0].(*s3storage.S3Storage)
 	assert.Equal(t, "REDUCED_REDUNDANCY", storage.StorageClass)
 }
+
+func TestS3DefaultCredentialChain(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-s3-endpoint", "asdfasdf",
+		"-s3-loader-bucket", "a",
+	}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	loader := app.Loaders[0].(*s3storage.S3Storage)
+	assert.Equal(t, "a", loader.Bucket)
+	assert.Equal(t, "asdf", aws.StringValue(loader.S3.Config.Region))
+	assert.Equal(t, "asdfasdf", aws.StringValue(loader.S3.Config.Endpoint))
+	assert.NotEqual(t, credentials.AnonymousCredentials, loader.S3.Config.Credentials)
+}
+
+func TestS3Anonymous(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-s3-anonymous",
+		"-s3-loader-bucket", "a",
+	}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	loader := app.Loaders[0].(*s3storage.S3Storage)
+	assert.Equal(t, "a", loader.Bucket)
+	assert.Equal(t, credentials.AnonymousCredentials, loader.S3.Config.Credentials)
+}
+
+func TestS3SSE(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-aws-region", "asdf",
+		"-aws-access-key-id", "asdf",
+		"-aws-secret-access-key", "asdf",
+
+		"-s3-storage-bucket", "a",
+		"-s3-storage-sse-kms-key-id", "my-key",
+
+		"-s3-result-storage-bucket", "b",
+		"-s3-result-storage-sse", "AES256",
+		"-s3-multipart-threshold", "10485760",
+	}, WithAWS)
+	app := srv.App.(*imagor.Imagor)
+	storage := app.Storages[0].(*s3storage.S3Storage)
+	assert.Empty(t, storage.SSE)
+	assert.Equal(t, "my-key", storage.SSEKMSKeyID)
+
+	resultStorage := app.ResultStorages[0].(*s3storage.S3Storage)
+	assert.Equal(t, "AES256", resultStorage.SSE)
+	assert.Empty(t, resultStorage.SSEKMSKeyID)
+	assert.Equal(t, int64(10485760), storage.MultipartThreshold)
+	assert.Equal(t, int64(10485760), resultStorage.MultipartThreshold)
+}
@@ -0,0 +1,88 @@
+package azureconfig
+
+import (
+	"flag"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/azurestorage"
+	"go.uber.org/zap"
+)
+
+// WithAzure with Azure Blob Storage Loader, Storage, Result Storage config option
+func WithAzure(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		azureStorageConnectionString = fs.String("azure-storage-connection-string", "",
+			"Azure Storage account connection string, also from AZURE_STORAGE_CONNECTION_STRING env")
+		azureSafeChars = fs.String("azure-safe-chars", "",
+			"Azure safe characters to be excluded from image key escape. Set -- for no-op")
+
+		azureLoaderContainer = fs.String("azure-loader-container", "",
+			"Container name for Azure Blob Storage Loader. Enable Azure Loader only if this value present")
+		azureLoaderBaseDir = fs.String("azure-loader-base-dir", "",
+			"Base directory for Azure Loader")
+		azureLoaderPathPrefix = fs.String("azure-loader-path-prefix", "",
+			"Base path prefix for Azure Loader")
+
+		azureStorageContainer = fs.String("azure-storage-container", "",
+			"Container name for Azure Blob Storage. Enable Azure Storage only if this value present")
+		azureStorageBaseDir = fs.String("azure-storage-base-dir", "",
+			"Base directory for Azure Storage")
+		azureStoragePathPrefix = fs.String("azure-storage-path-prefix", "",
+			"Base path prefix for Azure Storage")
+		azureStorageExpiration = fs.Duration("azure-storage-expiration", 0,
+			"Azure Storage expiration duration e.g. 24h, blobs older than the duration are deleted. Default no expiration")
+
+		azureResultStorageContainer = fs.String("azure-result-storage-container", "",
+			"Container name for Azure Blob Result Storage. Enable Azure Result Storage only if this value present")
+		azureResultStorageBaseDir = fs.String("azure-result-storage-base-dir", "",
+			"Base directory for Azure Result Storage")
+		azureResultStoragePathPrefix = fs.String("azure-result-storage-path-prefix", "",
+			"Base path prefix for Azure Result Storage")
+		azureResultStorageExpiration = fs.Duration("azure-result-storage-expiration", 0,
+			"Azure Result Storage expiration duration e.g. 24h, blobs older than the duration are deleted. Default no expiration")
+
+		_, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *azureStorageContainer != "" || *azureLoaderContainer != "" || *azureResultStorageContainer != "" {
+			// Activate the client, will panic if connection string is invalid
+			azureClient, err := azblob.NewClientFromConnectionString(*azureStorageConnectionString, nil)
+			if err != nil {
+				panic(err)
+			}
+			if *azureStorageContainer != "" {
+				// activate Azure Storage only if container config presents
+				app.Storages = append(app.Storages,
+					azurestorage.New(azureClient, *azureStorageContainer,
+						azurestorage.WithPathPrefix(*azureStoragePathPrefix),
+						azurestorage.WithBaseDir(*azureStorageBaseDir),
+						azurestorage.WithSafeChars(*azureSafeChars),
+						azurestorage.WithExpiration(*azureStorageExpiration),
+					),
+				)
+			}
+			if *azureLoaderContainer != "" {
+				// activate Azure Loader only if container config presents
+				app.Loaders = append(app.Loaders,
+					azurestorage.New(azureClient, *azureLoaderContainer,
+						azurestorage.WithPathPrefix(*azureLoaderPathPrefix),
+						azurestorage.WithBaseDir(*azureLoaderBaseDir),
+						azurestorage.WithSafeChars(*azureSafeChars),
+					),
+				)
+			}
+			if *azureResultStorageContainer != "" {
+				// activate Azure Result Storage only if container config presents
+				app.ResultStorages = append(app.ResultStorages,
+					azurestorage.New(azureClient, *azureResultStorageContainer,
+						azurestorage.WithPathPrefix(*azureResultStoragePathPrefix),
+						azurestorage.WithBaseDir(*azureResultStorageBaseDir),
+						azurestorage.WithSafeChars(*azureSafeChars),
+						azurestorage.WithExpiration(*azureResultStorageExpiration),
+					),
+				)
+			}
+		}
+	}
+}
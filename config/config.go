@@ -4,12 +4,14 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/TheZeroSlave/zapsentry"
 	"github.com/getsentry/sentry-go"
 	"go.uber.org/zap/zapcore"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 
 var baseConfig = []Option{
 	withFileSystem,
+	withDataLoader,
 	withHTTPLoader,
 }
 
@@ -33,13 +36,21 @@ func NewImagor(
 ) *imagor.Imagor {
 	var (
 		imagorSecret = fs.String("imagor-secret", "",
-			"Secret key for signing imagor URL")
+			"Secret key for signing imagor URL. Accepts csv of secrets for key rotation, signs with the first and verifies against any")
 		imagorUnsafe = fs.Bool("imagor-unsafe", false,
 			"Unsafe imagor that does not require URL signature. Prone to URL tampering")
+		imagorSigningMode = fs.String("imagor-signing-mode", "enforce",
+			"URL signature verification mode: enforce, or permissive that allows requests without signature during migration from unsigned URLs")
 		imagorAutoWebP = fs.Bool("imagor-auto-webp", false,
 			"Output WebP format automatically if browser supports")
 		imagorAutoAVIF = fs.Bool("imagor-auto-avif", false,
 			"Output AVIF format automatically if browser supports (experimental)")
+		imagorAutoFormats = fs.String("imagor-auto-formats", "",
+			"Output format automatically by browser Accept header quality values, from priority list of formats separated by comma e.g. avif,webp,jpeg. Overrides imagor-auto-webp and imagor-auto-avif")
+		imagorSaveDataMode = fs.Bool("imagor-save-data-mode", false,
+			"Output lower quality images automatically if browser sends Save-Data: on client hint")
+		imagorSaveDataQuality = fs.Int("imagor-save-data-quality", 50,
+			"Image quality for imagor-save-data-mode, unless quality filter specified")
 		imagorRequestTimeout = fs.Duration("imagor-request-timeout",
 			time.Second*30, "Timeout for performing imagor request")
 		imagorLoadTimeout = fs.Duration("imagor-load-timeout",
@@ -48,14 +59,24 @@ func NewImagor(
 			0, "Timeout for saving image to imagor Storage")
 		imagorProcessTimeout = fs.Duration("imagor-process-timeout",
 			0, "Timeout for image processing")
+		imagorMaxProcessTimeout = fs.Duration("imagor-max-process-timeout",
+			0, "Maximum timeout for image processing overridden per request by signed timeout(seconds) filter. Disabled if not set")
 		imagorBasePathRedirect = fs.String("imagor-base-path-redirect", "",
 			"URL to redirect for imagor / base path e.g. https://www.google.com")
+		imagorBasePathHandler = fs.String("imagor-base-path-handler", "",
+			"imagor / base path response mode: status, redirect, json, nocontent, notfound. Default status page, or redirect if imagor-base-path-redirect is set")
 		imagorBaseParams = fs.String("imagor-base-params", "",
 			"imagor endpoint base params that applies to all resulting images e.g. filters:watermark(example.jpg)")
+		imagorDefaultGravity = fs.String("imagor-default-gravity", "",
+			"Default crop gravity when not specified by request: top, bottom, left, right or combination e.g. top-left")
 		imagorProcessConcurrency = fs.Int64("imagor-process-concurrency",
 			-1, "Maximum number of image process to be executed simultaneously. Requests that exceed this limit are put in the queue. Set -1 for no limit")
 		imagorProcessQueueSize = fs.Int64("imagor-process-queue-size",
 			0, "Maximum number of image process that can be put in the queue. Requests that exceed this limit are rejected with HTTP status 429")
+		imagorProcessPriorityAging = fs.Duration("imagor-process-priority-aging",
+			0, "Enable priority aware process queue where signed priority(n) filter requests acquire process slots ahead, each priority level is worth this duration of waiting so that lower priority requests still progress. Disabled if not set")
+		imagorLoadConcurrencyPerHost = fs.Int64("imagor-load-concurrency-per-host",
+			0, "Maximum number of requests loading from the same source host simultaneously, in addition to imagor-process-concurrency. Set 0 for no limit")
 		imagorCacheHeaderTTL = fs.Duration("imagor-cache-header-ttl",
 			time.Hour*24*7, "imagor HTTP Cache-Control header TTL for successful image response")
 		imagorCacheHeaderSWR = fs.Duration("imagor-cache-header-swr",
@@ -65,15 +86,28 @@ func NewImagor(
 		imagorModifiedTimeCheck = fs.Bool("imagor-modified-time-check", false,
 			"Check modified time of result image against the source image. This eliminates stale result but require more lookups")
 		imagorDisableErrorBody       = fs.Bool("imagor-disable-error-body", false, "imagor disable response body on error")
+		imagorVerboseErrors          = fs.Bool("imagor-verbose-errors", false, "imagor include diagnostic detail e.g. decoder message in error response body. Not recommended for production")
 		imagorDisableParamsEndpoint  = fs.Bool("imagor-disable-params-endpoint", false, "imagor disable /params endpoint")
+		imagorEnableTarEndpoint      = fs.Bool("imagor-enable-tar-endpoint", false, "imagor enable POST /tar endpoint for streaming tar archive of rendered images")
+		imagorEnableBatchEndpoint    = fs.Bool("imagor-enable-batch-endpoint", false, "imagor enable POST /batch endpoint for processing JSON array of imagor paths into result storages e.g. for prewarming")
+		imagorEnableStatEndpoint     = fs.Bool("imagor-enable-stat-endpoint", false, "imagor enable HEAD /stat endpoint for checking result storage existence without processing")
+		imagorAllowedSizes           = fs.String("imagor-allowed-sizes", "", "imagor allowed output dimensions presets separated by comma e.g. 100x100,300x200,0x400. Other dimensions are rejected")
+		imagorAllowedTypes           = fs.String("imagor-allowed-types", "", "imagor allowed source image types sniffed from bytes separated by comma e.g. jpeg,png,webp. Other types are rejected regardless of origin content type")
+		imagorAllowedSizesSnap       = fs.Bool("imagor-allowed-sizes-snap", false, "imagor snap non-allowed output dimensions to the nearest allowed size instead of rejecting")
+		imagorMaxResolution          = fs.Float64("imagor-max-resolution", 0, "imagor maximum resolution in megapixels of source image based on header declared dimensions and target output dimensions. Set 0 for no limit")
+		imagorMaxDecompressedBytes   = fs.Int64("imagor-max-decompressed-bytes", 0, "imagor maximum decompressed size in bytes of source image based on header declared dimensions, bands and bit depth. Set 0 for no limit")
 		imagorSignerType             = fs.String("imagor-signer-type", "sha1", "imagor URL signature hasher type: sha1, sha256, sha512")
 		imagorSignerTruncate         = fs.Int("imagor-signer-truncate", 0, "imagor URL signature truncate at length")
+		imagorPathSecrets            = fs.String("imagor-path-secrets", "", "imagor URL signature secrets scoped to image path prefix by leading path segment, in csv of prefix:secret e.g. tenant-a:secret1,tenant-b:secret2")
+		imagorPathSecretsStrict      = fs.Bool("imagor-path-secrets-strict", false, "imagor rejects image path of prefix not in imagor-path-secrets instead of falling back to imagor-secret")
+		imagorSignerAccept           = fs.String("imagor-signer-accept", "", "imagor URL signature hasher types accepted in addition by prefix marker, in csv e.g. sha256 accepts s256:<signature>. Unprefixed signature uses imagor-signer-type")
 		imagorStoragePathStyle       = fs.String("imagor-storage-path-style", "original", "imagor storage path style: original, digest")
 		imagorResultStoragePathStyle = fs.String("imagor-result-storage-path-style", "original", "imagor result storage path style: original, digest, suffix")
 
 		options, logger, isDebug = applyOptions(fs, cb, append(funcs, baseConfig...)...)
 
 		alg          = sha1.New
+		signer       imagorpath.Signer
 		hasher       imagorpath.StorageHasher
 		resultHasher imagorpath.ResultStorageHasher
 	)
@@ -84,6 +118,50 @@ func NewImagor(
 		alg = sha512.New
 	}
 
+	switch strings.ToLower(strings.TrimSpace(*imagorBasePathHandler)) {
+	case "", imagor.BasePathModeStatus, imagor.BasePathModeRedirect, imagor.BasePathModeJSON,
+		imagor.BasePathModeNoContent, imagor.BasePathModeNotFound:
+	default:
+		panic(fmt.Errorf("invalid imagor-base-path-handler: %s", *imagorBasePathHandler))
+	}
+
+	secrets := []string{*imagorSecret}
+	if *imagorSecret != "" {
+		// skip empty entries, which would accept URLs signed with an empty key
+		secrets = nil
+		for _, secret := range strings.Split(*imagorSecret, ",") {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				secrets = append(secrets, secret)
+			}
+		}
+		if len(secrets) == 0 {
+			panic(errors.New("invalid imagor-secret: no non-empty secret"))
+		}
+	}
+	signer = imagorpath.NewMultiHMACSigner(alg, *imagorSignerTruncate, secrets...)
+	if *imagorSignerAccept != "" {
+		signers := map[string]imagorpath.Signer{"": signer}
+		for _, typ := range strings.Split(*imagorSignerAccept, ",") {
+			switch strings.ToLower(strings.TrimSpace(typ)) {
+			case "sha1":
+				signers["s1"] = imagorpath.NewMultiHMACSigner(sha1.New, *imagorSignerTruncate, secrets...)
+			case "sha256":
+				signers["s256"] = imagorpath.NewMultiHMACSigner(sha256.New, *imagorSignerTruncate, secrets...)
+			case "sha512":
+				signers["s512"] = imagorpath.NewMultiHMACSigner(sha512.New, *imagorSignerTruncate, secrets...)
+			}
+		}
+		signer = imagorpath.NewPrefixSigner("", signers)
+	}
+
+	var pathSigners []imagor.Option
+	for _, pair := range strings.Split(*imagorPathSecrets, ",") {
+		if prefix, secret, ok := strings.Cut(strings.TrimSpace(pair), ":"); ok && secret != "" {
+			pathSigners = append(pathSigners, imagor.WithPathSigner(
+				prefix, imagorpath.NewHMACSigner(alg, *imagorSignerTruncate, secret)))
+		}
+	}
+
 	if strings.ToLower(*imagorStoragePathStyle) == "digest" {
 		hasher = imagorpath.DigestStorageHasher
 	}
@@ -98,33 +176,67 @@ func NewImagor(
 
 	return imagor.New(append(
 		options,
-		imagor.WithSigner(imagorpath.NewHMACSigner(
-			alg, *imagorSignerTruncate, *imagorSecret,
-		)),
+		imagor.WithSigner(signer),
+		imagor.WithOptions(pathSigners...),
+		imagor.WithPathSignersStrict(*imagorPathSecretsStrict),
 		imagor.WithBasePathRedirect(*imagorBasePathRedirect),
+		imagor.WithBasePathHandler(*imagorBasePathHandler),
 		imagor.WithBaseParams(*imagorBaseParams),
+		imagor.WithDefaultGravity(*imagorDefaultGravity),
 		imagor.WithRequestTimeout(*imagorRequestTimeout),
 		imagor.WithLoadTimeout(*imagorLoadTimeout),
 		imagor.WithSaveTimeout(*imagorSaveTimeout),
 		imagor.WithProcessTimeout(*imagorProcessTimeout),
+		imagor.WithMaxProcessTimeout(*imagorMaxProcessTimeout),
 		imagor.WithProcessConcurrency(*imagorProcessConcurrency),
+		imagor.WithProcessPriority(*imagorProcessPriorityAging),
+		imagor.WithLoadConcurrencyPerHost(*imagorLoadConcurrencyPerHost),
 		imagor.WithProcessQueueSize(*imagorProcessQueueSize),
 		imagor.WithCacheHeaderTTL(*imagorCacheHeaderTTL),
 		imagor.WithCacheHeaderSWR(*imagorCacheHeaderSWR),
 		imagor.WithCacheHeaderNoCache(*imagorCacheHeaderNoCache),
 		imagor.WithAutoWebP(*imagorAutoWebP),
 		imagor.WithAutoAVIF(*imagorAutoAVIF),
+		imagor.WithAutoFormats(strings.Split(*imagorAutoFormats, ",")...),
+		imagor.WithSaveDataMode(*imagorSaveDataMode),
+		imagor.WithSaveDataQuality(*imagorSaveDataQuality),
 		imagor.WithModifiedTimeCheck(*imagorModifiedTimeCheck),
 		imagor.WithDisableErrorBody(*imagorDisableErrorBody),
+		imagor.WithVerboseErrors(*imagorVerboseErrors),
 		imagor.WithDisableParamsEndpoint(*imagorDisableParamsEndpoint),
+		imagor.WithTarEndpoint(*imagorEnableTarEndpoint),
+		imagor.WithStatEndpoint(*imagorEnableStatEndpoint),
+		imagor.WithBatchEndpoint(*imagorEnableBatchEndpoint),
+		imagor.WithAllowedSizes(parseAllowedSizes(*imagorAllowedSizes)...),
+		imagor.WithAllowedTypes(strings.Split(*imagorAllowedTypes, ",")...),
+		imagor.WithAllowedSizesSnap(*imagorAllowedSizesSnap),
+		imagor.WithMaxDecompressedBytes(*imagorMaxDecompressedBytes),
+		imagor.WithMaxResolution(*imagorMaxResolution),
 		imagor.WithStoragePathStyle(hasher),
 		imagor.WithResultStoragePathStyle(resultHasher),
 		imagor.WithUnsafe(*imagorUnsafe),
+		imagor.WithSigningMode(imagor.SigningMode(*imagorSigningMode)),
 		imagor.WithLogger(logger),
 		imagor.WithDebug(isDebug),
 	)...)
 }
 
+func parseAllowedSizes(str string) (sizes []imagor.AllowedSize) {
+	for _, s := range strings.Split(str, ",") {
+		w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+		if !ok {
+			continue
+		}
+		width, err1 := strconv.Atoi(w)
+		height, err2 := strconv.Atoi(h)
+		if err1 != nil || err2 != nil || width < 0 || height < 0 {
+			continue
+		}
+		sizes = append(sizes, imagor.AllowedSize{Width: width, Height: height})
+	}
+	return
+}
+
 // CreateServer create server from config flags. Returns nil on version or help command
 func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 	var (
@@ -132,6 +244,7 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		logger *zap.Logger
 		err    error
 		app    *imagor.Imagor
+		pm     *prometheusmetrics.PrometheusMetrics
 
 		debug        = fs.Bool("debug", false, "Debug mode")
 		version      = fs.Bool("version", false, "imagor version")
@@ -153,6 +266,10 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 			"Enable strip query string redirection")
 		serverAccessLog = fs.Bool("server-access-log", false,
 			"Enable server access log")
+		serverCompression = fs.Bool("server-compression", false,
+			"Enable gzip/deflate response compression for text based content types e.g. json, svg, negotiated by Accept-Encoding")
+		serverShutdownTimeout = fs.Duration("server-shutdown-timeout", time.Second*10,
+			"Server graceful shutdown timeout for draining in-flight requests before forced close")
 		sentryDsn = fs.String("sentry-dsn", "",
 			"Sentry DSN config")
 
@@ -198,7 +315,21 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		}
 
 		return logger, *debug
-	}, funcs...)
+	}, append(funcs, func(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+		logger, _ := cb()
+		if *prometheusBind == "" {
+			return imagor.WithOptions()
+		}
+		pm = prometheusmetrics.New(
+			prometheusmetrics.WithAddr(*prometheusBind),
+			prometheusmetrics.WithPath(*prometheusPath),
+			prometheusmetrics.WithLogger(logger),
+		)
+		return imagor.WithOptions(
+			imagor.WithConversionObserver(pm),
+			imagor.WithUnsignedRequestObserver(pm),
+		)
+	})...)
 
 	if *version {
 		fmt.Println(imagor.Version)
@@ -210,15 +341,6 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		runtime.GOMAXPROCS(*goMaxProcess)
 	}
 
-	var pm *prometheusmetrics.PrometheusMetrics
-	if *prometheusBind != "" {
-		pm = prometheusmetrics.New(
-			prometheusmetrics.WithAddr(*prometheusBind),
-			prometheusmetrics.WithPath(*prometheusPath),
-			prometheusmetrics.WithLogger(logger),
-		)
-	}
-
 	return server.New(app,
 		server.WithAddr(*bind),
 		server.WithPort(*port),
@@ -227,6 +349,8 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		server.WithCORS(*serverCORS),
 		server.WithStripQueryString(*serverStripQueryString),
 		server.WithAccessLog(*serverAccessLog),
+		server.WithCompression(*serverCompression),
+		server.WithShutdownTimeout(*serverShutdownTimeout),
 		server.WithLogger(logger),
 		server.WithDebug(*debug),
 		server.WithMetrics(pm),
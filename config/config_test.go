@@ -1,12 +1,18 @@
 package config
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagor/loader/dataloader"
 	"github.com/cshum/imagor/loader/httploader"
+	"github.com/cshum/imagor/loader/ziploader"
 	"github.com/cshum/imagor/metrics/prometheusmetrics"
 	"github.com/cshum/imagor/storage/filestorage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 	"net/http"
 	"testing"
 	"time"
@@ -15,6 +21,7 @@ import (
 func TestDefault(t *testing.T) {
 	srv := CreateServer(nil)
 	assert.Equal(t, ":8000", srv.Addr)
+	assert.Equal(t, time.Second*10, srv.ShutdownTimeout)
 	app := srv.App.(*imagor.Imagor)
 
 	assert.False(t, app.Debug)
@@ -23,14 +30,32 @@ func TestDefault(t *testing.T) {
 	assert.Equal(t, time.Second*20, app.LoadTimeout)
 	assert.Equal(t, time.Second*20, app.SaveTimeout)
 	assert.Equal(t, time.Second*20, app.ProcessTimeout)
+	assert.Empty(t, app.MaxProcessTimeout)
 	assert.Empty(t, app.BasePathRedirect)
+	assert.Empty(t, app.BasePathHandler)
 	assert.Empty(t, app.ProcessConcurrency)
+	assert.Empty(t, app.LoadConcurrencyPerHost)
+	assert.Empty(t, app.ProcessPriorityAging)
 	assert.Empty(t, app.BaseParams)
+	assert.Empty(t, app.DefaultGravity)
 	assert.False(t, app.ModifiedTimeCheck)
 	assert.False(t, app.AutoWebP)
 	assert.False(t, app.AutoAVIF)
+	assert.Empty(t, app.AutoFormats)
+	assert.False(t, app.SaveDataMode)
+	assert.Equal(t, 50, app.SaveDataQuality)
 	assert.False(t, app.DisableErrorBody)
+	assert.False(t, app.VerboseErrors)
+	assert.Equal(t, imagor.SigningModeEnforce, app.SigningMode)
 	assert.False(t, app.DisableParamsEndpoint)
+	assert.False(t, app.EnableTarEndpoint)
+	assert.False(t, app.EnableStatEndpoint)
+	assert.False(t, app.EnableBatchEndpoint)
+	assert.Empty(t, app.AllowedSizes)
+	assert.False(t, app.AllowedSizesSnap)
+	assert.Empty(t, app.MaxDecompressedBytes)
+	assert.Empty(t, app.AllowedTypes)
+	assert.Empty(t, app.MaxResolution)
 	assert.Equal(t, time.Hour*24*7, app.CacheHeaderTTL)
 	assert.Equal(t, time.Hour*24, app.CacheHeaderSWR)
 	assert.Empty(t, app.ResultStorages)
@@ -38,48 +63,97 @@ func TestDefault(t *testing.T) {
 	loader := app.Loaders[0].(*httploader.HTTPLoader)
 	assert.Empty(t, loader.BaseURL)
 	assert.Equal(t, "https", loader.DefaultScheme)
+	assert.False(t, loader.RespectOriginCacheControl)
+	assert.Equal(t, 0, loader.MaxRetries)
+	assert.Equal(t, time.Millisecond*100, loader.RetryBackoff)
+	assert.Zero(t, loader.RateLimit)
 }
 
 func TestBasic(t *testing.T) {
 	srv := CreateServer([]string{
 		"-debug",
 		"-port", "2345",
+		"-server-shutdown-timeout", "45s",
 		"-imagor-secret", "foo",
 		"-imagor-unsafe",
+		"-imagor-signing-mode", "permissive",
 		"-imagor-auto-webp",
 		"-imagor-auto-avif",
+		"-imagor-auto-formats", "avif, WEBP,jpg",
+		"-imagor-save-data-mode",
+		"-imagor-save-data-quality", "40",
 		"-imagor-disable-error-body",
+		"-imagor-verbose-errors",
 		"-imagor-disable-params-endpoint",
+		"-imagor-enable-tar-endpoint",
+		"-imagor-enable-stat-endpoint",
+		"-imagor-enable-batch-endpoint",
+		"-imagor-allowed-sizes", "100x100, 300X200,invalid,0x400",
+		"-imagor-allowed-sizes-snap",
+		"-imagor-allowed-types", "jpg, PNG,webp",
+		"-imagor-max-decompressed-bytes", "100000000",
+		"-imagor-max-resolution", "12.5",
 		"-imagor-request-timeout", "16s",
 		"-imagor-load-timeout", "7s",
 		"-imagor-process-timeout", "19s",
+		"-imagor-max-process-timeout", "2m",
 		"-imagor-process-concurrency", "199",
+		"-imagor-load-concurrency-per-host", "9",
+		"-imagor-process-priority-aging", "2s",
 		"-imagor-process-queue-size", "1999",
 		"-imagor-base-path-redirect", "https://www.google.com",
 		"-imagor-base-params", "filters:watermark(example.jpg)",
+		"-imagor-default-gravity", "top",
 		"-imagor-cache-header-ttl", "169h",
 		"-imagor-cache-header-swr", "167h",
 		"-http-loader-insecure-skip-verify-transport",
 		"-http-loader-override-response-headers", "cache-control,content-type",
 		"-http-loader-base-url", "https://www.example.com/foo.org",
+		"-http-loader-respect-origin-cache-control",
+		"-http-loader-origin-cache-min-ttl", "1m",
+		"-http-loader-origin-cache-max-ttl", "24h",
+		"-http-loader-max-retries", "3",
+		"-http-loader-retry-backoff", "50ms",
+		"-http-loader-rate-limit", "5.5",
+		"-http-loader-rate-limit-burst", "3",
 	})
 	app := srv.App.(*imagor.Imagor)
 
 	assert.Equal(t, 2345, srv.Port)
 	assert.Equal(t, ":2345", srv.Addr)
+	assert.Equal(t, time.Second*45, srv.ShutdownTimeout)
 	assert.True(t, app.Debug)
 	assert.True(t, app.Unsafe)
 	assert.True(t, app.AutoWebP)
+	assert.Equal(t, []string{"avif", "webp", "jpeg"}, app.AutoFormats)
+	assert.True(t, app.SaveDataMode)
+	assert.Equal(t, 40, app.SaveDataQuality)
 	assert.True(t, app.DisableErrorBody)
+	assert.True(t, app.VerboseErrors)
+	assert.Equal(t, imagor.SigningModePermissive, app.SigningMode)
 	assert.True(t, app.DisableParamsEndpoint)
+	assert.True(t, app.EnableTarEndpoint)
+	assert.True(t, app.EnableBatchEndpoint)
+	assert.True(t, app.EnableStatEndpoint)
+	assert.Equal(t, []imagor.AllowedSize{
+		{Width: 100, Height: 100}, {Width: 300, Height: 200}, {Width: 0, Height: 400},
+	}, app.AllowedSizes)
+	assert.True(t, app.AllowedSizesSnap)
+	assert.Equal(t, int64(100000000), app.MaxDecompressedBytes)
+	assert.Equal(t, 12.5, app.MaxResolution)
+	assert.Equal(t, []imagor.BlobType{imagor.BlobTypeJPEG, imagor.BlobTypePNG, imagor.BlobTypeWEBP}, app.AllowedTypes)
 	assert.Equal(t, "RrTsWGEXFU2s1J1mTl1j_ciO-1E=", app.Signer.Sign("bar"))
 	assert.Equal(t, time.Second*16, app.RequestTimeout)
 	assert.Equal(t, time.Second*7, app.LoadTimeout)
 	assert.Equal(t, time.Second*19, app.ProcessTimeout)
+	assert.Equal(t, time.Minute*2, app.MaxProcessTimeout)
 	assert.Equal(t, int64(199), app.ProcessConcurrency)
+	assert.Equal(t, int64(9), app.LoadConcurrencyPerHost)
+	assert.Equal(t, time.Second*2, app.ProcessPriorityAging)
 	assert.Equal(t, int64(1999), app.ProcessQueueSize)
 	assert.Equal(t, "https://www.google.com", app.BasePathRedirect)
 	assert.Equal(t, "filters:watermark(example.jpg)/", app.BaseParams)
+	assert.Equal(t, "top", app.DefaultGravity)
 	assert.Equal(t, time.Hour*169, app.CacheHeaderTTL)
 	assert.Equal(t, time.Hour*167, app.CacheHeaderSWR)
 
@@ -87,6 +161,13 @@ func TestBasic(t *testing.T) {
 	assert.True(t, httpLoader.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
 	assert.Equal(t, "https://www.example.com/foo.org", httpLoader.BaseURL.String())
 	assert.Equal(t, []string{"cache-control", "content-type"}, httpLoader.OverrideResponseHeaders)
+	assert.True(t, httpLoader.RespectOriginCacheControl)
+	assert.Equal(t, time.Minute, httpLoader.OriginCacheMinTTL)
+	assert.Equal(t, time.Hour*24, httpLoader.OriginCacheMaxTTL)
+	assert.Equal(t, 3, httpLoader.MaxRetries)
+	assert.Equal(t, time.Millisecond*50, httpLoader.RetryBackoff)
+	assert.Equal(t, rate.Limit(5.5), httpLoader.RateLimit)
+	assert.Equal(t, 3, httpLoader.RateLimitBurst)
 }
 
 func TestVersion(t *testing.T) {
@@ -102,6 +183,21 @@ func TestBind(t *testing.T) {
 	assert.Equal(t, ":4567", srv.Addr)
 }
 
+func TestAllowedTypesInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		CreateServer([]string{"-imagor-allowed-types", "jpeg,jepg"})
+	})
+}
+
+func TestBasePathHandler(t *testing.T) {
+	srv := CreateServer([]string{"-imagor-base-path-handler", "JSON"})
+	assert.Equal(t, imagor.BasePathModeJSON, srv.App.(*imagor.Imagor).BasePathHandler)
+
+	assert.Panics(t, func() {
+		CreateServer([]string{"-imagor-base-path-handler", "jsonn"})
+	})
+}
+
 func TestSentry(t *testing.T) {
 	srv := CreateServer([]string{
 		"-sentry-dsn", "https://12345@sentry.com/123",
@@ -124,6 +220,61 @@ func TestSignerAlgorithm(t *testing.T) {
 	assert.Equal(t, "Kmml5ejnmsn7M7TszYkeM2j5G3bpI7mp", app.Signer.Sign("bar"))
 }
 
+func TestSignerAccept(t *testing.T) {
+	srv := CreateServer([]string{
+		"-imagor-secret", "1234",
+		"-imagor-signer-accept", "sha256,sha512",
+	})
+	app := srv.App.(*imagor.Imagor)
+	verifier, ok := app.Signer.(imagorpath.Verifier)
+	require.True(t, ok)
+	assert.Equal(t, imagorpath.NewDefaultSigner("1234").Sign("bar"), app.Signer.Sign("bar"))
+	assert.True(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("1234").Sign("bar")))
+	assert.True(t, verifier.Verify("bar", "s256:"+imagorpath.NewHMACSigner(sha256.New, 0, "1234").Sign("bar")))
+	assert.True(t, verifier.Verify("bar", "s512:"+imagorpath.NewHMACSigner(sha512.New, 0, "1234").Sign("bar")))
+	assert.False(t, verifier.Verify("bar", "s1:"+imagorpath.NewDefaultSigner("1234").Sign("bar")))
+}
+
+func TestSecretRotation(t *testing.T) {
+	srv := CreateServer([]string{
+		"-imagor-secret", "new,old",
+	})
+	app := srv.App.(*imagor.Imagor)
+	verifier, ok := app.Signer.(imagorpath.Verifier)
+	require.True(t, ok)
+	assert.Equal(t, imagorpath.NewDefaultSigner("new").Sign("bar"), app.Signer.Sign("bar"))
+	assert.True(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("new").Sign("bar")))
+	assert.True(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("old").Sign("bar")))
+	assert.False(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("foo").Sign("bar")))
+
+	srv = CreateServer([]string{
+		"-imagor-secret", " new, ,old,",
+	})
+	app = srv.App.(*imagor.Imagor)
+	verifier = app.Signer.(imagorpath.Verifier)
+	assert.Equal(t, imagorpath.NewDefaultSigner("new").Sign("bar"), app.Signer.Sign("bar"))
+	assert.True(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("old").Sign("bar")))
+	assert.False(t, verifier.Verify("bar", imagorpath.NewDefaultSigner("").Sign("bar")),
+		"should not accept empty secret")
+
+	assert.Panics(t, func() {
+		CreateServer([]string{"-imagor-secret", " , "})
+	})
+}
+
+func TestPathSecrets(t *testing.T) {
+	srv := CreateServer([]string{
+		"-imagor-secret", "1234",
+		"-imagor-path-secrets", "tenant-a:secret-a, tenant-b:secret-b,invalid,tenant-c:",
+		"-imagor-path-secrets-strict",
+	})
+	app := srv.App.(*imagor.Imagor)
+	assert.True(t, app.PathSignersStrict)
+	require.Len(t, app.PathSigners, 2)
+	assert.Equal(t, imagorpath.NewDefaultSigner("secret-a").Sign("bar"), app.PathSigners["tenant-a"].Sign("bar"))
+	assert.Equal(t, imagorpath.NewDefaultSigner("secret-b").Sign("bar"), app.PathSigners["tenant-b"].Sign("bar"))
+}
+
 func TestCacheHeaderNoCache(t *testing.T) {
 	srv := CreateServer([]string{"-imagor-cache-header-no-cache"})
 	app := srv.App.(*imagor.Imagor)
@@ -142,12 +293,51 @@ func TestFileLoader(t *testing.T) {
 
 		"-file-loader-base-dir", "./foo",
 		"-file-loader-path-prefix", "abcd",
+		"-file-loader-allowed-patterns", "images/*.jpg, /images/*/*.png",
 	})
 	app := srv.App.(*imagor.Imagor)
 	fileLoader := app.Loaders[0].(*filestorage.FileStorage)
 	assert.Equal(t, "./foo", fileLoader.BaseDir)
 	assert.Equal(t, "/abcd/", fileLoader.PathPrefix)
 	assert.Equal(t, "!", fileLoader.SafeChars)
+	assert.Equal(t, []string{"images/*.jpg", "images/*/*.png"}, fileLoader.AllowedPatterns)
+}
+
+func TestZipLoader(t *testing.T) {
+	srv := CreateServer([]string{
+		"-zip-loader-base-dir", "./foo",
+		"-zip-loader-max-open-archives", "3",
+		"-file-loader-base-dir", "./bar",
+	})
+	app := srv.App.(*imagor.Imagor)
+	zipLoader := app.Loaders[0].(*ziploader.ZipLoader)
+	assert.Equal(t, "./foo", zipLoader.BaseDir)
+	assert.Equal(t, 3, zipLoader.MaxOpenArchives)
+	assert.IsType(t, &filestorage.FileStorage{}, app.Loaders[1])
+}
+
+func TestDataLoader(t *testing.T) {
+	srv := CreateServer([]string{
+		"-data-loader-enable",
+		"-data-loader-max-allowed-size", "1000",
+	})
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, 2, len(app.Loaders))
+	dataLoader := app.Loaders[0].(*dataloader.DataLoader)
+	assert.Equal(t, 1000, dataLoader.MaxAllowedSize)
+	assert.IsType(t, &httploader.HTTPLoader{}, app.Loaders[1])
+
+	srv = CreateServer([]string{
+		"-data-loader-enable",
+		"-http-loader-max-allowed-size", "2000",
+	})
+	app = srv.App.(*imagor.Imagor)
+	dataLoader = app.Loaders[0].(*dataloader.DataLoader)
+	assert.Equal(t, 2000, dataLoader.MaxAllowedSize)
+
+	srv = CreateServer([]string{})
+	app = srv.App.(*imagor.Imagor)
+	assert.Equal(t, 1, len(app.Loaders))
 }
 
 func TestFileStorage(t *testing.T) {
@@ -156,9 +346,11 @@ func TestFileStorage(t *testing.T) {
 
 		"-file-storage-base-dir", "./foo",
 		"-file-storage-path-prefix", "abcd",
+		"-file-storage-hash-depth", "2",
 
 		"-file-result-storage-base-dir", "./bar",
 		"-file-result-storage-path-prefix", "bcda",
+		"-file-result-storage-hash-depth", "3",
 	})
 	app := srv.App.(*imagor.Imagor)
 	assert.Equal(t, 1, len(app.Loaders))
@@ -166,11 +358,14 @@ func TestFileStorage(t *testing.T) {
 	assert.Equal(t, "./foo", storage.BaseDir)
 	assert.Equal(t, "/abcd/", storage.PathPrefix)
 	assert.Equal(t, "!", storage.SafeChars)
+	assert.Equal(t, 2, storage.HashDepth)
+	assert.Equal(t, 2, storage.HashWidth)
 
 	resultStorage := app.ResultStorages[0].(*filestorage.FileStorage)
 	assert.Equal(t, "./bar", resultStorage.BaseDir)
 	assert.Equal(t, "/bcda/", resultStorage.PathPrefix)
 	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.Equal(t, 3, resultStorage.HashDepth)
 }
 
 func TestPathStyle(t *testing.T) {
@@ -205,4 +400,7 @@ func TestPrometheusBind(t *testing.T) {
 	pm := srv.Metrics.(*prometheusmetrics.PrometheusMetrics)
 	assert.Equal(t, pm.Path, "/myprom")
 	assert.Equal(t, pm.Addr, ":6789")
+	app := srv.App.(*imagor.Imagor)
+	assert.Equal(t, pm, app.ConversionObserver)
+	assert.Equal(t, pm, app.UnsignedObserver)
 }
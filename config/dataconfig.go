@@ -0,0 +1,34 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/loader/dataloader"
+	"go.uber.org/zap"
+)
+
+// withDataLoader with Data URI Loader config option
+func withDataLoader(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		dataLoaderEnable = fs.Bool("data-loader-enable", false,
+			"Enable Data URI Loader that loads base64 data URI image key e.g. data:image/png;base64,iVBORw0KGgo...")
+		dataLoaderMaxAllowedSize = fs.Int("data-loader-max-allowed-size", 0,
+			"Data URI Loader maximum allowed decoded size in bytes if set. Defaults to http-loader-max-allowed-size")
+	)
+	_, _ = cb()
+	maxAllowedSize := *dataLoaderMaxAllowedSize
+	if f := fs.Lookup("http-loader-max-allowed-size"); f != nil && maxAllowedSize == 0 {
+		maxAllowedSize, _ = strconv.Atoi(f.Value.String())
+	}
+	return func(app *imagor.Imagor) {
+		if *dataLoaderEnable {
+			app.Loaders = append(app.Loaders,
+				dataloader.New(
+					dataloader.WithMaxAllowedSize(maxAllowedSize),
+				),
+			)
+		}
+	}
+}
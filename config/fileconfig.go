@@ -3,8 +3,10 @@ package config
 import (
 	"flag"
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/loader/ziploader"
 	"github.com/cshum/imagor/storage/filestorage"
 	"go.uber.org/zap"
+	"strings"
 )
 
 // withFileSystem with File Loader, Storage, Result Storage based config option
@@ -16,6 +18,13 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"Base directory for File Loader. Enable File Loader only if this value present")
 		fileLoaderPathPrefix = fs.String("file-loader-path-prefix", "",
 			"Base path prefix for File Loader")
+		fileLoaderAllowedPatterns = fs.String("file-loader-allowed-patterns", "",
+			"File Loader allowed glob patterns of file path relative to base dir if set. Accept csv e.g. images/*.jpg,images/*/*.png")
+
+		zipLoaderBaseDir = fs.String("zip-loader-base-dir", "",
+			"Base directory for Zip Loader, loads zip archive entry by image key e.g. zip://archive.zip#path/to/image.png. Enable Zip Loader only if this value present")
+		zipLoaderMaxOpenArchives = fs.Int("zip-loader-max-open-archives", 16,
+			"Zip Loader maximum number of archive handles kept open")
 
 		fileStorageBaseDir = fs.String("file-storage-base-dir", "",
 			"Base directory for File Storage. Enable File Storage only if this value present")
@@ -27,6 +36,8 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"File Storage write permission")
 		fileStorageExpiration = fs.Duration("file-storage-expiration", 0,
 			"File Storage expiration duration e.g. 24h. Default no expiration")
+		fileStorageHashDepth = fs.Int("file-storage-hash-depth", 0,
+			"File Storage number of hash derived sub directory levels e.g. 2 for ab/cd/image. Default 0 no hashed sub directories")
 
 		fileResultStorageBaseDir = fs.String("file-result-storage-base-dir", "",
 			"Base directory for File Result Storage. Enable File Result Storage only if this value present")
@@ -38,6 +49,8 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"File Storage write permission")
 		fileResultStorageExpiration = fs.Duration("file-result-storage-expiration", 0,
 			"File Result Storage expiration duration e.g. 24h. Default no expiration")
+		fileResultStorageHashDepth = fs.Int("file-result-storage-hash-depth", 0,
+			"File Result Storage number of hash derived sub directory levels e.g. 2 for ab/cd/image. Default 0 no hashed sub directories")
 
 		_, _ = cb()
 	)
@@ -52,6 +65,16 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					filestorage.WithWritePermission(*fileStorageWritePermission),
 					filestorage.WithSafeChars(*fileSafeChars),
 					filestorage.WithExpiration(*fileStorageExpiration),
+					filestorage.WithHashedPath(*fileStorageHashDepth, 2),
+				),
+			)
+		}
+		if *zipLoaderBaseDir != "" {
+			// activate Zip Loader only if base dir config presents
+			o.Loaders = append(o.Loaders,
+				ziploader.New(
+					*zipLoaderBaseDir,
+					ziploader.WithMaxOpenArchives(*zipLoaderMaxOpenArchives),
 				),
 			)
 		}
@@ -62,6 +85,7 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					*fileLoaderBaseDir,
 					filestorage.WithPathPrefix(*fileLoaderPathPrefix),
 					filestorage.WithSafeChars(*fileSafeChars),
+					filestorage.WithAllowedPatterns(strings.Split(*fileLoaderAllowedPatterns, ",")),
 				),
 			)
 		}
@@ -75,6 +99,7 @@ func withFileSystem(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					filestorage.WithWritePermission(*fileResultStorageWritePermission),
 					filestorage.WithSafeChars(*fileSafeChars),
 					filestorage.WithExpiration(*fileResultStorageExpiration),
+					filestorage.WithHashedPath(*fileResultStorageHashDepth, 2),
 				),
 			)
 		}
@@ -32,6 +32,8 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for Google Cloud Storage")
 		gcloudStorageExpiration = fs.Duration("gcloud-storage-expiration", 0,
 			"Google Cloud Storage expiration duration e.g. 24h. Default no expiration")
+		gcloudStorageCacheControl = fs.String("gcloud-storage-cache-control", "",
+			"Cache-Control object attribute for Google Cloud Storage upload e.g. public, max-age=86400")
 
 		gcloudResultStorageBucket = fs.String("gcloud-result-storage-bucket", "",
 			"Bucket name for Google Cloud Result Storage. Enable Google Cloud Result Storage only if this value present")
@@ -43,6 +45,8 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"Upload ACL for Google Cloud Result Storage")
 		gcloudResultStorageExpiration = fs.Duration("gcloud-result-storage-expiration", 0,
 			"Google Cloud Result Storage expiration duration e.g. 24h. Default no expiration")
+		gcloudResultStorageCacheControl = fs.String("gcloud-result-storage-cache-control", "",
+			"Cache-Control object attribute for Google Cloud Result Storage upload e.g. public, max-age=86400")
 
 		_, _ = cb()
 	)
@@ -63,6 +67,7 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 						gcloudstorage.WithACL(*gcloudStorageACL),
 						gcloudstorage.WithSafeChars(*gcloudSafeChars),
 						gcloudstorage.WithExpiration(*gcloudStorageExpiration),
+						gcloudstorage.WithCacheControl(*gcloudStorageCacheControl),
 					),
 				)
 			}
@@ -86,6 +91,7 @@ func WithGCloud(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 						gcloudstorage.WithACL(*gcloudResultStorageACL),
 						gcloudstorage.WithSafeChars(*gcloudSafeChars),
 						gcloudstorage.WithExpiration(*gcloudResultStorageExpiration),
+						gcloudstorage.WithCacheControl(*gcloudResultStorageCacheControl),
 					),
 				)
 			}
@@ -56,6 +56,7 @@ func TestGCSStorage(t *testing.T) {
 		"-gcloud-result-storage-bucket", "b",
 		"-gcloud-result-storage-base-dir", "bar",
 		"-gcloud-result-storage-path-prefix", "bcda",
+		"-gcloud-result-storage-cache-control", "public, max-age=86400",
 	}, WithGCloud)
 	app := srv.App.(*imagor.Imagor)
 	assert.Equal(t, 1, len(app.Loaders))
@@ -70,4 +71,6 @@ func TestGCSStorage(t *testing.T) {
 	assert.Equal(t, "bar", resultStorage.BaseDir)
 	assert.Equal(t, "/bcda/", resultStorage.PathPrefix)
 	assert.Equal(t, "!", resultStorage.SafeChars)
+	assert.Equal(t, "public, max-age=86400", resultStorage.CacheControl)
+	assert.Empty(t, storage.CacheControl)
 }
@@ -3,10 +3,12 @@ package config
 import (
 	"flag"
 	"net"
+	"time"
 
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/loader/httploader"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
 // withHTTPLoader with HTTP Loader config option
@@ -25,7 +27,7 @@ func withHTTPLoader(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 		httpLoaderAllowedSources = fs.String("http-loader-allowed-sources", "",
 			"HTTP Loader allowed hosts whitelist to load images from if set. Accept csv wth glob pattern e.g. *.google.com,*.github.com.")
 		httpLoaderMaxAllowedSize = fs.Int("http-loader-max-allowed-size", 0,
-			"HTTP Loader maximum allowed size in bytes for loading images if set")
+			"HTTP Loader maximum allowed size in bytes for loading images if set, including decompressed size of gzip, deflate or br encoded responses")
 		httpLoaderInsecureSkipVerifyTransport = fs.Bool("http-loader-insecure-skip-verify-transport", false,
 			"HTTP Loader to use HTTP transport with InsecureSkipVerify true")
 		httpLoaderBaseURL = fs.String("http-loader-base-url", "",
@@ -44,6 +46,20 @@ func withHTTPLoader(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 			"HTTP Loader rejects connections to private network IP addresses.")
 		httpLoaderBlockLinkLocalNetworks = fs.Bool("http-loader-block-link-local-networks", false,
 			"HTTP Loader rejects connections to link local network IP addresses.")
+		httpLoaderRespectOriginCacheControl = fs.Bool("http-loader-respect-origin-cache-control", false,
+			"HTTP Loader derives image response cache TTL from origin Cache-Control and Expires headers in place of imagor-cache-header-ttl, and ETag and Last-Modified for conditional revalidation of unprocessed images")
+		httpLoaderOriginCacheMinTTL = fs.Duration("http-loader-origin-cache-min-ttl", 0,
+			"HTTP Loader minimum cache TTL derived from origin if set")
+		httpLoaderOriginCacheMaxTTL = fs.Duration("http-loader-origin-cache-max-ttl", 0,
+			"HTTP Loader maximum cache TTL derived from origin if set")
+		httpLoaderMaxRetries = fs.Int("http-loader-max-retries", 0,
+			"HTTP Loader maximum retries on network errors and retryable status codes e.g. 502, 503. Default no retry")
+		httpLoaderRetryBackoff = fs.Duration("http-loader-retry-backoff", time.Millisecond*100,
+			"HTTP Loader base backoff duration between retries, doubled on each retry")
+		httpLoaderRateLimit = fs.Float64("http-loader-rate-limit", 0,
+			"HTTP Loader maximum requests per second per host if set. Requests exceeding the limit wait up to imagor-load-timeout")
+		httpLoaderRateLimitBurst = fs.Int("http-loader-rate-limit-burst", 1,
+			"HTTP Loader maximum burst requests per host if rate limit is set")
 		httpLoaderBlockNetworks []*net.IPNet
 		httpLoaderDisable       = fs.Bool("http-loader-disable", false,
 			"Disable HTTP Loader")
@@ -72,6 +88,11 @@ func withHTTPLoader(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Opti
 					httploader.WithBlockPrivateNetworks(*httpLoaderBlockPrivateNetworks),
 					httploader.WithBlockLinkLocalNetworks(*httpLoaderBlockLinkLocalNetworks),
 					httploader.WithBlockNetworks(httpLoaderBlockNetworks...),
+					httploader.WithRespectOriginCacheControl(*httpLoaderRespectOriginCacheControl),
+					httploader.WithOriginCacheTTLBounds(*httpLoaderOriginCacheMinTTL, *httpLoaderOriginCacheMaxTTL),
+					httploader.WithMaxRetries(*httpLoaderMaxRetries),
+					httploader.WithRetryBackoff(*httpLoaderRetryBackoff),
+					httploader.WithRateLimit(rate.Limit(*httpLoaderRateLimit), *httpLoaderRateLimitBurst),
 				),
 			)
 		}
@@ -0,0 +1,51 @@
+package redisconfig
+
+import (
+	"flag"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/storage/redisstorage"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// WithRedis with Redis Result Storage config option
+func WithRedis(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
+	var (
+		redisResultStorageAddr = fs.String("redis-result-storage-addr", "",
+			"Redis address host:port for Redis Result Storage. Enable Redis Result Storage only if this value present")
+		redisResultStoragePassword = fs.String("redis-result-storage-password", "",
+			"Redis password for Redis Result Storage")
+		redisResultStorageDB = fs.Int("redis-result-storage-db", 0,
+			"Redis database number for Redis Result Storage")
+		redisResultStorageKeyPrefix = fs.String("redis-result-storage-key-prefix", "imagor:",
+			"Key prefix for Redis Result Storage")
+		redisResultStorageExpiration = fs.Duration("redis-result-storage-expiration", 0,
+			"Redis Result Storage expiration duration applied as key TTL e.g. 24h. Default no expiration")
+		redisResultStorageMaxValueSize = fs.Int64("redis-result-storage-max-value-size", 10<<20,
+			"Redis Result Storage maximum value size in bytes, larger result images are not stored. Set -1 for no limit")
+		redisResultStorageSafeChars = fs.String("redis-result-storage-safe-chars", "",
+			"Redis Result Storage safe characters to be excluded from image key escape. Set -- for no-op")
+
+		_, _ = cb()
+	)
+	return func(app *imagor.Imagor) {
+		if *redisResultStorageAddr == "" {
+			return
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr:     *redisResultStorageAddr,
+			Password: *redisResultStoragePassword,
+			DB:       *redisResultStorageDB,
+		})
+		// activate Redis Result Storage only if addr config presents
+		app.ResultStorages = append(app.ResultStorages,
+			redisstorage.New(client,
+				redisstorage.WithKeyPrefix(*redisResultStorageKeyPrefix),
+				redisstorage.WithExpiration(*redisResultStorageExpiration),
+				redisstorage.WithMaxValueSize(*redisResultStorageMaxValueSize),
+				redisstorage.WithSafeChars(*redisResultStorageSafeChars),
+			),
+		)
+	}
+}
@@ -0,0 +1,40 @@
+package redisconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/config"
+	"github.com/cshum/imagor/storage/redisstorage"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisEmpty(t *testing.T) {
+	srv := config.CreateServer([]string{}, WithRedis)
+	app := srv.App.(*imagor.Imagor)
+	assert.Empty(t, app.ResultStorages)
+}
+
+func TestRedisResultStorage(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-redis-result-storage-addr", "localhost:6380",
+		"-redis-result-storage-password", "pass",
+		"-redis-result-storage-db", "2",
+		"-redis-result-storage-key-prefix", "result:",
+		"-redis-result-storage-expiration", "1h",
+		"-redis-result-storage-max-value-size", "1024",
+		"-redis-result-storage-safe-chars", "!",
+	}, WithRedis)
+	app := srv.App.(*imagor.Imagor)
+	resultStorage := app.ResultStorages[0].(*redisstorage.RedisStorage)
+	assert.Equal(t, "result:", resultStorage.KeyPrefix)
+	assert.Equal(t, time.Hour, resultStorage.Expiration)
+	assert.Equal(t, int64(1024), resultStorage.MaxValueSize)
+	assert.Equal(t, "!", resultStorage.SafeChars)
+	opts := resultStorage.Client.(*redis.Client).Options()
+	assert.Equal(t, "localhost:6380", opts.Addr)
+	assert.Equal(t, "pass", opts.Password)
+	assert.Equal(t, 2, opts.DB)
+}
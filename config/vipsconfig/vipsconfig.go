@@ -14,6 +14,8 @@ func WithVips(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"VIPS disable blur operations for vips processor")
 		vipsMaxAnimationFrames = fs.Int("vips-max-animation-frames", -1,
 			"VIPS maximum number of animation frames to be loaded. Set 1 to disable animation, -1 for unlimited")
+		vipsMaxAnimationFramesStrict = fs.Bool("vips-max-animation-frames-strict", false,
+			"VIPS reject animated image exceeding vips-max-animation-frames instead of truncating extra frames")
 		vipsDisableFilters = fs.String("vips-disable-filters", "",
 			"VIPS disable filters by csv e.g. blur,watermark,rgb")
 		vipsMaxFilterOps = fs.Int("vips-max-filter-ops", -1,
@@ -32,18 +34,21 @@ func WithVips(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"VIPS max image height")
 		vipsMaxResolution = fs.Int("vips-max-resolution", 0,
 			"VIPS max image resolution")
+		vipsMaxDPI = fs.Int("vips-max-dpi", 1200,
+			"VIPS maximum dpi for rasterizing PDF and SVG. Set -1 for unlimited")
 		vipsMozJPEG = fs.Bool("vips-mozjpeg", false,
 			"VIPS enable maximum compression with MozJPEG. Requires mozjpeg to be installed")
 		vipsAvifSpeed = fs.Int("vips-avif-speed", 5,
 			"VIPS avif speed, the lowest is at 0 and the fastest is at 9 (Default 5).")
 		vipsStripMetadata = fs.Bool("vips-strip-metadata", false,
-			"VIPS strips all metadata from the resulting image")
+			"VIPS strips all metadata from the resulting image, unless strip_metadata(0) filter specified")
 
 		logger, isDebug = cb()
 	)
 	return imagor.WithProcessors(
 		vips.NewProcessor(
 			vips.WithMaxAnimationFrames(*vipsMaxAnimationFrames),
+			vips.WithMaxAnimationFramesStrict(*vipsMaxAnimationFramesStrict),
 			vips.WithDisableBlur(*vipsDisableBlur),
 			vips.WithDisableFilters(*vipsDisableFilters),
 			vips.WithConcurrency(*vipsConcurrency),
@@ -54,6 +59,7 @@ func WithVips(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			vips.WithMaxWidth(*vipsMaxWidth),
 			vips.WithMaxHeight(*vipsMaxHeight),
 			vips.WithMaxResolution(*vipsMaxResolution),
+			vips.WithMaxDPI(*vipsMaxDPI),
 			vips.WithMozJPEG(*vipsMozJPEG),
 			vips.WithAvifSpeed(*vipsAvifSpeed),
 			vips.WithStripMetadata(*vipsStripMetadata),
@@ -11,10 +11,14 @@ import (
 func TestWithVips(t *testing.T) {
 	srv := config.CreateServer([]string{
 		"-vips-max-animation-frames", "167",
+		"-vips-max-animation-frames-strict",
+		"-vips-max-dpi", "300",
 		"-vips-disable-filters", "blur,watermark,rgb",
 	}, WithVips)
 	app := srv.App.(*imagor.Imagor)
 	processor := app.Processors[0].(*vips.Processor)
 	assert.Equal(t, 167, processor.MaxAnimationFrames)
+	assert.True(t, processor.MaxAnimationFramesStrict)
+	assert.Equal(t, 300, processor.MaxDPI)
 	assert.Equal(t, []string{"blur", "watermark", "rgb"}, processor.DisableFilters)
 }
@@ -1,10 +1,13 @@
 package imagor
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"sync"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 type contextKey struct {
@@ -13,27 +16,126 @@ type contextKey struct {
 
 var imagorContextKey = contextKey{1}
 var detachContextKey = contextKey{2}
+var timingsContextKey = contextKey{3}
+var priorityContextKey = contextKey{4}
 
 type imagorContextRef struct {
-	funcs []func()
-	l     sync.Mutex
+	funcs  []func()
+	l      sync.Mutex
+	logger *zap.Logger
+
+	cache           map[any]*list.Element
+	cacheList       *list.List
+	cacheBytes      int64
+	cacheMaxEntries int
+	cacheMaxBytes   int64
+	cl              sync.Mutex
 
 	Blob *Blob
 }
 
+type contextCacheEntry struct {
+	key  any
+	val  any
+	size int64
+}
+
+// cacheSizer cache value that reports its size in bytes
+type cacheSizer interface {
+	Size() int64
+}
+
 func (r *imagorContextRef) Defer(fn func()) {
 	r.l.Lock()
 	r.funcs = append(r.funcs, fn)
 	r.l.Unlock()
 }
 
+// Done calls deferred funcs in reverse order like Go defer,
+// a panic in one func does not prevent the others from running
 func (r *imagorContextRef) Done() {
 	r.l.Lock()
-	for _, fn := range r.funcs {
-		fn()
-	}
+	funcs := r.funcs
 	r.funcs = nil
+	logger := r.logger
 	r.l.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		r.call(funcs[i], logger)
+	}
+}
+
+func (r *imagorContextRef) call(fn func(), logger *zap.Logger) {
+	defer func() {
+		if rec := recover(); rec != nil && logger != nil {
+			logger.Error("context defer panic", zap.Any("panic", rec), zap.Stack("stack"))
+		}
+	}()
+	fn()
+}
+
+func (r *imagorContextRef) cachePut(key, val any) {
+	var size int64
+	if s, ok := val.(cacheSizer); ok {
+		size = s.Size()
+	}
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if r.cache == nil {
+		r.cache = map[any]*list.Element{}
+		r.cacheList = list.New()
+	}
+	if e, ok := r.cache[key]; ok {
+		r.cacheRemove(e)
+	}
+	r.cache[key] = r.cacheList.PushBack(&contextCacheEntry{key: key, val: val, size: size})
+	r.cacheBytes += size
+	// evict least recently stored entries when exceeding bounds
+	for r.cacheList.Len() > 0 &&
+		((r.cacheMaxEntries > 0 && r.cacheList.Len() > r.cacheMaxEntries) ||
+			(r.cacheMaxBytes > 0 && r.cacheBytes > r.cacheMaxBytes)) {
+		r.cacheRemove(r.cacheList.Front())
+	}
+}
+
+func (r *imagorContextRef) cacheGet(key any) (any, bool) {
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if e, ok := r.cache[key]; ok {
+		return e.Value.(*contextCacheEntry).val, true
+	}
+	return nil, false
+}
+
+func (r *imagorContextRef) cacheDelete(key any) {
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if e, ok := r.cache[key]; ok {
+		r.cacheRemove(e)
+	}
+}
+
+func (r *imagorContextRef) cacheRange(fn func(key, val any) bool) {
+	r.cl.Lock()
+	var entries []*contextCacheEntry
+	if r.cacheList != nil {
+		for e := r.cacheList.Front(); e != nil; e = e.Next() {
+			entries = append(entries, e.Value.(*contextCacheEntry))
+		}
+	}
+	r.cl.Unlock()
+	// iterate on snapshot so that fn may modify the cache
+	for _, entry := range entries {
+		if !fn(entry.key, entry.val) {
+			return
+		}
+	}
+}
+
+// cacheRemove removes cache element, must be called with cache lock held
+func (r *imagorContextRef) cacheRemove(e *list.Element) {
+	entry := r.cacheList.Remove(e).(*contextCacheEntry)
+	delete(r.cache, entry.key)
+	r.cacheBytes -= entry.size
 }
 
 // withContext context with imagor defer handling and cache
@@ -50,6 +152,13 @@ func withContext(ctx context.Context) context.Context {
 	return ctx
 }
 
+// WithContext context with imagor defer handling and cache for calling Imagor.Serve in-process.
+// Deferred clean up of the request runs once ctx done,
+// so ctx should be canceled only after the resulting Blob consumed
+func WithContext(ctx context.Context) context.Context {
+	return withContext(ctx)
+}
+
 func mustContextRef(ctx context.Context) *imagorContextRef {
 	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
 		return r
@@ -62,6 +171,124 @@ func contextDefer(ctx context.Context, fn func()) {
 	mustContextRef(ctx).Defer(fn)
 }
 
+// withContextLogger sets logger for recovered panics of deferred funcs
+func withContextLogger(ctx context.Context, logger *zap.Logger) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		r.l.Lock()
+		r.logger = logger
+		r.l.Unlock()
+	}
+}
+
+// withContextCacheLimit sets the bounds of context cache,
+// by maximum number of entries and total bytes of values with Size() int64.
+// Zero means unbounded
+func withContextCacheLimit(ctx context.Context, maxEntries int, maxBytes int64) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		r.cl.Lock()
+		r.cacheMaxEntries = maxEntries
+		r.cacheMaxBytes = maxBytes
+		r.cl.Unlock()
+	}
+}
+
+// ContextCachePut put cache value to imagor request context.
+// Least recently stored entries may be evicted if cache bound exceeded,
+// resulting a cache miss and reload, which is acceptable.
+// No-op if not imagor context
+func ContextCachePut(ctx context.Context, key any, val any) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		r.cachePut(key, val)
+	}
+}
+
+// ContextCacheGet get cache value from imagor request context
+func ContextCacheGet(ctx context.Context, key any) (any, bool) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		return r.cacheGet(key)
+	}
+	return nil, false
+}
+
+// ContextCacheDelete delete cache value from imagor request context.
+// No-op if not imagor context
+func ContextCacheDelete(ctx context.Context, key any) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		r.cacheDelete(key)
+	}
+}
+
+// ContextCacheRange iterates cache values of imagor request context in stored order,
+// stops if fn returns false. No-op if not imagor context
+func ContextCacheRange(ctx context.Context, fn func(key, val any) bool) {
+	if r, ok := ctx.Value(imagorContextKey).(*imagorContextRef); ok && r != nil {
+		r.cacheRange(fn)
+	}
+}
+
+type contextTimings struct {
+	names     []string
+	durations map[string]time.Duration
+	l         sync.Mutex
+}
+
+// WithTimings context that accumulates durations of stages recorded by ContextTiming,
+// e.g. load, subload, process and save
+func WithTimings(ctx context.Context) context.Context {
+	if t, ok := ctx.Value(timingsContextKey).(*contextTimings); ok && t != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, timingsContextKey, &contextTimings{
+		durations: map[string]time.Duration{},
+	})
+}
+
+// ContextTiming adds duration to the named stage of context timings,
+// accumulated if recorded multiple times. No-op if not timings context
+func ContextTiming(ctx context.Context, name string, d time.Duration) {
+	if t, ok := ctx.Value(timingsContextKey).(*contextTimings); ok && t != nil {
+		t.l.Lock()
+		if _, ok := t.durations[name]; !ok {
+			t.names = append(t.names, name)
+		}
+		t.durations[name] += d
+		t.l.Unlock()
+	}
+}
+
+// ContextTimingsRange iterates stage timings of context in first recorded order,
+// stops if fn returns false. No-op if not timings context
+func ContextTimingsRange(ctx context.Context, fn func(name string, d time.Duration) bool) {
+	if t, ok := ctx.Value(timingsContextKey).(*contextTimings); ok && t != nil {
+		t.l.Lock()
+		var names = make([]string, len(t.names))
+		var durations = make([]time.Duration, len(t.names))
+		for i, name := range t.names {
+			names[i] = name
+			durations[i] = t.durations[name]
+		}
+		t.l.Unlock()
+		for i, name := range names {
+			if !fn(name, durations[i]) {
+				return
+			}
+		}
+	}
+}
+
+// WithPriority context with process priority of imagor request,
+// higher priority requests acquire process slots ahead if process priority enabled
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityContextKey, priority)
+}
+
+func contextPriority(ctx context.Context) int {
+	if priority, ok := ctx.Value(priorityContextKey).(int); ok {
+		return priority
+	}
+	return 0
+}
+
 type detachedContext struct {
 	ctx context.Context
 }
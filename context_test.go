@@ -3,6 +3,8 @@ package imagor
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"testing"
 	"time"
 )
@@ -31,6 +33,28 @@ func TestDefer(t *testing.T) {
 	assert.Equal(t, 2, called, "should count all defers before cancel")
 }
 
+func TestDeferOrderPanic(t *testing.T) {
+	var calls []int
+	core, logs := observer.New(zap.ErrorLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = withContext(ctx)
+	withContextLogger(ctx, zap.New(core))
+	for i := 0; i < 3; i++ {
+		i := i
+		contextDefer(ctx, func() {
+			calls = append(calls, i)
+			if i == 1 {
+				panic("cleanup failed")
+			}
+		})
+	}
+	assert.NotPanics(t, mustContextRef(ctx).Done)
+	cancel()
+	assert.Equal(t, []int{2, 1, 0}, calls, "should call in reverse order regardless of panic")
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "cleanup failed", logs.All()[0].ContextMap()["panic"])
+}
+
 func TestDetachContext(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
 	defer cancel()
@@ -49,3 +73,126 @@ func TestDetachContext(t *testing.T) {
 	time.Sleep(time.Millisecond * 10)
 	assert.Equal(t, ctx.Err(), context.DeadlineExceeded)
 }
+
+func TestContextCache(t *testing.T) {
+	ctx := context.Background()
+	assert.NotPanics(t, func() {
+		ContextCachePut(ctx, "foo", "bar")
+	})
+	_, ok := ContextCacheGet(ctx, "foo")
+	assert.False(t, ok)
+
+	ctx = withContext(ctx)
+	ContextCachePut(ctx, "foo", "bar")
+	ContextCachePut(ctx, "foo", "baz")
+	val, ok := ContextCacheGet(ctx, "foo")
+	assert.True(t, ok)
+	assert.Equal(t, "baz", val)
+	_, ok = ContextCacheGet(ctx, "bar")
+	assert.False(t, ok)
+}
+
+func TestContextCacheLimit(t *testing.T) {
+	t.Run("max entries", func(t *testing.T) {
+		ctx := withContext(context.Background())
+		withContextCacheLimit(ctx, 2, 0)
+		ContextCachePut(ctx, "a", 1)
+		ContextCachePut(ctx, "b", 2)
+		ContextCachePut(ctx, "a", 3)
+		ContextCachePut(ctx, "c", 4)
+		_, ok := ContextCacheGet(ctx, "b")
+		assert.False(t, ok, "should evict least recently stored")
+		val, ok := ContextCacheGet(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, 3, val)
+		val, ok = ContextCacheGet(ctx, "c")
+		assert.True(t, ok)
+		assert.Equal(t, 4, val)
+	})
+	t.Run("max bytes", func(t *testing.T) {
+		ctx := withContext(context.Background())
+		withContextCacheLimit(ctx, 0, 10)
+		ContextCachePut(ctx, "a", NewBlobFromBytes([]byte("abcd")))
+		ContextCachePut(ctx, "b", NewBlobFromBytes([]byte("efgh")))
+		ContextCachePut(ctx, "c", "no size")
+		_, ok := ContextCacheGet(ctx, "a")
+		assert.True(t, ok)
+		ContextCachePut(ctx, "d", NewBlobFromBytes([]byte("ijkl")))
+		_, ok = ContextCacheGet(ctx, "a")
+		assert.False(t, ok, "should evict when exceeding max bytes")
+		for _, key := range []string{"b", "c", "d"} {
+			_, ok = ContextCacheGet(ctx, key)
+			assert.True(t, ok, key)
+		}
+		ContextCachePut(ctx, "e", NewBlobFromBytes([]byte("abcdefghijkl")))
+		for _, key := range []string{"b", "c", "d", "e"} {
+			_, ok = ContextCacheGet(ctx, key)
+			assert.False(t, ok, key)
+		}
+	})
+}
+
+func TestContextCacheDelete(t *testing.T) {
+	assert.NotPanics(t, func() {
+		ContextCacheDelete(context.Background(), "foo")
+		ContextCacheRange(context.Background(), func(key, val any) bool {
+			t.Fatal("should not call")
+			return true
+		})
+	})
+	ctx := withContext(context.Background())
+	ContextCacheDelete(ctx, "foo")
+	ContextCachePut(ctx, "foo", "bar")
+	ContextCachePut(ctx, "abc", "def")
+	ContextCacheDelete(ctx, "foo")
+	_, ok := ContextCacheGet(ctx, "foo")
+	assert.False(t, ok)
+	val, ok := ContextCacheGet(ctx, "abc")
+	assert.True(t, ok)
+	assert.Equal(t, "def", val)
+}
+
+func TestContextCacheRange(t *testing.T) {
+	ctx := withContext(context.Background())
+	ContextCacheRange(ctx, func(key, val any) bool {
+		t.Fatal("should not call")
+		return true
+	})
+	ContextCachePut(ctx, "a", 1)
+	ContextCachePut(ctx, "b", 2)
+	ContextCachePut(ctx, "c", 3)
+	var keys []any
+	ContextCacheRange(ctx, func(key, val any) bool {
+		keys = append(keys, key)
+		ContextCacheDelete(ctx, key)
+		return key != "b"
+	})
+	assert.Equal(t, []any{"a", "b"}, keys)
+	_, ok := ContextCacheGet(ctx, "b")
+	assert.False(t, ok)
+	_, ok = ContextCacheGet(ctx, "c")
+	assert.True(t, ok)
+}
+
+func TestContextTimings(t *testing.T) {
+	ContextTiming(context.Background(), "load", time.Second)
+	ContextTimingsRange(context.Background(), func(name string, d time.Duration) bool {
+		t.Fatal("should not call")
+		return true
+	})
+	ctx := WithTimings(context.Background())
+	assert.Equal(t, ctx, WithTimings(ctx))
+	ContextTiming(ctx, "load", time.Second)
+	ContextTiming(ctx, "process", time.Millisecond)
+	ContextTiming(ctx, "load", time.Second)
+	ContextTiming(detachContext(withContext(ctx)), "save", time.Minute)
+	var names []string
+	var durations []time.Duration
+	ContextTimingsRange(ctx, func(name string, d time.Duration) bool {
+		names = append(names, name)
+		durations = append(durations, d)
+		return true
+	})
+	assert.Equal(t, []string{"load", "process", "save"}, names)
+	assert.Equal(t, []time.Duration{time.Second * 2, time.Millisecond, time.Minute}, durations)
+}
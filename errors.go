@@ -33,6 +33,18 @@ var (
 	ErrMaxSizeExceeded = NewError("maximum size exceeded", http.StatusBadRequest)
 	// ErrMaxResolutionExceeded maximum resolution exceeded error
 	ErrMaxResolutionExceeded = NewError("maximum resolution exceeded", http.StatusUnprocessableEntity)
+	// ErrMaxFramesExceeded maximum animation frames exceeded error
+	ErrMaxFramesExceeded = NewError("maximum animation frames exceeded", http.StatusUnprocessableEntity)
+	// ErrMaxDecompressedBytesExceeded image decompressed size declared by header exceeds maximum error
+	ErrMaxDecompressedBytesExceeded = NewError("maximum decompressed size exceeded", http.StatusUnprocessableEntity)
+	// ErrTypeNotAllowed sniffed source type not in allowed types error
+	ErrTypeNotAllowed = NewError("source type not allowed", http.StatusNotAcceptable)
+	// ErrSizeNotAllowed output dimensions not in allowed sizes error
+	ErrSizeNotAllowed = NewError("size not allowed", http.StatusBadRequest)
+	// ErrDecodeFailed image cannot be decoded error
+	ErrDecodeFailed = NewError("cannot decode image", http.StatusUnprocessableEntity)
+	// ErrMetadataNotSupported metadata cannot be parsed without decoding error
+	ErrMetadataNotSupported = NewError("metadata not supported", http.StatusNotAcceptable)
 	// ErrTooManyRequests too many requests error
 	ErrTooManyRequests = NewError("too many requests", http.StatusTooManyRequests)
 	// ErrInternal internal error
@@ -57,6 +69,8 @@ func (p ErrForward) Error() string {
 type Error struct {
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"status,omitempty"`
+	// Detail diagnostic detail, only exposed with verbose errors
+	Detail string `json:"detail,omitempty"`
 }
 
 type timeoutErr interface {
@@ -73,11 +87,24 @@ func (e Error) Timeout() bool {
 	return e.Code == http.StatusRequestTimeout || e.Code == http.StatusGatewayTimeout
 }
 
+// Is reports if target is the same Error regardless of Detail,
+// so errors.Is matches sentinel errors with diagnostic detail
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Code == e.Code && t.Message == e.Message
+}
+
 // NewError creates imagor Error from message and status code
 func NewError(msg string, code int) Error {
 	return Error{Message: msg, Code: code}
 }
 
+// WithDetail returns copy of Error with diagnostic detail
+func (e Error) WithDetail(detail string) Error {
+	e.Detail = detail
+	return e
+}
+
 // NewErrorFromStatusCode creates imagor Error solely from status code
 func NewErrorFromStatusCode(code int) Error {
 	return NewError(http.StatusText(code), code)
@@ -3,6 +3,7 @@ package imagor
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/cshum/imagor/imagorpath"
 	"github.com/stretchr/testify/assert"
 	"net"
@@ -44,4 +45,11 @@ func TestWrapError(t *testing.T) {
 	assert.Equal(t, "imagor: forward 167x169/foo", err.Error())
 	assert.Equal(t, ErrUnsupportedFormat, WrapError(err))
 
+	err = ErrTypeNotAllowed.WithDetail("gif")
+	assert.True(t, errors.Is(err, ErrTypeNotAllowed))
+	assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), ErrTypeNotAllowed))
+	assert.False(t, errors.Is(err, ErrUnsupportedFormat))
+	assert.False(t, errors.Is(err, errors.New(ErrTypeNotAllowed.Message)))
+	assert.Equal(t, ErrTypeNotAllowed, WrapError(err).WithDetail(""))
+
 }
@@ -1,8 +1,10 @@
 package fanoutreader
 
 import (
+	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 // Fanout allows fanout arbitrary number of reader streams concurrently
@@ -17,6 +19,12 @@ type Fanout struct {
 	lock    sync.RWMutex
 	once    sync.Once
 	readers []*reader
+	notify  chan struct{}
+
+	pool     *sync.Pool
+	active   int
+	drained  bool
+	released bool
 }
 
 // reader io.ReadCloser spawned via Fanout
@@ -24,9 +32,10 @@ type reader struct {
 	fanout        *Fanout
 	channel       chan []byte
 	closeChannel  chan struct{}
+	closeOnce     sync.Once
 	buf           []byte
 	current       int
-	readerClosed  bool
+	readerClosed  atomic.Bool
 }
 
 // New Fanout factory via single io.ReadCloser source with known size
@@ -35,9 +44,50 @@ func New(source io.ReadCloser, size int) *Fanout {
 		source: source,
 		size:   size,
 		buf:    make([]byte, size),
+		notify: make(chan struct{}),
 	}
 }
 
+// NewWithPool Fanout factory via single io.ReadCloser source with known size,
+// borrowing buffer from pool of *[]byte.
+// Buffer is returned to the pool once source fully drained and all spawned readers closed,
+// readers spawned afterwards are closed
+func NewWithPool(source io.ReadCloser, size int, pool *sync.Pool) *Fanout {
+	var buf []byte
+	if b, ok := pool.Get().(*[]byte); ok && b != nil && cap(*b) >= size {
+		buf = (*b)[:size]
+	} else {
+		buf = make([]byte, size)
+	}
+	return &Fanout{
+		source: source,
+		size:   size,
+		buf:    buf,
+		notify: make(chan struct{}),
+		pool:   pool,
+	}
+}
+
+// release returns buffer to the pool if source drained and all readers closed.
+// must be called with lock held
+func (f *Fanout) release() {
+	if f.pool == nil || f.released || !f.drained || f.active > 0 {
+		return
+	}
+	f.released = true
+	buf := f.buf[:0]
+	f.buf = nil
+	f.pool.Put(&buf)
+}
+
+// readerDone marks a spawned reader closed
+func (f *Fanout) readerDone() {
+	f.lock.Lock()
+	f.active--
+	f.release()
+	f.lock.Unlock()
+}
+
 // do triggers reading data from source
 func (f *Fanout) do() {
 	f.once.Do(func() {
@@ -46,8 +96,11 @@ func (f *Fanout) do() {
 }
 
 func (f *Fanout) readAll() {
+	var closed bool
 	defer func() {
-		_ = f.source.Close()
+		if !closed {
+			_ = f.source.Close()
+		}
 	}()
 	for f.current < f.size {
 		b := f.buf[f.current:]
@@ -59,6 +112,13 @@ func (f *Fanout) readAll() {
 		if n > 0 {
 			bn = b[:n]
 		}
+		var closeErr error
+		if f.current+n >= f.size || (e != nil && n == 0) {
+			// close source before dispatching the last chunk,
+			// so that readers see close error instead of clean EOF
+			closed = true
+			closeErr = f.source.Close()
+		}
 		f.lock.Lock()
 		f.current += n
 		if e != nil {
@@ -74,6 +134,12 @@ func (f *Fanout) readAll() {
 				f.size = f.current
 			}
 		}
+		if closeErr != nil && f.err == nil {
+			f.err = closeErr
+		}
+		// notify read seekers waiting for data
+		close(f.notify)
+		f.notify = make(chan struct{})
 		readersCopy := f.readers
 		f.lock.Unlock()
 
@@ -104,26 +170,39 @@ func (f *Fanout) readAll() {
 			f.lock.Unlock()
 		}
 	}
+	f.lock.Lock()
+	f.drained = true
+	f.release()
+	f.lock.Unlock()
 }
 
 // NewReader spawns new io.ReadCloser
 func (f *Fanout) NewReader() io.ReadCloser {
 	r := &reader{}
-	r.channel = make(chan []byte, f.size/4096+1)
 	r.closeChannel = make(chan struct{})
 	r.fanout = f
 
 	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.released {
+		// buffer already returned to pool
+		r.readerClosed.Store(true)
+		r.closeOnce.Do(func() {
+			close(r.closeChannel)
+		})
+		return r
+	}
+	r.channel = make(chan []byte, f.size/4096+1)
 	r.buf = f.buf[:f.current]
 	f.readers = append(f.readers, r)
-	f.lock.Unlock()
+	f.active++
 	return r
 }
 
 // Read implements the io.Reader interface.
 func (r *reader) Read(p []byte) (n int, err error) {
 	r.fanout.do()
-	if r.readerClosed {
+	if r.readerClosed.Load() {
 		return 0, io.ErrClosedPipe
 	}
 	r.fanout.lock.RLock()
@@ -152,30 +231,134 @@ func (r *reader) Read(p []byte) (n int, err error) {
 		r.current += nn
 		n += nn
 		if r.current >= size {
-			_ = r.close(false)
+			_ = r.close()
 			return
 		}
 	}
 }
 
 // close reader or just closing the underlying channel
-func (r *reader) close(closeReader bool) (e error) {
+func (r *reader) close() (e error) {
 	r.fanout.lock.RLock()
 	e = r.fanout.err
 	r.fanout.lock.RUnlock()
-	r.readerClosed = closeReader
 
 	// Close channel if it's not closed yet
-	select {
-	case <-r.closeChannel:
-	default:
+	r.closeOnce.Do(func() {
 		close(r.closeChannel)
-	}
+	})
 
 	return
 }
 
 // Close implements the io.Closer interface.
 func (r *reader) Close() error {
-	return r.close(true)
+	wasClosed := r.readerClosed.Swap(true)
+	err := r.close()
+	if !wasClosed {
+		r.fanout.readerDone()
+	}
+	return err
+}
+
+// readSeeker io.ReadSeekCloser spawned via Fanout,
+// reading from buffered data and waits only when reading beyond
+type readSeeker struct {
+	fanout *Fanout
+	offset int64
+	closed atomic.Bool
+}
+
+// NewReadSeeker spawns new io.ReadSeekCloser.
+// Seek within buffered data does not block,
+// Read blocks only when offset exceeds the buffered data
+func (f *Fanout) NewReadSeeker() io.ReadSeekCloser {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.released {
+		// buffer already returned to pool
+		r := &readSeeker{fanout: f}
+		r.closed.Store(true)
+		return r
+	}
+	f.active++
+	return &readSeeker{fanout: f}
+}
+
+// Read implements the io.Reader interface.
+func (r *readSeeker) Read(p []byte) (n int, err error) {
+	if r.closed.Load() {
+		return 0, io.ErrClosedPipe
+	}
+	f := r.fanout
+	f.do()
+	for {
+		f.lock.RLock()
+		current, size, e, notify := f.current, f.size, f.err, f.notify
+		if r.offset < int64(current) {
+			n = copy(p, f.buf[r.offset:current])
+		}
+		f.lock.RUnlock()
+		if n > 0 {
+			r.offset += int64(n)
+			return
+		}
+		if r.offset >= int64(size) || current >= size {
+			if e != nil {
+				return 0, e
+			}
+			return 0, io.EOF
+		}
+		<-notify
+	}
+}
+
+// Seek implements the io.Seeker interface.
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	if r.closed.Load() {
+		return 0, io.ErrClosedPipe
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		// wait for full buffer as source size may turn out to be smaller than declared
+		size, err := r.fanout.wait()
+		if err != nil {
+			return 0, err
+		}
+		offset += int64(size)
+	default:
+		return 0, errors.New("fanoutreader: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("fanoutreader: negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close implements the io.Closer interface.
+func (r *readSeeker) Close() error {
+	if !r.closed.Swap(true) {
+		r.fanout.readerDone()
+	}
+	r.fanout.lock.RLock()
+	defer r.fanout.lock.RUnlock()
+	return r.fanout.err
+}
+
+// wait blocks until source fully read, returns the final size
+func (f *Fanout) wait() (int, error) {
+	f.do()
+	for {
+		f.lock.RLock()
+		current, size, e, notify := f.current, f.size, f.err, f.notify
+		f.lock.RUnlock()
+		if current >= size {
+			return size, e
+		}
+		<-notify
+	}
 }
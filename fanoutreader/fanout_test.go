@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sync/errgroup"
 	"io"
+	"sync"
 	"testing"
 )
 
@@ -91,3 +92,241 @@ func TestFanoutErrClosedPipe(t *testing.T) {
 	assert.ErrorIs(t, err, io.ErrClosedPipe)
 	assert.Empty(t, n)
 }
+
+type readCloser struct {
+	io.Reader
+	closeErr error
+}
+
+func (rc readCloser) Close() error { return rc.closeErr }
+
+func TestFanoutSourceCloseError(t *testing.T) {
+	e := errors.New("close error")
+	buf := []byte("abcd")
+	source := readCloser{Reader: bytes.NewReader(buf), closeErr: e}
+	factory := New(source, 9)
+	doFanoutTest(t, func() {
+		r := factory.NewReader()
+		res1, err := io.ReadAll(r)
+		assert.Equal(t, e, err)
+		assert.Equal(t, e, r.Close())
+		assert.Equal(t, buf, res1)
+	}, 100, 1)
+}
+
+func TestFanoutSourceCloseErrorFullSize(t *testing.T) {
+	e := errors.New("close error")
+	buf := []byte("abcd")
+	source := readCloser{Reader: bytes.NewReader(buf), closeErr: e}
+	factory := New(source, 4)
+	doFanoutTest(t, func() {
+		r := factory.NewReader()
+		res1, err := io.ReadAll(r)
+		assert.Equal(t, e, err)
+		assert.Equal(t, buf, res1)
+	}, 100, 1)
+}
+
+func TestFanoutReadSeeker(t *testing.T) {
+	buf := []byte("abcdefghijklmnopqrstuvwxyz")
+	source := io.NopCloser(bytes.NewReader(buf))
+	factory := New(source, len(buf))
+	doFanoutTest(t, func() {
+		rs := factory.NewReadSeeker()
+		b := make([]byte, 5)
+		n, err := io.ReadFull(rs, b)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		assert.Equal(t, buf[:5], b)
+
+		pos, err := rs.Seek(10, io.SeekStart)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), pos)
+		res, err := io.ReadAll(rs)
+		assert.NoError(t, err)
+		assert.Equal(t, buf[10:], res)
+
+		pos, err = rs.Seek(-3, io.SeekEnd)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(buf)-3), pos)
+		res, err = io.ReadAll(rs)
+		assert.NoError(t, err)
+		assert.Equal(t, buf[len(buf)-3:], res)
+
+		pos, err = rs.Seek(-6, io.SeekCurrent)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(buf)-6), pos)
+
+		_, err = rs.Seek(-100, io.SeekCurrent)
+		assert.Error(t, err)
+
+		assert.NoError(t, rs.Close())
+		_, err = rs.Read(b)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	}, 100, 1)
+}
+
+func TestFanoutReadSeekerNonBlocking(t *testing.T) {
+	buf := []byte("abcdefghi")
+	pr, pw := io.Pipe()
+	factory := New(pr, len(buf)*2)
+	go func() {
+		_, _ = pw.Write(buf)
+	}()
+	rs := factory.NewReadSeeker()
+	b := make([]byte, len(buf))
+	n, err := io.ReadFull(rs, b)
+	assert.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, buf, b)
+
+	// seek backward within buffered data while source still pending
+	_, err = rs.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	b = make([]byte, 3)
+	n, err = rs.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, buf[3:6], b)
+
+	done := make(chan []byte)
+	go func() {
+		_, _ = rs.Seek(0, io.SeekStart)
+		res, _ := io.ReadAll(rs)
+		done <- res
+	}()
+	_, _ = pw.Write(buf)
+	assert.Equal(t, append(append([]byte{}, buf...), buf...), <-done)
+}
+
+func TestFanoutReadSeekerSourceShorter(t *testing.T) {
+	e := errors.New("close error")
+	buf := []byte("abcd")
+	source := readCloser{Reader: bytes.NewReader(buf), closeErr: e}
+	factory := New(source, 9)
+	rs := factory.NewReadSeeker()
+	pos, err := rs.Seek(0, io.SeekEnd)
+	assert.Equal(t, e, err)
+	assert.Empty(t, pos)
+	res, err := io.ReadAll(rs)
+	assert.Equal(t, e, err)
+	assert.Equal(t, buf, res)
+}
+
+func TestFanoutWithPool(t *testing.T) {
+	buf := []byte("abcdefghi")
+	pooled := make([]byte, 0, 32)
+	pool := &sync.Pool{New: func() any {
+		return &pooled
+	}}
+	factory := NewWithPool(io.NopCloser(bytes.NewReader(buf)), len(buf), pool)
+	var readers []io.ReadCloser
+	for i := 0; i < 3; i++ {
+		readers = append(readers, factory.NewReader())
+	}
+	rs := factory.NewReadSeeker()
+	for _, r := range readers {
+		res, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, buf, res)
+	}
+	res, err := io.ReadAll(rs)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+	assert.Equal(t, buf, pooled[:len(buf)], "should borrow buffer from pool")
+
+	for _, r := range readers {
+		assert.NoError(t, r.Close())
+		assert.NoError(t, r.Close())
+	}
+	factory.lock.RLock()
+	assert.False(t, factory.released, "should not release until all readers closed")
+	factory.lock.RUnlock()
+	assert.NoError(t, rs.Close())
+	factory.lock.RLock()
+	assert.True(t, factory.released)
+	assert.Nil(t, factory.buf)
+	factory.lock.RUnlock()
+
+	r := factory.NewReader()
+	_, err = r.Read(make([]byte, 5))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	_, err = factory.NewReadSeeker().Read(make([]byte, 5))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestFanoutWithPoolSmallBuffer(t *testing.T) {
+	buf := []byte("abcdefghi")
+	small := make([]byte, 4)
+	pool := &sync.Pool{New: func() any {
+		return &small
+	}}
+	factory := NewWithPool(io.NopCloser(bytes.NewReader(buf)), len(buf), pool)
+	r := factory.NewReader()
+	res, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []byte{0, 0, 0, 0}, small)
+}
+
+func TestFanoutWithPoolConcurrentClose(t *testing.T) {
+	buf := []byte("abcdefghi")
+	pool := &sync.Pool{New: func() any {
+		b := make([]byte, 0, 32)
+		return &b
+	}}
+	factory := NewWithPool(io.NopCloser(bytes.NewReader(buf)), len(buf), pool)
+	r1 := factory.NewReader()
+	r2 := factory.NewReader()
+	rs := factory.NewReadSeeker()
+	res, err := io.ReadAll(r1)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+	doFanoutTest(t, func() {
+		_ = r1.Close()
+		_ = rs.Close()
+	}, 10, 2)
+	factory.lock.RLock()
+	assert.Equal(t, 1, factory.active, "concurrent close should count reader done once")
+	assert.False(t, factory.released)
+	factory.lock.RUnlock()
+	res, err = io.ReadAll(r2)
+	assert.NoError(t, err)
+	assert.Equal(t, buf, res)
+	assert.NoError(t, r2.Close())
+	factory.lock.RLock()
+	assert.True(t, factory.released)
+	factory.lock.RUnlock()
+}
+
+func benchmarkFanout(b *testing.B, newFanout func(source io.ReadCloser, size int) *Fanout) {
+	buf := bytes.Repeat([]byte("abcdefghi"), 100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		factory := newFanout(io.NopCloser(bytes.NewReader(buf)), len(buf))
+		var wg sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			r := factory.NewReader()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = io.Copy(io.Discard, r)
+				_ = r.Close()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkFanout(b *testing.B) {
+	benchmarkFanout(b, New)
+}
+
+func BenchmarkFanoutWithPool(b *testing.B) {
+	pool := &sync.Pool{}
+	benchmarkFanout(b, func(source io.ReadCloser, size int) *Fanout {
+		return NewWithPool(source, size, pool)
+	})
+}
@@ -0,0 +1,75 @@
+package imagor
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// hostSemaphore weighted semaphore keyed by load source host,
+// evicts semaphores of hosts without holders or waiters
+type hostSemaphore struct {
+	n int64
+
+	mu    sync.Mutex
+	semas map[string]*hostSema
+}
+
+type hostSema struct {
+	sema *semaphore.Weighted
+	refs int
+}
+
+func newHostSemaphore(n int64) *hostSemaphore {
+	return &hostSemaphore{
+		n:     n,
+		semas: map[string]*hostSema{},
+	}
+}
+
+// Acquire blocks until a slot of host is acquired or context done,
+// returns release func to be called once done
+func (h *hostSemaphore) Acquire(ctx context.Context, host string) (func(), error) {
+	h.mu.Lock()
+	hs, ok := h.semas[host]
+	if !ok {
+		hs = &hostSema{sema: semaphore.NewWeighted(h.n)}
+		h.semas[host] = hs
+	}
+	hs.refs++
+	h.mu.Unlock()
+	if err := hs.sema.Acquire(ctx, 1); err != nil {
+		h.unref(host, hs)
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			hs.sema.Release(1)
+			h.unref(host, hs)
+		})
+	}, nil
+}
+
+func (h *hostSemaphore) unref(host string, hs *hostSema) {
+	h.mu.Lock()
+	hs.refs--
+	if hs.refs <= 0 {
+		delete(h.semas, host)
+	}
+	h.mu.Unlock()
+}
+
+// getHost returns host of image key, or the first path segment if not URL
+func getHost(image string) string {
+	if i := strings.Index(image, "://"); i >= 0 {
+		image = image[i+3:]
+	}
+	image = strings.TrimPrefix(image, "/")
+	if i := strings.IndexAny(image, "/?#"); i >= 0 {
+		image = image[:i]
+	}
+	return strings.ToLower(image)
+}
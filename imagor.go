@@ -2,17 +2,21 @@ package imagor
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cshum/imagor/imagorpath"
@@ -24,6 +28,15 @@ import (
 // Version imagor version
 const Version = "1.4.16"
 
+// BasePathHandler modes for imagor / base path
+const (
+	BasePathModeStatus    = "status"
+	BasePathModeRedirect  = "redirect"
+	BasePathModeJSON      = "json"
+	BasePathModeNoContent = "nocontent"
+	BasePathModeNotFound  = "notfound"
+)
+
 // Loader image loader interface
 type Loader interface {
 	Get(r *http.Request, key string) (*Blob, error)
@@ -44,6 +57,14 @@ type Storage interface {
 	Delete(ctx context.Context, key string) error
 }
 
+// statter loader or storage that supports Stat
+type statter interface {
+	Stat(ctx context.Context, key string) (*Stat, error)
+}
+
+// healthCheckKey sentinel key for health check Stat
+const healthCheckKey = "imagor-health-check"
+
 // LoadFunc function handler for Processor to call loader
 type LoadFunc func(string) (*Blob, error)
 
@@ -61,13 +82,44 @@ type Processor interface {
 	Shutdown(ctx context.Context) error
 }
 
+// ConversionObserver observes source to output BlobType conversions of processed images
+type ConversionObserver interface {
+	ObserveConversion(from, to BlobType, duration time.Duration, inSize, outSize int64)
+}
+
+// UnsignedRequestObserver observes requests without URL signature allowed by permissive signing mode
+type UnsignedRequestObserver interface {
+	ObserveUnsignedRequest()
+}
+
+// SigningMode URL signature verification mode
+type SigningMode string
+
+const (
+	// SigningModeEnforce requires valid URL signature
+	SigningModeEnforce SigningMode = "enforce"
+	// SigningModePermissive allows request without URL signature,
+	// while request with invalid signature still fails
+	SigningModePermissive SigningMode = "permissive"
+)
+
+// AllowedSize allowed output dimensions preset
+type AllowedSize struct {
+	Width  int
+	Height int
+}
+
 // Imagor main application
 type Imagor struct {
 	Unsafe                 bool
 	Signer                 imagorpath.Signer
+	PathSigners            map[string]imagorpath.Signer
+	PathSignersStrict      bool
+	SigningMode            SigningMode
 	StoragePathStyle       imagorpath.StorageHasher
 	ResultStoragePathStyle imagorpath.ResultStorageHasher
 	BasePathRedirect       string
+	BasePathHandler        string
 	Loaders                []Loader
 	Storages               []Storage
 	ResultStorages         []Storage
@@ -76,22 +128,46 @@ type Imagor struct {
 	LoadTimeout            time.Duration
 	SaveTimeout            time.Duration
 	ProcessTimeout         time.Duration
+	MaxProcessTimeout      time.Duration
 	CacheHeaderTTL         time.Duration
 	CacheHeaderSWR         time.Duration
 	ProcessConcurrency     int64
 	ProcessQueueSize       int64
+	LoadConcurrencyPerHost int64
+	ProcessPriorityAging   time.Duration
 	AutoWebP               bool
 	AutoAVIF               bool
+	AutoFormats            []string
+	SaveDataMode           bool
+	SaveDataQuality        int
 	ModifiedTimeCheck      bool
 	DisableErrorBody       bool
+	VerboseErrors          bool
 	DisableParamsEndpoint  bool
+	EnableTarEndpoint      bool
+	EnableStatEndpoint     bool
+	EnableBatchEndpoint    bool
+	AllowedSizes           []AllowedSize
+	AllowedSizesSnap       bool
+	ConversionObserver     ConversionObserver
+	UnsignedObserver       UnsignedRequestObserver
+	ResultSavedHook        func(ctx context.Context, key string, blob *Blob) error
+	ContextCacheMaxEntries int
+	ContextCacheMaxBytes   int64
+	MaxDecompressedBytes   int64
+	AllowedTypes           []BlobType
+	MaxResolution          float64
 	BaseParams             string
+	KeyNormalizer          func(string) string
+	DefaultGravity         string
+	Clock                  func() time.Time
 	Logger                 *zap.Logger
 	Debug                  bool
 
 	g          singleflight.Group
-	sema       *semaphore.Weighted
+	sema       *prioritySemaphore
 	queueSema  *semaphore.Weighted
+	hostSema   *hostSemaphore
 	baseParams imagorpath.Params
 }
 
@@ -105,20 +181,28 @@ func New(options ...Option) *Imagor {
 		ProcessTimeout: time.Second * 20,
 		CacheHeaderTTL: time.Hour * 24 * 7,
 		CacheHeaderSWR: time.Hour * 24,
+
+		SaveDataQuality: 50,
 	}
 	for _, option := range options {
 		option(app)
 	}
 	if app.ProcessConcurrency > 0 {
-		app.sema = semaphore.NewWeighted(app.ProcessConcurrency)
+		app.sema = newPrioritySemaphore(app.ProcessConcurrency, app.ProcessPriorityAging)
 		app.queueSema = semaphore.NewWeighted(app.ProcessQueueSize + app.ProcessConcurrency)
 	}
+	if app.LoadConcurrencyPerHost > 0 {
+		app.hostSema = newHostSemaphore(app.LoadConcurrencyPerHost)
+	}
 	if app.Debug {
 		app.debugLog()
 	}
 	if app.Signer == nil {
 		app.Signer = imagorpath.NewDefaultSigner("")
 	}
+	if app.Clock == nil {
+		app.Clock = time.Now
+	}
 	app.BaseParams = strings.TrimSpace(app.BaseParams)
 	if app.BaseParams != "" {
 		app.BaseParams = strings.TrimSuffix(app.BaseParams, "/") + "/"
@@ -136,6 +220,31 @@ func (app *Imagor) Startup(ctx context.Context) (err error) {
 	return
 }
 
+// CheckHealth checks if storages, result storages and loaders that support Stat are reachable,
+// by Stat a sentinel key where not found is considered healthy.
+// Loaders without Stat e.g. HTTP Loader are not checked
+func (app *Imagor) CheckHealth(ctx context.Context) error {
+	var statters []statter
+	for _, loader := range app.Loaders {
+		if s, ok := loader.(statter); ok {
+			statters = append(statters, s)
+		}
+	}
+	for _, storage := range app.Storages {
+		statters = append(statters, storage)
+	}
+	for _, storage := range app.ResultStorages {
+		statters = append(statters, storage)
+	}
+	for _, s := range statters {
+		if _, err := s.Stat(ctx, healthCheckKey); err != nil &&
+			err != ErrNotFound && err != ErrInvalid {
+			return err
+		}
+	}
+	return nil
+}
+
 // Shutdown Imagor shutdown lifecycle
 func (app *Imagor) Shutdown(ctx context.Context) (err error) {
 	for _, processor := range app.Processors {
@@ -148,18 +257,25 @@ func (app *Imagor) Shutdown(ctx context.Context) (err error) {
 
 // ServeHTTP implements http.Handler for imagor operations
 func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if app.EnableTarEndpoint && r.Method == http.MethodPost && r.URL.Path == "/tar" {
+		app.serveTar(w, r)
+		return
+	}
+	if app.EnableBatchEndpoint && r.Method == http.MethodPost && r.URL.Path == "/batch" {
+		app.serveBatch(w, r)
+		return
+	}
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 	path := r.URL.EscapedPath()
 	if path == "/" || path == "" {
-		if app.BasePathRedirect == "" {
-			w.Header().Set("Content-Type", "text/html")
-			_, _ = w.Write([]byte(landing))
-		} else {
-			http.Redirect(w, r, app.BasePathRedirect, http.StatusTemporaryRedirect)
-		}
+		app.serveBasePath(w, r)
+		return
+	}
+	if app.EnableStatEndpoint && r.Method == http.MethodHead && strings.HasPrefix(path, "/stat/") {
+		app.serveStat(w, r, strings.TrimPrefix(path, "/stat"))
 		return
 	}
 	p := imagorpath.Parse(path)
@@ -178,17 +294,7 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			w.WriteHeader(499)
-			return
-		}
-		e := WrapError(err)
-		if app.DisableErrorBody {
-			w.WriteHeader(e.Code)
-			return
-		}
-		w.WriteHeader(e.Code)
-		writeJSON(w, r, e)
+		app.writeError(w, r, err)
 		return
 	}
 	if isBlobEmpty(blob) {
@@ -196,10 +302,13 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", blob.ContentType())
 	w.Header().Set("Content-Disposition", getContentDisposition(p, blob))
-	setCacheHeaders(w, r, getTtl(p, app.CacheHeaderTTL), app.CacheHeaderSWR)
+	setCacheHeaders(w, r, getTtl(p, getBlobTtl(blob, app.CacheHeaderTTL), app.Clock()), app.CacheHeaderSWR)
 	if r.Header.Get("Imagor-Auto-Format") != "" {
 		w.Header().Add("Vary", "Accept")
 	}
+	if r.Header.Get("Imagor-Save-Data") != "" {
+		w.Header().Add("Vary", "Save-Data")
+	}
 	if r.Header.Get("Imagor-Raw") != "" {
 		w.Header().Set("Content-Security-Policy", "script-src 'none'")
 	}
@@ -208,16 +317,124 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set(key, h.Get(key))
 		}
 	}
-	if checkStatNotModified(w, r, blob.Stat) {
+	stat := blob.Stat
+	if (stat == nil || stat.ETag == "") && r.Header.Get("Imagor-Raw") == "" && blob.isInMemory() {
+		// strong ETag of in-memory content, stable across restarts for identical output.
+		// processed results have it computed once by Do
+		if etag := getBlobETag(blob); etag != "" {
+			if stat != nil {
+				s := *stat
+				stat = &s
+			} else {
+				stat = &Stat{}
+			}
+			stat.ETag = etag
+		}
+	}
+	if checkStatNotModified(w, r, stat) {
 		w.WriteHeader(http.StatusNotModified)
 		return
 	}
+	if r.Header.Get("Range") != "" && serveRange(w, r, blob) {
+		return
+	}
 	reader, size, _ := blob.NewReader()
 	writeBody(w, r, reader, size)
 	return
 }
 
-// Serve serves imagor by context and params
+// serveRange serves byte range request of Blob with known size,
+// returns false if not seekable for falling back to full body
+func serveRange(w http.ResponseWriter, r *http.Request, blob *Blob) bool {
+	if blob.Size() <= 0 {
+		return false
+	}
+	rs, size, err := blob.NewReadSeeker()
+	if err != nil || size <= 0 {
+		if rs != nil {
+			_ = rs.Close()
+		}
+		return false
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+	http.ServeContent(w, r, "", time.Time{}, rs)
+	return true
+}
+
+// serveStat serves HEAD request of result storage Stat of imagor path without processing,
+// empty body with Content-Length and Last-Modified headers on hit
+func (app *Imagor) serveStat(w http.ResponseWriter, r *http.Request, path string) {
+	stat, err := app.resultStat(r, imagorpath.Parse(path))
+	if err == ErrInvalid || err == ErrSignatureMismatch {
+		if path2, e := url.QueryUnescape(path); e == nil {
+			stat, err = app.resultStat(r, imagorpath.Parse(path2))
+		}
+	}
+	if err != nil {
+		app.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(stat.Size, 10))
+	if !stat.ModifiedTime.IsZero() {
+		w.Header().Set("Last-Modified", stat.ModifiedTime.Format(http.TimeFormat))
+	}
+	if stat.ETag != "" {
+		w.Header().Set("ETag", stat.ETag)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (app *Imagor) resultStat(r *http.Request, p imagorpath.Params) (*Stat, error) {
+	r = r.Clone(r.Context()) // result params may set request headers
+	_, opts, err := app.resultParams(r, p)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ResultKey == "" || opts.IsRaw {
+		return nil, ErrNotFound
+	}
+	for _, storage := range app.ResultStorages {
+		if stat, err := storage.Stat(r.Context(), opts.ResultKey); stat != nil && err == nil {
+			return stat, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// serveBasePath serves imagor / base path based on BasePathHandler mode
+func (app *Imagor) serveBasePath(w http.ResponseWriter, r *http.Request) {
+	mode := app.BasePathHandler
+	if mode == "" && app.BasePathRedirect != "" {
+		mode = BasePathModeRedirect
+	}
+	switch mode {
+	case BasePathModeRedirect:
+		if app.BasePathRedirect != "" {
+			http.Redirect(w, r, app.BasePathRedirect, http.StatusTemporaryRedirect)
+			return
+		}
+	case BasePathModeJSON:
+		writeJSON(w, r, map[string]string{
+			"status":  "ok",
+			"version": Version,
+		})
+		return
+	case BasePathModeNoContent:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case BasePathModeNotFound:
+		app.writeError(w, r, ErrNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = w.Write([]byte(landing))
+}
+
+// Serve serves imagor by context and params through the load, process and save pipeline,
+// returns the resulting Blob with resolved content type.
+// ctx should be wrapped by WithContext and canceled after the Blob consumed for clean up
 func (app *Imagor) Serve(ctx context.Context, p imagorpath.Params) (*Blob, error) {
 	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
 	if err != nil {
@@ -240,105 +457,141 @@ func (app *Imagor) ServeBlob(
 	return app.Serve(ctx, p)
 }
 
+// checkDecompressedSize rejects image with header declared dimensions, bands and bit depth
+// that decompress beyond MaxDecompressedBytes, before processor allocation
+func (app *Imagor) checkDecompressedSize(blob *Blob) error {
+	if app.MaxDecompressedBytes <= 0 || isBlobEmpty(blob) {
+		return nil
+	}
+	meta, err := blob.Metadata()
+	if err != nil {
+		// cannot be determined from header, leave it to processor guards
+		return nil
+	}
+	if meta.DecompressedSize() > app.MaxDecompressedBytes {
+		return ErrMaxDecompressedBytesExceeded
+	}
+	return nil
+}
+
+// checkAllowedType rejects source image of sniffed type not in AllowedTypes,
+// regardless of content type claimed by the origin
+func (app *Imagor) checkAllowedType(blob *Blob) error {
+	if len(app.AllowedTypes) == 0 || isBlobEmpty(blob) {
+		return nil
+	}
+	blobType := blob.BlobType()
+	for _, t := range app.AllowedTypes {
+		if t == blobType {
+			return nil
+		}
+	}
+	return ErrTypeNotAllowed.WithDetail(blobType.String())
+}
+
+// exceedsResolution checks if dimensions exceed MaxResolution megapixels
+func (app *Imagor) exceedsResolution(width, height int) bool {
+	return app.MaxResolution > 0 &&
+		float64(abs(width))*float64(abs(height)) > app.MaxResolution*1000000
+}
+
+// checkTargetResolution rejects target output dimensions including padding
+// exceeding MaxResolution, if both dimensions determined by params
+func (app *Imagor) checkTargetResolution(p imagorpath.Params) error {
+	if p.Width == 0 || p.Height == 0 {
+		return nil
+	}
+	if app.exceedsResolution(
+		abs(p.Width)+p.PaddingLeft+p.PaddingRight,
+		abs(p.Height)+p.PaddingTop+p.PaddingBottom) {
+		return ErrMaxResolutionExceeded
+	}
+	return nil
+}
+
+// checkResolution rejects source image with header declared dimensions exceeding MaxResolution,
+// or target output dimensions derived from source aspect ratio exceeding MaxResolution
+func (app *Imagor) checkResolution(blob *Blob, p imagorpath.Params) error {
+	if app.MaxResolution <= 0 || isBlobEmpty(blob) {
+		return nil
+	}
+	meta, err := blob.Metadata()
+	if err != nil || meta.Width <= 0 || meta.Height <= 0 {
+		// cannot be determined from header, leave it to processor guards
+		return nil
+	}
+	if app.exceedsResolution(meta.Width, meta.Height) {
+		return ErrMaxResolutionExceeded
+	}
+	width, height := meta.Width, meta.Height
+	if meta.Swapped() {
+		width, height = height, width
+	}
+	if w, h := abs(p.Width), abs(p.Height); w > 0 && h == 0 {
+		p.Height = int(float64(w) * float64(height) / float64(width))
+	} else if h > 0 && w == 0 {
+		p.Width = int(float64(h) * float64(width) / float64(height))
+	} else {
+		return nil
+	}
+	return app.checkTargetResolution(p)
+}
+
 // Do executes imagor operations
 func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err error) {
 	var ctx = withContext(r.Context())
+	withContextCacheLimit(ctx, app.ContextCacheMaxEntries, app.ContextCacheMaxBytes)
+	withContextLogger(ctx, app.Logger)
 	var cancel func()
 	if app.RequestTimeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, app.RequestTimeout)
 		contextDefer(ctx, cancel)
 		r = r.WithContext(ctx)
 	}
-	if !(app.Unsafe && p.Unsafe) && app.Signer != nil && p.Path != "" {
-		if hash := app.Signer.Sign(p.Path); hash != p.Hash {
-			err = ErrSignatureMismatch
-			if app.Debug {
-				app.Logger.Debug("sign-mismatch", zap.Any("params", p), zap.String("expected", hash))
-			}
-			return
-		}
-	}
-	var isPathChanged bool
-	if app.BaseParams != "" {
-		p = imagorpath.Apply(p, app.BaseParams)
-		isPathChanged = true
+	var opts requestOptions
+	if p, opts, err = app.resultParams(r, p); err != nil {
+		return
 	}
-	var hasFormat, hasPreview, isRaw bool
-	var filters = p.Filters
-	p.Filters = nil
-	for _, f := range filters {
-		switch f.Name {
-		case "expire":
-			// expire(timestamp) filter
-			if ts, e := strconv.ParseInt(f.Args, 10, 64); e == nil {
-				if exp := time.UnixMilli(ts); !exp.IsZero() && time.Now().After(exp) {
-					err = ErrExpired
-					return
+	var resultKey, isRaw = opts.ResultKey, opts.IsRaw
+	var subLoad atomic.Int64
+	var scoped = len(app.PathSigners) > 0 && app.isSigned(p)
+	var scope, _ = app.pathSignerPrefix(p.Image)
+	load := func(image string) (*Blob, error) {
+		if scoped {
+			// sub-loads e.g. watermarks limited to the path signer scope of the image
+			if prefix, ok := app.pathSignerPrefix(image); !ok || prefix != scope {
+				if app.Debug {
+					app.Logger.Debug("sign-prefix-load", zap.String("image", image), zap.String("scope", scope))
 				}
-				r.Header.Set("Cache-Control", "private")
+				return nil, ErrSignatureMismatch
 			}
-		case "format":
-			hasFormat = true
-		case "raw":
-			r.Header.Set("Imagor-Raw", "1")
-			isRaw = true
-		case "preview":
-			r.Header.Set("Cache-Control", "no-cache")
-			hasPreview = true // disable result storage on preview() filter
 		}
-		// exclude utility filters from result path
-		switch f.Name {
-		case "expire", "attachment":
-			isPathChanged = true
-		default:
-			p.Filters = append(p.Filters, f)
+		if app.KeyNormalizer != nil {
+			image = app.KeyNormalizer(image)
 		}
-	}
-	// auto WebP / AVIF
-	if !hasFormat && (app.AutoWebP || app.AutoAVIF) {
-		accept := r.Header.Get("Accept")
-		if app.AutoAVIF && strings.Contains(accept, "image/avif") {
-			p.Filters = append(p.Filters, imagorpath.Filter{
-				Name: "format",
-				Args: "avif",
-			})
-			r.Header.Set("Imagor-Auto-Format", "avif") // response Vary: Accept header
-			isPathChanged = true
-		} else if app.AutoWebP && strings.Contains(accept, "image/webp") {
-			p.Filters = append(p.Filters, imagorpath.Filter{
-				Name: "format",
-				Args: "webp",
-			})
-			r.Header.Set("Imagor-Auto-Format", "webp") // response Vary: Accept header
-			isPathChanged = true
+		// sub-loads e.g. watermarks timed apart from load and process
+		var start = time.Now()
+		blob, _, err := app.loadStorage(r, image)
+		var took = time.Since(start)
+		subLoad.Add(int64(took))
+		ContextTiming(ctx, "subload", took)
+		if err == nil {
+			err = app.checkAllowedType(blob)
 		}
-	}
-	if isPathChanged || p.Path == "" {
-		p.Path = imagorpath.GeneratePath(p)
-	}
-	if p.Width < 0 {
-		p.Width = -p.Width
-		p.HFlip = !p.HFlip
-	}
-	if p.Height < 0 {
-		p.Height = -p.Height
-		p.VFlip = !p.VFlip
-	}
-	var resultKey string
-	if p.Image != "" && !hasPreview {
-		if app.ResultStoragePathStyle != nil {
-			resultKey = app.ResultStoragePathStyle.HashResult(p)
-		} else {
-			resultKey = p.Path
+		if err == nil {
+			err = app.checkResolution(blob, imagorpath.Params{})
+		}
+		if err == nil {
+			err = app.checkDecompressedSize(blob)
 		}
-	}
-	load := func(image string) (*Blob, error) {
-		blob, _, err := app.loadStorage(r, image)
 		return blob, err
 	}
-	return app.suppress(ctx, resultKey, func(ctx context.Context, cb func(*Blob, error)) (*Blob, error) {
+	return app.suppress(ctx, resultKey, func(ctx context.Context, cb func(*Blob, error)) (blob *Blob, err error) {
 		if resultKey != "" && !isRaw {
-			if blob := app.loadResult(r, resultKey, p.Image); blob != nil {
+			var start = time.Now()
+			blob := app.loadResult(r, resultKey, p.Image)
+			ContextTiming(ctx, "result", time.Since(start))
+			if blob != nil {
 				return blob, nil
 			}
 		}
@@ -352,17 +605,32 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 			}
 			defer app.queueSema.Release(1)
 		}
+		if app.hostSema != nil && p.Image != "" {
+			// per host slot acquired before global slot,
+			// so that waiting on a busy host does not hold a global slot
+			release, err := app.hostSema.Acquire(ctx, getHost(p.Image))
+			if err != nil {
+				if app.Debug {
+					app.Logger.Debug("host-acquire", zap.Error(err))
+				}
+				return blob, err
+			}
+			defer release()
+		}
 		if app.sema != nil && !isRaw {
-			if err = app.sema.Acquire(ctx, 1); err != nil {
+			if err = app.sema.Acquire(ctx, opts.Priority); err != nil {
 				if app.Debug {
 					app.Logger.Debug("acquire", zap.Error(err))
 				}
 				return blob, err
 			}
-			defer app.sema.Release(1)
+			defer app.sema.Release()
 		}
 		var shouldSave bool
-		if blob, shouldSave, err = app.loadStorage(r, p.Image); err != nil {
+		var start = time.Now()
+		blob, shouldSave, err = app.loadStorage(r, p.Image)
+		ContextTiming(ctx, "load", time.Since(start))
+		if err != nil {
 			if app.Debug {
 				app.Logger.Debug("load", zap.Any("params", p), zap.Error(err))
 			}
@@ -375,26 +643,57 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 			if app.StoragePathStyle != nil {
 				storageKey = app.StoragePathStyle.Hash(p.Image)
 			}
-			go func(blob *Blob) {
+			go func(ctx context.Context, blob *Blob) {
+				var start = time.Now()
 				app.save(ctx, app.Storages, storageKey, blob)
+				// recorded before response, result storage save afterwards is not timed
+				ContextTiming(ctx, "save", time.Since(start))
 				close(doneSave)
-			}(blob)
+			}(ctx, blob)
 		}
 		if isBlobEmpty(blob) {
 			return blob, err
 		}
 		if !isRaw {
+			// sniffed type, resolution and decompression bomb guards before processing
+			if err = app.checkAllowedType(blob); err == nil {
+				err = app.checkResolution(blob, p)
+			}
+			if err == nil {
+				err = app.checkDecompressedSize(blob)
+			}
+		}
+		if !isRaw && err == nil {
 			var cancel func()
-			if app.ProcessTimeout > 0 {
-				ctx, cancel = context.WithTimeout(ctx, app.ProcessTimeout)
+			if opts.ProcessTimeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, opts.ProcessTimeout)
 				contextDefer(ctx, cancel)
 			}
 			var forwardP = p
+			if opts.AutoFormat != "" && !isAnimationFormat(opts.AutoFormat) && blob.SupportsAnimation() {
+				// keep animation of animated source, instead of auto format without animation support
+				forwardP = replaceAutoFormat(forwardP, opts.AnimatedFormat)
+			}
+			var source = blob
+			var start = time.Now()
+			var startSubLoad = subLoad.Load()
 			for _, processor := range app.Processors {
 				b, e := checkBlob(processor.Process(ctx, blob, forwardP, load))
 				if !isBlobEmpty(b) {
-					if blob != nil && blob.Header != nil && b.Header == nil {
-						b.Header = blob.Header // forward blob Header
+					if blob != nil && blob.Header != nil {
+						if b.Header == nil {
+							b.Header = blob.Header // forward blob Header
+						} else {
+							// merge blob Header not set by processor
+							for key, values := range blob.Header {
+								if _, ok := b.Header[key]; !ok {
+									b.Header[key] = values
+								}
+							}
+						}
+					}
+					if blob != nil && blob.CacheTTL != 0 && b.CacheTTL == 0 {
+						b.CacheTTL = blob.CacheTTL // forward origin cache TTL
 					}
 					blob = b // forward Blob to next processor if exists
 				}
@@ -421,6 +720,14 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 					break
 				}
 			}
+			ContextTiming(ctx, "process", max(0, time.Since(start)-time.Duration(subLoad.Load()-startSubLoad)))
+			if err == nil && app.ConversionObserver != nil && !isBlobEmpty(blob) && blob != source {
+				app.ConversionObserver.ObserveConversion(
+					source.BlobType(), blob.BlobType(), time.Since(start), source.Size(), blob.Size())
+			}
+			if err == nil && !isRaw && blob != source {
+				setBlobETag(blob)
+			}
 		}
 		if shouldSave {
 			// make sure storage saved before response and result storage
@@ -430,7 +737,13 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 		ctx = detachContext(ctx)
 		if err == nil && !isBlobEmpty(blob) && resultKey != "" && !isRaw &&
 			len(app.ResultStorages) > 0 {
-			app.save(ctx, app.ResultStorages, resultKey, blob)
+			saveErr := app.save(ctx, app.ResultStorages, resultKey, blob)
+			if saveErr == nil && app.ResultSavedHook != nil {
+				go app.resultSaved(ctx, resultKey, blob)
+			}
+			if save, ok := ctx.Value(resultSaveContextKey).(*resultSave); ok {
+				save.Err = saveErr
+			}
 		}
 		if err != nil && shouldSave {
 			var storageKey = p.Image
@@ -443,6 +756,368 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 	})
 }
 
+// requestOptions options of imagor request derived from params
+type requestOptions struct {
+	ResultKey      string
+	IsRaw          bool
+	ProcessTimeout time.Duration
+	Priority       int
+	AutoFormat     string
+	AnimatedFormat string
+}
+
+// resultParams checks signature, applies base params, allowed sizes and filters,
+// returns params to be processed with request options
+func (app *Imagor) resultParams(
+	r *http.Request, p imagorpath.Params,
+) (_ imagorpath.Params, opts requestOptions, err error) {
+	opts.ProcessTimeout = app.ProcessTimeout
+	opts.Priority = contextPriority(r.Context())
+	if app.KeyNormalizer != nil && p.Image != "" {
+		// normalized form is what's signed and used for result key
+		if image := app.KeyNormalizer(p.Image); image != p.Image {
+			p.Image = image
+			p.Path = imagorpath.GeneratePath(p)
+		}
+	}
+	if err = app.checkSignature(p); err != nil {
+		return
+	}
+	var isPathChanged bool
+	if app.BaseParams != "" {
+		p = imagorpath.Apply(p, app.BaseParams)
+		isPathChanged = true
+	}
+	if len(app.AllowedSizes) > 0 {
+		var changed bool
+		if p, changed, err = app.checkAllowedSize(p); err != nil {
+			return
+		} else if changed {
+			isPathChanged = true
+		}
+	}
+	if err = app.checkTargetResolution(p); err != nil {
+		return
+	}
+	var hasFormat, hasQuality, hasPreview, hasFocal bool
+	var filters = p.Filters
+	p.Filters = nil
+	for _, f := range filters {
+		switch f.Name {
+		case "expire":
+			// expire(timestamp) filter
+			if ts, e := strconv.ParseInt(f.Args, 10, 64); e == nil {
+				if exp := time.UnixMilli(ts); !exp.IsZero() && app.Clock().After(exp) {
+					err = ErrExpired
+					return
+				}
+				r.Header.Set("Cache-Control", "private")
+			}
+		case "format":
+			hasFormat = true
+		case "quality":
+			hasQuality = true
+		case "focal", "smart":
+			hasFocal = true // focal or smart() filter overrides default gravity
+		case "raw":
+			r.Header.Set("Imagor-Raw", "1")
+			opts.IsRaw = true
+		case "preview":
+			r.Header.Set("Cache-Control", "no-cache")
+			hasPreview = true // disable result storage on preview() filter
+		case "priority":
+			// priority(n) filter, only honored for signed request if process priority enabled
+			if n, e := strconv.Atoi(f.Args); e == nil && app.ProcessPriorityAging > 0 && app.isSigned(p) {
+				opts.Priority = n
+			}
+		case "timeout":
+			// timeout(seconds) filter, only honored for signed request, clamped by max
+			if secs, e := strconv.ParseFloat(f.Args, 64); e == nil && secs > 0 &&
+				app.MaxProcessTimeout > 0 && app.isSigned(p) {
+				opts.ProcessTimeout = time.Duration(secs * float64(time.Second))
+				if opts.ProcessTimeout > app.MaxProcessTimeout {
+					opts.ProcessTimeout = app.MaxProcessTimeout
+				}
+			}
+		}
+		// exclude utility filters from result path
+		switch f.Name {
+		case "expire", "attachment", "timeout", "priority":
+			isPathChanged = true
+		default:
+			p.Filters = append(p.Filters, f)
+		}
+	}
+	// default crop gravity, explicit alignment, smart crop or focal wins
+	if app.DefaultGravity != "" && p.HAlign == "" && p.VAlign == "" && !p.Smart && !hasFocal {
+		if hAlign, vAlign, ok := parseGravity(app.DefaultGravity); ok && (hAlign != "" || vAlign != "") {
+			p.HAlign = hAlign
+			p.VAlign = vAlign
+			isPathChanged = true
+		}
+	}
+	// auto format by Accept header e.g. AVIF, WebP
+	if formats := app.autoFormats(); !hasFormat && len(formats) > 0 {
+		if accepted := negotiateFormats(r.Header.Get("Accept"), formats); len(accepted) > 0 {
+			p.Filters = append(p.Filters, imagorpath.Filter{
+				Name: "format",
+				Args: accepted[0],
+			})
+			r.Header.Set("Imagor-Auto-Format", accepted[0]) // response Vary: Accept header
+			isPathChanged = true
+			opts.AutoFormat = accepted[0]
+			for _, format := range accepted {
+				if isAnimationFormat(format) {
+					opts.AnimatedFormat = format
+					break
+				}
+			}
+		}
+	}
+	// Save-Data client hint, lower quality unless explicitly specified
+	if app.SaveDataMode && !hasQuality {
+		r.Header.Set("Imagor-Save-Data", "1") // response Vary: Save-Data header
+		if strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on") {
+			p.Filters = append(p.Filters, imagorpath.Filter{
+				Name: "quality",
+				Args: strconv.Itoa(app.SaveDataQuality),
+			})
+			isPathChanged = true
+		}
+	}
+	if isPathChanged || p.Path == "" {
+		p.Path = imagorpath.GeneratePath(p)
+	}
+	if p.Width < 0 {
+		p.Width = -p.Width
+		p.HFlip = !p.HFlip
+	}
+	if p.Height < 0 {
+		p.Height = -p.Height
+		p.VFlip = !p.VFlip
+	}
+	if p.Image != "" && !hasPreview {
+		if app.ResultStoragePathStyle != nil {
+			opts.ResultKey = app.ResultStoragePathStyle.HashResult(p)
+		} else {
+			opts.ResultKey = p.Path
+		}
+	}
+	return p, opts, nil
+}
+
+// resultSaved calls result saved hook, errors are logged but not propagated
+func (app *Imagor) resultSaved(ctx context.Context, key string, blob *Blob) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			app.Logger.Error("result-saved-hook-panic", zap.String("key", key), zap.Any("panic", rec))
+		}
+	}()
+	if err := app.ResultSavedHook(ctx, key, blob); err != nil {
+		app.Logger.Warn("result-saved-hook", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// isSigned returns if params are verified by URL signature
+func (app *Imagor) isSigned(p imagorpath.Params) bool {
+	return app.Signer != nil && !p.Unsafe && p.Hash != ""
+}
+
+func (app *Imagor) checkSignature(p imagorpath.Params) error {
+	if !(app.Unsafe && p.Unsafe) && app.Signer != nil && p.Path != "" {
+		if p.Hash == "" && app.SigningMode == SigningModePermissive {
+			// allow unsigned request during migration, tracked for remaining unsigned traffic
+			if app.Debug {
+				app.Logger.Debug("unsigned", zap.String("path", p.Path))
+			}
+			if app.UnsignedObserver != nil {
+				app.UnsignedObserver.ObserveUnsignedRequest()
+			}
+			return nil
+		}
+		signer, ok := app.getSigner(p.Image)
+		if !ok {
+			if app.Debug {
+				app.Logger.Debug("sign-prefix-unknown", zap.Any("params", p))
+			}
+			return ErrSignatureMismatch
+		}
+		if verifier, ok := signer.(imagorpath.Verifier); ok {
+			if !verifier.Verify(p.Path, p.Hash) {
+				if app.Debug {
+					app.Logger.Debug("sign-mismatch", zap.Any("params", p))
+				}
+				return ErrSignatureMismatch
+			}
+		} else if hash := signer.Sign(p.Path); hash != p.Hash {
+			if app.Debug {
+				app.Logger.Debug("sign-mismatch", zap.Any("params", p), zap.String("expected", hash))
+			}
+			return ErrSignatureMismatch
+		}
+	}
+	return nil
+}
+
+// getSigner returns signer of the image path prefix by leading path segment,
+// falls back to the default signer unless strict
+func (app *Imagor) getSigner(image string) (imagorpath.Signer, bool) {
+	if len(app.PathSigners) == 0 {
+		return app.Signer, true
+	}
+	prefix, ok := app.pathSignerPrefix(image)
+	if !ok {
+		return nil, false
+	}
+	if prefix != "" {
+		return app.PathSigners[prefix], true
+	}
+	if app.PathSignersStrict {
+		return nil, false
+	}
+	return app.Signer, true
+}
+
+// pathSignerPrefix returns the leading path segment of image if scoped by path signer,
+// or empty for the default signer. Rejects image with ".." segments,
+// or scoped image that changes under path.Clean, that could escape the prefix
+func (app *Imagor) pathSignerPrefix(image string) (string, bool) {
+	image = strings.TrimPrefix(image, "/")
+	for _, seg := range strings.Split(image, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	prefix, _, _ := strings.Cut(image, "/")
+	if _, ok := app.PathSigners[prefix]; !ok {
+		return "", true
+	}
+	if path.Clean(image) != image {
+		return "", false
+	}
+	return prefix, true
+}
+
+// checkAllowedSize rejects dimensions not in allowed sizes,
+// or snaps to the nearest allowed size if snap enabled.
+// Original dimensions 0x0 are always allowed
+func (app *Imagor) checkAllowedSize(p imagorpath.Params) (imagorpath.Params, bool, error) {
+	w, h := abs(p.Width), abs(p.Height)
+	if w == 0 && h == 0 {
+		return p, false, nil
+	}
+	var nearest AllowedSize
+	var minDist = -1
+	for _, size := range app.AllowedSizes {
+		if size.Width == w && size.Height == h {
+			return p, false, nil
+		}
+		dw, dh := size.Width-w, size.Height-h
+		// first preset wins on equal distance for deterministic result
+		if dist := dw*dw + dh*dh; minDist < 0 || dist < minDist {
+			nearest = size
+			minDist = dist
+		}
+	}
+	if !app.AllowedSizesSnap {
+		return p, false, ErrSizeNotAllowed
+	}
+	p.Width = sign(p.Width) * nearest.Width
+	p.Height = sign(p.Height) * nearest.Height
+	return p, true, nil
+}
+
+// autoFormats returns auto format priority list,
+// defaults to AVIF and WebP if AutoAVIF and AutoWebP enabled
+func (app *Imagor) autoFormats() []string {
+	if len(app.AutoFormats) > 0 {
+		return app.AutoFormats
+	}
+	var formats []string
+	if app.AutoAVIF {
+		formats = append(formats, "avif")
+	}
+	if app.AutoWebP {
+		formats = append(formats, "webp")
+	}
+	return formats
+}
+
+// negotiateFormats returns formats explicitly accepted by Accept header,
+// ordered by quality values then by priority of formats.
+// Wildcards are not considered as browsers send */* regardless of format support
+func negotiateFormats(accept string, formats []string) (accepted []string) {
+	var weights = map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		var q = 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, val, ok := strings.Cut(param, "="); ok && strings.TrimSpace(key) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if w, ok := weights[mediaType]; !ok || q > w {
+			weights[mediaType] = q
+		}
+	}
+	for _, format := range formats {
+		if weights[formatMimeType(format)] > 0 {
+			accepted = append(accepted, format)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return weights[formatMimeType(accepted[i])] > weights[formatMimeType(accepted[j])]
+	})
+	return
+}
+
+func formatMimeType(format string) string {
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	return "image/" + format
+}
+
+// isAnimationFormat checks if output format supports animation
+func isAnimationFormat(format string) bool {
+	return format == "gif" || format == "webp"
+}
+
+// replaceAutoFormat replaces auto format filter by format, or removes it if format empty
+func replaceAutoFormat(p imagorpath.Params, format string) imagorpath.Params {
+	var filters = make(imagorpath.Filters, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		if f.Name == "format" {
+			// only auto format filter exists, as auto format skipped if format specified
+			if format != "" {
+				filters = append(filters, imagorpath.Filter{Name: "format", Args: format})
+			}
+			continue
+		}
+		filters = append(filters, f)
+	}
+	p.Filters = filters
+	p.Path = imagorpath.GeneratePath(p)
+	return p
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	}
+	return 1
+}
+
 func (app *Imagor) requestWithLoadContext(r *http.Request) *http.Request {
 	var ctx = r.Context()
 	var cancel func()
@@ -491,6 +1166,10 @@ func fromStorages(
 }
 
 func (app *Imagor) loadStorage(r *http.Request, key string) (blob *Blob, shouldSave bool, err error) {
+	if b, ok := getTarSource(r.Context(), key); ok {
+		// loaded and saved by previous entry of tar request
+		return b, false, nil
+	}
 	r = app.requestWithLoadContext(r)
 	var origin Storage
 	blob, origin, err = app.fromStoragesAndLoaders(r, app.Storages, app.Loaders, key)
@@ -498,6 +1177,9 @@ func (app *Imagor) loadStorage(r *http.Request, key string) (blob *Blob, shouldS
 		key != "" && err == nil && len(app.Storages) > 0 {
 		shouldSave = true
 	}
+	if !isBlobEmpty(blob) && key != "" && err == nil {
+		putTarSource(r.Context(), key, blob)
+	}
 	return
 }
 
@@ -549,9 +1231,10 @@ func (app *Imagor) storageStat(ctx context.Context, key string) (stat *Stat, err
 	return
 }
 
-func (app *Imagor) save(ctx context.Context, storages []Storage, key string, blob *Blob) {
+// save puts blob to storages, returns error only if not saved to any of the storages
+func (app *Imagor) save(ctx context.Context, storages []Storage, key string, blob *Blob) error {
 	if key == "" {
-		return
+		return nil
 	}
 	if app.SaveTimeout > 0 {
 		var cancel func()
@@ -559,19 +1242,31 @@ func (app *Imagor) save(ctx context.Context, storages []Storage, key string, blo
 		defer cancel()
 	}
 	var wg sync.WaitGroup
+	var ok atomic.Bool
+	var mu sync.Mutex
+	var errs []error
 	for _, storage := range storages {
 		wg.Add(1)
 		go func(storage Storage) {
 			defer wg.Done()
 			if err := storage.Put(ctx, key, blob); err != nil {
 				app.Logger.Warn("save", zap.String("key", key), zap.Error(err))
-			} else if app.Debug {
-				app.Logger.Debug("saved", zap.String("key", key))
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			} else {
+				ok.Store(true)
+				if app.Debug {
+					app.Logger.Debug("saved", zap.String("key", key))
+				}
 			}
 		}(storage)
 	}
 	wg.Wait()
-	return
+	if ok.Load() {
+		return nil
+	}
+	return errors.Join(errs...)
 }
 
 func (app *Imagor) del(ctx context.Context, storages []Storage, key string) {
@@ -603,6 +1298,12 @@ type suppressKey struct {
 
 func blobNoop(*Blob, error) {}
 
+// suppressResult singleflight result of suppressed fn
+type suppressResult struct {
+	Blob *Blob
+	Save *resultSave
+}
+
 func (app *Imagor) suppress(
 	ctx context.Context,
 	key string, fn func(ctx context.Context, cb func(*Blob, error)) (*Blob, error),
@@ -623,24 +1324,34 @@ func (app *Imagor) suppress(
 	}
 	isCanceled := false
 	ch := app.g.DoChan(key, func() (v interface{}, err error) {
-		v, err = fn(context.WithValue(ctx, suppressKey{key}, true), cb)
+		// result storage save shared with all callers of the key
+		save := &resultSave{}
+		blob, err := fn(context.WithValue(
+			context.WithValue(ctx, suppressKey{key}, true), resultSaveContextKey, save), cb)
 		if errors.Is(err, context.Canceled) {
 			app.g.Forget(key)
 			isCanceled = true
 		}
-		return v, err
+		return suppressResult{Blob: blob, Save: save}, err
 	})
+	callerSave, waitSave := ctx.Value(resultSaveContextKey).(*resultSave)
+	waitCb := chanCb
+	if waitSave {
+		// wait for result storage save instead of returning once cb
+		waitCb = nil
+	}
 	select {
 	case res := <-ch:
 		if !isCanceled && errors.Is(res.Err, context.Canceled) {
 			// resolve canceled
 			return app.suppress(ctx, key, fn)
 		}
-		if res.Val != nil {
-			return res.Val.(*Blob), res.Err
+		r, _ := res.Val.(suppressResult)
+		if waitSave && r.Save != nil {
+			callerSave.Err = r.Save.Err
 		}
-		return nil, res.Err
-	case res := <-chanCb:
+		return r.Blob, res.Err
+	case res := <-waitCb:
 		return res.Val.(*Blob), res.Err
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -695,7 +1406,7 @@ func checkStatNotModified(w http.ResponseWriter, r *http.Request, stat *Stat) bo
 	if stat == nil || strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
 		return false
 	}
-	var isETagMatch, isNotModified bool
+	var isETagMatched, isNotModified bool
 	var etag = stat.ETag
 	if etag == "" && stat.Size > 0 && !stat.ModifiedTime.IsZero() {
 		etag = fmt.Sprintf(
@@ -703,8 +1414,8 @@ func checkStatNotModified(w http.ResponseWriter, r *http.Request, stat *Stat) bo
 	}
 	if etag != "" {
 		w.Header().Set("ETag", etag)
-		if inm := r.Header.Get("If-None-Match"); inm == etag {
-			isETagMatch = true
+		if inm := r.Header.Get("If-None-Match"); inm != "" && isETagMatch(inm, etag) {
+			isETagMatched = true
 		}
 	}
 	if mTime := stat.ModifiedTime; !mTime.IsZero() {
@@ -722,14 +1433,62 @@ func checkStatNotModified(w http.ResponseWriter, r *http.Request, stat *Stat) bo
 			}
 		}
 	}
-	return isETagMatch || isNotModified
+	return isETagMatched || isNotModified
 }
 
-func getTtl(p imagorpath.Params, defaultTtl time.Duration) time.Duration {
+// isETagMatch returns if If-None-Match list matches etag by weak comparison
+func isETagMatch(inm, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// getBlobETag returns strong ETag from content hash of Blob
+func getBlobETag(blob *Blob) string {
+	sum, err := blob.SHA256()
+	if err != nil || len(sum) < 16 {
+		return ""
+	}
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setBlobETag sets content ETag on Stat of freshly processed in-memory Blob,
+// so that it is hashed once instead of per response
+func setBlobETag(blob *Blob) {
+	if isBlobEmpty(blob) || !blob.isInMemory() || (blob.Stat != nil && blob.Stat.ETag != "") {
+		return
+	}
+	if etag := getBlobETag(blob); etag != "" {
+		if blob.Stat == nil {
+			blob.Stat = &Stat{}
+		}
+		blob.Stat.ETag = etag
+	}
+}
+
+func getBlobTtl(blob *Blob, defaultTtl time.Duration) time.Duration {
+	if defaultTtl == 0 {
+		// no cache configured
+		return 0
+	}
+	if blob.CacheTTL < 0 {
+		return 0
+	} else if blob.CacheTTL > 0 {
+		return blob.CacheTTL
+	}
+	return defaultTtl
+}
+
+func getTtl(p imagorpath.Params, defaultTtl time.Duration, now time.Time) time.Duration {
 	for _, f := range p.Filters {
 		if f.Name == "expire" {
 			if ts, e := strconv.ParseInt(f.Args, 10, 64); e == nil {
-				ttl := (time.UnixMilli(ts).Sub(time.Now()) + time.Second - 1).Truncate(time.Second)
+				ttl := (time.UnixMilli(ts).Sub(now) + time.Second - 1).Truncate(time.Second)
 				if ttl <= defaultTtl {
 					return ttl
 				}
@@ -830,3 +1589,28 @@ func getType(v interface{}) string {
 	}
 	return t.Name()
 }
+
+// parseGravity parses gravity e.g. top, bottom-right into horizontal and vertical align
+func parseGravity(gravity string) (hAlign, vAlign string, ok bool) {
+	for _, s := range strings.FieldsFunc(strings.ToLower(gravity), func(r rune) bool {
+		return r == '-' || r == '_' || r == ','
+	}) {
+		switch s {
+		case imagorpath.HAlignLeft, imagorpath.HAlignRight:
+			if hAlign != "" {
+				return "", "", false
+			}
+			hAlign = s
+		case imagorpath.VAlignTop, imagorpath.VAlignBottom:
+			if vAlign != "" {
+				return "", "", false
+			}
+			vAlign = s
+		case "center", "middle":
+		default:
+			return "", "", false
+		}
+		ok = true
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package imagor
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"crypto/sha256"
@@ -15,8 +16,11 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -116,6 +120,57 @@ func TestWithInternal(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestServePipeline(t *testing.T) {
+	var saved sync.Map
+	var cleanup atomic.Int32
+	app := New(
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromFile("testdata/" + image), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			contextDefer(ctx, func() {
+				cleanup.Add(1)
+			})
+			buf, err := blob.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			out := NewBlobFromBytes(buf)
+			out.SetContentType("image/webp")
+			return out, nil
+		})),
+		WithResultStorages(saverFunc(func(ctx context.Context, image string, blob *Blob) error {
+			saved.Store(image, blob)
+			return nil
+		})))
+	require.NoError(t, app.Startup(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = WithContext(ctx)
+	blob, err := app.Serve(ctx, imagorpath.Params{
+		Image:   "gopher.png",
+		Width:   100,
+		Filters: imagorpath.Filters{{Name: "format", Args: "webp"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "image/webp", blob.ContentType())
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	expected, err := os.ReadFile("testdata/gopher.png")
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf)
+	assert.Eventually(t, func() bool {
+		_, ok := saved.Load("100x0/filters:format(webp)/gopher.png")
+		return ok
+	}, time.Second, time.Millisecond, "should save result")
+	assert.Equal(t, int32(0), cleanup.Load(), "should defer clean up until context done")
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return cleanup.Load() == 1
+	}, time.Second, time.Millisecond)
+}
+
 func TestWithContentDisposition(t *testing.T) {
 	logger := zap.NewExample()
 	app := New(
@@ -236,6 +291,50 @@ func TestWithSigner(t *testing.T) {
 	assert.Equal(t, w.Body.String(), jsonStr(ErrSignatureMismatch))
 }
 
+type unsignedObserverFunc func()
+
+func (f unsignedObserverFunc) ObserveUnsignedRequest() {
+	f()
+}
+
+func TestWithSigningMode(t *testing.T) {
+	var unsigned int
+	app := New(
+		WithLogger(zap.NewExample()),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithSigningMode("foo"),
+		WithSigningMode(SigningModePermissive),
+		WithUnsignedRequestObserver(unsignedObserverFunc(func() {
+			unsigned++
+		})),
+		WithSigner(imagorpath.NewDefaultSigner("1234")))
+	assert.Equal(t, SigningModePermissive, app.SigningMode)
+
+	for path, code := range map[string]int{
+		"/_-19cQt1szHeUV0WyWFntvTImDI=/foo.jpg": 200,
+		"/_-19cQt1szHeUV0WyWFntvTIm/foo.jpg":    403,
+		"/foo.jpg":                              200,
+		"/unsafe/foo.jpg":                       200,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+	assert.Equal(t, 2, unsigned)
+
+	app = New(
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithSigningMode(SigningModeEnforce),
+		WithSigner(imagorpath.NewDefaultSigner("1234")))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/foo.jpg", nil))
+	assert.Equal(t, 403, w.Code)
+}
+
 func TestWithCustomSigner(t *testing.T) {
 	app := New(
 		WithDebug(true),
@@ -264,6 +363,123 @@ func TestWithCustomSigner(t *testing.T) {
 	assert.Equal(t, w.Body.String(), jsonStr(ErrSignatureMismatch))
 }
 
+func TestWithPrefixSigner(t *testing.T) {
+	app := New(
+		WithDebug(true),
+		WithLogger(zap.NewExample()),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithSigner(imagorpath.NewPrefixSigner("", map[string]imagorpath.Signer{
+			"":     imagorpath.NewDefaultSigner("1234"),
+			"s256": imagorpath.NewHMACSigner(sha256.New, 40, "1234"),
+		})))
+
+	for path, code := range map[string]int{
+		"/_-19cQt1szHeUV0WyWFntvTImDI=/foo.jpg":                  200,
+		"/s256:91DBDJtTFePFnbaj5Qq8JLvq5sM5VTipE685f4Gp/foo.jpg": 200,
+		"/91DBDJtTFePFnbaj5Qq8JLvq5sM5VTipE685f4Gp/foo.jpg":      403,
+		"/s256:_-19cQt1szHeUV0WyWFntvTImDI=/foo.jpg":             403,
+		"/s512:91DBDJtTFePFnbaj5Qq8JLvq5sM5VTipE685f4Gp/foo.jpg": 403,
+		"/foo.jpg": 403,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
+func TestWithPathSigner(t *testing.T) {
+	signerA := imagorpath.NewDefaultSigner("secret-a")
+	signerB := imagorpath.NewDefaultSigner("secret-b")
+	loader := loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+		return NewBlobFromBytes([]byte("foo")), nil
+	})
+	app := New(
+		WithLoaders(loader),
+		WithSigner(imagorpath.NewDefaultSigner("1234")),
+		WithPathSigner("/tenant-a/", signerA),
+		WithPathSigner("tenant-b", signerB),
+		WithPathSigner("", signerB),
+		WithPathSigner("tenant-c", nil))
+	assert.Len(t, app.PathSigners, 2)
+	assert.False(t, app.PathSignersStrict)
+
+	sign := func(signer imagorpath.Signer, image string) string {
+		return "/" + imagorpath.NewBuilder(image).Resize(100, 100).Build(signer)
+	}
+	for path, code := range map[string]int{
+		sign(signerA, "tenant-a/foo.jpg"):                                      200,
+		sign(signerB, "tenant-b/foo.jpg"):                                      200,
+		sign(signerB, "tenant-a/foo.jpg"):                                      403,
+		sign(signerA, "tenant-b/foo.jpg"):                                      403,
+		sign(imagorpath.NewDefaultSigner("1234"), "tenant-a/foo.jpg"):          403,
+		sign(imagorpath.NewDefaultSigner("1234"), "other/foo.jpg"):             200,
+		sign(signerA, "other/foo.jpg"):                                         403,
+		sign(signerA, "tenant-a/../tenant-b/foo.jpg"):                          403,
+		sign(signerA, "tenant-a//foo.jpg"):                                     403,
+		sign(imagorpath.NewDefaultSigner("1234"), "other/../tenant-b/foo.jpg"): 403,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+
+	app = New(
+		WithLoaders(loader),
+		WithSigner(imagorpath.NewDefaultSigner("1234")),
+		WithPathSigner("tenant-a", signerA),
+		WithPathSignersStrict(true))
+	for path, code := range map[string]int{
+		sign(signerA, "tenant-a/foo.jpg"):                          200,
+		sign(imagorpath.NewDefaultSigner("1234"), "other/foo.jpg"): 403,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
+func TestWithPathSignerFilterLoad(t *testing.T) {
+	signerA := imagorpath.NewDefaultSigner("secret-a")
+	signerB := imagorpath.NewDefaultSigner("secret-b")
+	defaultSigner := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithSigner(defaultSigner),
+		WithPathSigner("tenant-a", signerA),
+		WithPathSigner("tenant-b", signerB),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			for _, f := range p.Filters {
+				if f.Name == "watermark" {
+					if _, err := load(f.Args); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return blob, nil
+		})))
+	sign := func(signer imagorpath.Signer, image, watermark string) string {
+		return "/" + imagorpath.NewBuilder(image).Filter("watermark", watermark).Build(signer)
+	}
+	for path, code := range map[string]int{
+		sign(signerA, "tenant-a/foo.jpg", "tenant-a/bar.png"):           200,
+		sign(signerA, "tenant-a/foo.jpg", "tenant-b/bar.png"):           403,
+		sign(signerA, "tenant-a/foo.jpg", "other/bar.png"):              403,
+		sign(signerA, "tenant-a/foo.jpg", "tenant-a/../tenant-b/x.png"): 403,
+		sign(signerA, "tenant-a/foo.jpg", "tenant-a/./bar.png"):         403,
+		sign(defaultSigner, "other/foo.jpg", "other/bar.png"):           200,
+		sign(defaultSigner, "other/foo.jpg", "tenant-b/bar.png"):        403,
+		sign(defaultSigner, "other/foo.jpg", "other/../tenant-b/x.png"): 403,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
 func TestWithRetryQueryUnescape(t *testing.T) {
 	opts := WithOptions(
 		WithDebug(true),
@@ -347,6 +563,7 @@ func TestWithOverrideHeader(t *testing.T) {
 		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
 			out := NewBlobFromBytes([]byte("processed"))
 			out.SetContentType("boom")
+			out.SetHeader("Foo", "processed")
 			return out, nil
 		})),
 	)
@@ -357,6 +574,7 @@ func TestWithOverrideHeader(t *testing.T) {
 	assert.Equal(t, "processed", w.Body.String())
 	assert.Equal(t, "tada", w.Header().Get("Content-Type"))
 	assert.Equal(t, "fghj", w.Header().Get("ASDF"))
+	assert.Equal(t, "processed", w.Header().Get("Foo"))
 }
 
 func TestNewBlobFromPathNotFound(t *testing.T) {
@@ -406,6 +624,39 @@ func TestWithDisableErrorBody(t *testing.T) {
 	assert.Empty(t, w.Body.String())
 }
 
+func TestWithVerboseErrors(t *testing.T) {
+	newApp := func(verbose bool) *Imagor {
+		return New(
+			WithLogger(zap.NewExample()),
+			WithUnsafe(true),
+			WithVerboseErrors(verbose),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return nil, ErrDecodeFailed.WithDetail("unsupported JPEG arithmetic coding")
+			})))
+	}
+	t.Run("default", func(t *testing.T) {
+		app := newApp(false)
+		assert.False(t, app.VerboseErrors)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+		assert.Equal(t, 422, w.Code)
+		assert.Equal(t, jsonStr(ErrDecodeFailed), w.Body.String())
+	})
+	t.Run("verbose", func(t *testing.T) {
+		app := newApp(true)
+		assert.True(t, app.VerboseErrors)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+		assert.Equal(t, 422, w.Code)
+		assert.Equal(t, `{"message":"cannot decode image","status":422,"detail":"unsupported JPEG arithmetic coding"}`, w.Body.String())
+	})
+}
+
 func TestWithCacheHeaderTTL(t *testing.T) {
 	loader := loaderFunc(func(r *http.Request, image string) (blob *Blob, err error) {
 		return NewBlobFromBytes([]byte("ok")), nil
@@ -473,6 +724,72 @@ func TestWithCacheHeaderTTL(t *testing.T) {
 		assert.NotEmpty(t, w.Header().Get("Expires"))
 		assert.Equal(t, "private, no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
 	})
+	t.Run("origin cache ttl", func(t *testing.T) {
+		app := New(
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (blob *Blob, err error) {
+				blob = NewBlobFromBytes([]byte("ok"))
+				if image == "no-cache.jpg" {
+					blob.CacheTTL = -1
+				} else {
+					blob.CacheTTL = time.Second * 300
+				}
+				return
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte("processed")), nil
+			})),
+			WithCacheHeaderSWR(time.Second*167),
+			WithCacheHeaderTTL(time.Second*169),
+			WithUnsafe(true))
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "processed", w.Body.String())
+		assert.Equal(t, "public, s-maxage=300, max-age=300, no-transform, stale-while-revalidate=167", w.Header().Get("Cache-Control"))
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/no-cache.jpg", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "private, no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
+	})
+	t.Run("origin stat not forwarded to processed result", func(t *testing.T) {
+		app := New(
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (blob *Blob, err error) {
+				blob = NewBlobFromBytes([]byte("ok"))
+				blob.Stat = &Stat{ETag: `"abcd"`}
+				blob.SetHeader("X-Origin", "foo")
+				return
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte("processed")), nil
+			})),
+			WithUnsafe(true))
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "processed", w.Body.String())
+		etag := w.Header().Get("ETag")
+		assert.Equal(t, getBlobETag(NewBlobFromBytes([]byte("processed"))), etag, "ETag derived from result")
+		assert.Equal(t, "foo", w.Header().Get("X-Origin"))
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil)
+		r.Header.Set("If-None-Match", `"abcd"`)
+		app.ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code, "origin ETag does not validate processed result")
+
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/foo.jpg", nil)
+		r.Header.Set("If-None-Match", etag)
+		app.ServeHTTP(w, r)
+		assert.Equal(t, 304, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
 }
 
 func TestExpire(t *testing.T) {
@@ -525,6 +842,42 @@ func TestExpire(t *testing.T) {
 	assert.Equal(t, 410, w.Code)
 }
 
+func TestExpireSigned(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithLogger(zap.NewExample()),
+		WithClock(func() time.Time {
+			return now
+		}),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("ok")), nil
+		})),
+		WithSigner(signer))
+
+	valid := imagorpath.NewBuilder("foo.jpg").Expire(now.Add(time.Minute)).Build(signer)
+	expired := imagorpath.NewBuilder("foo.jpg").Expire(now.Add(-time.Minute)).Build(signer)
+	tampered := strings.Replace(expired,
+		strconv.FormatInt(now.Add(-time.Minute).UnixMilli(), 10),
+		strconv.FormatInt(now.Add(time.Hour).UnixMilli(), 10), 1)
+	require.NotEqual(t, expired, tampered)
+
+	for uri, code := range map[string]int{
+		valid:    200,
+		expired:  410,
+		tampered: 403,
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/"+uri, nil))
+		assert.Equal(t, code, w.Code, uri)
+	}
+
+	now = now.Add(time.Hour)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/"+valid, nil))
+	assert.Equal(t, 410, w.Code, "expired as clock passes")
+}
+
 func TestVersion(t *testing.T) {
 	app := New(
 		WithDebug(true),
@@ -553,6 +906,53 @@ func TestWithBasePathRedirect(t *testing.T) {
 	assert.Equal(t, "https://www.bar.com", w.Header().Get("Location"))
 }
 
+func TestWithBasePathHandler(t *testing.T) {
+	tests := []struct {
+		mode        string
+		code        int
+		contentType string
+	}{
+		{mode: "", code: http.StatusTemporaryRedirect},
+		{mode: "redirect", code: http.StatusTemporaryRedirect},
+		{mode: "status", code: http.StatusOK, contentType: "text/html"},
+		{mode: "JSON", code: http.StatusOK, contentType: "application/json"},
+		{mode: "nocontent", code: http.StatusNoContent},
+		{mode: "notfound", code: http.StatusNotFound, contentType: "application/json"},
+		{mode: "foo", code: http.StatusTemporaryRedirect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			app := New(
+				WithBasePathRedirect("https://www.bar.com"),
+				WithBasePathHandler(tt.mode),
+			)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://foo.com/", nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+			if tt.code == http.StatusTemporaryRedirect {
+				assert.Equal(t, "https://www.bar.com", w.Header().Get("Location"))
+			}
+		})
+	}
+	app := New(WithBasePathHandler("json"))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://foo.com/", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, fmt.Sprintf(`{"status":"ok","version":"%s"}`, Version), w.Body.String())
+
+	app = New(WithBasePathHandler("redirect"))
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://foo.com/", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), Version)
+}
+
 func TestParams(t *testing.T) {
 	app := New(
 		WithDebug(true),
@@ -585,18 +985,746 @@ func TestParams(t *testing.T) {
 	w = httptest.NewRecorder()
 	app.ServeHTTP(w, r)
 	assert.Equal(t, 200, w.Code)
-	assert.Empty(t, w.Body.String())
+	assert.Empty(t, w.Body.String())
+}
+
+var clock time.Time
+
+type mapStore struct {
+	l       sync.RWMutex
+	Map     map[string]*Blob
+	ModTime map[string]time.Time
+	LoadCnt map[string]int
+	SaveCnt map[string]int
+	DelCnt  map[string]int
+}
+
+func TestWithTarEndpoint(t *testing.T) {
+	var loads = map[string]int{}
+	var l sync.Mutex
+	store := newMapStore()
+	app := New(
+		WithDebug(true),
+		WithLogger(zap.NewExample()),
+		WithSigner(imagorpath.NewDefaultSigner("1234")),
+		WithTarEndpoint(true),
+		WithStorages(store),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			l.Lock()
+			loads[image]++
+			l.Unlock()
+			if image == "missing.jpg" {
+				return nil, ErrNotFound
+			}
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			buf, err := blob.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			return NewBlobFromBytes([]byte(fmt.Sprintf("%s:%dx%d", buf, p.Width, p.Height))), nil
+		})),
+	)
+	signer := imagorpath.NewDefaultSigner("1234")
+	spec := []TarEntry{
+		{Name: "a/small.jpg", Params: imagorpath.Generate(imagorpath.Params{Image: "foo.jpg", Width: 10, Height: 10}, signer)},
+		{Name: "b.jpg", Params: imagorpath.Generate(imagorpath.Params{Image: "bar.jpg", Width: 20, Height: 20}, signer)},
+		{Name: "a/large.jpg", Params: imagorpath.Generate(imagorpath.Params{Image: "foo.jpg", Width: 30, Height: 30}, signer)},
+	}
+	buf, _ := json.Marshal(spec)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-tar", w.Header().Get("Content-Type"))
+
+	var files = map[string]string{}
+	var names []string
+	tr := tar.NewReader(w.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		files[hdr.Name] = string(b)
+	}
+	assert.Equal(t, []string{"a/small.jpg", "b.jpg", "a/large.jpg"}, names, "request order")
+	assert.Equal(t, "foo.jpg:10x10", files["a/small.jpg"])
+	assert.Equal(t, "foo.jpg:30x30", files["a/large.jpg"])
+	assert.Equal(t, "bar.jpg:20x20", files["b.jpg"])
+	assert.Equal(t, map[string]int{"foo.jpg": 1, "bar.jpg": 1}, loads, "source loaded once")
+	assert.Equal(t, 1, store.SaveCnt["foo.jpg"], "source storage saved")
+	assert.Equal(t, 1, store.SaveCnt["bar.jpg"], "source storage saved")
+
+	missing := imagorpath.Generate(imagorpath.Params{Image: "missing.jpg", Width: 10, Height: 10}, signer)
+	w = httptest.NewRecorder()
+	buf, _ = json.Marshal([]TarEntry{{Name: "a.jpg", Params: missing}, {Name: "b.jpg", Params: spec[0].Params}})
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 404, w.Code, "error status before archive committed")
+
+	buf, _ = json.Marshal([]TarEntry{{Name: "a.jpg", Params: spec[0].Params}, {Name: "b.jpg", Params: missing}})
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(
+			http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	}, "abort instead of truncated archive")
+
+	w = httptest.NewRecorder()
+	buf, _ = json.Marshal([]TarEntry{{Name: "a.jpg", Params: "abcd/10x10/foo.jpg"}})
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 403, w.Code)
+
+	w = httptest.NewRecorder()
+	buf, _ = json.Marshal([]TarEntry{{Name: "../a.jpg", Params: spec[0].Params}})
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", strings.NewReader("{")))
+	assert.Equal(t, 400, w.Code)
+
+	app.EnableTarEndpoint = false
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 405, w.Code)
+}
+
+func TestTarSourceReleasedAfterLastEntry(t *testing.T) {
+	var events []string
+	var l sync.Mutex
+	record := func(event string) {
+		l.Lock()
+		events = append(events, event)
+		l.Unlock()
+	}
+	app := New(
+		WithUnsafe(true),
+		WithTarEndpoint(true),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			record("load:" + image)
+			blob := NewBlobFromBytes([]byte(image))
+			blob.release = func() {
+				record("release:" + image)
+			}
+			return blob, nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			record(fmt.Sprintf("process:%s:%d", p.Image, p.Width))
+			return NewBlobFromBytes([]byte("processed")), nil
+		})),
+	)
+	buf, _ := json.Marshal([]TarEntry{
+		{Name: "a.jpg", Params: "unsafe/10x10/foo.jpg"},
+		{Name: "b.jpg", Params: "unsafe/20x20/foo.jpg"},
+		{Name: "c.jpg", Params: "unsafe/30x30/bar.jpg"},
+	})
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/tar", bytes.NewReader(buf)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, []string{
+		"load:foo.jpg", "process:foo.jpg:10",
+		"process:foo.jpg:20", "release:foo.jpg",
+		"load:bar.jpg", "process:bar.jpg:30",
+	}, events, "source kept only until its last entry, single use source not kept")
+}
+
+func TestWithBatchEndpoint(t *testing.T) {
+	resultStore := newMapStore()
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithDebug(true),
+		WithLogger(zap.NewExample()),
+		WithSigner(signer),
+		WithBatchEndpoint(true),
+		WithProcessConcurrency(2),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "missing.jpg" {
+				return nil, ErrNotFound
+			}
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			buf, err := blob.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			return NewBlobFromBytes([]byte(fmt.Sprintf("%s:%dx%d", buf, p.Width, p.Height))), nil
+		})),
+	)
+	paths := []string{
+		imagorpath.Generate(imagorpath.Params{Image: "foo.jpg", Width: 10, Height: 10}, signer),
+		imagorpath.Generate(imagorpath.Params{Image: "bar.jpg", Width: 20, Height: 20}, signer),
+		imagorpath.Generate(imagorpath.Params{Image: "missing.jpg", Width: 20, Height: 20}, signer),
+		"abcdefghij/30x30/foo.jpg",
+		"",
+	}
+	var results = map[string]BatchResult{}
+	var l sync.Mutex
+	app.Batch(context.Background(), paths, func(res BatchResult) {
+		l.Lock()
+		results[res.Path] = res
+		l.Unlock()
+	})
+	require.Len(t, results, len(paths))
+	assert.Equal(t, 200, results[paths[0]].Code)
+	assert.Empty(t, results[paths[0]].Error)
+	assert.Equal(t, 200, results[paths[1]].Code)
+	assert.Equal(t, 404, results[paths[2]].Code)
+	assert.Equal(t, ErrNotFound.Message, results[paths[2]].Error)
+	assert.Equal(t, 403, results[paths[3]].Code)
+	assert.Equal(t, 400, results[paths[4]].Code)
+	_, err := resultStore.Stat(context.Background(), "10x10/foo.jpg")
+	assert.NoError(t, err, "should save to result storage before result")
+
+	buf, _ := json.Marshal(paths[1:4])
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/batch", bytes.NewReader(buf)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	var codes = map[string]int{}
+	dec := json.NewDecoder(w.Body)
+	for dec.More() {
+		var res BatchResult
+		require.NoError(t, dec.Decode(&res))
+		codes[res.Path] = res.Code
+	}
+	assert.Equal(t, map[string]int{paths[1]: 200, paths[2]: 404, paths[3]: 403}, codes)
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/batch", strings.NewReader("[]")))
+	assert.Equal(t, 400, w.Code)
+
+	app.EnableBatchEndpoint = false
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodPost, "https://example.com/batch", bytes.NewReader(buf)))
+	assert.Equal(t, 405, w.Code)
+}
+
+func TestBatchResultSaveError(t *testing.T) {
+	app := New(
+		WithUnsafe(true),
+		WithResultStorages(saverFunc(func(ctx context.Context, image string, blob *Blob) error {
+			time.Sleep(time.Millisecond * 10)
+			return errors.New("save failed")
+		})),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			return NewBlobFromBytes([]byte("processed")), nil
+		})),
+	)
+	var results []BatchResult
+	app.Batch(context.Background(), []string{"unsafe/10x10/foo.jpg"}, func(res BatchResult) {
+		results = append(results, res)
+	})
+	require.Len(t, results, 1)
+	assert.Equal(t, 500, results[0].Code)
+	assert.Equal(t, "save failed", results[0].Error)
+
+	// items of the same key joining an in flight call also report the save error
+	var processed atomic.Int32
+	app = New(
+		WithUnsafe(true),
+		WithProcessConcurrency(2),
+		WithResultStorages(saverFunc(func(ctx context.Context, image string, blob *Blob) error {
+			return errors.New("save failed")
+		})),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			processed.Add(1)
+			time.Sleep(time.Millisecond * 50)
+			return NewBlobFromBytes([]byte("processed")), nil
+		})),
+	)
+	var l sync.Mutex
+	results = nil
+	app.Batch(context.Background(), []string{"unsafe/10x10/foo.jpg", "unsafe/10x10/foo.jpg"}, func(res BatchResult) {
+		l.Lock()
+		results = append(results, res)
+		l.Unlock()
+	})
+	assert.Equal(t, int32(1), processed.Load(), "should join in flight call")
+	require.Len(t, results, 2)
+	for _, res := range results {
+		assert.Equal(t, 500, res.Code)
+		assert.Equal(t, "save failed", res.Error)
+	}
+}
+
+func TestWithResultSavedHook(t *testing.T) {
+	type saved struct {
+		key string
+		buf string
+	}
+	ch := make(chan saved, 10)
+	app := New(
+		WithUnsafe(true),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithResultStorages(saverFunc(func(ctx context.Context, image string, blob *Blob) error {
+			if strings.Contains(image, "fail") {
+				return errors.New("save failed")
+			}
+			return nil
+		})),
+		WithResultSavedHook(func(ctx context.Context, key string, blob *Blob) error {
+			buf, err := blob.ReadAll()
+			if err != nil {
+				return err
+			}
+			ch <- saved{key, string(buf)}
+			return errors.New("hook error is logged only")
+		}),
+	)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/10x10/foo.jpg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "foo.jpg", w.Body.String())
+	select {
+	case s := <-ch:
+		assert.Equal(t, saved{"10x10/foo.jpg", "foo.jpg"}, s)
+	case <-time.After(time.Second):
+		t.Fatal("hook not called")
+	}
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/10x10/fail.jpg", nil))
+	assert.Equal(t, 200, w.Code)
+	select {
+	case s := <-ch:
+		t.Fatalf("hook called on failed save %v", s)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestWithStatEndpoint(t *testing.T) {
+	var loads int32
+	resultStore := newMapStore()
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithDebug(true),
+		WithLogger(zap.NewExample()),
+		WithSigner(signer),
+		WithStatEndpoint(true),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			atomic.AddInt32(&loads, 1)
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+	)
+	path := imagorpath.Generate(imagorpath.Params{Image: "foo.jpg", Width: 10, Height: 10}, signer)
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodHead, "https://example.com/stat/"+path, nil))
+	assert.Equal(t, 404, w.Code)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&loads))
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/"+path, nil))
+	assert.Equal(t, 200, w.Code)
+	time.Sleep(time.Millisecond * 10) // make sure storage reached
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodHead, "https://example.com/stat/"+path, nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "7", w.Header().Get("Content-Length"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/stat/"+path, nil))
+	assert.Equal(t, 403, w.Code, "stat endpoint is HEAD only")
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodHead, "https://example.com/stat/abcd/10x10/foo.jpg", nil))
+	assert.Equal(t, 403, w.Code)
+
+	app.EnableStatEndpoint = false
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodHead, "https://example.com/stat/"+path, nil))
+	assert.NotEqual(t, 200, w.Code)
+}
+
+type statErrStore struct {
+	*mapStore
+	Err error
+}
+
+func (s statErrStore) Stat(ctx context.Context, image string) (*Stat, error) {
+	return nil, s.Err
+}
+
+func TestCheckHealth(t *testing.T) {
+	app := New(
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return nil, ErrNotFound
+		})),
+		WithStorages(newMapStore()),
+		WithResultStorages(newMapStore()),
+	)
+	assert.NoError(t, app.CheckHealth(context.Background()))
+
+	app = New(WithResultStorages(statErrStore{newMapStore(), ErrInvalid}))
+	assert.NoError(t, app.CheckHealth(context.Background()))
+
+	err := errors.New("unreachable")
+	app = New(WithResultStorages(newMapStore(), statErrStore{newMapStore(), err}))
+	assert.Equal(t, err, app.CheckHealth(context.Background()))
+
+	app = New(WithLoaders(statErrStore{newMapStore(), err}))
+	assert.Equal(t, err, app.CheckHealth(context.Background()))
+}
+
+func TestWithAllowedSizes(t *testing.T) {
+	newApp := func(snap bool) *Imagor {
+		return New(
+			WithUnsafe(true),
+			WithAllowedSizes(
+				AllowedSize{Width: 100, Height: 100},
+				AllowedSize{Width: 300, Height: 200},
+				AllowedSize{Width: 0, Height: 400},
+			),
+			WithAllowedSizesSnap(snap),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte(image)), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(fmt.Sprintf("%dx%d:%v", p.Width, p.Height, p.HFlip))), nil
+			})),
+		)
+	}
+	tests := []struct {
+		path     string
+		snap     bool
+		code     int
+		expected string
+	}{
+		{path: "/unsafe/foo.jpg", code: 200, expected: "0x0:false"},
+		{path: "/unsafe/100x100/foo.jpg", code: 200, expected: "100x100:false"},
+		{path: "/unsafe/0x400/foo.jpg", code: 200, expected: "0x400:false"},
+		{path: "/unsafe/120x100/foo.jpg", code: 400},
+		{path: "/unsafe/120x100/foo.jpg", snap: true, code: 200, expected: "100x100:false"},
+		{path: "/unsafe/-280x190/foo.jpg", snap: true, code: 200, expected: "300x200:true"},
+		{path: "/unsafe/0x380/foo.jpg", snap: true, code: 200, expected: "0x400:false"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.path, tt.snap), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newApp(tt.snap).ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 200 {
+				assert.Equal(t, tt.expected, w.Body.String())
+			} else {
+				assert.Equal(t, jsonStr(ErrSizeNotAllowed), w.Body.String())
+			}
+		})
+	}
+}
+
+type conversionObserverFunc func(from, to BlobType, duration time.Duration, inSize, outSize int64)
+
+func (f conversionObserverFunc) ObserveConversion(from, to BlobType, duration time.Duration, inSize, outSize int64) {
+	f(from, to, duration, inSize, outSize)
+}
+
+func TestWithConversionObserver(t *testing.T) {
+	var observed []string
+	app := New(
+		WithUnsafe(true),
+		WithConversionObserver(conversionObserverFunc(
+			func(from, to BlobType, duration time.Duration, inSize, outSize int64) {
+				observed = append(observed, fmt.Sprintf("%s>%s:%d:%d", from, to, inSize, outSize))
+			})),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromFile("testdata/" + image), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			if p.Width == 0 {
+				return nil, ErrInvalid
+			}
+			return NewBlobFromFile("testdata/demo1.jpg"), nil
+		})),
+	)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/100x100/gopher.png", nil))
+	assert.Equal(t, 200, w.Code)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/gopher.png", nil))
+	assert.Equal(t, 400, w.Code)
+	in, err := os.Stat("testdata/gopher.png")
+	require.NoError(t, err)
+	out, err := os.Stat("testdata/demo1.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{fmt.Sprintf("png>jpeg:%d:%d", in.Size(), out.Size())}, observed)
+}
+
+func TestWithMaxDecompressedBytes(t *testing.T) {
+	factory := func(n int64) *Imagor {
+		return New(
+			WithUnsafe(true),
+			WithMaxDecompressedBytes(n),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromFile("testdata/" + image), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				for _, f := range p.Filters {
+					if f.Name == "watermark" {
+						if _, err := load(f.Args); err != nil {
+							return nil, err
+						}
+					}
+				}
+				return blob, nil
+			})),
+		)
+	}
+	for _, tt := range []struct {
+		name string
+		max  int64
+		path string
+		code int
+	}{
+		{name: "no limit", path: "gopher.png", code: 200},
+		{name: "within limit", max: 20000000, path: "gopher.png", code: 200},
+		// 1634x2224 RGBA 8 bit
+		{name: "exceeded", max: 14536063, path: "gopher.png", code: 422},
+		{name: "exceeded watermark", max: 14536063, path: "filters:watermark(gopher.png)/demo1.jpg", code: 422},
+		{name: "not supported skipped", max: 100, path: "sample.pdf", code: 200},
+		{name: "raw", max: 100, path: "filters:raw()/gopher.png", code: 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := factory(tt.max)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com/unsafe/"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 422 {
+				assert.Equal(t, jsonStr(ErrMaxDecompressedBytesExceeded), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithAllowedTypes(t *testing.T) {
+	var processed int
+	app := New(
+		WithUnsafe(true),
+		WithAllowedTypes("jpg", "png", "webp"),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "html.jpg" {
+				// text/html masquerading as image
+				blob := NewBlobFromBytes([]byte("<html><body>hello</body></html>"))
+				blob.SetContentType("image/jpeg")
+				return blob, nil
+			}
+			return NewBlobFromFile("testdata/" + image), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			for _, f := range p.Filters {
+				if f.Name == "watermark" {
+					if _, err := load(f.Args); err != nil {
+						return nil, err
+					}
+				}
+			}
+			processed++
+			return blob, nil
+		})),
+	)
+	for _, tt := range []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "jpeg", path: "demo1.jpg", code: 200},
+		{name: "png", path: "gopher.png", code: 200},
+		{name: "gif not allowed", path: "dancing-banana.gif", code: 406},
+		{name: "html masquerading", path: "html.jpg", code: 406},
+		{name: "watermark not allowed", path: "filters:watermark(sample.pdf)/demo1.jpg", code: 406},
+		{name: "raw", path: "filters:raw()/dancing-banana.gif", code: 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			processed = 0
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com/unsafe/"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 406 {
+				assert.Equal(t, jsonStr(ErrTypeNotAllowed), w.Body.String())
+			}
+			if tt.code == 406 && !strings.Contains(tt.path, "watermark") {
+				assert.Zero(t, processed, "rejected before processing")
+			}
+		})
+	}
+	assert.Empty(t, New(WithAllowedTypes("", " ")).AllowedTypes)
+	assert.Panics(t, func() {
+		New(WithAllowedTypes("jpeg", "foo"))
+	})
+	assert.Panics(t, func() {
+		New(WithAllowedTypes("unknown"))
+	})
+}
+
+func TestWithMaxResolution(t *testing.T) {
+	factory := func(megapixels float64) *Imagor {
+		return New(
+			WithUnsafe(true),
+			WithMaxResolution(megapixels),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromFile("testdata/" + image), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				for _, f := range p.Filters {
+					if f.Name == "watermark" {
+						if _, err := load(f.Args); err != nil {
+							return nil, err
+						}
+					}
+				}
+				return blob, nil
+			})),
+		)
+	}
+	for _, tt := range []struct {
+		name       string
+		megapixels float64
+		path       string
+		code       int
+	}{
+		{name: "no limit", path: "50000x50000/gopher.png", code: 200},
+		// 1634x2224
+		{name: "source within limit", megapixels: 5, path: "gopher.png", code: 200},
+		{name: "oversized source", megapixels: 3, path: "gopher.png", code: 422},
+		{name: "oversized source watermark", megapixels: 3, path: "100x100/filters:watermark(gopher.png)/demo1.jpg", code: 422},
+		{name: "target within limit", megapixels: 5, path: "fit-in/2000x2000/gopher.png", code: 200},
+		{name: "oversized target", megapixels: 5, path: "50000x50000/gopher.png", code: 422},
+		{name: "oversized flipped target", megapixels: 5, path: "-3000x-3000/gopher.png", code: 422},
+		{name: "oversized target padding", megapixels: 5, path: "2000x2000/300x300/gopher.png", code: 422},
+		{name: "oversized target by aspect ratio", megapixels: 5, path: "2000x0/gopher.png", code: 422},
+		{name: "target by aspect ratio within limit", megapixels: 5, path: "0x2000/gopher.png", code: 200},
+		{name: "oversized target not found", megapixels: 5, path: "50000x50000/notfound.png", code: 422},
+		{name: "not supported skipped", megapixels: 0.01, path: "sample.pdf", code: 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := factory(tt.megapixels)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com/unsafe/"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 422 {
+				assert.Equal(t, jsonStr(ErrMaxResolutionExceeded), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTimings(t *testing.T) {
+	app := New(
+		WithUnsafe(true),
+		WithStorages(newMapStore()),
+		WithResultStorages(newMapStore()),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "watermark.png" {
+				time.Sleep(time.Millisecond * 50)
+			}
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			if _, err := load("watermark.png"); err != nil {
+				return nil, err
+			}
+			return blob, nil
+		})),
+	)
+	ctx := WithTimings(context.Background())
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/foo.jpg", nil).WithContext(ctx))
+	assert.Equal(t, 200, w.Code)
+	timings := map[string]time.Duration{}
+	ContextTimingsRange(ctx, func(name string, d time.Duration) bool {
+		timings[name] = d
+		return true
+	})
+	assert.Contains(t, timings, "load")
+	assert.Contains(t, timings, "save", "storage save recorded before response")
+	assert.GreaterOrEqual(t, timings["subload"], time.Millisecond*50)
+	assert.Less(t, timings["load"], time.Millisecond*50, "sub-load not counted as load")
+	assert.Less(t, timings["process"], time.Millisecond*50, "sub-load not counted as process")
 }
 
-var clock time.Time
-
-type mapStore struct {
-	l       sync.RWMutex
-	Map     map[string]*Blob
-	ModTime map[string]time.Time
-	LoadCnt map[string]int
-	SaveCnt map[string]int
-	DelCnt  map[string]int
+func TestWithContextCacheLimit(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		maxEntries int
+		loads      int
+	}{
+		{name: "unbounded", loads: 2},
+		{name: "bounded", maxEntries: 1, loads: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var loads int
+			app := New(
+				WithUnsafe(true),
+				WithContextCacheLimit(tt.maxEntries, 0),
+				WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+					if image != "foo" {
+						loads++
+					}
+					return NewBlobFromBytes([]byte(image)), nil
+				})),
+				WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+					for _, image := range []string{"a", "a", "b", "a"} {
+						if _, ok := ContextCacheGet(ctx, image); ok {
+							continue
+						}
+						b, err := load(image)
+						if err != nil {
+							return nil, err
+						}
+						ContextCachePut(ctx, image, b)
+					}
+					return blob, nil
+				})),
+			)
+			assert.Equal(t, tt.maxEntries, app.ContextCacheMaxEntries)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com/unsafe/foo", nil))
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, tt.loads, loads)
+		})
+	}
 }
 
 func newMapStore() *mapStore {
@@ -851,6 +1979,124 @@ func TestWithLoadersStoragesProcessors(t *testing.T) {
 	}
 }
 
+func TestContentETag(t *testing.T) {
+	newApp := func() *Imagor {
+		return New(
+			WithUnsafe(true),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte(image)), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				buf, err := blob.ReadAll()
+				if err != nil {
+					return nil, err
+				}
+				return NewBlobFromBytes([]byte(fmt.Sprintf("%s:%dx%d", buf, p.Width, p.Height))), nil
+			})),
+		)
+	}
+	get := func(app *Imagor, path, inm string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		app.ServeHTTP(w, r)
+		return w
+	}
+	app := newApp()
+	w := get(app, "/unsafe/10x10/foo.jpg", "")
+	assert.Equal(t, 200, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.True(t, strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`), "strong ETag")
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+
+	assert.Equal(t, etag, get(newApp(), "/unsafe/10x10/foo.jpg", "").Header().Get("ETag"), "stable across instances")
+	assert.NotEqual(t, etag, get(app, "/unsafe/20x20/foo.jpg", "").Header().Get("ETag"))
+
+	for inm, code := range map[string]int{
+		etag:                      304,
+		"W/" + etag:               304,
+		`"abcd", ` + etag:         304,
+		"*":                       304,
+		etag[:len(etag)-2] + `0"`: 200,
+		`"abcd"`:                  200,
+	} {
+		w = get(app, "/unsafe/10x10/foo.jpg", inm)
+		assert.Equal(t, code, w.Code, inm)
+		if code == 304 {
+			assert.Empty(t, w.Body.String(), inm)
+		} else {
+			assert.Equal(t, "foo.jpg:10x10", w.Body.String(), inm)
+		}
+	}
+}
+
+func TestContentETagNotReadFromStorage(t *testing.T) {
+	var reads int64
+	app := New(
+		WithUnsafe(true),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlob(func() (io.ReadCloser, int64, error) {
+				atomic.AddInt64(&reads, 1)
+				return io.NopCloser(strings.NewReader(image)), int64(len(image)), nil
+			}), nil
+		})),
+	)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "foo.jpg", w.Body.String())
+	assert.Empty(t, w.Header().Get("ETag"), "no content hash of non in-memory blob")
+	assert.Equal(t, int64(1), atomic.LoadInt64(&reads), "blob read once for response only")
+}
+
+func TestRangeRequest(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	app := New(
+		WithUnsafe(true),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "unknown-size" {
+				return NewBlob(func() (io.ReadCloser, int64, error) {
+					return io.NopCloser(bytes.NewReader(data)), 0, nil
+				}), nil
+			}
+			return NewBlobFromBytes(data), nil
+		})),
+	)
+	get := func(path, rng string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+		if rng != "" {
+			r.Header.Set("Range", rng)
+		}
+		app.ServeHTTP(w, r)
+		return w
+	}
+	w := get("/unsafe/foo", "bytes=0-99")
+	assert.Equal(t, 206, w.Code)
+	assert.Equal(t, "bytes 0-99/1000", w.Header().Get("Content-Range"))
+	assert.Equal(t, "100", w.Header().Get("Content-Length"))
+	assert.Equal(t, data[:100], w.Body.Bytes())
+
+	w = get("/unsafe/foo", "bytes=950-")
+	assert.Equal(t, 206, w.Code)
+	assert.Equal(t, "bytes 950-999/1000", w.Header().Get("Content-Range"))
+	assert.Equal(t, data[950:], w.Body.Bytes())
+
+	w = get("/unsafe/foo", "bytes=2000-")
+	assert.Equal(t, 416, w.Code)
+
+	w = get("/unsafe/foo", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, data, w.Body.Bytes())
+
+	w = get("/unsafe/unknown-size", "bytes=0-99")
+	assert.Equal(t, 200, w.Code, "full body fallback if size unknown")
+	assert.Equal(t, data, w.Body.Bytes())
+}
+
 func TestWithResultStorageNotModified(t *testing.T) {
 	resultStore := newMapStore()
 	app := New(
@@ -869,7 +2115,8 @@ func TestWithResultStorageNotModified(t *testing.T) {
 	time.Sleep(time.Millisecond * 10) // make sure storage reached
 	assert.Equal(t, 200, w.Code)
 	assert.Equal(t, "foo", w.Body.String())
-	assert.Empty(t, w.Header().Get("ETag"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag, "content ETag of processed result")
 
 	w = httptest.NewRecorder()
 	r = httptest.NewRequest(
@@ -877,7 +2124,7 @@ func TestWithResultStorageNotModified(t *testing.T) {
 	app.ServeHTTP(w, r)
 	assert.Equal(t, 200, w.Code)
 	assert.Equal(t, "foo", w.Body.String())
-	etag := w.Header().Get("ETag")
+	assert.Equal(t, etag, w.Header().Get("ETag"), "same ETag from result storage")
 	lastModified := w.Header().Get("Last-Modified")
 	assert.NotEmpty(t, etag)
 	assert.NotEmpty(t, lastModified)
@@ -944,6 +2191,48 @@ func (fn resultKeyFunc) HashResult(p imagorpath.Params) string {
 	return fn(p)
 }
 
+func TestWithResultKey(t *testing.T) {
+	var loads int32
+	resultStore := newMapStore()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	app := New(
+		WithUnsafe(true),
+		WithStatEndpoint(true),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			atomic.AddInt32(&loads, 1)
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithResultKey(func(p imagorpath.Params) string {
+			tenant, _, _ := strings.Cut(p.Image, "/")
+			return tenant + "/" + date.Format("2006/01/02") + "/" + imagorpath.DigestResultStorageHasher.HashResult(p)
+		}),
+	)
+	key := "tenant-a/2024/01/02/" + imagorpath.DigestResultStorageHasher.HashResult(
+		imagorpath.Parse("/unsafe/10x10/tenant-a/foo.jpg"))
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "https://example.com/stat/unsafe/10x10/tenant-a/foo.jpg", nil))
+	assert.Equal(t, 404, w.Code)
+
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/10x10/tenant-a/foo.jpg", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "tenant-a/foo.jpg", w.Body.String())
+		assert.Eventually(t, func() bool {
+			resultStore.l.Lock()
+			defer resultStore.l.Unlock()
+			return resultStore.SaveCnt[key] == 1
+		}, time.Second, time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads), "should load result by custom key")
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "https://example.com/stat/unsafe/10x10/tenant-a/foo.jpg", nil))
+	assert.Equal(t, 200, w.Code)
+}
+
 func TestWithResultStorageHasher(t *testing.T) {
 	store := newMapStore()
 	resultStore := newMapStore()
@@ -1185,6 +2474,170 @@ func TestWithProcessConcurrency(t *testing.T) {
 	assert.Equal(t, 4, result[429])
 }
 
+func TestWithLoadConcurrencyPerHost(t *testing.T) {
+	var l sync.Mutex
+	var active, maxActive = map[string]int{}, map[string]int{}
+	var unblock = make(chan struct{})
+	app := New(
+		WithUnsafe(true),
+		// fewer global slots than slow requests, waiting on host slot holds no global slot
+		WithProcessConcurrency(2),
+		WithLoadConcurrencyPerHost(1),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			host := getHost(image)
+			l.Lock()
+			active[host]++
+			if active[host] > maxActive[host] {
+				maxActive[host] = active[host]
+			}
+			l.Unlock()
+			if host == "slow.com" {
+				<-unblock
+			}
+			l.Lock()
+			active[host]--
+			l.Unlock()
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+	)
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/"+path, nil))
+		return w.Code
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Equal(t, 200, serve(fmt.Sprintf("https://slow.com/%d.jpg", i)))
+		}(i)
+	}
+	// fast host not starved by slow host
+	assert.Equal(t, 200, serve("fast.com/a.jpg"))
+	assert.Equal(t, 200, serve("FAST.com/b.jpg"))
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, 1, maxActive["slow.com"])
+	assert.Equal(t, 1, maxActive["fast.com"])
+	assert.Eventually(t, func() bool {
+		app.hostSema.mu.Lock()
+		defer app.hostSema.mu.Unlock()
+		return len(app.hostSema.semas) == 0
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, "example.com", getHost("https://example.com/foo.jpg"))
+	assert.Equal(t, "example.com", getHost("Example.com?foo.jpg"))
+	assert.Equal(t, "foo", getHost("/foo/bar.jpg"))
+}
+
+func TestPrioritySemaphore(t *testing.T) {
+	ctx := context.Background()
+	waiters := func(s *prioritySemaphore, n int) {
+		assert.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return len(s.waiters) == n
+		}, time.Second, time.Millisecond)
+	}
+	acquireOrder := func(s *prioritySemaphore, priorities ...int) []int {
+		require.NoError(t, s.Acquire(ctx, 0))
+		var order []int
+		var l sync.Mutex
+		var wg sync.WaitGroup
+		for i, priority := range priorities {
+			wg.Add(1)
+			go func(priority int) {
+				defer wg.Done()
+				assert.NoError(t, s.Acquire(ctx, priority))
+				l.Lock()
+				order = append(order, priority)
+				l.Unlock()
+				s.Release()
+			}(priority)
+			waiters(s, i+1)
+			time.Sleep(time.Millisecond * 5)
+		}
+		s.Release()
+		wg.Wait()
+		assert.Zero(t, s.cur)
+		return order
+	}
+	assert.Equal(t, []int{0, 5, -1}, acquireOrder(newPrioritySemaphore(1, 0), 0, 5, -1))
+	assert.Equal(t, []int{2, 1, 0, -1}, acquireOrder(newPrioritySemaphore(1, time.Hour), -1, 0, 2, 1))
+	// aged lower priority waiter gets ahead
+	assert.Equal(t, []int{-1, 1}, acquireOrder(newPrioritySemaphore(1, time.Millisecond), -1, 1))
+
+	s := newPrioritySemaphore(1, time.Hour)
+	require.NoError(t, s.Acquire(ctx, 0))
+	cctx, cancel := context.WithTimeout(ctx, time.Millisecond*5)
+	defer cancel()
+	assert.ErrorIs(t, s.Acquire(cctx, 10), context.DeadlineExceeded)
+	waiters(s, 0)
+	s.Release()
+	require.NoError(t, s.Acquire(ctx, 0))
+}
+
+func TestWithProcessPriority(t *testing.T) {
+	var order []string
+	var l sync.Mutex
+	var unblock = make(chan struct{})
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithUnsafe(true),
+		WithSigner(signer),
+		WithProcessConcurrency(1),
+		WithProcessQueueSize(10),
+		WithProcessPriority(time.Hour),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "block" {
+				<-unblock
+			}
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			assert.Empty(t, p.Filters)
+			l.Lock()
+			order = append(order, p.Image)
+			l.Unlock()
+			return blob, nil
+		})),
+	)
+	serve := func(ctx context.Context, path string) {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/"+path, nil).WithContext(ctx))
+		assert.Equal(t, 200, w.Code)
+	}
+	var wg sync.WaitGroup
+	var n int
+	enqueue := func(ctx context.Context, path string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(ctx, path)
+		}()
+		assert.Eventually(t, func() bool {
+			app.sema.mu.Lock()
+			defer app.sema.mu.Unlock()
+			return len(app.sema.waiters) == n
+		}, time.Second, time.Millisecond)
+		n++
+	}
+	ctx := context.Background()
+	enqueue(ctx, "unsafe/block")
+	enqueue(ctx, "unsafe/filters:priority(9)/unsigned")
+	enqueue(ctx, imagorpath.Generate(imagorpath.Params{
+		Image: "background", Filters: imagorpath.Filters{{Name: "priority", Args: "-1"}}}, signer))
+	enqueue(WithPriority(ctx, 1), "unsafe/tagged")
+	enqueue(ctx, imagorpath.Generate(imagorpath.Params{
+		Image: "interactive", Filters: imagorpath.Filters{{Name: "priority", Args: "2"}}}, signer))
+	close(unblock)
+	wg.Wait()
+	assert.Equal(t, []string{"block", "interactive", "tagged", "unsigned", "background"}, order)
+}
+
 func TestWithModifiedTimeCheck(t *testing.T) {
 	store := newMapStore()
 	resultStore := newMapStore()
@@ -1356,6 +2809,64 @@ func TestAutoWebP(t *testing.T) {
 	})
 }
 
+func TestSaveDataMode(t *testing.T) {
+	factory := func(enable bool, options ...Option) *Imagor {
+		return New(
+			WithUnsafe(true),
+			WithSaveDataMode(enable),
+			WithOptions(options...),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})))
+	}
+	t.Run("not enabled", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/abc.png", nil)
+		r.Header.Set("Save-Data", "on")
+		factory(false).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Empty(t, w.Header().Get("Vary"))
+		assert.Equal(t, "abc.png", w.Body.String())
+	})
+	t.Run("save data on", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/abc.png", nil)
+		r.Header.Set("Save-Data", "on")
+		factory(true).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "Save-Data", w.Header().Get("Vary"))
+		assert.Equal(t, "filters:quality(50)/abc.png", w.Body.String())
+	})
+	t.Run("save data quality", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/100x0/abc.png", nil)
+		r.Header.Set("Save-Data", "On")
+		factory(true, WithSaveDataQuality(30)).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "100x0/filters:quality(30)/abc.png", w.Body.String())
+	})
+	t.Run("save data absent", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/abc.png", nil)
+		factory(true).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "Save-Data", w.Header().Get("Vary"))
+		assert.Equal(t, "abc.png", w.Body.String())
+	})
+	t.Run("explicit quality wins", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/filters:quality(90)/abc.png", nil)
+		r.Header.Set("Save-Data", "on")
+		factory(true).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Empty(t, w.Header().Get("Vary"))
+		assert.Equal(t, "filters:quality(90)/abc.png", w.Body.String())
+	})
+}
+
 func TestAutoAVIF(t *testing.T) {
 	factory := func(isAuto bool) *Imagor {
 		return New(
@@ -1425,6 +2936,104 @@ func TestAutoAVIF(t *testing.T) {
 	})
 }
 
+func TestAutoFormatAnimated(t *testing.T) {
+	gif, err := os.ReadFile("testdata/dancing-banana.gif")
+	require.NoError(t, err)
+	factory := func(options ...Option) *Imagor {
+		return New(append([]Option{
+			WithUnsafe(true),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				if image == "anim.gif" {
+					return NewBlobFromBytes(gif), nil
+				}
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})),
+		}, options...)...)
+	}
+	const accept = "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+	for _, tt := range []struct {
+		name    string
+		options []Option
+		image   string
+		result  string
+	}{
+		{name: "auto webp animated", options: []Option{WithAutoWebP(true)},
+			image: "anim.gif", result: "filters:format(webp)/anim.gif"},
+		{name: "auto avif animated keeps gif", options: []Option{WithAutoAVIF(true)},
+			image: "anim.gif", result: "anim.gif"},
+		{name: "auto avif and webp animated", options: []Option{WithAutoAVIF(true), WithAutoWebP(true)},
+			image: "anim.gif", result: "filters:format(webp)/anim.gif"},
+		{name: "auto avif and webp still", options: []Option{WithAutoAVIF(true), WithAutoWebP(true)},
+			image: "abc.png", result: "filters:format(avif)/abc.png"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app := factory(tt.options...)
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/"+tt.image, nil)
+			r.Header.Set("Accept", accept)
+			app.ServeHTTP(w, r)
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			assert.Equal(t, tt.result, w.Body.String())
+		})
+	}
+}
+
+func TestAutoFormats(t *testing.T) {
+	gif, err := os.ReadFile("testdata/dancing-banana.gif")
+	require.NoError(t, err)
+	app := New(
+		WithUnsafe(true),
+		WithAutoWebP(true),
+		WithAutoFormats("avif", "WEBP", "jpg", ""),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			if image == "anim.gif" {
+				return NewBlobFromBytes(gif), nil
+			}
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			return NewBlobFromBytes([]byte(p.Path)), nil
+		})),
+	)
+	assert.Equal(t, []string{"avif", "webp", "jpeg"}, app.AutoFormats)
+	for _, tt := range []struct {
+		name   string
+		image  string
+		accept string
+		result string
+	}{
+		{name: "quality values", image: "abc.png", accept: "image/avif;q=0.9,image/webp;q=0.8", result: "filters:format(avif)/abc.png"},
+		{name: "higher quality wins", image: "abc.png", accept: "image/avif;q=0.5,image/webp;q=0.8", result: "filters:format(webp)/abc.png"},
+		{name: "zero quality excluded", image: "abc.png", accept: "image/avif;q=0,image/webp", result: "filters:format(webp)/abc.png"},
+		{name: "equal quality by priority", image: "abc.png", accept: "image/webp,image/avif", result: "filters:format(avif)/abc.png"},
+		{name: "browser img tag", image: "abc.png", accept: "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", result: "filters:format(avif)/abc.png"},
+		{name: "jpeg", image: "abc.png", accept: "image/jpeg, image/*;q=0.8", result: "filters:format(jpeg)/abc.png"},
+		{name: "wildcards not considered", image: "abc.png", accept: "image/*,*/*;q=0.8", result: "abc.png"},
+		{name: "no accept", image: "abc.png", result: "abc.png"},
+		{name: "animated keeps animation", image: "anim.gif", accept: "image/avif,image/webp;q=0.8", result: "filters:format(webp)/anim.gif"},
+		{name: "animated no animation format", image: "anim.gif", accept: "image/avif,image/jpeg", result: "anim.gif"},
+		{name: "animated by animation format", image: "anim.gif", accept: "image/webp,image/avif;q=0.8", result: "filters:format(webp)/anim.gif"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/"+tt.image, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			app.ServeHTTP(w, r)
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, tt.result, w.Body.String())
+			if tt.result != tt.image {
+				assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
 func TestWithTimeout(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.String(), "sleep") {
@@ -1523,6 +3132,54 @@ func TestWithTimeout(t *testing.T) {
 	}
 }
 
+func TestWithMaxProcessTimeout(t *testing.T) {
+	var deadlines = make(chan time.Duration, 1)
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithUnsafe(true),
+		WithSigner(signer),
+		WithProcessTimeout(time.Second),
+		WithMaxProcessTimeout(time.Minute),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			deadline, _ := ctx.Deadline()
+			deadlines <- time.Until(deadline)
+			for _, f := range p.Filters {
+				assert.NotEqual(t, "timeout", f.Name)
+			}
+			return blob, nil
+		})),
+		WithRequestTimeout(time.Hour),
+	)
+	for _, tt := range []struct {
+		name   string
+		path   string
+		expect time.Duration
+	}{
+		{"signed", imagorpath.Generate(imagorpath.Params{
+			Image: "foo.pdf", Filters: imagorpath.Filters{{Name: "timeout", Args: "30"}}}, signer),
+			time.Second * 30},
+		{"signed clamped", imagorpath.Generate(imagorpath.Params{
+			Image: "foo.pdf", Filters: imagorpath.Filters{{Name: "timeout", Args: "600"}}}, signer),
+			time.Minute},
+		{"signed invalid", imagorpath.Generate(imagorpath.Params{
+			Image: "foo.pdf", Filters: imagorpath.Filters{{Name: "timeout", Args: "-5"}}}, signer),
+			time.Second},
+		{"unsafe", "unsafe/filters:timeout(30)/foo.pdf", time.Second},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/"+tt.path, nil))
+			assert.Equal(t, 200, w.Code)
+			remaining := <-deadlines
+			assert.True(t, remaining <= tt.expect && remaining > tt.expect-time.Second/2,
+				"expect %s got %s", tt.expect, remaining)
+		})
+	}
+}
+
 func TestSuppression(t *testing.T) {
 	app := New(
 		WithDebug(true), WithLogger(zap.NewExample()),
@@ -1611,3 +3268,76 @@ func (f processorFunc) Startup(_ context.Context) error {
 func (f processorFunc) Shutdown(_ context.Context) error {
 	return nil
 }
+
+func TestWithDefaultGravity(t *testing.T) {
+	app := New(
+		WithUnsafe(true),
+		WithDefaultGravity("top"),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			return NewBlobFromBytes([]byte(p.Path)), nil
+		})))
+	assert.Equal(t, "top", app.DefaultGravity)
+	for uri, path := range map[string]string{
+		"/unsafe/100x100/abc.png":                        "100x100/top/abc.png",
+		"/unsafe/100x100/left/abc.png":                   "100x100/left/abc.png",
+		"/unsafe/100x100/bottom/abc.png":                 "100x100/bottom/abc.png",
+		"/unsafe/100x100/smart/abc.png":                  "100x100/smart/abc.png",
+		"/unsafe/100x100/filters:focal(0.5x0.3)/abc.png": "100x100/filters:focal(0.5x0.3)/abc.png",
+		"/unsafe/100x100/filters:smart(entropy)/abc.png": "100x100/filters:smart(entropy)/abc.png",
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, uri, nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, path, w.Body.String(), uri)
+	}
+	assert.Empty(t, New(WithDefaultGravity("foo")).DefaultGravity)
+	assert.Empty(t, New(WithDefaultGravity("top-bottom")).DefaultGravity)
+	assert.Equal(t, "Bottom-Right", New(WithDefaultGravity("Bottom-Right")).DefaultGravity)
+
+	for gravity, align := range map[string][2]string{
+		"top":          {"", "top"},
+		"bottom-right": {"right", "bottom"},
+		"left_top":     {"left", "top"},
+		"center":       {"", ""},
+	} {
+		h, v, ok := parseGravity(gravity)
+		assert.True(t, ok, gravity)
+		assert.Equal(t, align, [2]string{h, v}, gravity)
+	}
+}
+
+func TestWithKeyNormalizer(t *testing.T) {
+	var loaded []string
+	signer := imagorpath.NewDefaultSigner("1234")
+	app := New(
+		WithSigner(signer),
+		WithKeyNormalizer(func(image string) string {
+			return strings.TrimSuffix(strings.ToLower(image), "/")
+		}),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			loaded = append(loaded, image)
+			return NewBlobFromBytes([]byte(image)), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			if _, err := load("Watermark.PNG"); err != nil {
+				return nil, err
+			}
+			return NewBlobFromBytes([]byte(p.Path)), nil
+		})))
+
+	path := "100x100/foo/bar.png"
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/"+signer.Sign(path)+"/100x100/Foo/BAR.png/", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, path, w.Body.String())
+	assert.Equal(t, []string{"foo/bar.png", "watermark.png"}, loaded)
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/"+signer.Sign("100x100/Foo/BAR.png")+"/100x100/Foo/BAR.png", nil))
+	assert.Equal(t, 403, w.Code, "normalized form is signed")
+}
@@ -0,0 +1,183 @@
+package imagorpath
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builder fluent builder of imagor endpoint, generates path through Params
+// so that Parse of the built path results in the same Params
+type Builder struct {
+	params Params
+}
+
+// NewBuilder creates Builder of image
+func NewBuilder(image string) Builder {
+	return Builder{params: Params{Image: image}}
+}
+
+// Image sets source image
+func (b Builder) Image(image string) Builder {
+	b.params.Image = image
+	return b
+}
+
+// Meta sets metadata mode
+func (b Builder) Meta() Builder {
+	b.params.Meta = true
+	return b
+}
+
+// Trim removes surrounding space of image, by top-left pixel color
+func (b Builder) Trim() Builder {
+	b.params.Trim = true
+	b.params.TrimBy = TrimByTopLeft
+	return b
+}
+
+// TrimBy removes surrounding space of image by top-left or bottom-right pixel color with tolerance
+func (b Builder) TrimBy(by string, tolerance int) Builder {
+	b.params.Trim = true
+	b.params.TrimBy = by
+	b.params.TrimTolerance = tolerance
+	return b
+}
+
+// Crop sets manual crop of left-top and right-bottom points
+func (b Builder) Crop(left, top, right, bottom float64) Builder {
+	b.params.CropLeft = left
+	b.params.CropTop = top
+	b.params.CropRight = right
+	b.params.CropBottom = bottom
+	return b
+}
+
+// Resize sets target width and height, negative values flip the image
+func (b Builder) Resize(width, height int) Builder {
+	if width < 0 {
+		width = -width
+		b.params.HFlip = !b.params.HFlip
+	}
+	if height < 0 {
+		height = -height
+		b.params.VFlip = !b.params.VFlip
+	}
+	b.params.Width = width
+	b.params.Height = height
+	return b
+}
+
+// FitIn fits image within the target dimensions
+func (b Builder) FitIn() Builder {
+	b.params.FitIn = true
+	return b
+}
+
+// Stretch stretches image to the target dimensions regardless of aspect ratio
+func (b Builder) Stretch() Builder {
+	b.params.Stretch = true
+	return b
+}
+
+// HFlip flips image horizontally
+func (b Builder) HFlip() Builder {
+	b.params.HFlip = !b.params.HFlip
+	return b
+}
+
+// VFlip flips image vertically
+func (b Builder) VFlip() Builder {
+	b.params.VFlip = !b.params.VFlip
+	return b
+}
+
+// Padding sets left, top, right and bottom padding
+func (b Builder) Padding(left, top, right, bottom int) Builder {
+	b.params.PaddingLeft = left
+	b.params.PaddingTop = top
+	b.params.PaddingRight = right
+	b.params.PaddingBottom = bottom
+	return b
+}
+
+// HAlign sets horizontal alignment left or right, otherwise center
+func (b Builder) HAlign(align string) Builder {
+	b.params.HAlign = ""
+	if align == HAlignLeft || align == HAlignRight {
+		b.params.HAlign = align
+	}
+	return b
+}
+
+// VAlign sets vertical alignment top or bottom, otherwise middle
+func (b Builder) VAlign(align string) Builder {
+	b.params.VAlign = ""
+	if align == VAlignTop || align == VAlignBottom {
+		b.params.VAlign = align
+	}
+	return b
+}
+
+// Smart enables smart detection of focal point
+func (b Builder) Smart() Builder {
+	b.params.Smart = true
+	return b
+}
+
+// Filter appends filter with args, in order of calls.
+// Args containing comma or parentheses are url encoded
+func (b Builder) Filter(name string, args ...string) Builder {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, ",()") {
+			arg = url.QueryEscape(arg)
+		}
+		escaped[i] = arg
+	}
+	return b.Filters(Filter{Name: name, Args: strings.Join(escaped, ",")})
+}
+
+// Filters appends filters, in order of calls, with args used as is
+func (b Builder) Filters(filters ...Filter) Builder {
+	// copy to avoid sharing filters across derived builders
+	f := make(Filters, 0, len(b.params.Filters)+len(filters))
+	f = append(f, b.params.Filters...)
+	b.params.Filters = append(f, filters...)
+	return b
+}
+
+// Expire appends expire filter with unix milliseconds timestamp,
+// covered by the URL signature so that expiry cannot be tampered
+func (b Builder) Expire(t time.Time) Builder {
+	return b.Filter("expire", strconv.FormatInt(t.UnixMilli(), 10))
+}
+
+// Params returns Params of builder
+func (b Builder) Params() Params {
+	p := b.params
+	p.Filters = append(Filters(nil), b.params.Filters...)
+	return p
+}
+
+// Path generates imagor path without signature.
+// Image is url encoded if it would otherwise be parsed differently
+func (b Builder) Path() string {
+	p := b.params
+	imgPath := GeneratePath(p)
+	if p.Image != "" && Parse("unsafe/"+imgPath).Image != p.Image {
+		p.Image = url.QueryEscape(p.Image)
+		imgPath = GeneratePath(p)
+	}
+	return imgPath
+}
+
+// Build generates imagor endpoint signed by signer, or unsafe if signer is nil
+func (b Builder) Build(signer Signer) string {
+	imgPath := b.Path()
+	if signer != nil {
+		return signer.Sign(imgPath) + "/" + imgPath
+	}
+	return "unsafe/" + imgPath
+}
@@ -1,12 +1,14 @@
 package imagorpath
 
 import (
+	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseGenerate(t *testing.T) {
@@ -422,6 +424,44 @@ func TestHMACSigner(t *testing.T) {
 	assert.Equal(t, signer.Sign("assfasf"), "zb6uWXQxwJDOe_zOgxkuj96Etrsz")
 }
 
+func TestMultiHMACSigner(t *testing.T) {
+	signer := NewMultiHMACSigner(sha256.New, 28, "new", "old")
+	assert.Equal(t, NewHMACSigner(sha256.New, 28, "new").Sign("assfasf"), signer.Sign("assfasf"))
+
+	verifier := signer.(Verifier)
+	assert.True(t, verifier.Verify("assfasf", NewHMACSigner(sha256.New, 28, "new").Sign("assfasf")))
+	assert.True(t, verifier.Verify("assfasf", NewHMACSigner(sha256.New, 28, "old").Sign("assfasf")))
+	assert.False(t, verifier.Verify("assfasf", NewHMACSigner(sha256.New, 28, "other").Sign("assfasf")))
+	assert.False(t, verifier.Verify("assfasf", ""))
+
+	assert.Equal(t, NewDefaultSigner("").Sign("assfasf"), NewMultiHMACSigner(sha1.New, 0).Sign("assfasf"))
+}
+
+func TestPrefixSigner(t *testing.T) {
+	signers := map[string]Signer{
+		"":     NewDefaultSigner("abcd"),
+		"s256": NewHMACSigner(sha256.New, 0, "abcd"),
+	}
+	signer := NewPrefixSigner("s256", signers)
+	hash := signer.Sign("fit-in/100x100/foo.jpg")
+	assert.Equal(t, "s256:"+signers["s256"].Sign("fit-in/100x100/foo.jpg"), hash)
+
+	uri := Generate(Params{FitIn: true, Width: 100, Height: 100, Image: "foo.jpg"}, signer)
+	p := Parse(uri)
+	assert.Equal(t, hash, p.Hash)
+	assert.Equal(t, "fit-in/100x100/foo.jpg", p.Path)
+
+	verifier := signer.(Verifier)
+	assert.True(t, verifier.Verify(p.Path, p.Hash))
+	assert.True(t, verifier.Verify(p.Path, signers[""].Sign(p.Path)), "unprefixed default")
+	assert.False(t, verifier.Verify(p.Path, "s512:"+signers["s256"].Sign(p.Path)))
+	assert.False(t, verifier.Verify(p.Path, "s256:"))
+	assert.False(t, verifier.Verify("fit-in/100x101/foo.jpg", p.Hash))
+	assert.Equal(t, "", NewPrefixSigner("s1", signers).Sign("foo.jpg"))
+
+	assert.Empty(t, Parse("trim:top-left/200x200/foo.jpg").Hash, "should not parse trim as hash")
+}
+
 func TestParseFilters(t *testing.T) {
 	filters, img := parseFilters("filters:watermark(s.glbimg.com/filters:label(abc):watermark(aaa.com/fit-in/filters:aaa(bbb))/aaa.jpg,0,0,0):brightness(-50):grayscale()/some/example/img")
 	assert.Equal(t, []Filter{
@@ -460,3 +500,104 @@ func TestParseFilters(t *testing.T) {
 	}, filters)
 	assert.Empty(t, img)
 }
+
+func TestBuilder(t *testing.T) {
+	signer := NewDefaultSigner("1234")
+	b := NewBuilder("raw.githubusercontent.com/cshum/imagor/master/testdata/gopher.png?v=1").
+		Resize(-300, 200).FitIn().Padding(10, 20, 10, 20).
+		HAlign(HAlignLeft).VAlign(VAlignTop).Smart().
+		Filter("fill", "white").
+		Filter("watermark", "raw.githubusercontent.com/cshum/imagor/master/testdata/gopher-front.png", "repeat", "bottom", "10").
+		Filter("format", "jpeg")
+
+	uri := b.Build(signer)
+	assert.Equal(t, "fit-in/-300x200/10x20/left/top/smart/filters:fill(white):watermark(raw.githubusercontent.com/cshum/imagor/master/testdata/gopher-front.png,repeat,bottom,10):format(jpeg)/raw.githubusercontent.com%2Fcshum%2Fimagor%2Fmaster%2Ftestdata%2Fgopher.png%3Fv%3D1", b.Path())
+
+	p := Parse(uri)
+	assert.Equal(t, signer.Sign(p.Path), p.Hash, "should verify against signer")
+	expected := b.Params()
+	expected.Path = p.Path
+	expected.Hash = p.Hash
+	assert.Equal(t, expected, p, "should round trip")
+	assert.Equal(t, uri, Generate(p, signer))
+
+	assert.Equal(t, "unsafe/"+b.Path(), b.Build(nil))
+
+	exp := time.UnixMilli(1700000000000)
+	assert.Equal(t, "unsafe/filters:expire(1700000000000)/foo.jpg", NewBuilder("foo.jpg").Expire(exp).Build(nil))
+
+	// derived builders do not share filters
+	b1 := b.Filter("grayscale")
+	b2 := b.Filter("blur", "2")
+	assert.Len(t, b.Params().Filters, 3)
+	assert.Equal(t, "grayscale", b1.Params().Filters[3].Name)
+	assert.Equal(t, "blur", b2.Params().Filters[3].Name)
+
+	assert.Equal(t, "unsafe/meta/trim/0x0:100x100/stretch/50x50/center%2Fmiddle.jpg",
+		NewBuilder("center/middle.jpg").Meta().Trim().Crop(0, 0, 100, 100).Stretch().Resize(50, 50).
+			HAlign("center").VAlign("middle").Build(nil))
+}
+
+func TestBuilderRoundTrip(t *testing.T) {
+	signer := NewDefaultSigner("1234")
+	tests := []struct {
+		name    string
+		builder Builder
+		path    string
+	}{
+		{
+			name:    "image like dimensions",
+			builder: NewBuilder("100x100/img.jpg"),
+			path:    "100x100%2Fimg.jpg",
+		},
+		{
+			name:    "image like filters",
+			builder: NewBuilder("filters:x/img.jpg"),
+			path:    "filters%3Ax%2Fimg.jpg",
+		},
+		{
+			name:    "image after filters not escaped",
+			builder: NewBuilder("100x100/img.jpg").Resize(50, 50).Filter("grayscale"),
+			path:    "50x50/filters:grayscale()/100x100/img.jpg",
+		},
+		{
+			name:    "image with plus and percent",
+			builder: NewBuilder("a+b%20c.jpg").Resize(50, 50),
+			path:    "50x50/a%2Bb%2520c.jpg",
+		},
+		{
+			name:    "image with leading slash",
+			builder: NewBuilder("/img.jpg"),
+			path:    "%2Fimg.jpg",
+		},
+		{
+			name:    "plain image not escaped",
+			builder: NewBuilder("foo/bar.jpg").Resize(50, 50),
+			path:    "50x50/foo/bar.jpg",
+		},
+		{
+			name: "filter args with comma and parentheses",
+			builder: NewBuilder("img.jpg").
+				Filter("watermark", "foo/bar(1),baz.png", "repeat", "bottom").
+				Filter("text", "a)b", "10", "10"),
+			path: "filters:watermark(foo%2Fbar%281%29%2Cbaz.png,repeat,bottom):text(a%29b,10,10)/img.jpg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.path, tt.builder.Path())
+			uri := tt.builder.Build(signer)
+			p := Parse(uri)
+			assert.Equal(t, signer.Sign(p.Path), p.Hash, "should verify against signer")
+			expected := tt.builder.Params()
+			expected.Path = p.Path
+			expected.Hash = p.Hash
+			assert.Equal(t, expected, p, "should round trip")
+
+			p = Parse(tt.builder.Build(nil))
+			assert.True(t, p.Unsafe)
+			assert.Equal(t, tt.builder.Params().Image, p.Image)
+			assert.Equal(t, tt.builder.Params().Filters, p.Filters)
+		})
+	}
+}
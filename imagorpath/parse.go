@@ -12,7 +12,7 @@ var pathRegex = regexp.MustCompile(
 		// params
 		"(params/)?" +
 		// hash
-		"((unsafe/)|([A-Za-z0-9-_=]{8,})/)?" +
+		"((unsafe/)|((?:s\\d+:)?[A-Za-z0-9-_=]{8,})/)?" +
 		// path
 		"(.+)?",
 )
@@ -5,6 +5,7 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"hash"
+	"strings"
 )
 
 // Signer imagor URL signature signer
@@ -26,6 +27,42 @@ func NewHMACSigner(alg func() hash.Hash, truncate int, secret string) Signer {
 	}
 }
 
+// NewMultiHMACSigner HMAC alg signer with multiple secrets for key rotation,
+// signs with the first secret and verifies against any of the secrets
+func NewMultiHMACSigner(alg func() hash.Hash, truncate int, secrets ...string) Signer {
+	if len(secrets) == 0 {
+		secrets = []string{""}
+	}
+	s := &multiHMACSigner{}
+	for _, secret := range secrets {
+		s.signers = append(s.signers, &hmacSigner{
+			alg:      alg,
+			truncate: truncate,
+			secret:   []byte(secret),
+		})
+	}
+	return s
+}
+
+type multiHMACSigner struct {
+	signers []*hmacSigner
+}
+
+func (s *multiHMACSigner) Sign(path string) string {
+	return s.signers[0].Sign(path)
+}
+
+func (s *multiHMACSigner) Verify(path, hash string) bool {
+	var ok bool
+	for _, signer := range s.signers {
+		// compare all candidates in constant time
+		if hmac.Equal([]byte(signer.Sign(path)), []byte(hash)) {
+			ok = true
+		}
+	}
+	return ok
+}
+
 type hmacSigner struct {
 	alg      func() hash.Hash
 	truncate int
@@ -41,3 +78,49 @@ func (s *hmacSigner) Sign(path string) string {
 	}
 	return sig
 }
+
+// Verifier imagor URL signature verifier
+type Verifier interface {
+	Verify(path, hash string) bool
+}
+
+// NewPrefixSigner signer that marks signature with algorithm prefix e.g. s256:xxx,
+// signs with signer of prefix, and verifies signature by signer of its prefix marker.
+// Signer of empty prefix signs and verifies plain unprefixed signatures
+func NewPrefixSigner(prefix string, signers map[string]Signer) Signer {
+	return &prefixSigner{
+		prefix:  prefix,
+		signers: signers,
+	}
+}
+
+type prefixSigner struct {
+	prefix  string
+	signers map[string]Signer
+}
+
+func (s *prefixSigner) Sign(path string) string {
+	signer, ok := s.signers[s.prefix]
+	if !ok {
+		return ""
+	}
+	if s.prefix == "" {
+		return signer.Sign(path)
+	}
+	return s.prefix + ":" + signer.Sign(path)
+}
+
+func (s *prefixSigner) Verify(path, hash string) bool {
+	var prefix string
+	if i := strings.Index(hash, ":"); i >= 0 {
+		prefix, hash = hash[:i], hash[i+1:]
+	}
+	signer, ok := s.signers[prefix]
+	if !ok || hash == "" {
+		return false
+	}
+	if verifier, ok := signer.(Verifier); ok {
+		return verifier.Verify(path, hash)
+	}
+	return hmac.Equal([]byte(signer.Sign(path)), []byte(hash))
+}
@@ -0,0 +1,68 @@
+package dataloader
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/cshum/imagor"
+)
+
+// Scheme data URI image key scheme e.g. data:image/png;base64,iVBORw0KGgo...
+const Scheme = "data:"
+
+// DataLoader Data URI Loader implements imagor.Loader interface,
+// loads image embedded as base64 data URI image key
+type DataLoader struct {
+	// MaxAllowedSize maximum decoded bytes allowed for image
+	MaxAllowedSize int
+}
+
+// New creates DataLoader
+func New(options ...Option) *DataLoader {
+	l := &DataLoader{}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// Get implements imagor.Loader interface
+func (l *DataLoader) Get(_ *http.Request, image string) (*imagor.Blob, error) {
+	if !strings.HasPrefix(image, Scheme) {
+		return nil, imagor.ErrInvalid
+	}
+	meta, data, ok := strings.Cut(strings.TrimPrefix(image, Scheme), ",")
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	meta, ok = strings.CutSuffix(meta, ";base64")
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	mediaType, _, err := mime.ParseMediaType(meta)
+	if err != nil || !strings.HasPrefix(mediaType, "image/") {
+		return nil, imagor.ErrInvalid
+	}
+	if l.MaxAllowedSize > 0 && base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(data, "="))) > l.MaxAllowedSize {
+		// reject before decoding
+		return nil, imagor.ErrMaxSizeExceeded
+	}
+	buf, err := decode(data)
+	if err != nil {
+		return nil, imagor.NewError("invalid base64 data", http.StatusBadRequest)
+	}
+	blob := imagor.NewBlobFromBytes(buf)
+	blob.SetContentType(mediaType)
+	return blob, nil
+}
+
+// decode base64 data in standard or URL encoding, with or without padding
+func decode(data string) ([]byte, error) {
+	data = strings.TrimRight(data, "=")
+	if strings.ContainsAny(data, "-_") {
+		return base64.RawURLEncoding.DecodeString(data)
+	}
+	return base64.RawStdEncoding.DecodeString(data)
+}
@@ -0,0 +1,58 @@
+package dataloader
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataLoader(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	png := []byte("\x89PNG\r\n\x1a\nfoobar?>")
+
+	t.Run("get", func(t *testing.T) {
+		l := New()
+		for _, image := range []string{
+			"data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+			"data:image/png;base64," + base64.RawStdEncoding.EncodeToString(png),
+			"data:image/png;base64," + base64.URLEncoding.EncodeToString(png),
+			"data:image/png;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(png),
+		} {
+			blob, err := l.Get(r, image)
+			require.NoError(t, err, image)
+			buf, err := blob.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, png, buf, image)
+			assert.Equal(t, "image/png", blob.ContentType(), image)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		l := New()
+		for _, image := range []string{
+			"foo.png",
+			"data:image/png;base64",
+			"data:image/png," + base64.StdEncoding.EncodeToString(png),
+			"data:text/html;base64," + base64.StdEncoding.EncodeToString(png),
+			"data:;base64," + base64.StdEncoding.EncodeToString(png),
+		} {
+			_, err := l.Get(r, image)
+			assert.Equal(t, imagor.ErrInvalid, err, image)
+		}
+		_, err := l.Get(r, "data:image/png;base64,!!!!")
+		assert.Equal(t, 400, imagor.WrapError(err).Code)
+	})
+
+	t.Run("max allowed size", func(t *testing.T) {
+		l := New(WithMaxAllowedSize(len(png)))
+		assert.Equal(t, len(png), l.MaxAllowedSize)
+		_, err := l.Get(r, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(png))
+		assert.NoError(t, err)
+		_, err = l.Get(r, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(append(png, 'a')))
+		assert.Equal(t, imagor.ErrMaxSizeExceeded, err)
+	})
+}
@@ -0,0 +1,13 @@
+package dataloader
+
+// Option DataLoader option
+type Option func(l *DataLoader)
+
+// WithMaxAllowedSize with maximum allowed decoded size option
+func WithMaxAllowedSize(maxAllowedSize int) Option {
+	return func(l *DataLoader) {
+		if maxAllowedSize > 0 {
+			l.MaxAllowedSize = maxAllowedSize
+		}
+	}
+}
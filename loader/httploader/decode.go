@@ -0,0 +1,114 @@
+package httploader
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/cshum/imagor"
+)
+
+// sniffSize bytes peeked from decoded body for content type sniffing
+const sniffSize = 512
+
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decodedBody) Close() (err error) {
+	for _, c := range d.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// maxBytesReader returns imagor.ErrMaxSizeExceeded once read beyond n bytes
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.n < 0 {
+		return 0, imagor.ErrMaxSizeExceeded
+	}
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if m.n < 0 {
+		return n, imagor.ErrMaxSizeExceeded
+	}
+	return n, err
+}
+
+// contentEncoding returns supported Content-Encoding of response,
+// empty if identity or unknown, so that body is passed through as is
+func contentEncoding(header http.Header) string {
+	enc := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding")))
+	switch enc {
+	case "gzip", "x-gzip", "deflate", "br":
+		return enc
+	}
+	return ""
+}
+
+// decodeBody decompresses gzip, deflate or br encoded body,
+// bounded by maxSize decompressed bytes against decompression bombs,
+// and returns content type sniffed from the decompressed bytes
+func decodeBody(encoding string, body io.ReadCloser, maxSize int) (io.ReadCloser, string, error) {
+	var r io.Reader
+	var closers []io.Closer
+	switch encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, "", err
+		}
+		r = gr
+		closers = append(closers, gr)
+	case "deflate":
+		// deflate should be zlib wrapped, some origins send raw deflate
+		br := bufio.NewReader(body)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, "", err
+			}
+			r = zr
+			closers = append(closers, zr)
+		} else {
+			fr := flate.NewReader(br)
+			r = fr
+			closers = append(closers, fr)
+		}
+	case "br":
+		r = brotli.NewReader(body)
+	default:
+		return nil, "", imagor.ErrUnsupportedFormat
+	}
+	closers = append(closers, body)
+	if maxSize > 0 {
+		r = &maxBytesReader{r: r, n: int64(maxSize)}
+	}
+	br := bufio.NewReaderSize(r, sniffSize)
+	buf, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+	contentType := imagor.NewBlobFromBytes(buf).ContentType()
+	return &decodedBody{Reader: br, closers: closers}, contentType, nil
+}
+
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
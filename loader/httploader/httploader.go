@@ -1,7 +1,7 @@
 package httploader
 
 import (
-	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,8 +14,10 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cshum/imagor"
+	"golang.org/x/time/rate"
 )
 
 // AllowedSource represents a source the HTTPLoader is allowed to load from.
@@ -66,6 +68,16 @@ type HTTPLoader struct {
 	// OverrideResponseHeaders override image response header from HTTP Loader response
 	OverrideResponseHeaders []string
 
+	// RespectOriginCacheControl derive cache TTL from origin Cache-Control and Expires response headers,
+	// and Stat from origin ETag and Last-Modified response headers
+	RespectOriginCacheControl bool
+
+	// OriginCacheMinTTL minimum cache TTL derived from origin if set
+	OriginCacheMinTTL time.Duration
+
+	// OriginCacheMaxTTL maximum cache TTL derived from origin if set
+	OriginCacheMaxTTL time.Duration
+
 	// AllowedSources list of sources allowed to load from
 	AllowedSources []AllowedSource
 
@@ -97,6 +109,25 @@ type HTTPLoader struct {
 	// BaseURL base URL for HTTP loader
 	BaseURL *url.URL
 
+	// RequestBuilder builds image request in place of the default GET request,
+	// for customizing method, headers and body per image.
+	// Runs before User-Agent, Accept, forward and override headers are applied
+	RequestBuilder func(ctx context.Context, image string) (*http.Request, error)
+
+	// MaxRetries maximum retries of image request on network errors and retryable status codes
+	MaxRetries int
+
+	// RetryBackoff base backoff duration between retries, doubled on each retry
+	RetryBackoff time.Duration
+
+	// RateLimit maximum requests per second per host if set
+	RateLimit rate.Limit
+
+	// RateLimitBurst maximum burst requests per host
+	RateLimitBurst int
+
+	rateLimiter *hostRateLimiter
+
 	accepts []string
 }
 
@@ -107,6 +138,7 @@ func New(options ...Option) *HTTPLoader {
 		DefaultScheme:   "https",
 		Accept:          "*/*",
 		UserAgent:       fmt.Sprintf("imagor/%s", imagor.Version),
+		RetryBackoff:    time.Millisecond * 100,
 	}
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	dialer := &net.Dialer{Control: h.DialControl}
@@ -119,6 +151,9 @@ func New(options ...Option) *HTTPLoader {
 	if s := strings.ToLower(h.DefaultScheme); s == "nil" {
 		h.DefaultScheme = ""
 	}
+	if h.RateLimit > 0 {
+		h.rateLimiter = newHostRateLimiter(h.RateLimit, h.RateLimitBurst)
+	}
 	if h.Accept != "" {
 		for _, seg := range strings.Split(h.Accept, ",") {
 			if typ := parseContentType(seg); typ != "" {
@@ -167,12 +202,12 @@ func (h *HTTPLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
 		Transport:     h.Transport,
 		CheckRedirect: h.checkRedirect,
 	}
-	if h.MaxAllowedSize > 0 {
+	if h.MaxAllowedSize > 0 && h.RequestBuilder == nil {
 		req, err := h.newRequest(r, http.MethodHead, image)
 		if err != nil {
 			return nil, err
 		}
-		resp, err := client.Do(req)
+		resp, err := h.do(client, req)
 		if err != nil {
 			return nil, err
 		}
@@ -192,7 +227,7 @@ func (h *HTTPLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
 	var blob *imagor.Blob
 	var once sync.Once
 	blob = imagor.NewBlob(func() (io.ReadCloser, int64, error) {
-		resp, err := client.Do(req)
+		resp, err := h.do(client, req)
 		if err != nil {
 			if errors.Is(err, ErrUnauthorizedRequest) {
 				err = imagor.NewError(
@@ -205,31 +240,39 @@ func (h *HTTPLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
 			}
 			return nil, 0, err
 		}
-		once.Do(func() {
-			blob.SetContentType(resp.Header.Get("Content-Type"))
-			if len(h.OverrideResponseHeaders) > 0 {
-				blob.Header = make(http.Header)
-				for _, key := range h.OverrideResponseHeaders {
-					if val := resp.Header.Get(key); val != "" {
-						blob.Header.Set(key, val)
-					}
-				}
-			}
-		})
 		body := resp.Body
 		size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
-		if resp.Header.Get("Content-Encoding") == "gzip" {
-			gzipBody, err := gzip.NewReader(resp.Body)
-			if err != nil {
+		contentType := resp.Header.Get("Content-Type")
+		if enc := contentEncoding(resp.Header); enc != "" {
+			// true content type sniffed from decompressed bytes
+			if body, contentType, err = decodeBody(enc, resp.Body, h.MaxAllowedSize); err != nil {
+				_ = resp.Body.Close()
+				if resp.StatusCode >= 400 {
+					err = imagor.NewErrorFromStatusCode(resp.StatusCode)
+				}
 				return nil, 0, err
 			}
-			body = gzipBody
 			size = 0 // size unknown after decompress
 		}
+		once.Do(func() {
+			blob.SetContentType(contentType)
+			for _, key := range h.OverrideResponseHeaders {
+				if val := resp.Header.Get(key); val != "" {
+					blob.SetHeader(key, val)
+				}
+			}
+			if h.RespectOriginCacheControl && resp.StatusCode < 400 {
+				blob.CacheTTL = h.originCacheTTL(resp.Header)
+				blob.Stat = originStat(resp.Header)
+			}
+		})
 		if resp.StatusCode >= 400 {
 			return body, size, imagor.NewErrorFromStatusCode(resp.StatusCode)
 		}
-		if !validateContentType(resp.Header.Get("Content-Type"), h.accepts) {
+		if h.MaxAllowedSize > 0 && size > int64(h.MaxAllowedSize) {
+			return body, size, imagor.ErrMaxSizeExceeded
+		}
+		if !validateContentType(contentType, h.accepts) {
 			return body, size, imagor.ErrUnsupportedFormat
 		}
 		return body, size, nil
@@ -237,13 +280,82 @@ func (h *HTTPLoader) Get(r *http.Request, image string) (*imagor.Blob, error) {
 	return blob, nil
 }
 
-func (h *HTTPLoader) newRequest(r *http.Request, method, url string) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(r.Context(), method, url, nil)
+// do sends image request subject to per host rate limit,
+// retries on network errors and retryable status codes
+// with exponential backoff, within the remaining request context deadline
+func (h *HTTPLoader) do(client *http.Client, req *http.Request) (resp *http.Response, err error) {
+	ctx := req.Context()
+	for retry := 0; ; retry++ {
+		if h.rateLimiter != nil {
+			if err = h.rateLimiter.Wait(ctx, req.URL.Host); err != nil {
+				return
+			}
+		}
+		if req.GetBody != nil {
+			// request body per attempt
+			attempt := req.Clone(ctx)
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return
+			}
+			resp, err = client.Do(attempt)
+		} else {
+			resp, err = client.Do(req)
+		}
+		if retry >= h.MaxRetries || !isRetryable(resp, err) ||
+			(req.GetBody == nil && req.Body != nil && req.Body != http.NoBody) {
+			return
+		}
+		backoff := h.RetryBackoff << retry
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return
+		}
+		if resp != nil {
+			// response body not consumed and discarded before retry
+			_ = resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func isRetryable(resp *http.Response, err error) bool {
 	if err != nil {
+		return !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrUnauthorizedRequest) &&
+			!errors.Is(err, imagor.ErrSourceNotAllowed)
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (h *HTTPLoader) newRequest(r *http.Request, method, url string) (req *http.Request, err error) {
+	if h.RequestBuilder != nil && method == http.MethodGet {
+		if req, err = h.RequestBuilder(r.Context(), url); err != nil {
+			return nil, err
+		}
+		if !isURLAllowed(req.URL, h.AllowedSources) {
+			return nil, imagor.ErrSourceNotAllowed
+		}
+		req = req.WithContext(r.Context())
+	} else if req, err = http.NewRequestWithContext(r.Context(), method, url, nil); err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", h.UserAgent)
-	if h.Accept != "" {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", h.UserAgent)
+	}
+	if h.Accept != "" && req.Header.Get("Accept") == "" {
 		req.Header.Set("Accept", h.Accept)
 	}
 	for _, header := range h.ForwardHeaders {
@@ -300,3 +412,69 @@ func (h *HTTPLoader) DialControl(network string, address string, conn syscall.Ra
 	}
 	return nil
 }
+
+// originCacheTTL derives cache TTL from origin response headers,
+// bounded by min and max TTL. Returns negative for no cache, zero if not specified
+func (h *HTTPLoader) originCacheTTL(header http.Header) time.Duration {
+	ttl, ok := parseCacheTTL(header)
+	if !ok {
+		return 0
+	}
+	if ttl < 0 {
+		return -1
+	}
+	if h.OriginCacheMinTTL > 0 && ttl < h.OriginCacheMinTTL {
+		ttl = h.OriginCacheMinTTL
+	}
+	if h.OriginCacheMaxTTL > 0 && ttl > h.OriginCacheMaxTTL {
+		ttl = h.OriginCacheMaxTTL
+	}
+	if ttl == 0 {
+		return -1
+	}
+	return ttl
+}
+
+// originStat derives Stat from origin ETag and Last-Modified response headers
+// for conditional revalidation, nil if not specified
+func originStat(header http.Header) *imagor.Stat {
+	etag := header.Get("ETag")
+	modTime, _ := http.ParseTime(header.Get("Last-Modified"))
+	if etag == "" && modTime.IsZero() {
+		return nil
+	}
+	return &imagor.Stat{
+		ETag:         etag,
+		ModifiedTime: modTime,
+	}
+}
+
+func parseCacheTTL(header http.Header) (ttl time.Duration, ok bool) {
+	var maxAge, sMaxAge = -1, -1
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		key, val, _ := strings.Cut(strings.ToLower(strings.TrimSpace(directive)), "=")
+		switch key {
+		case "no-store", "no-cache", "private":
+			return -1, true
+		case "max-age":
+			maxAge, _ = strconv.Atoi(strings.Trim(val, `"`))
+		case "s-maxage":
+			sMaxAge, _ = strconv.Atoi(strings.Trim(val, `"`))
+		}
+	}
+	if sMaxAge >= 0 {
+		return time.Duration(sMaxAge) * time.Second, true
+	}
+	if maxAge >= 0 {
+		return time.Duration(maxAge) * time.Second, true
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil || !t.After(time.Now()) {
+			// invalid or past Expires means already expired
+			return 0, true
+		}
+		return time.Until(t).Truncate(time.Second), true
+	}
+	return 0, false
+}
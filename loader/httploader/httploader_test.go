@@ -2,18 +2,26 @@ package httploader
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"io"
 	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/cshum/imagor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 type testTransport map[string]string
@@ -523,6 +531,86 @@ func TestWithOverrideResponseHeader(t *testing.T) {
 	})
 }
 
+func TestWithRespectOriginCacheControl(t *testing.T) {
+	expires := time.Now().Add(time.Hour * 2).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name     string
+		header   map[string]string
+		expected time.Duration
+	}{
+		{name: "max-age", header: map[string]string{"Cache-Control": "public, max-age=600"}, expected: time.Minute * 10},
+		{name: "s-maxage", header: map[string]string{"Cache-Control": "max-age=600, s-maxage=1200"}, expected: time.Minute * 20},
+		{name: "min bound", header: map[string]string{"Cache-Control": "max-age=10"}, expected: time.Minute},
+		{name: "max bound", header: map[string]string{"Cache-Control": "max-age=999999"}, expected: time.Hour * 24},
+		{name: "no-store", header: map[string]string{"Cache-Control": "no-store"}, expected: -1},
+		{name: "private", header: map[string]string{"Cache-Control": "private, max-age=600"}, expected: -1},
+		{name: "expires", header: map[string]string{"Expires": expires}, expected: time.Hour*2 - time.Second},
+		{name: "expires past", header: map[string]string{"Expires": "Thu, 01 Jan 1970 00:00:00 GMT"}, expected: time.Minute},
+		{name: "not specified", expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := New(
+				WithTransport(roundTripFunc(func(r *http.Request) (w *http.Response, err error) {
+					res := &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     map[string][]string{},
+						Body:       io.NopCloser(strings.NewReader("ok")),
+					}
+					res.Header.Set("Content-Type", "image/jpeg")
+					for key, val := range tt.header {
+						res.Header.Set(key, val)
+					}
+					return res, nil
+				})),
+				WithRespectOriginCacheControl(true),
+				WithOriginCacheTTLBounds(time.Minute, time.Hour*24),
+			)
+			b, err := loader.Get(httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil), "https://foo.bar/baz")
+			require.NoError(t, err)
+			require.NoError(t, b.Err())
+			assert.InDelta(t, tt.expected, b.CacheTTL, float64(time.Second))
+		})
+	}
+	t.Run("disabled", func(t *testing.T) {
+		loader := New(WithTransport(roundTripFunc(func(r *http.Request) (w *http.Response, err error) {
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     map[string][]string{},
+				Body:       io.NopCloser(strings.NewReader("ok")),
+			}
+			res.Header.Set("Content-Type", "image/jpeg")
+			res.Header.Set("Cache-Control", "max-age=600")
+			return res, nil
+		})))
+		b, err := loader.Get(httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil), "https://foo.bar/baz")
+		require.NoError(t, err)
+		require.NoError(t, b.Err())
+		assert.Empty(t, b.CacheTTL)
+		assert.Nil(t, b.Stat)
+	})
+	t.Run("etag and last modified", func(t *testing.T) {
+		lastModified := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		loader := New(WithTransport(roundTripFunc(func(r *http.Request) (w *http.Response, err error) {
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     map[string][]string{},
+				Body:       io.NopCloser(strings.NewReader("ok")),
+			}
+			res.Header.Set("Content-Type", "image/jpeg")
+			res.Header.Set("ETag", `"abcd"`)
+			res.Header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+			return res, nil
+		})), WithRespectOriginCacheControl(true))
+		b, err := loader.Get(httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil), "https://foo.bar/baz")
+		require.NoError(t, err)
+		require.NoError(t, b.Err())
+		require.NotNil(t, b.Stat)
+		assert.Equal(t, `"abcd"`, b.Stat.ETag)
+		assert.True(t, lastModified.Equal(b.Stat.ModifiedTime))
+	})
+}
+
 func TestWithForwardClientHeaders(t *testing.T) {
 	doTests(t, New(
 		WithTransport(roundTripFunc(func(r *http.Request) (w *http.Response, err error) {
@@ -759,6 +847,82 @@ func TestWithGzip(t *testing.T) {
 	})
 }
 
+func TestWithContentEncoding(t *testing.T) {
+	png, err := os.ReadFile("../../testdata/gopher.png")
+	require.NoError(t, err)
+	encode := func(enc string, buf []byte) []byte {
+		var b bytes.Buffer
+		var w io.WriteCloser
+		switch enc {
+		case "gzip":
+			w = gzip.NewWriter(&b)
+		case "deflate":
+			w = zlib.NewWriter(&b)
+		case "raw-deflate":
+			w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+		case "br":
+			w = brotli.NewWriter(&b)
+		}
+		_, _ = w.Write(buf)
+		_ = w.Close()
+		return b.Bytes()
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := strings.TrimPrefix(r.URL.Path, "/")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		switch enc {
+		case "bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(encode("gzip", append(png, make([]byte, 1<<20)...)))
+		case "unknown":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Encoding", "zstd")
+			_, _ = w.Write(png)
+		case "raw-deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			_, _ = w.Write(encode(enc, png))
+		default:
+			w.Header().Set("Content-Encoding", enc)
+			_, _ = w.Write(encode(enc, png))
+		}
+	}))
+	defer ts.Close()
+	loader := New(
+		WithAccept("image/*"),
+		WithMaxAllowedSize(len(png)+1024),
+	)
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil)
+	// explicit Accept-Encoding disables transparent decompression of transport
+	r.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	loader.ForwardHeaders = []string{"Accept-Encoding"}
+
+	for _, enc := range []string{"gzip", "deflate", "raw-deflate", "br"} {
+		t.Run(enc, func(t *testing.T) {
+			b, err := loader.Get(r, ts.URL+"/"+enc)
+			require.NoError(t, err)
+			buf, err := b.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, png, buf)
+			assert.Equal(t, "image/png", b.ContentType())
+			assert.Equal(t, imagor.BlobTypePNG, b.BlobType())
+		})
+	}
+	t.Run("decompression bomb", func(t *testing.T) {
+		b, err := loader.Get(r, ts.URL+"/bomb")
+		require.NoError(t, err)
+		_, err = b.ReadAll()
+		assert.ErrorIs(t, err, imagor.ErrMaxSizeExceeded)
+	})
+	t.Run("unknown encoding passed through", func(t *testing.T) {
+		b, err := loader.Get(r, ts.URL+"/unknown")
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, png, buf)
+		assert.Equal(t, imagor.BlobTypePNG, b.BlobType())
+	})
+}
+
 func TestWithInvalidHost(t *testing.T) {
 	r, err := http.NewRequest(http.MethodGet, "http://example.com/unsafe/foo/bar", nil)
 	assert.NoError(t, err)
@@ -769,3 +933,188 @@ func TestWithInvalidHost(t *testing.T) {
 	assert.Empty(t, b)
 	assert.Equal(t, 404, err.(imagor.Error).Code)
 }
+
+func TestWithMaxRetries(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&count, 1)
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/notfound"):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case strings.HasPrefix(r.URL.Path, "/reset") && n == 1:
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		case strings.HasPrefix(r.URL.Path, "/unavailable") && n <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	load := func(h *HTTPLoader, path string) (string, error) {
+		atomic.StoreInt32(&count, 0)
+		b, err := h.Get(httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil), ts.URL+path)
+		if err != nil {
+			return "", err
+		}
+		buf, err := b.ReadAll()
+		return string(buf), err
+	}
+	h := New(WithMaxRetries(2), WithRetryBackoff(time.Millisecond))
+	assert.Equal(t, 2, h.MaxRetries)
+	assert.Equal(t, time.Millisecond, h.RetryBackoff)
+
+	res, err := load(h, "/unavailable")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	res, err = load(h, "/reset")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	_, err = load(h, "/notfound")
+	assert.Equal(t, imagor.NewErrorFromStatusCode(http.StatusNotFound), err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	_, err = load(New(WithMaxRetries(1), WithRetryBackoff(time.Millisecond)), "/unavailable")
+	assert.Equal(t, imagor.NewErrorFromStatusCode(http.StatusServiceUnavailable), err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	_, err = load(New(), "/unavailable")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	t.Run("backoff exceeds deadline", func(t *testing.T) {
+		atomic.StoreInt32(&count, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil).WithContext(ctx)
+		b, err := New(WithMaxRetries(2), WithRetryBackoff(time.Second)).Get(r, ts.URL+"/unavailable")
+		require.NoError(t, err)
+		_, err = b.ReadAll()
+		assert.Equal(t, imagor.NewErrorFromStatusCode(http.StatusServiceUnavailable), err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	})
+}
+
+func TestWithRateLimit(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	h := New(WithRateLimit(10, 2))
+	assert.Equal(t, rate.Limit(10), h.RateLimit)
+	assert.Equal(t, 2, h.RateLimitBurst)
+	load := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil).WithContext(ctx)
+		b, err := h.Get(r, ts.URL)
+		if err != nil {
+			return err
+		}
+		_, err = b.ReadAll()
+		return err
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, load(time.Second))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*80, "wait for rate limit")
+	assert.Equal(t, ErrRateLimitExceeded, load(time.Millisecond*10))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+
+	assert.Zero(t, New(WithRateLimit(0, 2)).RateLimit)
+	assert.Equal(t, 1, New(WithRateLimit(1, 0)).RateLimitBurst)
+}
+
+func TestHostRateLimiterEviction(t *testing.T) {
+	l := newHostRateLimiter(100, 1)
+	l.idle = time.Millisecond * 10
+	assert.NoError(t, l.Wait(context.Background(), "a.com"))
+	assert.NoError(t, l.Wait(context.Background(), "b.com"))
+	assert.Len(t, l.limiters, 2)
+	time.Sleep(time.Millisecond * 20)
+	assert.NoError(t, l.Wait(context.Background(), "c.com"))
+	assert.Len(t, l.limiters, 1)
+}
+
+func TestWithRequestBuilder(t *testing.T) {
+	var count int32
+	var head int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt32(&head, 1)
+		}
+		n := atomic.AddInt32(&count, 1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"image":"foo"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/unavailable") && n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte(r.Header.Get("X-Signature") + "," + r.Header.Get("X-Foo")))
+	}))
+	defer ts.Close()
+
+	builder := func(ctx context.Context, image string) (*http.Request, error) {
+		if strings.HasSuffix(image, "/invalid") {
+			return nil, imagor.ErrInvalid
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, image, strings.NewReader(`{"image":"foo"}`))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Signature", "abcd")
+		req.Header.Set("X-Foo", "builder")
+		return req, nil
+	}
+	load := func(h *HTTPLoader, image string) (string, error) {
+		atomic.StoreInt32(&count, 0)
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/imagor", nil)
+		r.Header.Set("X-Foo", "client")
+		b, err := h.Get(r, image)
+		if err != nil {
+			return "", err
+		}
+		buf, err := b.ReadAll()
+		return string(buf), err
+	}
+
+	res, err := load(New(WithRequestBuilder(builder)), ts.URL+"/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd,builder", res)
+
+	res, err = load(New(WithRequestBuilder(builder), WithForwardHeaders("X-Foo")), ts.URL+"/foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd,client", res, "forward headers applied after builder")
+
+	res, err = load(New(WithRequestBuilder(builder), WithMaxRetries(1), WithRetryBackoff(time.Millisecond)), ts.URL+"/unavailable")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd,builder", res, "body resent on retry")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	_, err = load(New(WithRequestBuilder(builder)), ts.URL+"/invalid")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	_, err = load(New(WithRequestBuilder(builder), WithAllowedSources("foo.com")), ts.URL+"/foo")
+	assert.Equal(t, imagor.ErrSourceNotAllowed, err)
+
+	_, err = load(New(WithRequestBuilder(builder), WithMaxAllowedSize(5)), ts.URL+"/foo")
+	assert.Equal(t, imagor.ErrMaxSizeExceeded, err)
+	assert.Zero(t, atomic.LoadInt32(&head), "no HEAD request")
+}
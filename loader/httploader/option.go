@@ -1,11 +1,15 @@
 package httploader
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
 // Option HTTPLoader option
@@ -126,6 +130,26 @@ func WithMaxAllowedSize(maxAllowedSize int) Option {
 	}
 }
 
+// WithRespectOriginCacheControl with option to derive response cache TTL from origin Cache-Control and Expires headers,
+// and Stat from origin ETag and Last-Modified headers
+func WithRespectOriginCacheControl(enabled bool) Option {
+	return func(h *HTTPLoader) {
+		h.RespectOriginCacheControl = enabled
+	}
+}
+
+// WithOriginCacheTTLBounds with minimum and maximum bounds of cache TTL derived from origin
+func WithOriginCacheTTLBounds(min, max time.Duration) Option {
+	return func(h *HTTPLoader) {
+		if min > 0 {
+			h.OriginCacheMinTTL = min
+		}
+		if max > 0 {
+			h.OriginCacheMaxTTL = max
+		}
+	}
+}
+
 // WithUserAgent with custom user agent option
 func WithUserAgent(userAgent string) Option {
 	return func(h *HTTPLoader) {
@@ -201,3 +225,47 @@ func WithBlockNetworks(networks ...*net.IPNet) Option {
 		h.BlockNetworks = networks
 	}
 }
+
+// WithMaxRetries with maximum retries option,
+// retries image request on network errors and retryable status codes
+func WithMaxRetries(n int) Option {
+	return func(h *HTTPLoader) {
+		if n > 0 {
+			h.MaxRetries = n
+		}
+	}
+}
+
+// WithRetryBackoff with base backoff duration between retries option
+func WithRetryBackoff(base time.Duration) Option {
+	return func(h *HTTPLoader) {
+		if base > 0 {
+			h.RetryBackoff = base
+		}
+	}
+}
+
+// WithRateLimit with per host token bucket rate limit option,
+// requests exceeding the limit wait up to the remaining load timeout
+func WithRateLimit(perHost rate.Limit, burst int) Option {
+	return func(h *HTTPLoader) {
+		if perHost > 0 {
+			if burst < 1 {
+				burst = 1
+			}
+			h.RateLimit = perHost
+			h.RateLimitBurst = burst
+		}
+	}
+}
+
+// WithRequestBuilder with custom image request builder option,
+// in place of the default GET request. Allowed sources and max allowed size still apply.
+// The builder runs before forward and override headers are applied
+func WithRequestBuilder(builder func(ctx context.Context, image string) (*http.Request, error)) Option {
+	return func(h *HTTPLoader) {
+		if builder != nil {
+			h.RequestBuilder = builder
+		}
+	}
+}
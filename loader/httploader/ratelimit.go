@@ -0,0 +1,78 @@
+package httploader
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimitExceeded rate limit exceeded error,
+// when waiting for the rate limit exceeds the remaining load timeout
+var ErrRateLimitExceeded = imagor.NewError("rate limit exceeded", http.StatusTooManyRequests)
+
+// hostRateLimiter token bucket rate limiter keyed by host,
+// evicts limiters of hosts idle long enough for the bucket to refill
+type hostRateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*hostLimiter
+	lastSweep time.Time
+}
+
+type hostLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newHostRateLimiter(limit rate.Limit, burst int) *hostRateLimiter {
+	idle := time.Minute
+	if refill := time.Duration(float64(burst) / float64(limit) * float64(time.Second)); refill > idle {
+		idle = refill
+	}
+	return &hostRateLimiter{
+		limit:     limit,
+		burst:     burst,
+		idle:      idle,
+		limiters:  map[string]*hostLimiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Wait blocks until rate limit of host permits, or context done
+func (l *hostRateLimiter) Wait(ctx context.Context, host string) error {
+	if err := l.get(host).Wait(ctx); err != nil {
+		if e := ctx.Err(); e != nil {
+			return e
+		}
+		return ErrRateLimitExceeded
+	}
+	return nil
+}
+
+func (l *hostRateLimiter) get(host string) *rate.Limiter {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > l.idle {
+		for h, hl := range l.limiters {
+			if now.Sub(hl.lastSeen) > l.idle {
+				delete(l.limiters, h)
+			}
+		}
+		l.lastSweep = now
+	}
+	hl, ok := l.limiters[host]
+	if !ok {
+		hl = &hostLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[host] = hl
+	}
+	hl.lastSeen = now
+	return hl.limiter
+}
@@ -0,0 +1,13 @@
+package ziploader
+
+// Option ZipLoader option
+type Option func(l *ZipLoader)
+
+// WithMaxOpenArchives with maximum number of archive handles kept open option
+func WithMaxOpenArchives(n int) Option {
+	return func(l *ZipLoader) {
+		if n > 0 {
+			l.MaxOpenArchives = n
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package ziploader
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+)
+
+// Scheme zip image key scheme e.g. zip://archive.zip#path/to/image.png
+const Scheme = "zip://"
+
+// ZipLoader Zip Loader implements imagor.Loader interface,
+// loads entry of zip archive under base directory
+type ZipLoader struct {
+	BaseDir         string
+	MaxOpenArchives int
+
+	mu       sync.Mutex
+	archives map[string]*archive
+	order    []string
+}
+
+// archive open zip archive handle with reference count
+type archive struct {
+	reader  *zip.ReadCloser
+	files   map[string]*zip.File
+	modTime time.Time
+	size    int64
+	refs    int
+	evicted bool
+}
+
+// New creates ZipLoader
+func New(baseDir string, options ...Option) *ZipLoader {
+	l := &ZipLoader{
+		BaseDir:         baseDir,
+		MaxOpenArchives: 16,
+		archives:        map[string]*archive{},
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// Path transforms and validates image key into archive path and entry name
+func (l *ZipLoader) Path(image string) (archivePath, entry string, ok bool) {
+	if !strings.HasPrefix(image, Scheme) {
+		return
+	}
+	name, entry, found := strings.Cut(strings.TrimPrefix(image, Scheme), "#")
+	name = strings.TrimPrefix(name, "/")
+	if !found || !fs.ValidPath(name) || name == "." || !fs.ValidPath(entry) || entry == "." {
+		// must not escalate outside base dir or archive
+		return "", "", false
+	}
+	return filepath.Join(l.BaseDir, filepath.FromSlash(name)), entry, true
+}
+
+// Get implements imagor.Loader interface
+func (l *ZipLoader) Get(_ *http.Request, image string) (*imagor.Blob, error) {
+	archivePath, entry, ok := l.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	a, err := l.acquire(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	_, ok = a.files[entry]
+	l.release(a)
+	if !ok {
+		return nil, imagor.ErrNotFound
+	}
+	return imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		a, err := l.acquire(archivePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		f, ok := a.files[entry]
+		if !ok {
+			// archive modified since
+			l.release(a)
+			return nil, 0, imagor.ErrNotFound
+		}
+		rc, err := f.Open()
+		if err != nil {
+			l.release(a)
+			return nil, 0, err
+		}
+		return &entryReader{ReadCloser: rc, release: func() {
+			l.release(a)
+		}}, int64(f.UncompressedSize64), nil
+	}), nil
+}
+
+// acquire returns cached archive handle, reopens if archive modified
+func (l *ZipLoader) acquire(archivePath string) (*archive, error) {
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, imagor.ErrNotFound
+		}
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, imagor.ErrNotFound
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.archives[archivePath]
+	if ok && (!a.modTime.Equal(stat.ModTime()) || a.size != stat.Size()) {
+		l.evict(archivePath)
+		ok = false
+	}
+	if !ok {
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, imagor.NewError(err.Error(), http.StatusUnprocessableEntity)
+		}
+		a = &archive{
+			reader:  reader,
+			files:   make(map[string]*zip.File, len(reader.File)),
+			modTime: stat.ModTime(),
+			size:    stat.Size(),
+		}
+		for _, f := range reader.File {
+			if !f.FileInfo().IsDir() {
+				a.files[f.Name] = f
+			}
+		}
+		l.archives[archivePath] = a
+		l.order = append(l.order, archivePath)
+		for l.MaxOpenArchives > 0 && len(l.order) > l.MaxOpenArchives {
+			l.evict(l.order[0])
+		}
+	}
+	a.refs++
+	return a, nil
+}
+
+// release archive reference, closes evicted archive when no longer referenced
+func (l *ZipLoader) release(a *archive) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a.refs--
+	if a.evicted && a.refs <= 0 {
+		_ = a.reader.Close()
+	}
+}
+
+// evict removes archive from cache, requires lock
+func (l *ZipLoader) evict(archivePath string) {
+	a, ok := l.archives[archivePath]
+	if !ok {
+		return
+	}
+	delete(l.archives, archivePath)
+	for i, p := range l.order {
+		if p == archivePath {
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			break
+		}
+	}
+	a.evicted = true
+	if a.refs <= 0 {
+		_ = a.reader.Close()
+	}
+}
+
+// Close closes all archive handles not being referenced
+func (l *ZipLoader) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.order) > 0 {
+		l.evict(l.order[0])
+	}
+	return nil
+}
+
+type entryReader struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *entryReader) Close() (err error) {
+	err = r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return
+}
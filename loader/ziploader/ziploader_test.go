@@ -0,0 +1,125 @@
+package ziploader
+
+import (
+	"archive/zip"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, path string, entries map[string]string) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
+
+func TestZipLoader_Path(t *testing.T) {
+	l := New("/home/imagor")
+	tests := []struct {
+		image   string
+		archive string
+		entry   string
+		ok      bool
+	}{
+		{image: "zip://archive.zip#img/a.png", archive: "/home/imagor/archive.zip", entry: "img/a.png", ok: true},
+		{image: "zip:///foo/archive.zip#a.png", archive: "/home/imagor/foo/archive.zip", entry: "a.png", ok: true},
+		{image: "archive.zip#a.png"},
+		{image: "zip://archive.zip"},
+		{image: "zip://archive.zip#"},
+		{image: "zip://../archive.zip#a.png"},
+		{image: "zip://foo/../../archive.zip#a.png"},
+		{image: "zip://archive.zip#../a.png"},
+		{image: "zip://archive.zip#/a.png"},
+		{image: "zip://archive.zip#img/./a.png"},
+	}
+	for _, tt := range tests {
+		archive, entry, ok := l.Path(tt.image)
+		assert.Equal(t, tt.ok, ok, tt.image)
+		assert.Equal(t, tt.archive, archive, tt.image)
+		assert.Equal(t, tt.entry, entry, tt.image)
+	}
+}
+
+func TestZipLoader_Get(t *testing.T) {
+	dir := t.TempDir()
+	writeZip(t, filepath.Join(dir, "a.zip"), map[string]string{
+		"foo.txt":     "foo",
+		"bar/baz.txt": "baz",
+	})
+	writeZip(t, filepath.Join(dir, "b.zip"), map[string]string{"b.txt": "b"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.zip"), []byte("abcd"), 0666))
+
+	l := New(dir, WithMaxOpenArchives(1))
+	assert.Equal(t, 1, l.MaxOpenArchives)
+	t.Cleanup(func() {
+		assert.NoError(t, l.Close())
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+
+	for image, content := range map[string]string{
+		"zip://a.zip#foo.txt":     "foo",
+		"zip://a.zip#bar/baz.txt": "baz",
+		"zip://b.zip#b.txt":       "b",
+	} {
+		blob, err := l.Get(r, image)
+		require.NoError(t, err, image)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err, image)
+		assert.Equal(t, content, string(buf), image)
+	}
+	assert.Len(t, l.archives, 1, "max open archives")
+
+	for image, e := range map[string]error{
+		"zip://a.zip#missing.txt":   imagor.ErrNotFound,
+		"zip://a.zip#bar":           imagor.ErrNotFound,
+		"zip://missing.zip#foo.txt": imagor.ErrNotFound,
+		"zip://a.zip#../foo.txt":    imagor.ErrInvalid,
+		"a.zip#foo.txt":             imagor.ErrInvalid,
+	} {
+		_, err := l.Get(r, image)
+		assert.Equal(t, e, err, image)
+	}
+	_, err := l.Get(r, "zip://invalid.zip#foo.txt")
+	assert.Error(t, err)
+
+	t.Run("evict while reading", func(t *testing.T) {
+		blob, err := l.Get(r, "zip://a.zip#foo.txt")
+		require.NoError(t, err)
+		reader, _, err := blob.NewReader()
+		require.NoError(t, err)
+		_, err = l.Get(r, "zip://b.zip#b.txt")
+		require.NoError(t, err)
+		buf, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", string(buf))
+		assert.NoError(t, reader.Close())
+	})
+
+	t.Run("archive modified", func(t *testing.T) {
+		writeZip(t, filepath.Join(dir, "b.zip"), map[string]string{"c.txt": "cc"})
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "b.zip"), future, future))
+		_, err := l.Get(r, "zip://b.zip#b.txt")
+		assert.Equal(t, imagor.ErrNotFound, err)
+		blob, err := l.Get(r, "zip://b.zip#c.txt")
+		require.NoError(t, err)
+		buf, err := blob.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "cc", string(buf))
+	})
+}
@@ -3,6 +3,9 @@ package prometheusmetrics
 import (
 	"context"
 	"net/http"
+	"time"
+
+	"github.com/cshum/imagor"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -17,6 +20,27 @@ var (
 		},
 		[]string{"code", "method"},
 	)
+	conversionDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "imagor_conversion_duration_seconds",
+			Help: "A histogram of image processing latencies by source and output type",
+		},
+		[]string{"from", "to"},
+	)
+	conversionByteRatio = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "imagor_conversion_byte_ratio",
+			Help:    "A histogram of output to source byte size ratio by source and output type",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 5, 10},
+		},
+		[]string{"from", "to"},
+	)
+	unsignedRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "imagor_unsigned_requests_total",
+			Help: "A counter of requests without URL signature allowed by permissive signing mode",
+		},
+	)
 )
 
 // PrometheusMetrics wraps the Service with additional http and app lifecycle handling
@@ -51,8 +75,12 @@ func New(options ...Option) *PrometheusMetrics {
 
 // Startup prometheus metrics server
 func (s *PrometheusMetrics) Startup(_ context.Context) error {
-	if err := prometheus.Register(httpRequestDuration); err != nil {
-		return err
+	for _, c := range []prometheus.Collector{
+		httpRequestDuration, conversionDuration, conversionByteRatio, unsignedRequests,
+	} {
+		if err := prometheus.Register(c); err != nil {
+			return err
+		}
 	}
 
 	go func() {
@@ -69,6 +97,23 @@ func (s *PrometheusMetrics) Handle(next http.Handler) http.Handler {
 	return promhttp.InstrumentHandlerDuration(httpRequestDuration, next)
 }
 
+// ObserveConversion implements imagor.ConversionObserver,
+// counting source to output type conversions with their latency and byte ratio
+func (s *PrometheusMetrics) ObserveConversion(
+	from, to imagor.BlobType, duration time.Duration, inSize, outSize int64,
+) {
+	conversionDuration.WithLabelValues(from.String(), to.String()).Observe(duration.Seconds())
+	if inSize > 0 && outSize > 0 {
+		conversionByteRatio.WithLabelValues(from.String(), to.String()).Observe(float64(outSize) / float64(inSize))
+	}
+}
+
+// ObserveUnsignedRequest implements imagor.UnsignedRequestObserver,
+// counting requests without URL signature allowed by permissive signing mode
+func (s *PrometheusMetrics) ObserveUnsignedRequest() {
+	unsignedRequests.Inc()
+}
+
 // Option PrometheusMetrics option
 type Option func(s *PrometheusMetrics)
 
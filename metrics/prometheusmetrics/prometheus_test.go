@@ -4,6 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -33,3 +37,22 @@ func TestWithOption(t *testing.T) {
 		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
 	})
 }
+
+func TestObserveConversion(t *testing.T) {
+	v := New()
+	v.ObserveConversion(imagor.BlobTypeJPEG, imagor.BlobTypeAVIF, time.Millisecond*120, 1000, 250)
+	v.ObserveConversion(imagor.BlobTypeJPEG, imagor.BlobTypeAVIF, time.Millisecond*80, 0, 250)
+	assert.Equal(t, 1, testutil.CollectAndCount(conversionDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(conversionByteRatio))
+	v.ObserveConversion(imagor.BlobTypePNG, imagor.BlobTypeWEBP, time.Millisecond*50, 1000, 500)
+	assert.Equal(t, 2, testutil.CollectAndCount(conversionDuration))
+	assert.Equal(t, 2, testutil.CollectAndCount(conversionByteRatio))
+}
+
+func TestObserveUnsignedRequest(t *testing.T) {
+	v := New()
+	before := testutil.ToFloat64(unsignedRequests)
+	v.ObserveUnsignedRequest()
+	v.ObserveUnsignedRequest()
+	assert.Equal(t, before+2, testutil.ToFloat64(unsignedRequests))
+}
@@ -1,8 +1,11 @@
 package imagor
 
 import (
+	"context"
+	"fmt"
 	"github.com/cshum/imagor/imagorpath"
 	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
@@ -120,6 +123,16 @@ func WithProcessTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithMaxProcessTimeout with maximum process timeout option,
+// enables per request process timeout override by signed timeout(seconds) filter clamped to maximum
+func WithMaxProcessTimeout(timeout time.Duration) Option {
+	return func(app *Imagor) {
+		if timeout > 0 {
+			app.MaxProcessTimeout = timeout
+		}
+	}
+}
+
 // WithProcessConcurrency maximum number of processor call to be executed simultaneously.
 func WithProcessConcurrency(concurrency int64) Option {
 	return func(app *Imagor) {
@@ -129,6 +142,27 @@ func WithProcessConcurrency(concurrency int64) Option {
 	}
 }
 
+// WithLoadConcurrencyPerHost maximum number of concurrent requests loading from the same source host,
+// acquired after the process concurrency slot and held until request done
+func WithLoadConcurrencyPerHost(concurrency int64) Option {
+	return func(app *Imagor) {
+		if concurrency > 0 {
+			app.LoadConcurrencyPerHost = concurrency
+		}
+	}
+}
+
+// WithProcessPriority enables priority aware process concurrency queue option,
+// requests tagged by WithPriority context or signed priority(n) filter acquire process slots ahead.
+// Each priority level is worth aging duration of waiting, so that lower priority requests still progress
+func WithProcessPriority(aging time.Duration) Option {
+	return func(app *Imagor) {
+		if aging > 0 {
+			app.ProcessPriorityAging = aging
+		}
+	}
+}
+
 // WithProcessQueueSize maximum number of processor call that can be put to a queue
 func WithProcessQueueSize(size int64) Option {
 	return func(app *Imagor) {
@@ -145,6 +179,17 @@ func WithUnsafe(unsafe bool) Option {
 	}
 }
 
+// WithSigningMode with URL signature verification mode option,
+// permissive mode allows request without signature for migrating from unsigned URLs
+func WithSigningMode(mode SigningMode) Option {
+	return func(app *Imagor) {
+		switch mode {
+		case SigningModeEnforce, SigningModePermissive:
+			app.SigningMode = mode
+		}
+	}
+}
+
 // WithAutoWebP with auto WebP option based on browser Accept header
 func WithAutoWebP(enable bool) Option {
 	return func(app *Imagor) {
@@ -159,6 +204,38 @@ func WithAutoAVIF(enable bool) Option {
 	}
 }
 
+// WithAutoFormats with auto format option based on browser Accept header quality values,
+// by priority list of formats e.g. avif, webp, jpeg. Overrides auto WebP and AVIF options
+func WithAutoFormats(formats ...string) Option {
+	return func(app *Imagor) {
+		for _, format := range formats {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format == "jpg" {
+				format = "jpeg"
+			}
+			if format != "" {
+				app.AutoFormats = append(app.AutoFormats, format)
+			}
+		}
+	}
+}
+
+// WithSaveDataMode with option to serve lower quality images for requests with Save-Data: on client hint
+func WithSaveDataMode(enable bool) Option {
+	return func(app *Imagor) {
+		app.SaveDataMode = enable
+	}
+}
+
+// WithSaveDataQuality with default quality option for Save-Data mode
+func WithSaveDataQuality(quality int) Option {
+	return func(app *Imagor) {
+		if quality > 0 && quality <= 100 {
+			app.SaveDataQuality = quality
+		}
+	}
+}
+
 // WithBasePathRedirect with base path redirect option
 func WithBasePathRedirect(url string) Option {
 	return func(app *Imagor) {
@@ -166,6 +243,17 @@ func WithBasePathRedirect(url string) Option {
 	}
 }
 
+// WithBasePathHandler with base path handler mode option: status, redirect, json, nocontent, notfound.
+// Defaults to status page, or redirect if base path redirect URL is set
+func WithBasePathHandler(mode string) Option {
+	return func(app *Imagor) {
+		switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+		case BasePathModeStatus, BasePathModeRedirect, BasePathModeJSON, BasePathModeNoContent, BasePathModeNotFound:
+			app.BasePathHandler = mode
+		}
+	}
+}
+
 // WithBaseParams with base params string option
 func WithBaseParams(params string) Option {
 	return func(app *Imagor) {
@@ -173,6 +261,24 @@ func WithBaseParams(params string) Option {
 	}
 }
 
+// WithKeyNormalizer with image key normalizer option e.g. lowercase,
+// applied before signature check and loading
+func WithKeyNormalizer(fn func(string) string) Option {
+	return func(app *Imagor) {
+		app.KeyNormalizer = fn
+	}
+}
+
+// WithDefaultGravity with default crop gravity option e.g. top, bottom-right,
+// applies when request does not specify alignment, smart crop or focal
+func WithDefaultGravity(gravity string) Option {
+	return func(app *Imagor) {
+		if _, _, ok := parseGravity(gravity); ok {
+			app.DefaultGravity = gravity
+		}
+	}
+}
+
 // WithModifiedTimeCheck with option for modified time check of storage against result storage
 func WithModifiedTimeCheck(enabled bool) Option {
 	return func(app *Imagor) {
@@ -187,6 +293,13 @@ func WithDisableErrorBody(disabled bool) Option {
 	}
 }
 
+// WithVerboseErrors with option to include diagnostic detail e.g. decoder message in error response
+func WithVerboseErrors(verbose bool) Option {
+	return func(app *Imagor) {
+		app.VerboseErrors = verbose
+	}
+}
+
 // WithDisableParamsEndpoint with disable imagor /params endpoint
 func WithDisableParamsEndpoint(disabled bool) Option {
 	return func(app *Imagor) {
@@ -194,6 +307,131 @@ func WithDisableParamsEndpoint(disabled bool) Option {
 	}
 }
 
+// WithTarEndpoint with enable imagor POST /tar endpoint,
+// streaming a tar archive of rendered images from JSON spec
+func WithTarEndpoint(enabled bool) Option {
+	return func(app *Imagor) {
+		app.EnableTarEndpoint = enabled
+	}
+}
+
+// WithBatchEndpoint with enable imagor POST /batch endpoint,
+// processing JSON array of imagor paths into result storages e.g. for prewarming
+func WithBatchEndpoint(enabled bool) Option {
+	return func(app *Imagor) {
+		app.EnableBatchEndpoint = enabled
+	}
+}
+
+// WithStatEndpoint with enable imagor HEAD /stat endpoint,
+// checking result storage existence without processing
+func WithStatEndpoint(enabled bool) Option {
+	return func(app *Imagor) {
+		app.EnableStatEndpoint = enabled
+	}
+}
+
+// WithAllowedSizes with allowed output dimensions presets option,
+// requests for other dimensions are rejected unless snap enabled
+func WithAllowedSizes(sizes ...AllowedSize) Option {
+	return func(app *Imagor) {
+		app.AllowedSizes = append(app.AllowedSizes, sizes...)
+	}
+}
+
+// WithAllowedSizesSnap with option to snap non-allowed dimensions to nearest allowed size instead of rejecting
+func WithAllowedSizesSnap(snap bool) Option {
+	return func(app *Imagor) {
+		app.AllowedSizesSnap = snap
+	}
+}
+
+// WithResultSavedHook with hook option called asynchronously after result storage saved,
+// e.g. for notifying new variants. Errors returned are logged but not propagated
+func WithResultSavedHook(hook func(ctx context.Context, key string, blob *Blob) error) Option {
+	return func(app *Imagor) {
+		app.ResultSavedHook = hook
+	}
+}
+
+// WithConversionObserver with observer option for source to output BlobType conversions
+func WithConversionObserver(observer ConversionObserver) Option {
+	return func(app *Imagor) {
+		if observer != nil {
+			app.ConversionObserver = observer
+		}
+	}
+}
+
+// WithUnsignedRequestObserver with observer option for unsigned requests allowed by permissive signing mode
+func WithUnsignedRequestObserver(observer UnsignedRequestObserver) Option {
+	return func(app *Imagor) {
+		if observer != nil {
+			app.UnsignedObserver = observer
+		}
+	}
+}
+
+// WithMaxDecompressedBytes with maximum decompressed size option,
+// rejecting images that decompress beyond the bytes based on header declared dimensions, bands and bit depth
+func WithMaxDecompressedBytes(n int64) Option {
+	return func(app *Imagor) {
+		if n > 0 {
+			app.MaxDecompressedBytes = n
+		}
+	}
+}
+
+// WithAllowedTypes with allowed source types option e.g. jpeg, png, webp,
+// rejecting source images of other sniffed types before processing.
+// Panics on unknown type name, so that a typo does not disable the guard
+func WithAllowedTypes(types ...string) Option {
+	return func(app *Imagor) {
+		for _, name := range types {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == "jpg" {
+				name = "jpeg"
+			}
+			var found bool
+			for t, n := range blobTypeNames {
+				if n == name && t != BlobTypeUnknown && t != BlobTypeEmpty && t != BlobTypeMemory {
+					app.AllowedTypes = append(app.AllowedTypes, t)
+					found = true
+				}
+			}
+			if !found {
+				panic(fmt.Errorf("imagor: unknown allowed type: %s", name))
+			}
+		}
+	}
+}
+
+// WithMaxResolution with maximum resolution option in megapixels,
+// rejecting source images and target output dimensions exceeding the limit before processing
+func WithMaxResolution(megapixels float64) Option {
+	return func(app *Imagor) {
+		if megapixels > 0 {
+			app.MaxResolution = megapixels
+		}
+	}
+}
+
+// WithContextCacheLimit with bounds option for request context cache e.g. watermark images,
+// by maximum number of entries and total bytes of cached blobs. Zero means unbounded
+func WithContextCacheLimit(maxEntries int, maxBytes int64) Option {
+	return func(app *Imagor) {
+		if maxEntries > 0 {
+			app.ContextCacheMaxEntries = maxEntries
+		}
+		if maxBytes > 0 {
+			app.ContextCacheMaxBytes = maxBytes
+		}
+	}
+}
+
 // WithDebug with debug option
 func WithDebug(debug bool) Option {
 	return func(app *Imagor) {
@@ -201,6 +439,16 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithResultKey with result storage key option computed from params,
+// used consistently for result storage Get, Stat and Put. Empty key skips result storage
+func WithResultKey(fn func(p imagorpath.Params) string) Option {
+	return func(app *Imagor) {
+		if fn != nil {
+			app.ResultStoragePathStyle = imagorpath.ResultStorageHasherFunc(fn)
+		}
+	}
+}
+
 // WithResultStoragePathStyle with result storage path style hasher option
 func WithResultStoragePathStyle(hasher imagorpath.ResultStorageHasher) Option {
 	return func(app *Imagor) {
@@ -219,6 +467,39 @@ func WithStoragePathStyle(hasher imagorpath.StorageHasher) Option {
 	}
 }
 
+// WithPathSigner with URL signature signer option scoped to image path prefix,
+// selected by the leading path segment of image e.g. tenant-a for tenant-a/foo.jpg.
+// Images loaded by filters of a signed request e.g. watermark are limited to the same prefix
+func WithPathSigner(prefix string, signer imagorpath.Signer) Option {
+	return func(app *Imagor) {
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" || signer == nil {
+			return
+		}
+		if app.PathSigners == nil {
+			app.PathSigners = map[string]imagorpath.Signer{}
+		}
+		app.PathSigners[prefix] = signer
+	}
+}
+
+// WithPathSignersStrict with option to reject image path of unknown prefix
+// instead of falling back to the default signer
+func WithPathSignersStrict(strict bool) Option {
+	return func(app *Imagor) {
+		app.PathSignersStrict = strict
+	}
+}
+
+// WithClock with clock option for checking expire(timestamp), defaults to time.Now
+func WithClock(clock func() time.Time) Option {
+	return func(app *Imagor) {
+		if clock != nil {
+			app.Clock = clock
+		}
+	}
+}
+
 // WithSigner with URL signature signer option
 func WithSigner(signer imagorpath.Signer) Option {
 	return func(app *Imagor) {
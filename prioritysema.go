@@ -0,0 +1,118 @@
+package imagor
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// prioritySemaphore semaphore that grants slots to waiters of higher priority first.
+// With aging, each priority level is worth aging duration of waiting,
+// so that lower priority waiters eventually get ahead and do not starve.
+// Without aging, waiters are granted in FIFO order regardless of priority
+type prioritySemaphore struct {
+	size  int64
+	aging time.Duration
+
+	mu      sync.Mutex
+	cur     int64
+	seq     uint64
+	waiters priorityWaiters
+}
+
+type priorityWaiter struct {
+	key   int64
+	seq   uint64
+	ready chan struct{}
+	index int
+}
+
+func newPrioritySemaphore(size int64, aging time.Duration) *prioritySemaphore {
+	return &prioritySemaphore{
+		size:  size,
+		aging: aging,
+	}
+}
+
+// Acquire blocks until a slot is acquired with priority or context done
+func (s *prioritySemaphore) Acquire(ctx context.Context, priority int) error {
+	s.mu.Lock()
+	if s.cur < s.size && len(s.waiters) == 0 {
+		s.cur++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	w := &priorityWaiter{seq: s.seq, ready: make(chan struct{})}
+	if s.aging > 0 {
+		// waiting time and priority in the same unit, order remains unchanged over time
+		w.key = time.Now().UnixNano() - int64(priority)*int64(s.aging)
+	}
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// acquired while canceling, hand over the slot
+			s.mu.Unlock()
+			s.Release()
+		default:
+			heap.Remove(&s.waiters, w.index)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Release releases a slot, handing over to the next waiter if any
+func (s *prioritySemaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.waiters) > 0 {
+		w := heap.Pop(&s.waiters).(*priorityWaiter)
+		close(w.ready)
+		return
+	}
+	s.cur--
+}
+
+// priorityWaiters min heap of waiters by key then sequence
+type priorityWaiters []*priorityWaiter
+
+func (q priorityWaiters) Len() int {
+	return len(q)
+}
+
+func (q priorityWaiters) Less(i, j int) bool {
+	if q[i].key != q[j].key {
+		return q[i].key < q[j].key
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q priorityWaiters) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *priorityWaiters) Push(x any) {
+	w := x.(*priorityWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *priorityWaiters) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return w
+}
@@ -1,17 +1,26 @@
 package server
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cshum/imagor"
 	"go.uber.org/zap"
 )
 
+var errShuttingDown = errors.New("server shutting down")
+
 type errResp struct {
 	Message string `json:"message,omitempty"`
 	Code    int    `json:"status,omitempty"`
@@ -26,6 +35,57 @@ func isNoopRequest(r *http.Request) bool {
 	return r.Method == http.MethodGet && (r.URL.Path == "/healthcheck" || r.URL.Path == "/favicon.ico")
 }
 
+func isHealthRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && (r.URL.Path == "/health/live" || r.URL.Path == "/health/ready")
+}
+
+// healthHandler handles liveness and readiness probes,
+// readiness checks app and registered health checkers, fails during shutdown
+func (s *Server) healthHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isHealthRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/health/live" {
+			handleOk(w, r)
+			return
+		}
+		if err := s.checkReady(r.Context()); err != nil {
+			// details logged only, not exposed to the probe response
+			if err == errShuttingDown {
+				s.Logger.Debug("health-ready", zap.Error(err))
+			} else {
+				s.Logger.Warn("health-ready", zap.Error(err))
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			writeJSON(w, r, errResp{
+				Message: http.StatusText(http.StatusServiceUnavailable),
+				Code:    http.StatusServiceUnavailable,
+			})
+			return
+		}
+		handleOk(w, r)
+	})
+}
+
+func (s *Server) checkReady(ctx context.Context) error {
+	if s.shuttingDown.Load() {
+		return errShuttingDown
+	}
+	if checker, ok := s.App.(HealthChecker); ok {
+		if err := checker.CheckHealth(ctx); err != nil {
+			return err
+		}
+	}
+	for _, checker := range s.HealthChecks {
+		if err := checker.CheckHealth(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Server) panicHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -95,18 +155,151 @@ func (s *Server) accessLogHandler(next http.Handler) http.Handler {
 			ResponseWriter: w,
 			Status:         200,
 		}
+		r = r.WithContext(imagor.WithTimings(r.Context()))
 		next.ServeHTTP(wr, r)
-		if isNoopRequest(r) {
-			return // skip logging no-op requests
+		if isNoopRequest(r) || isHealthRequest(r) {
+			return // skip logging no-op and health requests
 		}
-		s.Logger.Info("access",
+		fields := []zap.Field{
 			zap.Int("status", wr.Status),
 			zap.String("method", r.Method),
 			zap.String("uri", r.URL.RequestURI()),
 			zap.String("ip", RealIP(r)),
 			zap.String("user-agent", r.UserAgent()),
 			zap.Duration("took", time.Since(start)),
-		)
+		}
+		var hasTimings bool
+		imagor.ContextTimingsRange(r.Context(), func(name string, d time.Duration) bool {
+			if !hasTimings {
+				// nest stage timings under timings field
+				fields = append(fields, zap.Namespace("timings"))
+				hasTimings = true
+			}
+			fields = append(fields, zap.Duration(name, d))
+			return true
+		})
+		s.Logger.Info("access", fields...)
+	})
+}
+
+// isCompressibleType checks if content type is text based e.g. json, svg, text,
+// already compressed image formats are not compressible
+func isCompressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson",
+		"application/xml", "application/javascript", "image/svg+xml":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
+
+// acceptedEncoding returns gzip or deflate accepted by request Accept-Encoding, gzip preferred
+func acceptedEncoding(r *http.Request) string {
+	var deflate bool
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v <= 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "*":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+type compressionWriter struct {
+	http.ResponseWriter
+	Encoding    string
+	writer      io.WriteCloser
+	wroteHeader bool
+}
+
+func (cw *compressionWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	h := cw.Header()
+	if isCompressibleType(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+		// skip partial content and responses without body
+		if status >= http.StatusOK &&
+			status != http.StatusNoContent &&
+			status != http.StatusPartialContent &&
+			status != http.StatusNotModified &&
+			h.Get("Content-Encoding") == "" {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", cw.Encoding)
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				// encoded body is not byte identical, strong validator no longer applies
+				h.Set("ETag", "W/"+etag)
+			}
+			if cw.Encoding == "deflate" {
+				cw.writer = zlib.NewWriter(cw.ResponseWriter)
+			} else {
+				cw.writer = gzip.NewWriter(cw.ResponseWriter)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressionWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush flushes compressed data so far for streaming responses e.g. batch endpoint
+func (cw *compressionWriter) Flush() {
+	if f, ok := cw.writer.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressionWriter) Close() error {
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// compressionHandler compresses text based responses e.g. json, svg
+// negotiated by Accept-Encoding. Image bytes are passed through as is
+func compressionHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r)
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressionWriter{ResponseWriter: w, Encoding: encoding}
+		defer func() {
+			_ = cw.Close()
+		}()
+		next.ServeHTTP(cw, r)
 	})
 }
 
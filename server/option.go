@@ -116,6 +116,22 @@ func WithAccessLog(enabled bool) Option {
 	}
 }
 
+// WithCompression with gzip/deflate response compression option for text based content types e.g. json, svg
+func WithCompression(enabled bool) Option {
+	return func(s *Server) {
+		if enabled {
+			s.Handler = compressionHandler(s.Handler)
+		}
+	}
+}
+
+// WithHealthChecks with custom health checkers option for /health/ready readiness endpoint
+func WithHealthChecks(checks ...HealthChecker) Option {
+	return func(s *Server) {
+		s.HealthChecks = append(s.HealthChecks, checks...)
+	}
+}
+
 // WithMetrics with server metrics option
 func WithMetrics(metrics Metrics) Option {
 	return func(s *Server) {
@@ -2,10 +2,12 @@ package server
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os/signal"
 	"reflect"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -36,6 +38,19 @@ type Metrics interface {
 	Shutdown(ctx context.Context) error
 }
 
+// HealthChecker checks readiness of a dependency
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckFunc HealthChecker handler func
+type HealthCheckFunc func(ctx context.Context) error
+
+// CheckHealth implements HealthChecker interface
+func (f HealthCheckFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
 // Server wraps the Service with additional http and app lifecycle handling
 type Server struct {
 	http.Server
@@ -51,6 +66,11 @@ type Server struct {
 	Logger          *zap.Logger
 	Debug           bool
 	Metrics         Metrics
+	HealthChecks    []HealthChecker
+
+	baseCtx      context.Context
+	baseCancel   context.CancelFunc
+	shuttingDown atomic.Bool
 }
 
 // New create new Server
@@ -63,6 +83,12 @@ func New(app Service, options ...Option) *Server {
 	s.ShutdownTimeout = time.Second * 10
 	s.Logger = zap.NewNop()
 
+	// base context of requests, canceled on forced shutdown
+	s.baseCtx, s.baseCancel = context.WithCancel(context.Background())
+	s.BaseContext = func(net.Listener) context.Context {
+		return s.baseCtx
+	}
+
 	// build up middleware handlers in reverse order
 	// Handler: application
 	s.Handler = s.App
@@ -70,6 +96,9 @@ func New(app Service, options ...Option) *Server {
 	// handle no-op routes /healthcheck, /favicon.ico
 	s.Handler = noopHandler(s.Handler)
 
+	// handle health probes /health/live, /health/ready
+	s.Handler = s.healthHandler(s.Handler)
+
 	for _, option := range options {
 		option(s)
 	}
@@ -139,18 +168,39 @@ func (s *Server) startup(ctx context.Context) {
 func (s *Server) shutdown(ctx context.Context) {
 	ctx, cancel := context.WithTimeout(ctx, s.ShutdownTimeout)
 	defer cancel()
+	_ = s.Shutdown(ctx)
+}
+
+// Shutdown gracefully shuts down the server and app.
+// It stops accepting new connections and waits for in-flight requests until ctx done,
+// then cancels the remaining request contexts and forcibly closes connections
+func (s *Server) Shutdown(ctx context.Context) (err error) {
 	s.Logger.Info("shutdown")
+	s.shuttingDown.Store(true)
 	if !isNil(s.Metrics) {
 		if err := s.Metrics.Shutdown(ctx); err != nil {
 			s.Logger.Error("metrics-shutdown", zap.Error(err))
 		}
 	}
-	if err := s.Shutdown(ctx); err != nil {
+	if err = s.Server.Shutdown(ctx); err != nil {
 		s.Logger.Error("server-shutdown", zap.Error(err))
+		// cancel in-flight requests so that context defers release resources
+		s.baseCancel()
+		if e := s.Server.Close(); e != nil {
+			s.Logger.Error("server-close", zap.Error(e))
+		}
+	}
+	s.baseCancel()
+	if ctx.Err() != nil {
+		// fresh bounded context for app clean up after forced close
+		var cancel func()
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), s.ShutdownTimeout)
+		defer cancel()
 	}
 	if err := s.App.Shutdown(ctx); err != nil {
 		s.Logger.Error("app-shutdown", zap.Error(err))
 	}
+	return
 }
 
 func (s *Server) listenAndServe() error {
@@ -1,16 +1,22 @@
 package server
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/imagorpath"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -32,7 +38,7 @@ func (app *testProcessor) Startup(ctx context.Context) error {
 
 func (app *testProcessor) Shutdown(ctx context.Context) error {
 	app.ShutdownCnt++
-	return nil
+	return ctx.Err()
 }
 
 type loaderFunc func(r *http.Request, image string) (blob *imagor.Blob, err error)
@@ -163,6 +169,157 @@ func TestServerErrorLog(t *testing.T) {
 	assert.Equal(t, expectLogged, logged)
 }
 
+func TestShutdownDrain(t *testing.T) {
+	var started = make(chan struct{})
+	s := New(imagor.New(
+		imagor.WithUnsafe(true),
+		imagor.WithLoaders(loaderFunc(func(r *http.Request, image string) (*imagor.Blob, error) {
+			close(started)
+			time.Sleep(time.Millisecond * 50)
+			return imagor.NewBlobFromBytes([]byte("foo")), nil
+		})),
+	))
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = &s.Server
+	ts.Start()
+	defer ts.Close()
+
+	var done = make(chan string)
+	go func() {
+		resp, err := http.Get(ts.URL + "/unsafe/foo.jpg")
+		if !assert.NoError(t, err) {
+			close(done)
+			return
+		}
+		buf, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		done <- string(buf)
+	}()
+	<-started
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Equal(t, "foo", <-done)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	var started = make(chan struct{})
+	var canceled = make(chan struct{})
+	processor := &testProcessor{}
+	core, logs := observer.New(zap.InfoLevel)
+	s := New(imagor.New(
+		imagor.WithUnsafe(true),
+		imagor.WithProcessors(processor),
+		imagor.WithLoaders(loaderFunc(func(r *http.Request, image string) (*imagor.Blob, error) {
+			close(started)
+			<-r.Context().Done()
+			close(canceled)
+			return nil, r.Context().Err()
+		})),
+	), WithLogger(zap.New(core)))
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = &s.Server
+	ts.Start()
+	defer ts.Close()
+
+	go func() {
+		resp, err := http.Get(ts.URL + "/unsafe/foo.jpg")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("request context not canceled")
+	}
+	assert.Equal(t, 1, processor.ShutdownCnt)
+	assert.Empty(t, logs.FilterMessage("app-shutdown").All(), "app shutdown with fresh context after forced close")
+}
+
+type healthApp struct {
+	*imagor.Imagor
+	Err error
+}
+
+func (app healthApp) CheckHealth(ctx context.Context) error {
+	return app.Err
+}
+
+func TestHealthChecks(t *testing.T) {
+	app := healthApp{Imagor: imagor.New()}
+	var checkErr error
+	core, logs := observer.New(zap.InfoLevel)
+	s := New(app, WithPathPrefix("/imagor"), WithLogger(zap.New(core)), WithHealthChecks(HealthCheckFunc(func(ctx context.Context) error {
+		return checkErr
+	})))
+
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/live", nil))
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/ready", nil))
+	assert.Equal(t, 200, w.Code)
+
+	checkErr = errors.New("redis unreachable")
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/ready", nil))
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, `{"message":"Service Unavailable","status":503}`, w.Body.String())
+	if entries := logs.FilterMessage("health-ready").All(); assert.Len(t, entries, 1) {
+		assert.Equal(t, "redis unreachable", entries[0].ContextMap()["error"])
+	}
+
+	checkErr = nil
+	s.App = healthApp{Imagor: app.Imagor, Err: errors.New("s3 unreachable")}
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/ready", nil))
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, `{"message":"Service Unavailable","status":503}`, w.Body.String())
+
+	s.App = app
+	assert.NoError(t, s.Shutdown(context.Background()))
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/ready", nil))
+	assert.Equal(t, 503, w.Code)
+
+	w = httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/imagor/health/live", nil))
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestAccessLogTimings(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	s := New(
+		imagor.New(
+			imagor.WithUnsafe(true),
+			imagor.WithLoaders(loaderFunc(func(r *http.Request, image string) (*imagor.Blob, error) {
+				imagor.ContextTiming(r.Context(), "fetch", time.Millisecond)
+				return imagor.NewBlobFromBytes([]byte("foo")), nil
+			})),
+		),
+		WithAccessLog(true),
+		WithLogger(zap.New(core)),
+	)
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/foo.jpg", nil))
+	assert.Equal(t, 200, w.Code)
+
+	entries := logs.FilterMessage("access").All()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, int64(200), fields["status"])
+		timings, ok := fields["timings"].(map[string]interface{})
+		if assert.True(t, ok) {
+			assert.Equal(t, time.Millisecond, timings["fetch"])
+			assert.Contains(t, timings, "load")
+		}
+	}
+}
+
 func TestWithStripQueryString(t *testing.T) {
 	s := New(imagor.New(),
 		WithAddr("https://example.com:1667"), WithPort(1234))
@@ -209,3 +366,79 @@ func TestWithSentry(t *testing.T) {
 	s := New(imagor.New(), WithSentry("https://12345@sentry.com/123"))
 	assert.Equal(t, "https://12345@sentry.com/123", s.SentryDsn)
 }
+
+func TestWithCompression(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><rect width="10" height="10"/></svg>`)
+	jpeg, err := os.ReadFile("../testdata/demo1.jpg")
+	assert.NoError(t, err)
+	s := New(imagor.New(
+		imagor.WithUnsafe(true),
+		imagor.WithLoaders(loaderFunc(func(r *http.Request, image string) (*imagor.Blob, error) {
+			if image == "image.svg" {
+				return imagor.NewBlobFromBytes(svg), nil
+			}
+			return imagor.NewBlobFromBytes(jpeg), nil
+		})),
+	), WithCompression(true))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/image.svg", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	s.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	gr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	buf, err := io.ReadAll(gr)
+	assert.NoError(t, err)
+	assert.Equal(t, svg, buf)
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), "should weaken ETag of compressed body: %s", etag)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/image.svg", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	s.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/image.svg", nil)
+	r.Header.Set("Accept-Encoding", "deflate")
+	s.Handler.ServeHTTP(w, r)
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(w.Body)
+	assert.NoError(t, err)
+	buf, err = io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Equal(t, svg, buf)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/image.svg", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+	s.Handler.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, svg, w.Body.Bytes())
+	assert.Equal(t, strings.TrimPrefix(etag, "W/"), w.Header().Get("ETag"), "should keep strong ETag of identity body")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/image.jpg", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	s.Handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, strconv.Itoa(len(jpeg)), w.Header().Get("Content-Length"))
+	assert.Equal(t, jpeg, w.Body.Bytes())
+
+	s = New(imagor.New(), WithCompression(false))
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "https://example.com/params/image.svg", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	s.Handler.ServeHTTP(w, r)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
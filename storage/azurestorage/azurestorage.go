@@ -0,0 +1,159 @@
+package azurestorage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	azureblob "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// AzureStorage Azure Blob Storage implements imagor.Storage interface
+type AzureStorage struct {
+	BaseDir    string
+	PathPrefix string
+	SafeChars  string
+	Expiration time.Duration
+	client     *azblob.Client
+	Container  string
+
+	safeChars imagorpath.SafeChars
+}
+
+// New creates AzureStorage
+func New(client *azblob.Client, container string, options ...Option) *AzureStorage {
+	s := &AzureStorage{client: client, Container: container}
+	for _, option := range options {
+		option(s)
+	}
+	s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	return s
+}
+
+// Get implements imagor.Storage interface
+func (s *AzureStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	ctx := r.Context()
+	image, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	stat, err := s.stat(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if s.Expiration > 0 && time.Since(stat.ModifiedTime) > s.Expiration {
+		// delete blob older than expiration
+		_, _ = s.client.DeleteBlob(ctx, s.Container, image, nil)
+		return nil, imagor.ErrExpired
+	}
+	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		resp, err := s.client.DownloadStream(context.Background(), s.Container, image, nil)
+		if err != nil {
+			return nil, 0, wrapErr(err)
+		}
+		return resp.Body, stat.Size, nil
+	})
+	blob.SetContentType(stat.contentType)
+	blob.Stat = &stat.Stat
+	return blob, nil
+}
+
+// Put implements imagor.Storage interface
+func (s *AzureStorage) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	image, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return err
+	}
+	_, err = s.client.UploadBuffer(ctx, s.Container, image, buf, &azblob.UploadBufferOptions{
+		HTTPHeaders: &azureblob.HTTPHeaders{
+			BlobContentType: to.Ptr(blob.ContentType()),
+		},
+	})
+	return err
+}
+
+// Delete implements imagor.Storage interface
+func (s *AzureStorage) Delete(ctx context.Context, image string) error {
+	image, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	_, err := s.client.DeleteBlob(ctx, s.Container, image, nil)
+	return wrapErr(err)
+}
+
+// Path transforms and validates image key for storage path
+func (s *AzureStorage) Path(image string) (string, bool) {
+	image = "/" + imagorpath.Normalize(image, s.safeChars)
+
+	if !strings.HasPrefix(image, s.PathPrefix) {
+		return "", false
+	}
+	joinedPath := filepath.Join(s.BaseDir, strings.TrimPrefix(image, s.PathPrefix))
+	// Azure blob names don't start with "/"
+	return strings.Trim(joinedPath, "/"), true
+}
+
+// Stat implements imagor.Storage interface
+func (s *AzureStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	image, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	stat, err := s.stat(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return &stat.Stat, nil
+}
+
+type blobStat struct {
+	imagor.Stat
+	contentType string
+}
+
+func (s *AzureStorage) stat(ctx context.Context, image string) (*blobStat, error) {
+	resp, err := s.client.ServiceClient().
+		NewContainerClient(s.Container).
+		NewBlobClient(image).
+		GetProperties(ctx, nil)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	stat := &blobStat{}
+	if resp.ContentLength != nil {
+		stat.Size = *resp.ContentLength
+	}
+	if resp.ETag != nil {
+		stat.ETag = string(*resp.ETag)
+	}
+	if resp.LastModified != nil {
+		stat.ModifiedTime = *resp.LastModified
+	}
+	if resp.ContentType != nil {
+		stat.contentType = *resp.ContentType
+	}
+	return stat, nil
+}
+
+func wrapErr(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return imagor.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,203 @@
+package azurestorage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/cshum/imagor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBlob struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+// fakeAzureServer minimal in-memory Azure Blob service for Put Blob, Get Blob, Get Blob Properties and Delete Blob
+func fakeAzureServer(t *testing.T) *azblob.Client {
+	var mu sync.Mutex
+	blobs := map[string]*fakeBlob{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := strings.TrimPrefix(r.URL.Path, "/account/")
+		b, ok := blobs[key]
+		if r.Method != http.MethodPut && !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			blobs[key] = &fakeBlob{
+				data:         data,
+				contentType:  r.Header.Get("x-ms-blob-content-type"),
+				lastModified: time.Now().UTC().Truncate(time.Second),
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodHead, http.MethodGet:
+			w.Header().Set("Content-Length", fmt.Sprint(len(b.data)))
+			w.Header().Set("Content-Type", b.contentType)
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, b.lastModified.UnixNano()))
+			w.Header().Set("Last-Modified", b.lastModified.Format(http.TimeFormat))
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write(b.data)
+			}
+		case http.MethodDelete:
+			delete(blobs, key)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := azblob.NewClientWithNoCredential(srv.URL+"/account", nil)
+	require.NoError(t, err)
+	return client
+}
+
+func TestAzureStorage_Path(t *testing.T) {
+	tests := []struct {
+		name         string
+		baseDir      string
+		baseURI      string
+		image        string
+		safeChars    string
+		expectedPath string
+		expectedOk   bool
+	}{
+		{
+			name:         "defaults ok",
+			image:        "/foo/bar",
+			expectedPath: "foo/bar",
+			expectedOk:   true,
+		},
+		{
+			name:         "escape unsafe chars",
+			image:        "/foo/b{:}ar",
+			expectedPath: "foo/b%7B%3A%7Dar",
+			expectedOk:   true,
+		},
+		{
+			name:         "escape safe chars",
+			image:        "/foo/b{:}ar",
+			expectedPath: "foo/b{%3A}ar",
+			safeChars:    "{}",
+			expectedOk:   true,
+		},
+		{
+			name:         "path under with base uri",
+			baseDir:      "home/imagor",
+			baseURI:      "/foo",
+			image:        "/foo/bar",
+			expectedPath: "home/imagor/bar",
+			expectedOk:   true,
+		},
+		{
+			name:         "path under no base uri",
+			baseDir:      "/home/imagor",
+			image:        "/foo/bar",
+			expectedPath: "home/imagor/foo/bar",
+			expectedOk:   true,
+		},
+		{
+			name:       "path not under",
+			baseDir:    "/home/imagor",
+			baseURI:    "/foo",
+			image:      "/fooo/bar",
+			expectedOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(nil, "test",
+				WithPathPrefix(tt.baseURI),
+				WithBaseDir(tt.baseDir),
+				WithSafeChars(tt.safeChars))
+			res, ok := s.Path(tt.image)
+			assert.Equal(t, tt.expectedPath, res)
+			assert.Equal(t, tt.expectedOk, ok)
+		})
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(fakeAzureServer(t), "test", WithPathPrefix("/foo"))
+	var err error
+
+	_, err = s.Get(r, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	_, err = s.Stat(ctx, "/bar/fooo/asdf")
+	assert.Equal(t, imagor.ErrInvalid, err)
+
+	_, err = s.Stat(ctx, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	assert.ErrorIs(t, s.Put(ctx, "/bar/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))), imagor.ErrInvalid)
+
+	blob := imagor.NewBlobFromBytes([]byte("bar"))
+	blob.SetContentType("image/png")
+	require.NoError(t, s.Put(ctx, "/foo/fooo/asdf", blob))
+
+	stat, err := s.Stat(ctx, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	assert.False(t, stat.ModifiedTime.After(time.Now()))
+	assert.NotEmpty(t, stat.ETag)
+	assert.Equal(t, int64(3), stat.Size)
+
+	b, err := s.Get(r, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	assert.Equal(t, "image/png", b.ContentType())
+	assert.Equal(t, stat, b.Stat)
+
+	require.NoError(t, s.Delete(ctx, "/foo/fooo/asdf"))
+
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+
+	assert.Equal(t, imagor.ErrNotFound, s.Delete(ctx, "/foo/fooo/asdf"))
+	assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, "/bar/fooo/asdf"))
+}
+
+func TestExpiration(t *testing.T) {
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	// last modified of second precision
+	s := New(fakeAzureServer(t), "test", WithExpiration(time.Millisecond*1500))
+	assert.Equal(t, time.Millisecond*1500, s.Expiration)
+
+	require.NoError(t, s.Put(ctx, "/foo/bar/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+	b, err := s.Get(r, "/foo/bar/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+
+	time.Sleep(time.Second * 2)
+	_, err = s.Get(r, "/foo/bar/asdf")
+	require.ErrorIs(t, err, imagor.ErrExpired)
+
+	// expired blob is deleted
+	_, err = s.Stat(ctx, "/foo/bar/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
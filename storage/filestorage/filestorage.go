@@ -2,13 +2,18 @@ package filestorage
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/imagorpath"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +30,9 @@ type FileStorage struct {
 	SaveErrIfExists bool
 	SafeChars       string
 	Expiration      time.Duration
+	AllowedPatterns []string
+	HashDepth       int
+	HashWidth       int
 
 	safeChars imagorpath.SafeChars
 }
@@ -56,16 +64,54 @@ func (s *FileStorage) Path(image string) (string, bool) {
 	if !strings.HasPrefix(image, s.PathPrefix) {
 		return "", false
 	}
-	return filepath.Join(s.BaseDir, strings.TrimPrefix(image, s.PathPrefix)), true
+	image = strings.TrimPrefix(image, s.PathPrefix)
+	if s.HashDepth > 0 {
+		image = s.hashedPath(image)
+	}
+	return filepath.Join(s.BaseDir, image), true
+}
+
+// hashedPath prepends hash derived sub directories to image key e.g. ab/cd/image
+func (s *FileStorage) hashedPath(image string) string {
+	sum := sha1.Sum([]byte(image))
+	hash := hex.EncodeToString(sum[:])
+	var dirs = make([]string, 0, s.HashDepth+1)
+	for i := 0; i < s.HashDepth && (i+1)*s.HashWidth <= len(hash); i++ {
+		dirs = append(dirs, hash[i*s.HashWidth:(i+1)*s.HashWidth])
+	}
+	return path.Join(append(dirs, image)...)
+}
+
+// allowed checks resolved file path does not escape base dir,
+// and matches allowed glob patterns relative to base dir if set
+func (s *FileStorage) allowed(image string) bool {
+	rel, err := filepath.Rel(filepath.Clean(s.BaseDir), filepath.Clean(image))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if len(s.AllowedPatterns) == 0 {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range s.AllowedPatterns {
+		if matched, err := path.Match(pattern, rel); matched && err == nil {
+			return true
+		}
+	}
+	return false
 }
 
 // Get implements imagor.Storage interface
-func (s *FileStorage) Get(_ *http.Request, image string) (*imagor.Blob, error) {
+func (s *FileStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
 	image, ok := s.Path(image)
 	if !ok {
 		return nil, imagor.ErrInvalid
 	}
-	return imagor.NewBlobFromFile(image, func(stat os.FileInfo) error {
+	if !s.allowed(image) {
+		// not distinguishable from non-existent file
+		return nil, imagor.ErrNotFound
+	}
+	return imagor.NewBlobFromFileContext(r.Context(), image, func(stat os.FileInfo) error {
 		if s.Expiration > 0 && time.Now().Sub(stat.ModTime()) > s.Expiration {
 			return imagor.ErrExpired
 		}
@@ -76,7 +122,7 @@ func (s *FileStorage) Get(_ *http.Request, image string) (*imagor.Blob, error) {
 // Put implements imagor.Storage interface
 func (s *FileStorage) Put(_ context.Context, image string, blob *imagor.Blob) (err error) {
 	image, ok := s.Path(image)
-	if !ok {
+	if !ok || !s.allowed(image) {
 		return imagor.ErrInvalid
 	}
 	if err = os.MkdirAll(filepath.Dir(image), s.MkdirPermission); err != nil {
@@ -89,19 +135,22 @@ func (s *FileStorage) Put(_ context.Context, image string, blob *imagor.Blob) (e
 	defer func() {
 		_ = reader.Close()
 	}()
-	flag := os.O_RDWR | os.O_CREATE | os.O_TRUNC
 	if s.SaveErrIfExists {
-		flag = os.O_RDWR | os.O_CREATE | os.O_EXCL
+		if _, err = os.Stat(image); err == nil {
+			return os.ErrExist
+		}
 	}
-	w, err := os.OpenFile(image, flag, s.WritePermission)
+	// write to temp file in the same directory then rename into place,
+	// so that a partial write never leaves a truncated file at the final path
+	tmp := filepath.Join(filepath.Dir(image),
+		"."+filepath.Base(image)+"."+strconv.FormatUint(rand.Uint64(), 36)+".tmp")
+	w, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_EXCL, s.WritePermission)
 	if err != nil {
 		return
 	}
 	defer func() {
 		_ = w.Close()
-		if err != nil {
-			_ = os.Remove(w.Name())
-		}
+		_ = os.Remove(tmp)
 	}()
 	if _, err = io.Copy(w, reader); err != nil {
 		return
@@ -109,13 +158,20 @@ func (s *FileStorage) Put(_ context.Context, image string, blob *imagor.Blob) (e
 	if err = w.Sync(); err != nil {
 		return
 	}
-	return
+	if err = w.Close(); err != nil {
+		return
+	}
+	if s.SaveErrIfExists {
+		// hard link fails if final path exists
+		return os.Link(tmp, image)
+	}
+	return os.Rename(tmp, image)
 }
 
 // Delete implements imagor.Storage interface
 func (s *FileStorage) Delete(_ context.Context, image string) error {
 	image, ok := s.Path(image)
-	if !ok {
+	if !ok || !s.allowed(image) {
 		return imagor.ErrInvalid
 	}
 	return os.Remove(image)
@@ -127,6 +183,9 @@ func (s *FileStorage) Stat(_ context.Context, image string) (stat *imagor.Stat,
 	if !ok {
 		return nil, imagor.ErrInvalid
 	}
+	if !s.allowed(image) {
+		return nil, imagor.ErrNotFound
+	}
 	osStat, err := os.Stat(image)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -2,13 +2,18 @@ package filestorage
 
 import (
 	"context"
+	"errors"
 	"github.com/cshum/imagor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -140,6 +145,48 @@ func TestFileStorage_Load_Save(t *testing.T) {
 		assert.Equal(t, imagor.ErrInvalid, err)
 		assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "/abc/.git", imagor.NewBlobFromBytes([]byte("boo"))))
 	})
+	t.Run("path traversal", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "imagor-secret"), []byte("secret"), 0666))
+		t.Cleanup(func() {
+			_ = os.Remove(filepath.Join(filepath.Dir(dir), "imagor-secret"))
+		})
+		s := New(dir, WithSafeChars("--"))
+		s.Blacklists = nil
+		for _, image := range []string{
+			"../imagor-secret",
+			"../../imagor-secret",
+			"foo/../../imagor-secret",
+			"..",
+		} {
+			_, err := checkBlob(s.Get(r, image))
+			assert.Equal(t, imagor.ErrNotFound, err, image)
+			_, err = s.Stat(ctx, image)
+			assert.Equal(t, imagor.ErrNotFound, err, image)
+			assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, image, imagor.NewBlobFromBytes([]byte("boo"))), image)
+			assert.Equal(t, imagor.ErrInvalid, s.Delete(ctx, image), image)
+		}
+	})
+	t.Run("allowed patterns", func(t *testing.T) {
+		s := New(dir, WithAllowedPatterns([]string{"images/*.jpg", "/images/*/*.png", ""}))
+		assert.Equal(t, []string{"images/*.jpg", "images/*/*.png"}, s.AllowedPatterns)
+		require.NoError(t, s.Put(ctx, "/images/a.jpg", imagor.NewBlobFromBytes([]byte("a"))))
+		require.NoError(t, s.Put(ctx, "/images/foo/b.png", imagor.NewBlobFromBytes([]byte("b"))))
+		assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "/images/c.png", imagor.NewBlobFromBytes([]byte("c"))))
+		require.NoError(t, New(dir).Put(ctx, "/images/c.png", imagor.NewBlobFromBytes([]byte("c"))))
+
+		for _, image := range []string{"/images/a.jpg", "/images/foo/b.png"} {
+			b, err := checkBlob(s.Get(r, image))
+			require.NoError(t, err, image)
+			_, err = b.ReadAll()
+			assert.NoError(t, err, image)
+		}
+		for _, image := range []string{"/images/c.png", "/images/foo/a.jpg", "/a.jpg", "/images/../a.jpg"} {
+			_, err := checkBlob(s.Get(r, image))
+			assert.Equal(t, imagor.ErrNotFound, err, image)
+		}
+		_, err := s.Stat(ctx, "/images/c.png")
+		assert.Equal(t, imagor.ErrNotFound, err)
+	})
 	t.Run("CRUD", func(t *testing.T) {
 		s := New(dir, WithPathPrefix("/foo"), WithMkdirPermission("0755"), WithWritePermission("0666"))
 
@@ -196,6 +243,69 @@ func TestFileStorage_Load_Save(t *testing.T) {
 		assert.Equal(t, "bar", string(buf))
 	})
 
+	t.Run("partial write", func(t *testing.T) {
+		s := New(dir, WithWritePermission("0600"))
+		require.NoError(t, s.Put(ctx, "/foo/partial/ok", imagor.NewBlobFromBytes([]byte("bar"))))
+		stat, err := os.Stat(filepath.Join(dir, "foo/partial/ok"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+		blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
+			return io.NopCloser(io.MultiReader(
+				strings.NewReader("partial"),
+				iotest.ErrReader(errors.New("connection reset")),
+			)), 0, nil
+		})
+		assert.Error(t, s.Put(ctx, "/foo/partial/asdf", blob))
+		_, err = checkBlob(s.Get(r, "/foo/partial/asdf"))
+		assert.Equal(t, imagor.ErrNotFound, err)
+
+		assert.Error(t, s.Put(ctx, "/foo/partial/ok", blob))
+		b, err := checkBlob(s.Get(r, "/foo/partial/ok"))
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(buf), "existing file untouched")
+
+		entries, err := os.ReadDir(filepath.Join(dir, "foo/partial"))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "no temp file left")
+	})
+
+	t.Run("hashed path", func(t *testing.T) {
+		s := New(dir, WithHashedPath(2, 0))
+		assert.Equal(t, 2, s.HashDepth)
+		assert.Equal(t, 2, s.HashWidth)
+		p, ok := s.Path("/foo/hashed/asdf")
+		require.True(t, ok)
+		rel, err := filepath.Rel(dir, p)
+		require.NoError(t, err)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		require.Len(t, parts, 5)
+		assert.Len(t, parts[0], 2)
+		assert.Len(t, parts[1], 2)
+		assert.Equal(t, "foo/hashed/asdf", strings.Join(parts[2:], "/"))
+		p2, _ := New(dir, WithHashedPath(2, 2)).Path("foo/hashed/asdf")
+		assert.Equal(t, p, p2, "deterministic")
+
+		require.NoError(t, s.Put(ctx, "/foo/hashed/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+		_, err = os.Stat(p)
+		require.NoError(t, err)
+		stat, err := s.Stat(ctx, "/foo/hashed/asdf")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stat.Size)
+		b, err := checkBlob(s.Get(r, "/foo/hashed/asdf"))
+		require.NoError(t, err)
+		buf, err := b.ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(buf))
+		_, err = checkBlob(New(dir).Get(r, "/foo/hashed/asdf"))
+		assert.Equal(t, imagor.ErrNotFound, err)
+		require.NoError(t, s.Delete(ctx, "/foo/hashed/asdf"))
+		_, err = os.Stat(p)
+		assert.True(t, os.IsNotExist(err))
+	})
+
 	t.Run("expiration", func(t *testing.T) {
 		s := New(dir, WithExpiration(time.Millisecond*10))
 		var err error
@@ -79,3 +79,29 @@ func WithExpiration(exp time.Duration) Option {
 		}
 	}
 }
+
+// WithAllowedPatterns with allowed glob patterns option,
+// matched against file path relative to base dir e.g. images/*.jpg
+func WithAllowedPatterns(patterns []string) Option {
+	return func(h *FileStorage) {
+		for _, pattern := range patterns {
+			if pattern = strings.Trim(strings.TrimSpace(pattern), "/"); pattern != "" {
+				h.AllowedPatterns = append(h.AllowedPatterns, pattern)
+			}
+		}
+	}
+}
+
+// WithHashedPath with hashed path option, spreading files across
+// hash derived sub directories of the key e.g. ab/cd/image with depth 2 and width 2
+func WithHashedPath(depth, width int) Option {
+	return func(h *FileStorage) {
+		if depth > 0 {
+			if width <= 0 {
+				width = 2
+			}
+			h.HashDepth = depth
+			h.HashWidth = width
+		}
+	}
+}
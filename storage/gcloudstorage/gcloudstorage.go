@@ -15,13 +15,15 @@ import (
 
 // GCloudStorage Google Cloud Storage implements imagor.Storage interface
 type GCloudStorage struct {
-	BaseDir    string
-	PathPrefix string
-	ACL        string
-	SafeChars  string
-	Expiration time.Duration
-	client     *storage.Client
-	Bucket     string
+	BaseDir      string
+	PathPrefix   string
+	ACL          string
+	SafeChars    string
+	Expiration   time.Duration
+	CacheControl string
+	Metadata     map[string]string
+	client       *storage.Client
+	Bucket       string
 
 	safeChars imagorpath.SafeChars
 }
@@ -97,6 +99,12 @@ func (s *GCloudStorage) Put(ctx context.Context, image string, blob *imagor.Blob
 		writer.PredefinedACL = s.ACL
 	}
 	writer.ContentType = blob.ContentType()
+	if s.CacheControl != "" {
+		writer.CacheControl = s.CacheControl
+	}
+	if len(s.Metadata) > 0 {
+		writer.Metadata = s.Metadata
+	}
 	if _, err = io.Copy(writer, reader); err != nil {
 		return err
 	}
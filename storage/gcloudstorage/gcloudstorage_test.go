@@ -152,6 +152,28 @@ func TestCRUD(t *testing.T) {
 	require.NoError(t, s.Put(ctx, "/foo/boo/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
 }
 
+func TestObjectAttrs(t *testing.T) {
+	srv := fakestorage.NewServer([]fakestorage.Object{{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName: "test",
+			Name:       "placeholder",
+		},
+		Content: []byte(""),
+	}})
+	defer srv.Stop()
+	ctx := context.Background()
+	s := New(srv.Client(), "test",
+		WithCacheControl("public, max-age=86400"),
+		WithMetadata(map[string]string{"foo": "bar"}),
+	)
+	require.NoError(t, s.Put(ctx, "/abc.png", imagor.NewBlobFromBytes([]byte("\x89PNG\r\n\x1a\nabcd"))))
+	obj, err := srv.GetObject("test", "abc.png")
+	require.NoError(t, err)
+	assert.Equal(t, "public, max-age=86400", obj.CacheControl)
+	assert.Equal(t, map[string]string{"foo": "bar"}, obj.Metadata)
+	assert.Equal(t, "image/png", obj.ContentType)
+}
+
 func TestExpiration(t *testing.T) {
 	srv := fakestorage.NewServer([]fakestorage.Object{{
 		ObjectAttrs: fakestorage.ObjectAttrs{
@@ -56,3 +56,26 @@ func WithExpiration(exp time.Duration) Option {
 		}
 	}
 }
+
+// WithCacheControl with Cache-Control object attribute option set on upload
+func WithCacheControl(cacheControl string) Option {
+	return func(h *GCloudStorage) {
+		if cacheControl != "" {
+			h.CacheControl = cacheControl
+		}
+	}
+}
+
+// WithMetadata with custom object metadata option set on upload
+func WithMetadata(metadata map[string]string) Option {
+	return func(h *GCloudStorage) {
+		if len(metadata) > 0 {
+			if h.Metadata == nil {
+				h.Metadata = make(map[string]string, len(metadata))
+			}
+			for key, val := range metadata {
+				h.Metadata[key] = val
+			}
+		}
+	}
+}
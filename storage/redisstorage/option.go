@@ -0,0 +1,43 @@
+package redisstorage
+
+import "time"
+
+// Option RedisStorage option
+type Option func(s *RedisStorage)
+
+// WithKeyPrefix with key prefix option
+func WithKeyPrefix(prefix string) Option {
+	return func(s *RedisStorage) {
+		if prefix != "" {
+			s.KeyPrefix = prefix
+		}
+	}
+}
+
+// WithSafeChars with safe chars option
+func WithSafeChars(chars string) Option {
+	return func(s *RedisStorage) {
+		if chars != "" {
+			s.SafeChars = chars
+		}
+	}
+}
+
+// WithExpiration with expiration option, applied as Redis key TTL
+func WithExpiration(exp time.Duration) Option {
+	return func(s *RedisStorage) {
+		if exp > 0 {
+			s.Expiration = exp
+		}
+	}
+}
+
+// WithMaxValueSize with max value size option,
+// blobs larger than the size are rejected rather than stored
+func WithMaxValueSize(size int64) Option {
+	return func(s *RedisStorage) {
+		if size != 0 {
+			s.MaxValueSize = size
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package redisstorage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/redis/go-redis/v9"
+)
+
+// modTimeSize size of the modified time prefix of stored values
+const modTimeSize = 8
+
+// RedisStorage Redis Storage implements imagor.Storage interface,
+// stores blobs as binary values with expiration as TTL
+type RedisStorage struct {
+	Client       redis.UniversalClient
+	KeyPrefix    string
+	SafeChars    string
+	Expiration   time.Duration
+	MaxValueSize int64
+
+	safeChars imagorpath.SafeChars
+}
+
+// New creates RedisStorage
+func New(client redis.UniversalClient, options ...Option) *RedisStorage {
+	s := &RedisStorage{
+		Client:       client,
+		KeyPrefix:    "imagor:",
+		MaxValueSize: 10 << 20,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.safeChars = imagorpath.NewSafeChars(s.SafeChars)
+	return s
+}
+
+// Path transforms and validates image key for storage key
+func (s *RedisStorage) Path(image string) (string, bool) {
+	image = strings.Trim(imagorpath.Normalize(image, s.safeChars), "/")
+	if image == "" || image == "." {
+		return "", false
+	}
+	return s.KeyPrefix + image, true
+}
+
+// Get implements imagor.Storage interface
+func (s *RedisStorage) Get(r *http.Request, image string) (*imagor.Blob, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	buf, err := s.Client.Get(r.Context(), key).Bytes()
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if len(buf) < modTimeSize {
+		return nil, imagor.ErrNotFound
+	}
+	blob := imagor.NewBlobFromBytes(buf[modTimeSize:])
+	blob.Stat = &imagor.Stat{
+		Size:         int64(len(buf) - modTimeSize),
+		ModifiedTime: decodeModTime(buf),
+	}
+	return blob, nil
+}
+
+// Put implements imagor.Storage interface
+func (s *RedisStorage) Put(ctx context.Context, image string, blob *imagor.Blob) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	if s.MaxValueSize > 0 && blob.Size() > s.MaxValueSize {
+		return imagor.ErrMaxSizeExceeded
+	}
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return err
+	}
+	if s.MaxValueSize > 0 && int64(len(buf)) > s.MaxValueSize {
+		return imagor.ErrMaxSizeExceeded
+	}
+	// value prefixed by modified time as Redis does not track it
+	value := make([]byte, modTimeSize+len(buf))
+	binary.BigEndian.PutUint64(value, uint64(time.Now().UnixNano()))
+	copy(value[modTimeSize:], buf)
+	return s.Client.Set(ctx, key, value, s.Expiration).Err()
+}
+
+// Delete implements imagor.Storage interface
+func (s *RedisStorage) Delete(ctx context.Context, image string) error {
+	key, ok := s.Path(image)
+	if !ok {
+		return imagor.ErrInvalid
+	}
+	return s.Client.Del(ctx, key).Err()
+}
+
+// Stat implements imagor.Storage interface
+func (s *RedisStorage) Stat(ctx context.Context, image string) (*imagor.Stat, error) {
+	key, ok := s.Path(image)
+	if !ok {
+		return nil, imagor.ErrInvalid
+	}
+	return s.stat(ctx, key)
+}
+
+// stat reads modified time prefix and size of value
+func (s *RedisStorage) stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	var prefix *redis.StringCmd
+	var size *redis.IntCmd
+	if _, err := s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		prefix = pipe.GetRange(ctx, key, 0, modTimeSize-1)
+		size = pipe.StrLen(ctx, key)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	buf := []byte(prefix.Val())
+	if size.Val() < modTimeSize || len(buf) < modTimeSize {
+		return nil, imagor.ErrNotFound
+	}
+	return &imagor.Stat{
+		Size:         size.Val() - modTimeSize,
+		ModifiedTime: decodeModTime(buf),
+	}, nil
+}
+
+func decodeModTime(buf []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+}
+
+func wrapErr(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return imagor.ErrNotFound
+	}
+	return err
+}
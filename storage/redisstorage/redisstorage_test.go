@@ -0,0 +1,126 @@
+package redisstorage
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cshum/imagor"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return mr, client
+}
+
+func TestRedisStorage_Path(t *testing.T) {
+	_, client := newClient(t)
+	tests := []struct {
+		name       string
+		image      string
+		options    []Option
+		expected   string
+		expectedOk bool
+	}{
+		{
+			name:       "default prefix",
+			image:      "abc/def.jpg",
+			expected:   "imagor:abc/def.jpg",
+			expectedOk: true,
+		},
+		{
+			name:       "custom prefix",
+			image:      "/abc/def.jpg",
+			options:    []Option{WithKeyPrefix("result:")},
+			expected:   "result:abc/def.jpg",
+			expectedOk: true,
+		},
+		{
+			name:  "empty",
+			image: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := New(client, tt.options...).Path(tt.image)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.expected, key)
+		})
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	_, client := newClient(t)
+	ctx := context.Background()
+	s := New(client, WithSafeChars("!"))
+	assert.Equal(t, "!", s.SafeChars)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	_, err := s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	_, err = s.Stat(ctx, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+	assert.Equal(t, imagor.ErrInvalid, s.Put(ctx, "", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	require.NoError(t, s.Put(ctx, "/foo/fooo/asdf", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	stat, err := s.Stat(ctx, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stat.Size)
+	assert.WithinDuration(t, time.Now(), stat.ModifiedTime, time.Second)
+
+	b, err := s.Get(r, "/foo/fooo/asdf")
+	require.NoError(t, err)
+	buf, err := b.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buf))
+	require.NotNil(t, b.Stat)
+	assert.Equal(t, stat.ModifiedTime, b.Stat.ModifiedTime)
+	assert.Equal(t, int64(3), b.Stat.Size)
+
+	require.NoError(t, s.Delete(ctx, "/foo/fooo/asdf"))
+	_, err = s.Get(r, "/foo/fooo/asdf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestExpiration(t *testing.T) {
+	mr, client := newClient(t)
+	ctx := context.Background()
+	s := New(client, WithExpiration(time.Hour))
+	assert.Equal(t, time.Hour, s.Expiration)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	require.NoError(t, s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("bar"))))
+	assert.Equal(t, time.Hour, mr.TTL("imagor:foo/bar"))
+
+	mr.FastForward(time.Minute * 10)
+	stat, err := s.Stat(ctx, "/foo/bar")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), stat.ModifiedTime, time.Second)
+
+	mr.FastForward(time.Hour)
+	_, err = s.Get(r, "/foo/bar")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestMaxValueSize(t *testing.T) {
+	mr, client := newClient(t)
+	ctx := context.Background()
+	s := New(client, WithMaxValueSize(5))
+	assert.Equal(t, int64(5), s.MaxValueSize)
+
+	assert.Equal(t, imagor.ErrMaxSizeExceeded,
+		s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("abcdefg"))))
+	assert.False(t, mr.Exists("imagor:foo/bar"))
+	assert.NoError(t, s.Put(ctx, "/foo/bar", imagor.NewBlobFromBytes([]byte("abcde"))))
+	assert.True(t, mr.Exists("imagor:foo/bar"))
+}
@@ -86,3 +86,33 @@ func WithStorageClass(storageClass string) Option {
 		}
 	}
 }
+
+// WithSSE with server-side encryption algorithm option e.g. AES256, aws:kms
+func WithSSE(alg string) Option {
+	return func(h *S3Storage) {
+		if alg != "" {
+			h.SSE = alg
+		}
+	}
+}
+
+// WithSSEKMSKeyID with server-side encryption KMS key ID option,
+// implies aws:kms encryption if algorithm not specified
+func WithSSEKMSKeyID(id string) Option {
+	return func(h *S3Storage) {
+		if id != "" {
+			h.SSEKMSKeyID = id
+		}
+	}
+}
+
+// WithMultipartThreshold with multipart upload threshold option,
+// blobs smaller than the threshold are uploaded with single PutObject,
+// otherwise streamed by multipart upload with threshold as part size
+func WithMultipartThreshold(bytes int64) Option {
+	return func(h *S3Storage) {
+		if bytes > 0 {
+			h.MultipartThreshold = bytes
+		}
+	}
+}
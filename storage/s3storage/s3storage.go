@@ -1,6 +1,7 @@
 package s3storage
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
@@ -31,6 +32,10 @@ type S3Storage struct {
 	SafeChars    string
 	StorageClass string
 	Expiration   time.Duration
+	SSE          string
+	SSEKMSKeyID  string
+
+	MultipartThreshold int64
 
 	safeChars imagorpath.SafeChars
 }
@@ -125,6 +130,11 @@ func (s *S3Storage) Put(ctx context.Context, image string, blob *imagor.Blob) er
 	if !ok {
 		return imagor.ErrInvalid
 	}
+	if s.MultipartThreshold > 0 {
+		if size := blob.Size(); size > 0 && size < s.MultipartThreshold {
+			return s.putObject(ctx, image, blob)
+		}
+	}
 	reader, _, err := blob.NewReader()
 	if err != nil {
 		return err
@@ -142,10 +152,48 @@ func (s *S3Storage) Put(ctx context.Context, image string, blob *imagor.Blob) er
 		Key:          aws.String(image),
 		StorageClass: aws.String(s.StorageClass),
 	}
-	_, err = s.Uploader.UploadWithContext(ctx, input)
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.sse()
+	_, err = s.Uploader.UploadWithContext(ctx, input, func(u *s3manager.Uploader) {
+		if s.MultipartThreshold > s3manager.MinUploadPartSize {
+			u.PartSize = s.MultipartThreshold
+		}
+	})
+	return err
+}
+
+// putObject uploads blob below multipart threshold with single PutObject
+func (s *S3Storage) putObject(ctx context.Context, image string, blob *imagor.Blob) error {
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		ACL:          aws.String(s.ACL),
+		Body:         bytes.NewReader(buf),
+		Bucket:       aws.String(s.Bucket),
+		ContentType:  aws.String(blob.ContentType()),
+		Key:          aws.String(image),
+		StorageClass: aws.String(s.StorageClass),
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.sse()
+	_, err = s.S3.PutObjectWithContext(ctx, input)
 	return err
 }
 
+// sse returns server-side encryption algorithm and KMS key ID if set
+func (s *S3Storage) sse() (alg, keyID *string) {
+	if s.SSE != "" {
+		alg = aws.String(s.SSE)
+	}
+	if s.SSEKMSKeyID != "" {
+		if alg == nil {
+			alg = aws.String(s3.ServerSideEncryptionAwsKms)
+		}
+		keyID = aws.String(s.SSEKMSKeyID)
+	}
+	return
+}
+
 // Delete implements imagor.Storage interface
 func (s *S3Storage) Delete(ctx context.Context, image string) error {
 	image, ok := s.Path(image)
@@ -170,7 +218,8 @@ func (s *S3Storage) Stat(ctx context.Context, image string) (stat *imagor.Stat,
 		Key:    aws.String(image),
 	}
 	head, err := s.S3.HeadObjectWithContext(ctx, input)
-	if e, ok := err.(awserr.Error); ok && e.Code() == s3.ErrCodeNoSuchKey {
+	// HEAD response has no body, S3 responds NotFound code instead of NoSuchKey
+	if e, ok := err.(awserr.Error); ok && (e.Code() == s3.ErrCodeNoSuchKey || e.Code() == "NotFound") {
 		return nil, imagor.ErrNotFound
 	} else if err != nil {
 		return nil, err
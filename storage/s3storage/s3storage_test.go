@@ -6,6 +6,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/cshum/imagor"
 	"github.com/johannesboyne/gofakes3"
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -229,3 +231,73 @@ func TestExpiration(t *testing.T) {
 	_, err = b.ReadAll()
 	require.ErrorIs(t, err, imagor.ErrExpired)
 }
+
+func TestSSE(t *testing.T) {
+	var mu sync.Mutex
+	var headers = map[string]http.Header{}
+	faker := gofakes3.New(s3mem.New()).Server()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			mu.Lock()
+			headers[r.URL.Path] = r.Header.Clone()
+			mu.Unlock()
+		}
+		faker.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	sess := fakeS3Session(ts, "test")
+
+	s := New(sess, "test")
+	require.NoError(t, s.Put(ctx, "/plain", imagor.NewBlobFromBytes([]byte("bar"))))
+	s = New(sess, "test", WithSSE("AES256"))
+	assert.Equal(t, "AES256", s.SSE)
+	require.NoError(t, s.Put(ctx, "/aes", imagor.NewBlobFromBytes([]byte("bar"))))
+	s = New(sess, "test", WithSSEKMSKeyID("my-key"))
+	assert.Equal(t, "my-key", s.SSEKMSKeyID)
+	require.NoError(t, s.Put(ctx, "/kms", imagor.NewBlobFromBytes([]byte("bar"))))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, headers["/test/plain"].Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "AES256", headers["/test/aes"].Get("X-Amz-Server-Side-Encryption"))
+	assert.Empty(t, headers["/test/aes"].Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "aws:kms", headers["/test/kms"].Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "my-key", headers["/test/kms"].Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+}
+
+func TestMultipartThreshold(t *testing.T) {
+	var mu sync.Mutex
+	var multipart = map[string]bool{}
+	faker := gofakes3.New(s3mem.New()).Server()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["uploads"]; ok && r.Method == http.MethodPost {
+			mu.Lock()
+			multipart[r.URL.Path] = true
+			mu.Unlock()
+		}
+		faker.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	r := (&http.Request{}).WithContext(ctx)
+	s := New(fakeS3Session(ts, "test"), "test", WithMultipartThreshold(s3manager.MinUploadPartSize))
+	assert.Equal(t, int64(s3manager.MinUploadPartSize), s.MultipartThreshold)
+
+	small := []byte("\x89PNG\r\n\x1a\nabcd")
+	large := make([]byte, s3manager.MinUploadPartSize*2+100)
+	copy(large, "\x89PNG\r\n\x1a\n")
+	for key, buf := range map[string][]byte{"/small": small, "/large": large} {
+		require.NoError(t, s.Put(ctx, key, imagor.NewBlobFromBytes(buf)), key)
+		b, err := s.Get(r, key)
+		require.NoError(t, err, key)
+		res, err := b.ReadAll()
+		require.NoError(t, err, key)
+		assert.Equal(t, buf, res, key)
+		assert.Equal(t, "image/png", b.ContentType(), key)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.False(t, multipart["/test/small"])
+	assert.True(t, multipart["/test/large"])
+}
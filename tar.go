@@ -0,0 +1,204 @@
+package imagor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor/imagorpath"
+	"go.uber.org/zap"
+)
+
+// maxTarSpecSize maximum bytes allowed for tar endpoint JSON spec
+const maxTarSpecSize = 1 << 20
+
+// TarEntry tar endpoint spec entry, rendering params as imagor path into archive file name
+type TarEntry struct {
+	Name   string `json:"name"`
+	Params string `json:"params"`
+}
+
+type tarEntry struct {
+	Name   string
+	Params imagorpath.Params
+	Source string
+}
+
+var tarSourcesContextKey = contextKey{5}
+
+// tarSources sources loaded within tar request, shared by entries of the same source image.
+// Sources are kept only until the last entry using it is written
+type tarSources struct {
+	blobs  map[string]*Blob
+	counts map[string]int
+	l      sync.Mutex
+}
+
+// withTarSources with number of entries using each source key
+func withTarSources(ctx context.Context, counts map[string]int) context.Context {
+	return context.WithValue(ctx, tarSourcesContextKey, &tarSources{
+		blobs: map[string]*Blob{}, counts: counts})
+}
+
+// getTarSource returns clone of source loaded by previous entry of tar request
+func getTarSource(ctx context.Context, key string) (*Blob, bool) {
+	if s, ok := ctx.Value(tarSourcesContextKey).(*tarSources); ok && s != nil {
+		s.l.Lock()
+		defer s.l.Unlock()
+		if blob, ok := s.blobs[key]; ok {
+			return blob.Clone(), true
+		}
+	}
+	return nil, false
+}
+
+// putTarSource keeps clone of source if used by later entries of tar request
+func putTarSource(ctx context.Context, key string, blob *Blob) {
+	if s, ok := ctx.Value(tarSourcesContextKey).(*tarSources); ok && s != nil {
+		s.l.Lock()
+		defer s.l.Unlock()
+		if _, ok := s.blobs[key]; !ok && s.counts[key] > 1 {
+			s.blobs[key] = blob.Clone()
+		}
+	}
+}
+
+// doneTarSource marks an entry of source written,
+// releasing the source once no more entries using it
+func doneTarSource(ctx context.Context, key string) {
+	if s, ok := ctx.Value(tarSourcesContextKey).(*tarSources); ok && s != nil {
+		s.l.Lock()
+		defer s.l.Unlock()
+		if s.counts[key]--; s.counts[key] > 0 {
+			return
+		}
+		delete(s.counts, key)
+		if blob, ok := s.blobs[key]; ok {
+			delete(s.blobs, key)
+			blob.Release()
+		}
+	}
+}
+
+// serveTar handles POST /tar with JSON spec of TarEntry,
+// streaming a tar archive of rendered images in spec order.
+// Entries sharing the same source image load the source once.
+func (app *Imagor) serveTar(w http.ResponseWriter, r *http.Request) {
+	var spec []TarEntry
+	if err := json.NewDecoder(
+		http.MaxBytesReader(w, r.Body, maxTarSpecSize)).Decode(&spec); err != nil || len(spec) == 0 {
+		app.writeError(w, r, ErrInvalid)
+		return
+	}
+	var entries []tarEntry
+	var counts = map[string]int{}
+	for _, e := range spec {
+		name := strings.TrimPrefix(path.Clean("/"+e.Name), "/")
+		if name == "" || name != strings.TrimPrefix(e.Name, "/") {
+			app.writeError(w, r, ErrInvalid)
+			return
+		}
+		p := imagorpath.Parse(e.Params)
+		if p.Image == "" || p.Path == "" {
+			app.writeError(w, r, ErrInvalid)
+			return
+		}
+		if err := app.checkSignature(p); err != nil {
+			app.writeError(w, r, err)
+			return
+		}
+		source := p.Image
+		if app.KeyNormalizer != nil {
+			source = app.KeyNormalizer(source)
+		}
+		counts[source]++
+		entries = append(entries, tarEntry{Name: name, Params: p, Source: source})
+	}
+	r = r.WithContext(withTarSources(r.Context(), counts))
+	var tw *tar.Writer
+	for _, e := range entries {
+		if err := app.serveTarEntry(r, e, func(blob *Blob) error {
+			if tw == nil {
+				// response committed once the first entry rendered
+				w.Header().Set("Content-Type", "application/x-tar")
+				w.Header().Set("Content-Disposition", `attachment; filename="imagor.tar"`)
+				tw = tar.NewWriter(w)
+			}
+			return writeTarEntry(tw, e.Name, blob)
+		}); err != nil {
+			if tw == nil {
+				app.writeError(w, r, err)
+				return
+			}
+			// abort the response instead of a valid looking truncated archive
+			app.Logger.Warn("tar", zap.String("name", e.Name), zap.Error(err))
+			panic(http.ErrAbortHandler)
+		}
+	}
+	_ = tw.Close()
+}
+
+// serveTarEntry renders entry through Do, with clean up once the entry written
+func (app *Imagor) serveTarEntry(r *http.Request, e tarEntry, write func(*Blob) error) error {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer doneTarSource(r.Context(), e.Source)
+	blob, err := checkBlob(app.Do(r.WithContext(ctx), e.Params))
+	if err != nil {
+		return err
+	}
+	return write(blob)
+}
+
+func writeTarEntry(tw *tar.Writer, name string, blob *Blob) error {
+	reader, size, err := blob.NewReader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	if size <= 0 {
+		// total size unknown, read all
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		reader = io.NopCloser(bytes.NewReader(buf))
+		size = int64(len(buf))
+	}
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, reader)
+	return err
+}
+
+func (app *Imagor) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		w.WriteHeader(499)
+		return
+	}
+	e := WrapError(err)
+	if !app.VerboseErrors {
+		// avoid leaking internals
+		e.Detail = ""
+	}
+	w.WriteHeader(e.Code)
+	if !app.DisableErrorBody {
+		writeJSON(w, r, e)
+	}
+}
@@ -2,6 +2,9 @@ package vips
 
 import (
 	"context"
+	"net/http"
+
+	"github.com/cshum/imagor"
 )
 
 type contextRefKey struct{}
@@ -9,6 +12,8 @@ type contextRefKey struct{}
 type contextRef struct {
 	cbs      []func()
 	Rotate90 bool
+	NoRotate bool
+	Header   http.Header
 }
 
 func (r *contextRef) Defer(cb func()) {
@@ -49,3 +54,56 @@ func isRotate90(ctx context.Context) bool {
 	}
 	return false
 }
+
+// setNoRotate disables autorotate of images loaded within context
+func setNoRotate(ctx context.Context) {
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		r.NoRotate = true
+	}
+}
+
+func isNoRotate(ctx context.Context) bool {
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		return r.NoRotate
+	}
+	return false
+}
+
+// setHeader sets response header to be forwarded with the processed blob
+func setHeader(ctx context.Context, key, value string) {
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set(key, value)
+	}
+}
+
+func getHeader(ctx context.Context) http.Header {
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		return r.Header
+	}
+	return nil
+}
+
+// contextLoadKey imagor context cache key of image loaded by contextLoad
+type contextLoadKey struct {
+	Image string
+}
+
+// contextLoad loads image through load func,
+// caching the blob within imagor request context to avoid re-fetching the same image,
+// bounded by imagor context cache limit
+func contextLoad(ctx context.Context, load imagor.LoadFunc, image string) (*imagor.Blob, error) {
+	if val, ok := imagor.ContextCacheGet(ctx, contextLoadKey{image}); ok {
+		if blob, ok := val.(*imagor.Blob); ok {
+			return blob, nil
+		}
+	}
+	blob, err := load(image)
+	if err != nil {
+		return nil, err
+	}
+	imagor.ContextCachePut(ctx, contextLoadKey{image}, blob)
+	return blob, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -24,7 +25,7 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 		image = unescape
 	}
 	var blob *imagor.Blob
-	if blob, err = load(image); err != nil {
+	if blob, err = contextLoad(ctx, load, image); err != nil {
 		return
 	}
 	var x, y, w, h int
@@ -126,6 +127,23 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 			y += img.PageHeight() - overlay.PageHeight()
 		}
 	}
+	// repeat tiles across the canvas with x y as tiling origin
+	if ln >= 7 && isRepeatArg(args[6]) {
+		across = img.Width()/w + 1
+		down = img.PageHeight()/h + 1
+		if x = x % w; x > 0 {
+			x -= w
+		}
+		if y = y % h; y > 0 {
+			y -= h
+		}
+		if x < 0 {
+			across++
+		}
+		if y < 0 {
+			down++
+		}
+	}
 	if across*down > 1 {
 		if err = overlay.Embed(0, 0, across*w, down*h, ExtendRepeat); err != nil {
 			return
@@ -151,6 +169,55 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 	return
 }
 
+func isRepeatArg(arg string) bool {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "repeat", "true", "1":
+		return true
+	}
+	return false
+}
+
+// frame delay range in milliseconds respected by GIF and WebP,
+// as browsers slow down GIF delay shorter than 20ms
+const (
+	minFrameDelay = 20
+	maxFrameDelay = 655350
+)
+
+func frameDelay(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	delay, _ := strconv.Atoi(args[0])
+	return setFrameDelay(img, delay)
+}
+
+func fps(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	n, _ := strconv.ParseFloat(args[0], 64)
+	if n <= 0 {
+		return
+	}
+	return setFrameDelay(img, int(math.Round(1000/n)))
+}
+
+// setFrameDelay sets delay of all frames clamped to valid range,
+// non-animated image is untouched
+func setFrameDelay(img *Image, delay int) error {
+	n := img.Height() / img.PageHeight()
+	if delay <= 0 || n <= 1 {
+		return nil
+	}
+	delay = min(max(delay, minFrameDelay), maxFrameDelay)
+	delays := make([]int, n)
+	for i := range delays {
+		delays[i] = delay
+	}
+	return img.SetPageDelay(delays)
+}
+
 func setFrames(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
 	ln := len(args)
 	if ln == 0 {
@@ -189,6 +256,70 @@ func setFrames(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string)
 	return
 }
 
+func (v *Processor) sprite(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 2 {
+		return
+	}
+	cols, _ := strconv.Atoi(strings.TrimSpace(args[0]))
+	rows, _ := strconv.Atoi(strings.TrimSpace(args[1]))
+	if cols < 1 || rows < 1 {
+		return imagor.NewError(fmt.Sprintf("invalid sprite grid %sx%s", args[0], args[1]), http.StatusBadRequest)
+	}
+	var interval int
+	if len(args) > 2 {
+		if interval, err = strconv.Atoi(strings.TrimSpace(args[2])); err != nil || interval < 0 {
+			return imagor.NewError(fmt.Sprintf("invalid sprite interval %s", args[2]), http.StatusBadRequest)
+		}
+	}
+	width, height := img.Width(), img.PageHeight()
+	if width*cols > v.MaxWidth || height*rows > v.MaxHeight {
+		return imagor.ErrMaxResolutionExceeded
+	}
+	frames := spriteFrames(img.Height()/height, cols*rows, img.PageDelay(), interval)
+	if err = img.Sprite(frames, cols, rows); err != nil {
+		return
+	}
+	setHeader(ctx, "Imagor-Sprite-Cell", fmt.Sprintf("%dx%d", width, height))
+	setHeader(ctx, "Imagor-Sprite-Count", strconv.Itoa(len(frames)))
+	return
+}
+
+// spriteFrames returns page indexes sampled for each sprite cell.
+// Frames are sampled every interval milliseconds based on page delays,
+// or the first cells frames if interval not specified, extra frames dropped
+func spriteFrames(n, cells int, delays []int, interval int) (frames []int) {
+	if interval <= 0 {
+		for i := 0; i < min(n, cells); i++ {
+			frames = append(frames, i)
+		}
+		return
+	}
+	if len(delays) != n {
+		delays = make([]int, n)
+	}
+	var idx, start int
+	for i := 0; i < cells; i++ {
+		t := i * interval
+		for idx < n-1 && start+spriteDelay(delays[idx]) <= t {
+			start += spriteDelay(delays[idx])
+			idx++
+		}
+		if t >= start+spriteDelay(delays[idx]) {
+			// beyond animation duration
+			break
+		}
+		frames = append(frames, idx)
+	}
+	return
+}
+
+func spriteDelay(delay int) int {
+	if delay <= 0 {
+		return 100
+	}
+	return delay
+}
+
 func (v *Processor) fill(ctx context.Context, img *Image, w, h int, pLeft, pTop, pRight, pBottom int, colour string) (err error) {
 	if isRotate90(ctx) {
 		tmpW := w
@@ -419,6 +550,32 @@ func (v *Processor) padding(ctx context.Context, img *Image, _ imagor.LoadFunc,
 	return v.fill(ctx, img, img.Width(), img.PageHeight(), left, top, right, bottom, c)
 }
 
+func (v *Processor) fitPad(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 2 {
+		return
+	}
+	w, _ := strconv.Atoi(args[0])
+	h, _ := strconv.Atoi(args[1])
+	if w <= 0 || h <= 0 {
+		return // no ops
+	}
+	if w > v.MaxWidth || h > v.MaxHeight || w*h > v.MaxResolution {
+		return imagor.ErrMaxResolutionExceeded
+	}
+	var c string
+	if len(args) > 2 {
+		c = args[2]
+	}
+	tw, th := w, h
+	if isRotate90(ctx) {
+		tw, th = h, w
+	}
+	if err = img.ThumbnailWithSize(tw, th, InterestingNone, SizeDown); err != nil {
+		return
+	}
+	return v.fill(ctx, img, w, h, 0, 0, 0, 0, c)
+}
+
 func backgroundColor(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
 	if len(args) == 0 {
 		return
@@ -484,6 +641,39 @@ func grayscale(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (e
 	return img.ToColorSpace(InterpretationBW)
 }
 
+// sepiaMatrix sepia tone recomb matrix
+var sepiaMatrix = []float64{
+	0.393, 0.769, 0.189,
+	0.349, 0.686, 0.168,
+	0.272, 0.534, 0.131,
+}
+
+func sepia(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	intensity := 100.0
+	if len(args) > 0 && args[0] != "" {
+		if intensity, err = strconv.ParseFloat(args[0], 64); err != nil {
+			return nil
+		}
+		intensity = math.Min(math.Max(intensity, 0), 100)
+	}
+	if intensity == 0 {
+		return
+	}
+	// blend sepia matrix with identity by intensity
+	t := intensity / 100
+	matrix := make([]float64, 9)
+	for i, m := range sepiaMatrix {
+		matrix[i] = m * t
+		if i%4 == 0 {
+			matrix[i] += 1 - t
+		}
+	}
+	if err = img.ToColorSpace(InterpretationSRGB); err != nil {
+		return
+	}
+	return img.Recomb(matrix, 3)
+}
+
 func brightness(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
 	if len(args) == 0 {
 		return
@@ -547,11 +737,10 @@ func modulate(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string)
 	return img.Modulate(b, s, h)
 }
 
+// maxBlurSigma maximum blur sigma to avoid pathological CPU use
+const maxBlurSigma = 50
+
 func blur(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
-	if isAnimated(img) {
-		// skip animation support
-		return
-	}
 	var sigma float64
 	switch len(args) {
 	case 2:
@@ -561,34 +750,104 @@ func blur(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (e
 		sigma, _ = strconv.ParseFloat(args[0], 64)
 		break
 	}
-	sigma /= 2
+	sigma = math.Min(sigma/2, maxBlurSigma)
 	if sigma > 0 {
 		return img.GaussianBlur(sigma)
 	}
 	return
 }
 
+// default sharpen flat/jaggy threshold and jaggy slope,
+// mild enough for sharpening after lanczos3 downscale
+const (
+	sharpenFlat   = 1
+	sharpenJagged = 2
+)
+
 func sharpen(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
-	if isAnimated(img) {
-		// skip animation support
-		return
-	}
-	var sigma float64
+	var (
+		sigma  float64
+		flat   float64 = sharpenFlat
+		jagged float64 = sharpenJagged
+	)
 	switch len(args) {
 	case 1:
 		sigma, _ = strconv.ParseFloat(args[0], 64)
 		break
-	case 2, 3:
+	case 3:
+		if f, err := strconv.ParseFloat(args[1], 64); err == nil {
+			if j, err := strconv.ParseFloat(args[2], 64); err == nil {
+				// sharpen(radius,flat,jagged)
+				sigma, _ = strconv.ParseFloat(args[0], 64)
+				flat = math.Max(f, 0)
+				jagged = math.Max(j, 0)
+				break
+			}
+		}
+		// sharpen(amount,radius,luminance_only)
+		sigma, _ = strconv.ParseFloat(args[1], 64)
+		break
+	case 2:
 		sigma, _ = strconv.ParseFloat(args[1], 64)
 		break
 	}
 	sigma = 1 + sigma*2
 	if sigma > 0 {
-		return img.Sharpen(sigma, 1, 2)
+		return img.Sharpen(sigma, flat, jagged)
 	}
 	return
 }
 
+func channel(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	var band int
+	switch arg := strings.ToLower(strings.TrimSpace(args[0])); arg {
+	case "r", "red":
+		band = 0
+	case "g", "green":
+		band = 1
+	case "b", "blue":
+		band = 2
+	case "a", "alpha":
+		if !img.HasAlpha() {
+			return imagor.NewError("channel alpha not available", http.StatusBadRequest)
+		}
+		band = img.Bands() - 1
+	default:
+		if band, err = strconv.Atoi(arg); err != nil {
+			return imagor.NewError(fmt.Sprintf("invalid channel %s", arg), http.StatusBadRequest)
+		}
+	}
+	if band < 0 || band >= img.Bands() {
+		return imagor.NewError(fmt.Sprintf("channel %s out of range", args[0]), http.StatusBadRequest)
+	}
+	return img.ExtractBand(band, 1)
+}
+
+func replaceColor(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 2 {
+		return
+	}
+	from, ok := parseStrictHexColor(args[0])
+	if !ok {
+		return imagor.NewError(fmt.Sprintf("invalid color %s", args[0]), http.StatusBadRequest)
+	}
+	to, ok := parseStrictHexColor(args[1])
+	if !ok {
+		return imagor.NewError(fmt.Sprintf("invalid color %s", args[1]), http.StatusBadRequest)
+	}
+	var tolerance = 30.0
+	if len(args) > 2 {
+		if tolerance, err = strconv.ParseFloat(strings.TrimSpace(args[2]), 64); err != nil ||
+			tolerance <= 0 || tolerance > 180 {
+			return imagor.NewError(fmt.Sprintf("invalid tolerance %s", args[2]), http.StatusBadRequest)
+		}
+	}
+	return img.ReplaceColor(rgbToHue(from), rgbToHue(to), tolerance, 5)
+}
+
 func stripIcc(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (err error) {
 	return img.RemoveICCProfile()
 }
@@ -597,24 +856,6 @@ func stripExif(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (e
 	return img.RemoveExif()
 }
 
-func trim(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) error {
-	var (
-		ln        = len(args)
-		pos       string
-		tolerance int
-	)
-	if ln > 0 {
-		tolerance, _ = strconv.Atoi(args[0])
-	}
-	if ln > 1 {
-		pos = args[1]
-	}
-	if l, t, w, h, err := findTrim(ctx, img, pos, tolerance); err == nil {
-		return img.ExtractArea(l, t, w, h)
-	}
-	return nil
-}
-
 func linearRGB(img *Image, a, b []float64) error {
 	if img.HasAlpha() {
 		a = append(a, 1)
@@ -683,6 +924,44 @@ func parseHexColor(s string) (c color.RGBA, ok bool) {
 	return
 }
 
+func parseStrictHexColor(s string) (c color.RGBA, ok bool) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "#")
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9') && !(s[i] >= 'a' && s[i] <= 'f') {
+			return
+		}
+	}
+	return parseHexColor(s)
+}
+
+// rgbToHue returns CIE LCh hue angle in degrees of sRGB color, D65 white point
+func rgbToHue(c color.RGBA) float64 {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(c.R), linear(c.G), linear(c.B)
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	a := 500 * (f(x) - f(y))
+	bb := 200 * (f(y) - f(z))
+	h := math.Atan2(bb, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
 func hexToByte(b byte) byte {
 	switch {
 	case b >= '0' && b <= '9':
@@ -140,6 +140,20 @@ int set_magicksave_options(VipsOperation *operation, SaveParams *params) {
   return ret;
 }
 
+// PDF saved through ImageMagick, as libvips has no native PDF writer
+int set_pdfsave_options(VipsOperation *operation, SaveParams *params) {
+  int ret = vips_object_set(VIPS_OBJECT(operation), "format", "PDF", NULL);
+  if (!ret && params->quality) {
+    ret = vips_object_set(VIPS_OBJECT(operation), "quality", params->quality,
+                          NULL);
+  }
+  if (!ret && params->stripMetadata) {
+    ret = vips_object_set(VIPS_OBJECT(operation), "strip", params->stripMetadata,
+                          NULL);
+  }
+  return ret;
+}
+
 // https://libvips.github.io/libvips/API/current/VipsForeignSave.html#vips-gifsave-buffer
 int set_gifsave_options(VipsOperation *operation, SaveParams *params) {
   int ret = 0;
@@ -207,6 +221,8 @@ int save_to_buffer(SaveParams *params) {
       return save_buffer("heifsave_buffer", params, set_avifsave_options);
     case JP2K:
       return save_buffer("jp2ksave_buffer", params, set_jp2ksave_options);
+    case PDF:
+      return save_buffer("magicksave_buffer", params, set_pdfsave_options);
     default:
       g_warning("Unsupported output type given: %d", params->outputFormat);
   }
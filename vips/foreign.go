@@ -158,6 +158,19 @@ func NewJp2kExportParams() *Jp2kExportParams {
 	}
 }
 
+// PdfExportParams are options when exporting a PDF to buffer.
+type PdfExportParams struct {
+	StripMetadata bool
+	Quality       int
+}
+
+// NewPdfExportParams creates default values for an export of a PDF document.
+func NewPdfExportParams() *PdfExportParams {
+	return &PdfExportParams{
+		Quality: 80,
+	}
+}
+
 func vipsSaveJPEGToBuffer(in *C.VipsImage, params JpegExportParams) ([]byte, error) {
 	p := C.create_save_params(C.JPEG)
 	p.inputImage = in
@@ -263,6 +276,15 @@ func vipsSaveGIFToBuffer(in *C.VipsImage, params GifExportParams) ([]byte, error
 	return vipsSaveToBuffer(p)
 }
 
+func vipsSavePDFToBuffer(in *C.VipsImage, params PdfExportParams) ([]byte, error) {
+	p := C.create_save_params(C.PDF)
+	p.inputImage = in
+	p.quality = C.int(params.Quality)
+	p.stripMetadata = C.int(boolToInt(params.StripMetadata))
+
+	return vipsSaveToBuffer(p)
+}
+
 func vipsSaveToBuffer(params C.struct_SaveParams) ([]byte, error) {
 	if err := C.save_to_buffer(&params); err != 0 {
 		if params.outputBuffer != nil {
@@ -0,0 +1,106 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidICO = errors.New("invalid ico")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// loadImageFromICO loads the highest resolution image of ICO directory.
+// PNG encoded image is loaded directly, BMP encoded image is decoded with Go BMP decoder
+func loadImageFromICO(buf []byte, params *ImportParams) (*Image, error) {
+	data, err := extractICOImage(buf)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, pngSignature) {
+		return LoadImageFromBuffer(data, params)
+	}
+	bmp, err := icoBMPToFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return loadImageFromBMP(bytes.NewReader(bmp))
+}
+
+// extractICOImage returns image data of the largest ICO directory entry
+func extractICOImage(buf []byte) ([]byte, error) {
+	if len(buf) < 6 {
+		return nil, errInvalidICO
+	}
+	count := int(binary.LittleEndian.Uint16(buf[4:6]))
+	if count == 0 || len(buf) < 6+count*16 {
+		return nil, errInvalidICO
+	}
+	var (
+		maxArea, maxBPP int
+		data            []byte
+	)
+	for i := 0; i < count; i++ {
+		entry := buf[6+i*16 : 6+i*16+16]
+		w, h := int(entry[0]), int(entry[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		bpp := int(binary.LittleEndian.Uint16(entry[6:8]))
+		size := int64(binary.LittleEndian.Uint32(entry[8:12]))
+		offset := int64(binary.LittleEndian.Uint32(entry[12:16]))
+		if size == 0 || offset+size > int64(len(buf)) {
+			continue
+		}
+		if area := w * h; area > maxArea || (area == maxArea && bpp > maxBPP) {
+			maxArea = area
+			maxBPP = bpp
+			data = buf[offset : offset+size]
+		}
+	}
+	if data == nil {
+		return nil, errInvalidICO
+	}
+	return data, nil
+}
+
+// icoBMPToFile converts ICO BMP data into BMP file,
+// by halving the height that includes AND mask and prepending file header
+func icoBMPToFile(data []byte) ([]byte, error) {
+	if len(data) < 40 {
+		return nil, errInvalidICO
+	}
+	infoLen := binary.LittleEndian.Uint32(data[0:4])
+	if infoLen < 40 || int(infoLen) > len(data) {
+		return nil, errInvalidICO
+	}
+	header := append([]byte{}, data[:infoLen]...)
+	pixels := data[infoLen:]
+	height := int32(binary.LittleEndian.Uint32(header[8:12])) / 2
+	binary.LittleEndian.PutUint32(header[8:12], uint32(height))
+	bpp := binary.LittleEndian.Uint16(header[14:16])
+	if bpp == 32 && infoLen == 40 {
+		// extend to V4 header so that the alpha channel is respected
+		infoLen = 108
+		binary.LittleEndian.PutUint32(header[0:4], infoLen)
+		header = append(header, make([]byte, infoLen-40)...)
+	}
+	var paletteLen uint32
+	if bpp <= 8 {
+		colors := binary.LittleEndian.Uint32(header[32:36])
+		if colors == 0 {
+			colors = 1 << bpp
+		}
+		paletteLen = colors * 4
+	}
+	offset := 14 + infoLen + paletteLen
+	file := make([]byte, 14, 14+len(header)+len(pixels))
+	copy(file, "BM")
+	binary.LittleEndian.PutUint32(file[2:6], uint32(14+len(header)+len(pixels)))
+	binary.LittleEndian.PutUint32(file[10:14], offset)
+	file = append(file, header...)
+	return append(file, pixels...), nil
+}
@@ -6,6 +6,7 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -82,6 +83,9 @@ type ImportParams struct {
 	JpegShrinkFactor IntParam
 	HeifThumbnail    BoolParam
 	SvgUnlimited     BoolParam
+
+	// NoRotate disables thumbnail autorotate and removes orientation of loaded image
+	NoRotate BoolParam
 }
 
 // NewImportParams creates default ImportParams
@@ -134,6 +138,13 @@ func LoadImageFromSource(s *Source, params *ImportParams) (*Image, error) {
 
 	ref := newImageRef(vipsImage, format, nil)
 	log("vips", LogLevelDebug, fmt.Sprintf("created imageRef %p", ref))
+	if params.NoRotate.IsSet() && params.NoRotate.Get() {
+		// orientation no longer applies to subsequent thumbnail
+		if err = ref.RemoveOrientation(); err != nil {
+			ref.Close()
+			return nil, err
+		}
+	}
 	return ref, nil
 }
 
@@ -151,6 +162,13 @@ func LoadThumbnailFromSource(s *Source, width, height int, crop Interesting, siz
 
 	ref := newImageRef(vipsImage, format, nil)
 	log("vips", LogLevelDebug, fmt.Sprintf("created imageRef %p", ref))
+	if params.NoRotate.IsSet() && params.NoRotate.Get() {
+		// orientation no longer applies to subsequent thumbnail
+		if err = ref.RemoveOrientation(); err != nil {
+			ref.Close()
+			return nil, err
+		}
+	}
 	return ref, nil
 }
 
@@ -302,6 +320,29 @@ func (r *Image) SetPageDelay(delay []int) error {
 	return vipsImageSetDelay(r.image, data)
 }
 
+// PageDelay get the page delay array for animation
+func (r *Image) PageDelay() []int {
+	return vipsImageGetDelay(r.image)
+}
+
+// SetLoop set the number of times animation plays, 0 for infinite
+func (r *Image) SetLoop(loop int) error {
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+
+	vipsImageSetLoop(out, loop)
+
+	r.setImage(out)
+	return nil
+}
+
+// Loop get the number of times animation plays, 0 for infinite
+func (r *Image) Loop() int {
+	return vipsImageGetLoop(r.image)
+}
+
 // Exif extracts Exif key value data
 func (r *Image) Exif() map[string]any {
 	return vipsImageGetExif(r.image)
@@ -419,6 +460,20 @@ func (r *Image) ExportJp2k(params *Jp2kExportParams) ([]byte, error) {
 	return buf, nil
 }
 
+// ExportPdf exports the image as PDF to a buffer.
+func (r *Image) ExportPdf(params *PdfExportParams) ([]byte, error) {
+	if params == nil {
+		params = NewPdfExportParams()
+	}
+
+	buf, err := vipsSavePDFToBuffer(r.image, *params)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
 // Composite composites the given overlay image on top of the associated image with provided blending mode.
 func (r *Image) Composite(overlay *Image, mode BlendMode, x, y int) error {
 	out, err := vipsComposite2(r.image, overlay.image, mode, x, y)
@@ -458,6 +513,19 @@ func (r *Image) Linear(a, b []float64) error {
 	return nil
 }
 
+// Recomb recombines color bands of the image with n x n matrix in row-major order, preserving alpha
+func (r *Image) Recomb(matrix []float64, n int) error {
+	if n <= 0 || len(matrix) != n*n {
+		return errors.New("matrix must be of n x n length")
+	}
+	out, err := vipsRecomb(r.image, matrix, n)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // ExtractArea crops the image to a specified area
 func (r *Image) ExtractArea(left, top, width, height int) error {
 	if r.Height() > r.PageHeight() {
@@ -489,6 +557,37 @@ func (r *Image) RemoveICCProfile() error {
 	return nil
 }
 
+// ICCProfile returns the embedded ICC Profile of the image, nil if none
+func (r *Image) ICCProfile() []byte {
+	return vipsGetICCProfile(r.image)
+}
+
+// SetICCProfileSRGB embeds the built-in sRGB ICC Profile to the image,
+// without transforming the pixels
+func (r *Image) SetICCProfileSRGB() error {
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+	if err = vipsSetICCProfileSRGB(out); err != nil {
+		clearImage(out)
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// RemoveOrientation removes the orientation metadata so that the image is not auto rotated
+func (r *Image) RemoveOrientation() error {
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+	vipsRemoveOrientation(out)
+	r.setImage(out)
+	return nil
+}
+
 // RemoveExif removes Exif metadata from the image.
 func (r *Image) RemoveExif() error {
 	out, err := vipsRemoveExif(r.image)
@@ -534,26 +633,47 @@ func (r *Image) Label(
 	return nil
 }
 
-// GaussianBlur blurs the image
+// GaussianBlur blurs the image, per frame if animated
 func (r *Image) GaussianBlur(sigma float64) error {
-	out, err := vipsGaussianBlur(r.image, sigma)
-	if err != nil {
-		return err
+	return r.GaussianBlurWithMinAmpl(sigma, 0.2)
+}
+
+// GaussianBlurWithMinAmpl blurs the image with minimum amplitude of the gaussian mask, per frame if animated
+func (r *Image) GaussianBlurWithMinAmpl(sigma, minAmpl float64) error {
+	if r.Height() > r.PageHeight() {
+		out, err := vipsGaussBlurMultiPage(r.image, sigma, minAmpl)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
+	} else {
+		out, err := vipsGaussBlur(r.image, sigma, minAmpl)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
 	}
-	r.setImage(out)
 	return nil
 }
 
-// Sharpen sharpens the image
+// Sharpen sharpens the image, per frame if animated
 // sigma: sigma of the gaussian
 // x1: flat/jaggy threshold
 // m2: slope for jaggy areas
 func (r *Image) Sharpen(sigma float64, x1 float64, m2 float64) error {
-	out, err := vipsSharpen(r.image, sigma, x1, m2)
-	if err != nil {
-		return err
+	if r.Height() > r.PageHeight() {
+		out, err := vipsSharpenMultiPage(r.image, sigma, x1, m2)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
+	} else {
+		out, err := vipsSharpen(r.image, sigma, x1, m2)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
 	}
-	r.setImage(out)
 	return nil
 }
 
@@ -594,6 +714,24 @@ func (r *Image) Modulate(brightness, saturation, hue float64) error {
 	return nil
 }
 
+// ReplaceColor shifts hue of pixels within hue tolerance of fromHue to toHue, in LCh color space.
+// Pixels with chroma below minChroma are left untouched
+func (r *Image) ReplaceColor(fromHue, toHue, tolerance, minChroma float64) error {
+	colorspace := r.ColorSpace()
+	if colorspace == InterpretationRGB {
+		colorspace = InterpretationSRGB
+	}
+	if err := r.ToColorSpace(InterpretationLCH); err != nil {
+		return err
+	}
+	out, err := vipsReplaceColor(r.image, fromHue, toHue, tolerance, minChroma)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return r.ToColorSpace(colorspace)
+}
+
 // FindTrim returns the bounding box of the non-border part of the image
 // Returned values are left, top, width, height
 func (r *Image) FindTrim(threshold float64, x, y int) (int, int, int, int, error) {
@@ -610,6 +748,11 @@ func (r *Image) GetPoint(x int, y int) ([]float64, error) {
 	return vipsGetPoint(r.image, n, x, y)
 }
 
+// RGBAPixels returns the image as 8-bit sRGB RGBA pixels in row-major order.
+func (r *Image) RGBAPixels() ([]byte, error) {
+	return vipsRGBAPixels(r.image)
+}
+
 // Thumbnail resizes the image to the given width and height.
 // crop decides algorithm vips uses to shrink and crop to fill target,
 func (r *Image) Thumbnail(width, height int, crop Interesting) error {
@@ -628,6 +771,30 @@ func (r *Image) ThumbnailWithSize(width, height int, crop Interesting, size Size
 	return nil
 }
 
+// Resize resizes the image by horizontal and vertical scale factors using the given kernel
+func (r *Image) Resize(scale, vscale float64, kernel Kernel) error {
+	if r.Height() > r.PageHeight() {
+		// multi-page: scale per page height to keep frames aligned
+		pageHeight := int(math.Round(float64(r.PageHeight()) * vscale))
+		if pageHeight < 1 {
+			pageHeight = 1
+		}
+		n := r.Height() / r.PageHeight()
+		out, err := vipsResize(r.image, scale, float64(pageHeight*n)/float64(r.Height()), kernel)
+		if err != nil {
+			return err
+		}
+		r.setImage(out)
+		return r.SetPageHeight(pageHeight)
+	}
+	out, err := vipsResize(r.image, scale, vscale, kernel)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Embed embeds the given picture in a new one, i.e. the opposite of ExtractArea
 func (r *Image) Embed(left, top, width, height int, extend ExtendStrategy) error {
 	if r.Height() > r.PageHeight() {
@@ -712,6 +879,29 @@ func (r *Image) Replicate(across int, down int) error {
 	return nil
 }
 
+// Sprite lays out frames of given page indexes into a single page grid of cols x rows
+func (r *Image) Sprite(frames []int, cols, rows int) error {
+	if len(frames) == 0 {
+		return nil
+	}
+	out, err := vipsSprite(r.image, frames, cols, rows)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return r.SetPageHeight(r.Height())
+}
+
+// ExtractBand extracts num bands starting from band index
+func (r *Image) ExtractBand(band int, num int) error {
+	out, err := vipsExtractBand(r.image, band, num)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // setImage resets the image for this image and frees the previous one
 func (r *Image) setImage(image *C.VipsImage) {
 	r.lock.Lock()
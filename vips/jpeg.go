@@ -0,0 +1,113 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+var errJPEGStripNotSupported = errors.New("jpeg strip not supported")
+
+var (
+	jpegICCPrefix  = []byte("ICC_PROFILE\x00")
+	jpegJFIFPrefix = []byte("JFIF\x00")
+	jpegAdobe      = []byte("Adobe")
+)
+
+// jpegStripOptions metadata to be stripped losslessly
+type jpegStripOptions struct {
+	Exif     bool
+	ICC      bool
+	Metadata bool
+}
+
+// jpegStripParams returns strip options if metadata stripping is the only operation of params
+func (v *Processor) jpegStripParams(blob *imagor.Blob, p imagorpath.Params) (opts jpegStripOptions, ok bool) {
+	if blob == nil || blob.BlobType() != imagor.BlobTypeJPEG || p.Meta || p.Trim ||
+		p.Width != 0 || p.Height != 0 || p.HFlip || p.VFlip ||
+		p.CropLeft != 0 || p.CropTop != 0 || p.CropRight != 0 || p.CropBottom != 0 {
+		return
+	}
+	opts.Metadata = v.StripMetadata
+	for _, f := range p.Filters {
+		if v.disableFilters[f.Name] {
+			continue
+		}
+		switch f.Name {
+		case "strip_exif":
+			opts.Exif = true
+		case "strip_icc":
+			opts.ICC = true
+		case "strip_metadata":
+			opts.Metadata = !isKeepArg(f.Args)
+		default:
+			return
+		}
+	}
+	if !opts.Exif && !opts.ICC && !opts.Metadata {
+		return
+	}
+	meta, err := blob.Metadata()
+	if err != nil || meta.Orientation > 1 ||
+		meta.Width > v.MaxWidth || meta.Height > v.MaxHeight ||
+		meta.Width*meta.Height > v.MaxResolution {
+		// orientation requires rotation, or let vips handle the error
+		return
+	}
+	return opts, true
+}
+
+// stripJPEG removes metadata segments from JPEG without recompressing.
+// strip exif removes all metadata except ICC profile, consistent with vips
+func stripJPEG(buf []byte, opts jpegStripOptions) ([]byte, error) {
+	if len(buf) < 4 || buf[0] != 0xFF || buf[1] != 0xD8 {
+		return nil, errJPEGStripNotSupported
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	out.Write(buf[:2])
+	i := 2
+	for {
+		if i+4 > len(buf) || buf[i] != 0xFF {
+			return nil, errJPEGStripNotSupported
+		}
+		marker := buf[i+1]
+		if marker == 0xFF {
+			// fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA {
+			// start of scan, entropy coded data onwards copied as is
+			out.Write(buf[i:])
+			return out.Bytes(), nil
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(buf[i+2:i+4]))
+		if end > len(buf) || end < i+4 {
+			return nil, errJPEGStripNotSupported
+		}
+		segment := buf[i:end]
+		data := segment[4:]
+		var drop bool
+		switch {
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			// start of frame, CMYK or unusual components requires color conversion
+			if len(data) < 6 || (data[5] != 1 && data[5] != 3) {
+				return nil, errJPEGStripNotSupported
+			}
+		case marker == 0xE0 && bytes.HasPrefix(data, jpegJFIFPrefix),
+			marker == 0xEE && bytes.HasPrefix(data, jpegAdobe):
+			// required for decoding
+		case marker == 0xE2 && bytes.HasPrefix(data, jpegICCPrefix):
+			drop = opts.ICC || opts.Metadata
+		case marker >= 0xE0 && marker <= 0xEF, marker == 0xFE:
+			drop = opts.Exif || opts.Metadata
+		}
+		if !drop {
+			out.Write(segment)
+		}
+		i = end
+	}
+}
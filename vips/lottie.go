@@ -0,0 +1,404 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errInvalidLottie = errors.New("invalid lottie")
+
+// lottie animation subset required for rendering a single frame
+type lottie struct {
+	Version string        `json:"v"`
+	Width   float64       `json:"w"`
+	Height  float64       `json:"h"`
+	InPoint float64       `json:"ip"`
+	Layers  []lottieLayer `json:"layers"`
+}
+
+type lottieLayer struct {
+	Type        int             `json:"ty"`
+	Index       *int            `json:"ind"`
+	Parent      *int            `json:"parent"`
+	Hidden      bool            `json:"hd"`
+	InPoint     float64         `json:"ip"`
+	OutPoint    float64         `json:"op"`
+	StartTime   float64         `json:"st"`
+	Transform   lottieTransform `json:"ks"`
+	Shapes      []lottieShape   `json:"shapes"`
+	SolidColor  string          `json:"sc"`
+	SolidWidth  float64         `json:"sw"`
+	SolidHeight float64         `json:"sh"`
+}
+
+type lottieTransform struct {
+	Anchor   *lottieValue    `json:"a"`
+	Position *lottiePosition `json:"p"`
+	Scale    *lottieValue    `json:"s"`
+	Rotation *lottieValue    `json:"r"`
+	Opacity  *lottieValue    `json:"o"`
+}
+
+// lottiePosition position value, which may be split into separate x y values
+type lottiePosition struct {
+	lottieValue
+	Split bool         `json:"s"`
+	X     *lottieValue `json:"x"`
+	Y     *lottieValue `json:"y"`
+}
+
+type lottieShape struct {
+	lottieTransform
+	Type      string        `json:"ty"`
+	Hidden    bool          `json:"hd"`
+	Items     []lottieShape `json:"it"`
+	Size      *lottieValue  `json:"s"`
+	Roundness *lottieValue  `json:"r"`
+	Color     *lottieValue  `json:"c"`
+	Width     *lottieValue  `json:"w"`
+	Path      *lottieValue  `json:"ks"`
+}
+
+// lottieValue static or keyframed property value
+type lottieValue struct {
+	Animated int             `json:"a"`
+	K        json.RawMessage `json:"k"`
+}
+
+type lottieKeyframe struct {
+	Time  float64         `json:"t"`
+	Start json.RawMessage `json:"s"`
+	End   json.RawMessage `json:"e"`
+	Hold  int             `json:"h"`
+}
+
+type lottieBezier struct {
+	Closed   bool        `json:"c"`
+	Vertices [][]float64 `json:"v"`
+	In       [][]float64 `json:"i"`
+	Out      [][]float64 `json:"o"`
+}
+
+// isLottie checks if JSON buffer matches the Lottie schema
+func isLottie(buf []byte) bool {
+	var l struct {
+		Version string            `json:"v"`
+		Width   float64           `json:"w"`
+		Height  float64           `json:"h"`
+		Layers  []json.RawMessage `json:"layers"`
+	}
+	if err := json.Unmarshal(buf, &l); err != nil {
+		return false
+	}
+	return l.Version != "" && l.Width > 0 && l.Height > 0 && l.Layers != nil
+}
+
+// lottieToSVG renders Lottie animation frame to SVG.
+// Supports solid and shape layers with rect, ellipse, path, fill and stroke,
+// keyframes are interpolated linearly
+func lottieToSVG(buf []byte, frame float64, hasFrame bool) ([]byte, error) {
+	var l lottie
+	if err := json.Unmarshal(buf, &l); err != nil {
+		return nil, err
+	}
+	if l.Width <= 0 || l.Height <= 0 {
+		return nil, errInvalidLottie
+	}
+	if !hasFrame {
+		frame = l.InPoint
+	}
+	var layers = map[int]*lottieLayer{}
+	for i := range l.Layers {
+		if ind := l.Layers[i].Index; ind != nil {
+			layers[*ind] = &l.Layers[i]
+		}
+	}
+	var w bytes.Buffer
+	_, _ = fmt.Fprintf(&w,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`,
+		num(l.Width), num(l.Height), num(l.Width), num(l.Height))
+	// first layer is the top most
+	for i := len(l.Layers) - 1; i >= 0; i-- {
+		layer := &l.Layers[i]
+		if layer.Hidden || frame < layer.InPoint || frame >= layer.OutPoint {
+			continue
+		}
+		if layer.Type != 1 && layer.Type != 4 {
+			// only solid and shape layers supported
+			continue
+		}
+		t := frame - layer.StartTime
+		_, _ = fmt.Fprintf(&w, `<g transform="%s" opacity="%s">`,
+			layerTransform(layer, layers, t, 0), num(layer.Transform.opacity(t)))
+		if layer.Type == 1 {
+			if isHexColor(layer.SolidColor) {
+				_, _ = fmt.Fprintf(&w, `<rect width="%s" height="%s" fill="%s"/>`,
+					num(layer.SolidWidth), num(layer.SolidHeight), layer.SolidColor)
+			}
+		} else {
+			writeLottieShapes(&w, layer.Shapes, t, nil, nil)
+		}
+		w.WriteString(`</g>`)
+	}
+	w.WriteString(`</svg>`)
+	return w.Bytes(), nil
+}
+
+// isHexColor checks if color is in #rgb or #rrggbb form,
+// so that solid color is not injected as arbitrary SVG markup
+func isHexColor(color string) bool {
+	if (len(color) != 4 && len(color) != 7) || color[0] != '#' {
+		return false
+	}
+	for _, c := range color[1:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// layerTransform returns SVG transform of layer including parent layers
+func layerTransform(layer *lottieLayer, layers map[int]*lottieLayer, t float64, depth int) string {
+	transform := layer.Transform.svg(t)
+	if layer.Parent != nil && depth < 32 {
+		if parent, ok := layers[*layer.Parent]; ok {
+			return layerTransform(parent, layers, t, depth+1) + " " + transform
+		}
+	}
+	return transform
+}
+
+func writeLottieShapes(w *bytes.Buffer, shapes []lottieShape, t float64, fill, stroke *lottieShape) {
+	// fill and stroke apply to all shapes of the group including nested groups
+	for i := range shapes {
+		switch s := &shapes[i]; s.Type {
+		case "fl":
+			if !s.Hidden {
+				fill = s
+			}
+		case "st":
+			if !s.Hidden {
+				stroke = s
+			}
+		}
+	}
+	style := lottieStyle(fill, stroke, t)
+	for i := range shapes {
+		s := &shapes[i]
+		if s.Hidden || (s.Type != "gr" && fill == nil && stroke == nil) {
+			continue
+		}
+		switch s.Type {
+		case "gr":
+			var transform = lottieTransform{}
+			var items []lottieShape
+			for _, item := range s.Items {
+				if item.Type == "tr" {
+					transform = item.lottieTransform
+				} else {
+					items = append(items, item)
+				}
+			}
+			_, _ = fmt.Fprintf(w, `<g transform="%s" opacity="%s">`,
+				transform.svg(t), num(transform.opacity(t)))
+			writeLottieShapes(w, items, t, fill, stroke)
+			w.WriteString(`</g>`)
+		case "rc":
+			p := s.Position.at(t, 2)
+			size := s.Size.at(t, 2)
+			r := s.Roundness.at(t, 1)
+			_, _ = fmt.Fprintf(w, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" %s/>`,
+				num(p[0]-size[0]/2), num(p[1]-size[1]/2), num(size[0]), num(size[1]), num(r[0]), style)
+		case "el":
+			p := s.Position.at(t, 2)
+			size := s.Size.at(t, 2)
+			_, _ = fmt.Fprintf(w, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" %s/>`,
+				num(p[0]), num(p[1]), num(size[0]/2), num(size[1]/2), style)
+		case "sh":
+			if d := s.Path.bezier(t).svg(); d != "" {
+				_, _ = fmt.Fprintf(w, `<path d="%s" %s/>`, d, style)
+			}
+		}
+	}
+}
+
+func lottieStyle(fill, stroke *lottieShape, t float64) string {
+	var style = `fill="none"`
+	if fill != nil {
+		style = fmt.Sprintf(`fill="%s" fill-opacity="%s"`,
+			lottieColor(fill.Color.at(t, 3)), num(fill.opacity(t)))
+	}
+	if stroke != nil {
+		style += fmt.Sprintf(` stroke="%s" stroke-opacity="%s" stroke-width="%s"`,
+			lottieColor(stroke.Color.at(t, 3)), num(stroke.opacity(t)), num(stroke.Width.at(t, 1)[0]))
+	}
+	return style
+}
+
+func lottieColor(c []float64) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)",
+		uint8(math.Round(clamp01(c[0])*255)),
+		uint8(math.Round(clamp01(c[1])*255)),
+		uint8(math.Round(clamp01(c[2])*255)))
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// svg returns SVG transform of anchor, position, scale and rotation
+func (tr *lottieTransform) svg(t float64) string {
+	p := tr.Position.at(t, 2)
+	a := tr.Anchor.at(t, 2)
+	s := []float64{100, 100}
+	if tr.Scale != nil {
+		s = tr.Scale.at(t, 2)
+	}
+	r := tr.Rotation.at(t, 1)
+	return fmt.Sprintf("translate(%s %s) rotate(%s) scale(%s %s) translate(%s %s)",
+		num(p[0]), num(p[1]), num(r[0]), num(s[0]/100), num(s[1]/100), num(-a[0]), num(-a[1]))
+}
+
+// opacity returns transform opacity between 0 and 1
+func (tr *lottieTransform) opacity(t float64) float64 {
+	if tr.Opacity == nil {
+		return 1
+	}
+	return clamp01(tr.Opacity.at(t, 1)[0] / 100)
+}
+
+func (p *lottiePosition) at(t float64, n int) []float64 {
+	if p == nil {
+		return make([]float64, n)
+	}
+	if p.Split {
+		return []float64{p.X.at(t, 1)[0], p.Y.at(t, 1)[0]}
+	}
+	return p.lottieValue.at(t, n)
+}
+
+// at returns property value at time t, padded to n dimensions
+func (v *lottieValue) at(t float64, n int) []float64 {
+	var res []float64
+	if v != nil && len(v.K) > 0 {
+		res = v.eval(t)
+	}
+	for len(res) < n {
+		res = append(res, 0)
+	}
+	return res
+}
+
+func (v *lottieValue) eval(t float64) []float64 {
+	var f float64
+	if err := json.Unmarshal(v.K, &f); err == nil {
+		return []float64{f}
+	}
+	var arr []float64
+	if err := json.Unmarshal(v.K, &arr); err == nil {
+		return arr
+	}
+	var keyframes []lottieKeyframe
+	if err := json.Unmarshal(v.K, &keyframes); err != nil || len(keyframes) == 0 {
+		return nil
+	}
+	start, end, ratio := interpolateKeyframes(keyframes, t)
+	var s, e []float64
+	_ = json.Unmarshal(start, &s)
+	_ = json.Unmarshal(end, &e)
+	if len(e) != len(s) {
+		return s
+	}
+	res := make([]float64, len(s))
+	for i := range s {
+		res[i] = s[i] + (e[i]-s[i])*ratio
+	}
+	return res
+}
+
+// bezier returns path value at time t, keyframed path holds value without interpolation
+func (v *lottieValue) bezier(t float64) (b lottieBezier) {
+	if v == nil || len(v.K) == 0 {
+		return
+	}
+	if err := json.Unmarshal(v.K, &b); err == nil {
+		return
+	}
+	var keyframes []lottieKeyframe
+	if err := json.Unmarshal(v.K, &keyframes); err != nil || len(keyframes) == 0 {
+		return
+	}
+	start, _, _ := interpolateKeyframes(keyframes, t)
+	var paths []lottieBezier
+	if err := json.Unmarshal(start, &paths); err == nil && len(paths) > 0 {
+		return paths[0]
+	}
+	return
+}
+
+// interpolateKeyframes returns start and end values and ratio between keyframes at time t
+func interpolateKeyframes(keyframes []lottieKeyframe, t float64) (start, end json.RawMessage, ratio float64) {
+	i := 0
+	for i < len(keyframes)-1 && keyframes[i+1].Time <= t {
+		i++
+	}
+	kf := keyframes[i]
+	start = kf.Start
+	if len(start) == 0 && i > 0 {
+		// last keyframe may only contain time, ends with previous value
+		if start = keyframes[i-1].End; len(start) == 0 {
+			start = keyframes[i-1].Start
+		}
+	}
+	if i == len(keyframes)-1 || kf.Hold == 1 || t <= kf.Time {
+		return start, start, 0
+	}
+	next := keyframes[i+1]
+	end = kf.End
+	if len(end) == 0 {
+		end = next.Start
+	}
+	if next.Time > kf.Time {
+		ratio = (t - kf.Time) / (next.Time - kf.Time)
+	}
+	return start, end, ratio
+}
+
+func (b lottieBezier) svg() string {
+	n := len(b.Vertices)
+	if n == 0 || len(b.In) != n || len(b.Out) != n {
+		return ""
+	}
+	for i := 0; i < n; i++ {
+		if len(b.Vertices[i]) < 2 || len(b.In[i]) < 2 || len(b.Out[i]) < 2 {
+			return ""
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("M%s %s", num(b.Vertices[0][0]), num(b.Vertices[0][1])))
+	segment := func(from, to int) {
+		sb.WriteString(fmt.Sprintf(" C%s %s %s %s %s %s",
+			num(b.Vertices[from][0]+b.Out[from][0]), num(b.Vertices[from][1]+b.Out[from][1]),
+			num(b.Vertices[to][0]+b.In[to][0]), num(b.Vertices[to][1]+b.In[to][1]),
+			num(b.Vertices[to][0]), num(b.Vertices[to][1])))
+	}
+	for i := 1; i < n; i++ {
+		segment(i-1, i)
+	}
+	if b.Closed {
+		segment(n-1, 0)
+		sb.WriteString(" Z")
+	}
+	return sb.String()
+}
+
+func num(f float64) string {
+	// adding zero normalizes negative zero
+	return strconv.FormatFloat(f+0, 'f', -1, 64)
+}
@@ -60,6 +60,24 @@ func WithAvifSpeed(avifSpeed int) Option {
 	}
 }
 
+// WithMaxAnimationFramesStrict with option to reject animated image
+// exceeding maximum count of animation frames, instead of truncating frames
+func WithMaxAnimationFramesStrict(strict bool) Option {
+	return func(v *Processor) {
+		v.MaxAnimationFramesStrict = strict
+	}
+}
+
+// WithMaxDPI with maximum dpi option for rasterizing PDF and SVG,
+// dpi filter exceeding the maximum is capped
+func WithMaxDPI(dpi int) Option {
+	return func(v *Processor) {
+		if dpi != 0 {
+			v.MaxDPI = dpi
+		}
+	}
+}
+
 // WithMaxFilterOps with maximum number of filter operations option
 func WithMaxFilterOps(num int) Option {
 	return func(v *Processor) {
@@ -24,6 +24,8 @@ func TestWithOption(t *testing.T) {
 			WithStripMetadata(true),
 			WithDebug(true),
 			WithMaxAnimationFrames(3),
+			WithMaxAnimationFramesStrict(true),
+			WithMaxDPI(300),
 			WithDisableFilters("rgb", "fill, watermark"),
 			WithFilter("noop", func(ctx context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
 				return nil
@@ -38,6 +40,8 @@ func TestWithOption(t *testing.T) {
 		assert.Equal(t, 998, v.MaxHeight)
 		assert.Equal(t, 1666667, v.MaxResolution)
 		assert.Equal(t, 3, v.MaxAnimationFrames)
+		assert.True(t, v.MaxAnimationFramesStrict)
+		assert.Equal(t, 300, v.MaxDPI)
 		assert.Equal(t, true, v.MozJPEG)
 		assert.Equal(t, true, v.StripMetadata)
 		assert.Equal(t, 9, v.AvifSpeed)
@@ -49,6 +53,7 @@ func TestWithOption(t *testing.T) {
 			WithConcurrency(-1),
 		)
 		assert.Equal(t, runtime.NumCPU(), v.Concurrency)
+		assert.Equal(t, 1200, v.MaxDPI)
 	})
 }
 
@@ -2,7 +2,9 @@ package vips
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -36,11 +38,24 @@ func (v *Processor) Process(
 	defer contextDone(ctx)
 	var (
 		thumbnailNotSupported bool
+		hasMaxDistortion      bool
+		hasSprite             bool
+		hasStill              bool
+		pdfOutput             bool
+		hasPlaceholder        bool
+		hasDominantColor      bool
+		hasThumbHash          bool
+		placeholderMode       string
+		hasLottie             bool
+		lottieFrame           float64
+		hasLottieFrame        bool
+		maxFrames             int
 		upscale               = true
 		stretch               = p.Stretch
 		thumbnail             = false
 		stripExif             bool
 		stripMetadata         = v.StripMetadata
+		keepMetadata          bool
 		orient                int
 		img                   *Image
 		format                = ImageTypeUnknown
@@ -51,6 +66,17 @@ func (v *Processor) Process(
 		focalRects            []focal
 		err                   error
 	)
+	if opts, ok := v.jpegStripParams(blob, p); ok {
+		// lossless fast path if metadata stripping is the only operation
+		if buf, err := blob.ReadAll(); err == nil {
+			if buf, err = stripJPEG(buf, opts); err == nil {
+				if v.Debug {
+					v.Logger.Debug("jpeg lossless strip", zap.Int("bytes", len(buf)))
+				}
+				return imagor.NewBlobFromBytes(buf), nil
+			}
+		}
+	}
 	if p.Trim {
 		thumbnailNotSupported = true
 	}
@@ -71,6 +97,16 @@ func (v *Processor) Process(
 		case "format":
 			if imageType, ok := imageTypeMap[p.Args]; ok {
 				format = supportedSaveFormat(imageType)
+				if IsAnimationSupported(imageType) && !IsAnimationSupported(format) &&
+					blob != nil && blob.SupportsAnimation() && IsSaveSupported(ImageTypeGIF) {
+					// keep animation as gif if animated export format not supported e.g. auto webp
+					format = ImageTypeGIF
+				}
+				if imageType == ImageTypePDF && IsSaveSupported(ImageTypePDF) {
+					// explicit PDF output, PDF source is otherwise rasterized
+					format = ImageTypePDF
+					pdfOutput = true
+				}
 				if !IsAnimationSupported(format) {
 					// no frames if export format not support animation
 					maxN = 1
@@ -78,8 +114,13 @@ func (v *Processor) Process(
 			}
 			break
 		case "max_frames":
-			if n, _ := strconv.Atoi(p.Args); n > 0 && (maxN == -1 || n < maxN) {
-				maxN = n
+			if n, _ := strconv.Atoi(p.Args); n > 0 {
+				if maxN == -1 || n < maxN {
+					maxN = n
+				}
+				if maxFrames == 0 || n < maxFrames {
+					maxFrames = n
+				}
 			}
 			break
 		case "stretch":
@@ -101,17 +142,36 @@ func (v *Processor) Process(
 				page = n
 			}
 			break
+		case "frame":
+			if n, err := strconv.Atoi(strings.TrimSpace(p.Args)); err == nil {
+				page = max(n, 0) + 1
+				hasStill = true
+			}
+			break
+		case "still":
+			page = 1
+			hasStill = true
+			break
 		case "dpi":
 			if n, _ := strconv.Atoi(p.Args); n > 0 {
 				dpi = n
+				if v.MaxDPI > 0 && dpi > v.MaxDPI {
+					// avoid huge allocation rasterizing at absurd density
+					dpi = v.MaxDPI
+				}
 			}
 			break
 		case "orient":
 			if n, _ := strconv.Atoi(p.Args); n > 0 {
 				orient = n
 				thumbnailNotSupported = true
+			} else if strings.TrimSpace(p.Args) == "0" {
+				setNoRotate(ctx)
 			}
 			break
+		case "no_autorotate":
+			setNoRotate(ctx)
+			break
 		case "max_bytes":
 			if n, _ := strconv.Atoi(p.Args); n > 0 {
 				maxBytes = n
@@ -124,12 +184,125 @@ func (v *Processor) Process(
 		case "strip_exif":
 			stripExif = true
 		case "strip_metadata":
-			stripMetadata = true
+			// strip_metadata(0) keeps metadata overriding default
+			stripMetadata = !isKeepArg(p.Args)
+			keepMetadata = !stripMetadata
+			break
+		case "max_distortion":
+			if _, err := strconv.ParseFloat(p.Args, 64); err == nil {
+				hasMaxDistortion = true
+			}
 			break
+		case "sprite":
+			hasSprite = true
+			break
+		case "sprite_cell":
+			if _, _, _, err := parseSpriteCell(p.Args); err != nil {
+				return nil, err
+			}
+			// sprite cell extracted from full resolution image
+			thumbnailNotSupported = true
+			break
+		case "dztile":
+			if _, err := parseDZTile(p.Args); err != nil {
+				return nil, err
+			}
+			// deep zoom tile extracted from full resolution single frame
+			thumbnailNotSupported = true
+			hasStill = true
+			break
+		case "dominant_color":
+			hasDominantColor = true
+			break
+		case "thumbhash":
+			hasThumbHash = true
+			break
+		case "placeholder":
+			hasPlaceholder = true
+			placeholderMode = strings.ToLower(strings.TrimSpace(p.Args))
+			break
+		case "lottie":
+			hasLottie = true
+			if f, err := strconv.ParseFloat(p.Args, 64); err == nil {
+				lottieFrame = f
+				hasLottieFrame = true
+			}
+			break
+		case "kernel":
+			if kernel, ok := parseKernel(p.Args); ok && kernel != KernelAuto {
+				// thumbnail does not support resize kernel
+				thumbnailNotSupported = true
+			}
+			break
+		case "upscale_mode":
+			if strings.ToLower(p.Args) == "pixel" {
+				// nearest-neighbor upscale not supported by thumbnail
+				thumbnailNotSupported = true
+			}
+			break
+		}
+	}
+	if hasSprite && blob != nil && blob.SupportsAnimation() {
+		// sprite sheet is single frame, requires all frames regardless of export format
+		if maxN = v.MaxAnimationFrames; maxN == 0 || maxN < -1 {
+			maxN = 1
+		}
+		if maxFrames > 0 && (maxN == -1 || maxFrames < maxN) {
+			maxN = maxFrames
+		}
+	}
+	if hasStill {
+		// single frame import regardless of animation support
+		maxN = 1
+	}
+	if hasLottie && blob != nil && blob.BlobType() == imagor.BlobTypeJSON {
+		// render Lottie animation frame as SVG source
+		if buf, err := blob.ReadAll(); err == nil && isLottie(buf) {
+			if buf, err = lottieToSVG(buf, lottieFrame, hasLottieFrame); err != nil {
+				return nil, imagor.NewError(err.Error(), http.StatusUnprocessableEntity)
+			}
+			blob = imagor.NewBlobFromBytes(buf)
+			maxN = 1
+			if format == ImageTypeUnknown {
+				// preserve transparency instead of falling back to jpeg
+				format = supportedSaveFormat(ImageTypePNG)
+			}
 		}
 	}
+	if dpi > 0 && blob != nil && blob.BlobType() != imagor.BlobTypePDF && blob.BlobType() != imagor.BlobTypeSVG {
+		// density only applies to vector sources
+		dpi = 0
+	}
+	if stretch && hasMaxDistortion {
+		// distortion can only be determined from source dimensions
+		thumbnailNotSupported = true
+	}
 
-	if !thumbnailNotSupported &&
+	if hasDominantColor {
+		// color output without image processing
+		color, err := v.dominantColor(ctx, blob, page, dpi)
+		if err != nil {
+			return nil, err
+		}
+		return imagor.NewBlobFromJsonMarshal(color), nil
+	}
+	if hasThumbHash {
+		// placeholder hash output without image processing
+		hash, err := v.thumbHash(ctx, blob, page, dpi)
+		if err != nil {
+			return nil, err
+		}
+		return imagor.NewBlobFromJsonMarshal(hash), nil
+	}
+	if hasPlaceholder {
+		// short-circuit processing with placeholder from source colors
+		if img, err = v.newPlaceholder(
+			ctx, blob, p.Width, p.Height, placeholderMode == "gradient", page, dpi,
+		); err != nil {
+			return nil, err
+		}
+		thumbnail = true
+	} else if !thumbnailNotSupported &&
 		p.CropBottom == 0.0 && p.CropTop == 0.0 && p.CropLeft == 0.0 && p.CropRight == 0.0 {
 		// apply shrink-on-load where possible
 		if p.FitIn {
@@ -166,8 +339,8 @@ func (v *Processor) Process(
 		} else {
 			if p.Width > 0 && p.Height > 0 {
 				interest := InterestingNone
-				if p.Smart {
-					interest = InterestingAttention
+				if smart, ok := v.getSmartInterest(p); ok {
+					interest = smart
 					thumbnail = true
 				} else if (p.VAlign == imagorpath.VAlignTop && p.HAlign == "") ||
 					(p.HAlign == imagorpath.HAlignLeft && p.VAlign == "") {
@@ -233,12 +406,16 @@ func (v *Processor) Process(
 		}
 	}
 	var (
-		quality     int
-		bitdepth    int
-		compression int
-		palette     bool
-		origWidth   = float64(img.Width())
-		origHeight  = float64(img.PageHeight())
+		quality      int
+		bitdepth     int
+		compression  int
+		evenMultiple int
+		encode       = encodeOptions{WebpEffort: -1, AvifSpeed: -1, PngCompression: -1, Loop: -1, KeepMetadata: keepMetadata}
+		background   string
+		bgForce      bool
+		palette      bool
+		origWidth    = float64(img.Width())
+		origHeight   = float64(img.PageHeight())
 	)
 	if format == ImageTypeUnknown {
 		if blob.BlobType() == imagor.BlobTypeAVIF {
@@ -287,10 +464,17 @@ func (v *Processor) Process(
 				f := focal{}
 				f.Left, _ = strconv.ParseFloat(args[0], 64)
 				f.Top, _ = strconv.ParseFloat(args[1], 64)
-				if f.Left < 1 && f.Top < 1 {
+				// decimal values are fractions of image dimensions, 1.0 denotes the far edge,
+				// otherwise pixels e.g. focal(0x1)
+				if f.Left <= 1 && strings.Contains(args[0], ".") {
 					f.Left *= origWidth
+				}
+				if f.Top <= 1 && strings.Contains(args[1], ".") {
 					f.Top *= origHeight
 				}
+				// clamp point within image bounds
+				f.Left = math.Max(0, math.Min(f.Left, origWidth-1))
+				f.Top = math.Max(0, math.Min(f.Top, origHeight-1))
 				f.Right = f.Left + 1
 				f.Bottom = f.Top + 1
 				focalRects = append(focalRects, f)
@@ -305,22 +489,77 @@ func (v *Processor) Process(
 		case "compression":
 			compression, _ = strconv.Atoi(p.Args)
 			break
+		case "webp_effort":
+			if n, err := strconv.Atoi(p.Args); err == nil && n >= 0 && n <= 6 {
+				encode.WebpEffort = n
+			}
+			break
+		case "avif_speed":
+			if n, err := strconv.Atoi(p.Args); err == nil && n >= 0 && n <= 9 {
+				encode.AvifSpeed = n
+			}
+			break
+		case "lossless":
+			encode.Lossless = p.Args == "" || p.Args == "1" || strings.EqualFold(p.Args, "true")
+			break
+		case "loop":
+			if n, err := strconv.Atoi(p.Args); err == nil && n >= 0 {
+				encode.Loop = n
+			}
+			break
+		case "strip_icc":
+			encode.StripICC = true
+			break
+		case "png_compression":
+			if n, err := strconv.Atoi(p.Args); err == nil && n >= 0 && n <= 9 {
+				encode.PngCompression = n
+			}
+			break
+		case "even":
+			evenMultiple = 2
+			if n, _ := strconv.Atoi(p.Args); n > 0 {
+				evenMultiple = n
+			}
+			break
+		case "background":
+			args := strings.Split(p.Args, ",")
+			background = strings.TrimSpace(args[0])
+			bgForce = len(args) > 1 && strings.TrimSpace(args[1]) == "force"
+			break
+		}
+	}
+	if !hasPlaceholder {
+		if err := v.process(ctx, img, p, load, thumbnail, stretch, upscale, focalRects); err != nil {
+			return nil, WrapErr(err)
 		}
 	}
-	if err := v.process(ctx, img, p, load, thumbnail, stretch, upscale, focalRects); err != nil {
-		return nil, WrapErr(err)
+	if evenMultiple > 1 {
+		// final sizing step after all resize, padding and filters
+		if err := roundDimensions(img, evenMultiple); err != nil {
+			return nil, WrapErr(err)
+		}
+	}
+	if background != "" && img.HasAlpha() &&
+		(bgForce || supportedSaveFormat(format) == ImageTypeJPEG) {
+		// flatten alpha before export to format without alpha
+		if err := img.Flatten(getColor(img, background)); err != nil {
+			return nil, WrapErr(err)
+		}
 	}
 	if p.Meta {
 		// metadata without export
 		return imagor.NewBlobFromJsonMarshal(metadata(img, format, stripExif)), nil
 	}
-	format = supportedSaveFormat(format) // convert to supported export format
+	if !pdfOutput {
+		format = supportedSaveFormat(format) // convert to supported export format
+	}
 	for {
-		buf, err := v.export(img, format, compression, quality, palette, bitdepth, stripMetadata)
+		buf, err := v.export(img, format, compression, quality, palette, bitdepth, stripMetadata, encode)
 		if err != nil {
 			return nil, WrapErr(err)
 		}
-		if maxBytes > 0 && (quality > 10 || quality == 0) && format != ImageTypePNG {
+		if maxBytes > 0 && (quality > 10 || quality == 0) && format != ImageTypePNG &&
+			!encode.isLossless(format) {
 			ln := len(buf)
 			if v.Debug {
 				v.Logger.Debug("max_bytes",
@@ -351,6 +590,14 @@ func (v *Processor) Process(
 		if typ, ok := ImageMimeTypes[format]; ok {
 			blob.SetContentType(typ)
 		}
+		if header := getHeader(ctx); len(header) > 0 {
+			if blob.Header == nil {
+				blob.Header = make(http.Header, len(header))
+			}
+			for key, values := range header {
+				blob.Header[key] = append(blob.Header[key], values...)
+			}
+		}
 		return blob, nil
 	}
 }
@@ -358,6 +605,22 @@ func (v *Processor) Process(
 func (v *Processor) process(
 	ctx context.Context, img *Image, p imagorpath.Params, load imagor.LoadFunc, thumbnail, stretch, upscale bool, focalRects []focal,
 ) error {
+	if cols, rows, index, ok := v.getSpriteCell(p); ok {
+		// extract sprite cell as source image before crop and resize
+		w, h := img.Width()/cols, img.PageHeight()/rows
+		if w == 0 || h == 0 {
+			return imagor.NewError(fmt.Sprintf("sprite grid %dx%d exceeds image dimensions", cols, rows), http.StatusBadRequest)
+		}
+		if err := img.ExtractArea(index%cols*w, index/cols*h, w, h); err != nil {
+			return err
+		}
+	}
+	if tile, ok := v.getDZTile(p); ok {
+		// extract deep zoom tile as source image before crop and resize
+		if err := extractDZTile(img, tile); err != nil {
+			return err
+		}
+	}
 	var (
 		origWidth  = float64(img.Width())
 		origHeight = float64(img.PageHeight())
@@ -387,8 +650,9 @@ func (v *Processor) process(
 		cropRight = math.Min(cropRight, origWidth-1)
 		cropBottom = math.Min(cropBottom, origHeight-1)
 	}
-	if p.Trim {
-		if l, t, w, h, err := findTrim(ctx, img, p.TrimBy, p.TrimTolerance); err == nil {
+	if trimBy, trimTolerance, ok := v.getTrim(p); ok {
+		// trim on full resolution image before resize
+		if l, t, w, h, err := findTrim(ctx, img, trimBy, trimTolerance); err == nil && w > 0 && h > 0 {
 			cropLeft = math.Max(cropLeft, float64(l))
 			cropTop = math.Max(cropTop, float64(t))
 			if cropRight > 0 {
@@ -428,6 +692,27 @@ func (v *Processor) process(
 			h = img.PageHeight()
 		}
 	}
+	if stretch && !thumbnail && p.Width > 0 && p.Height > 0 {
+		if maxDistortion, ok := v.getMaxDistortion(p); ok &&
+			getDistortion(w, h, img.Width(), img.PageHeight()) > maxDistortion {
+			// crop to fill if stretch exceeds max distortion
+			stretch = false
+		}
+	}
+	if !thumbnail && upscale && v.isPixelUpscale(p) {
+		// nearest-neighbor upscale, remaining resize and crop are then no-op or downscale
+		if err := pixelUpscale(img, w, h, p.FitIn, stretch); err != nil {
+			return err
+		}
+	}
+	if !thumbnail {
+		if kernel := v.getKernel(p); kernel != KernelAuto {
+			// resize with kernel, remaining thumbnail is then crop only
+			if err := kernelResize(img, w, h, p.FitIn, stretch, upscale, kernel); err != nil {
+				return err
+			}
+		}
+	}
 	if !thumbnail {
 		if p.FitIn {
 			if upscale || w < img.Width() || h < img.PageHeight() {
@@ -445,8 +730,8 @@ func (v *Processor) process(
 			}
 		} else if upscale || w < img.Width() || h < img.PageHeight() {
 			interest := InterestingCentre
-			if p.Smart {
-				interest = InterestingAttention
+			if smart, ok := v.getSmartInterest(p); ok {
+				interest = smart
 			} else if float64(w)/float64(h) > float64(img.Width())/float64(img.PageHeight()) {
 				if p.VAlign == imagorpath.VAlignTop {
 					interest = InterestingLow
@@ -528,6 +813,104 @@ func (v *Processor) process(
 	return nil
 }
 
+// roundDimensions crops image centered so that width and page height
+// are rounded down to the nearest multiple
+func roundDimensions(img *Image, multiple int) error {
+	w, h := img.Width(), img.PageHeight()
+	newW, newH := w, h
+	if w >= multiple {
+		newW = w - w%multiple
+	}
+	if h >= multiple {
+		newH = h - h%multiple
+	}
+	if newW == w && newH == h {
+		return nil
+	}
+	return img.ExtractArea((w-newW)/2, (h-newH)/2, newW, newH)
+}
+
+// DominantColor average color of the image
+type DominantColor struct {
+	Color string `json:"color"`
+	R     uint8  `json:"r"`
+	G     uint8  `json:"g"`
+	B     uint8  `json:"b"`
+}
+
+// dominantColor shrinks the first frame of image to 1x1 for the average color
+func (v *Processor) dominantColor(
+	ctx context.Context, blob *imagor.Blob, page, dpi int,
+) (*DominantColor, error) {
+	img, err := v.NewThumbnail(ctx, blob, 1, 1, InterestingNone, SizeForce, 1, page, dpi)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	if img.Interpretation() != InterpretationSRGB {
+		if err = img.ToColorSpace(InterpretationSRGB); err != nil {
+			return nil, err
+		}
+	}
+	point, err := img.GetPoint(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(point) < 3 {
+		return nil, imagor.ErrUnsupportedFormat
+	}
+	c := &DominantColor{
+		R: uint8(math.Round(point[0])),
+		G: uint8(math.Round(point[1])),
+		B: uint8(math.Round(point[2])),
+	}
+	c.Color = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	return c, nil
+}
+
+// newPlaceholder creates image of the requested dimensions
+// with solid average color, or gradient of the corner colors of the source image
+func (v *Processor) newPlaceholder(
+	ctx context.Context, blob *imagor.Blob, width, height int, gradient bool, page, dpi int,
+) (*Image, error) {
+	src, err := v.NewImage(ctx, blob, 1, page, dpi)
+	if err != nil {
+		return nil, err
+	}
+	srcWidth, srcHeight := src.Width(), src.PageHeight()
+	if o := src.Orientation(); o >= 5 && o <= 8 {
+		srcWidth, srcHeight = srcHeight, srcWidth
+	}
+	src.Close()
+	if width == 0 && height == 0 {
+		width, height = srcWidth, srcHeight
+	} else if width == 0 {
+		width = int(math.Max(1, math.Round(float64(height*srcWidth)/float64(srcHeight))))
+	} else if height == 0 {
+		height = int(math.Max(1, math.Round(float64(width*srcHeight)/float64(srcWidth))))
+	}
+	width = min(width, v.MaxWidth)
+	height = min(height, v.MaxHeight)
+	n := 1
+	if gradient {
+		n = 2
+	}
+	img, err := v.NewThumbnail(ctx, blob, n, n, InterestingNone, SizeForce, 1, page, dpi)
+	if err != nil {
+		return nil, err
+	}
+	if gradient {
+		err = img.Resize(float64(width)/float64(img.Width()), float64(height)/float64(img.Height()), KernelLinear)
+	} else {
+		err = img.Embed(0, 0, width, height, ExtendCopy)
+	}
+	if err != nil {
+		img.Close()
+		return nil, err
+	}
+	return img, nil
+}
+
 // Metadata image attributes
 type Metadata struct {
 	Format      string         `json:"format"`
@@ -537,6 +920,8 @@ type Metadata struct {
 	Orientation int            `json:"orientation"`
 	Pages       int            `json:"pages"`
 	Bands       int            `json:"bands"`
+	HasAlpha    bool           `json:"has_alpha"`
+	Space       string         `json:"space"`
 	Exif        map[string]any `json:"exif"`
 }
 
@@ -559,6 +944,8 @@ func metadata(img *Image, format ImageType, stripExif bool) *Metadata {
 		Height:      img.PageHeight(),
 		Pages:       pages,
 		Bands:       img.Bands(),
+		HasAlpha:    img.HasAlpha(),
+		Space:       InterpretationNames[img.Interpretation()],
 		Orientation: img.Orientation(),
 		Exif:        exif,
 	}
@@ -577,9 +964,39 @@ func supportedSaveFormat(format ImageType) ImageType {
 	return ImageTypeJPEG
 }
 
+// encodeOptions format specific encoder options, applied only to the matching export format.
+// Negative means encoder default
+type encodeOptions struct {
+	WebpEffort     int
+	AvifSpeed      int
+	PngCompression int
+	Lossless       bool
+	KeepMetadata   bool
+	StripICC       bool
+	Loop           int
+}
+
+// isLossless returns if lossless encoding applies to the export format,
+// which quality does not apply to
+func (o encodeOptions) isLossless(format ImageType) bool {
+	return o.Lossless && (format == ImageTypeWEBP || format == ImageTypeAVIF)
+}
+
 func (v *Processor) export(
 	image *Image, format ImageType, compression int, quality int, palette bool, bitdepth int, stripMetadata bool,
+	encode encodeOptions,
 ) ([]byte, error) {
+	if encode.KeepMetadata && !encode.StripICC && !stripMetadata {
+		if err := keepICCProfile(image); err != nil {
+			return nil, err
+		}
+	}
+	if encode.Loop >= 0 && IsAnimationSupported(format) && isAnimated(image) {
+		// loop metadata written by animated gif and webp savers
+		if err := image.SetLoop(encode.Loop); err != nil {
+			return nil, err
+		}
+	}
 	switch format {
 	case ImageTypePNG:
 		opts := NewPngExportParams()
@@ -595,6 +1012,9 @@ func (v *Processor) export(
 		if compression > 0 {
 			opts.Compression = compression
 		}
+		if encode.PngCompression >= 0 {
+			opts.Compression = encode.PngCompression
+		}
 		if stripMetadata {
 			opts.StripMetadata = true
 		}
@@ -604,6 +1024,12 @@ func (v *Processor) export(
 		if quality > 0 {
 			opts.Quality = quality
 		}
+		if encode.WebpEffort >= 0 {
+			opts.ReductionEffort = encode.WebpEffort
+		}
+		if encode.isLossless(format) {
+			opts.Lossless = true
+		}
 		if stripMetadata {
 			opts.StripMetadata = true
 		}
@@ -635,6 +1061,12 @@ func (v *Processor) export(
 			opts.StripMetadata = true
 		}
 		opts.Speed = v.AvifSpeed
+		if encode.AvifSpeed >= 0 {
+			opts.Speed = encode.AvifSpeed
+		}
+		if encode.isLossless(format) {
+			opts.Lossless = true
+		}
 		return image.ExportAvif(opts)
 	case ImageTypeHEIF:
 		opts := NewHeifExportParams()
@@ -648,6 +1080,15 @@ func (v *Processor) export(
 			opts.Quality = quality
 		}
 		return image.ExportJp2k(opts)
+	case ImageTypePDF:
+		opts := NewPdfExportParams()
+		if quality > 0 {
+			opts.Quality = quality
+		}
+		if stripMetadata {
+			opts.StripMetadata = true
+		}
+		return image.ExportPdf(opts)
 	default:
 		opts := NewJpegExportParams()
 		if v.MozJPEG {
@@ -664,11 +1105,32 @@ func (v *Processor) export(
 		}
 		if stripMetadata {
 			opts.StripMetadata = true
+		} else if encode.KeepMetadata {
+			// explicitly kept metadata overrides MozJPEG stripping
+			opts.StripMetadata = false
 		}
 		return image.ExportJpeg(opts)
 	}
 }
 
+// keepICCProfile embeds sRGB ICC profile to sRGB image of kept metadata,
+// if profile is missing or no longer matches the image e.g. converted from CMYK
+func keepICCProfile(img *Image) error {
+	if img.Interpretation() != InterpretationSRGB {
+		return nil
+	}
+	if icc := img.ICCProfile(); len(icc) >= 20 && string(icc[16:20]) == "RGB " {
+		return nil
+	}
+	return img.SetICCProfileSRGB()
+}
+
+// isKeepArg returns if filter arg disables stripping e.g. strip_metadata(0)
+func isKeepArg(arg string) bool {
+	arg = strings.TrimSpace(arg)
+	return arg == "0" || strings.EqualFold(arg, "false") || strings.EqualFold(arg, "keep")
+}
+
 func argSplit(r rune) bool {
 	return r == 'x' || r == ',' || r == ':'
 }
@@ -693,6 +1155,115 @@ func parseFocalPoint(focalRects ...focal) (focalX, focalY float64) {
 	return
 }
 
+// getSpriteCell returns sprite grid and cell index of sprite_cell filter
+func (v *Processor) getSpriteCell(p imagorpath.Params) (cols, rows, index int, ok bool) {
+	for _, f := range p.Filters {
+		if f.Name == "sprite_cell" && !v.disableFilters[f.Name] {
+			if c, r, i, err := parseSpriteCell(f.Args); err == nil {
+				cols, rows, index, ok = c, r, i, true
+			}
+		}
+	}
+	return
+}
+
+// dzTile deep zoom pyramid tile of dztile filter
+type dzTile struct {
+	Level, X, Y, Size, Overlap int
+}
+
+// getDZTile returns deep zoom tile of dztile filter
+func (v *Processor) getDZTile(p imagorpath.Params) (tile dzTile, ok bool) {
+	for _, f := range p.Filters {
+		if f.Name == "dztile" && !v.disableFilters[f.Name] {
+			if t, err := parseDZTile(f.Args); err == nil {
+				tile, ok = t, true
+			}
+		}
+	}
+	return
+}
+
+// parseDZTile parses level, column, row, tile size and overlap of dztile filter args,
+// tile size defaults 256 and overlap defaults 0
+func parseDZTile(args string) (tile dzTile, err error) {
+	arr := strings.Split(args, ",")
+	if len(arr) < 3 {
+		return tile, imagor.NewError(fmt.Sprintf("invalid dztile %s", args), http.StatusBadRequest)
+	}
+	tile.Size = 256
+	var nums = []*int{&tile.Level, &tile.X, &tile.Y, &tile.Size, &tile.Overlap}
+	for i := 0; i < len(arr) && i < len(nums); i++ {
+		if *nums[i], err = strconv.Atoi(strings.TrimSpace(arr[i])); err != nil || *nums[i] < 0 {
+			return tile, imagor.NewError(fmt.Sprintf("invalid dztile %s", args), http.StatusBadRequest)
+		}
+	}
+	if tile.Size < 1 || tile.Level > 32 {
+		return tile, imagor.NewError(fmt.Sprintf("invalid dztile %s", args), http.StatusBadRequest)
+	}
+	return tile, nil
+}
+
+// dzTileRegion returns source region and output dimensions of deep zoom tile,
+// where the highest level is full resolution and each level below halves the dimensions
+func dzTileRegion(width, height int, tile dzTile) (left, top, w, h, tileW, tileH int, err error) {
+	maxLevel := int(math.Ceil(math.Log2(float64(max(width, height)))))
+	if tile.Level > maxLevel {
+		err = imagor.NewError(fmt.Sprintf("dztile level %d exceeds max level %d", tile.Level, maxLevel), http.StatusBadRequest)
+		return
+	}
+	scale := math.Pow(2, float64(maxLevel-tile.Level))
+	levelW := int(math.Ceil(float64(width) / scale))
+	levelH := int(math.Ceil(float64(height) / scale))
+	l := max(tile.X*tile.Size-tile.Overlap, 0)
+	t := max(tile.Y*tile.Size-tile.Overlap, 0)
+	r := min((tile.X+1)*tile.Size+tile.Overlap, levelW)
+	b := min((tile.Y+1)*tile.Size+tile.Overlap, levelH)
+	if tile.X*tile.Size >= levelW || tile.Y*tile.Size >= levelH {
+		err = imagor.NewError(fmt.Sprintf("dztile %d,%d out of level %d bounds", tile.X, tile.Y, tile.Level), http.StatusBadRequest)
+		return
+	}
+	tileW, tileH = r-l, b-t
+	left = int(math.Floor(float64(l) * scale))
+	top = int(math.Floor(float64(t) * scale))
+	w = min(int(math.Ceil(float64(r)*scale)), width) - left
+	h = min(int(math.Ceil(float64(b)*scale)), height) - top
+	return
+}
+
+// extractDZTile extracts and downscales deep zoom tile region of image
+func extractDZTile(img *Image, tile dzTile) error {
+	left, top, w, h, tileW, tileH, err := dzTileRegion(img.Width(), img.PageHeight(), tile)
+	if err != nil {
+		return err
+	}
+	if err = img.ExtractArea(left, top, w, h); err != nil {
+		return err
+	}
+	if w == tileW && h == tileH {
+		return nil
+	}
+	return img.ThumbnailWithSize(tileW, tileH, InterestingNone, SizeForce)
+}
+
+// parseSpriteCell parses cols, rows and zero based cell index of sprite_cell filter args
+func parseSpriteCell(args string) (cols, rows, index int, err error) {
+	arr := strings.Split(args, ",")
+	if len(arr) < 3 {
+		return 0, 0, 0, imagor.NewError(fmt.Sprintf("invalid sprite cell %s", args), http.StatusBadRequest)
+	}
+	cols, _ = strconv.Atoi(strings.TrimSpace(arr[0]))
+	rows, _ = strconv.Atoi(strings.TrimSpace(arr[1]))
+	if cols < 1 || rows < 1 {
+		return 0, 0, 0, imagor.NewError(fmt.Sprintf("invalid sprite grid %sx%s", arr[0], arr[1]), http.StatusBadRequest)
+	}
+	index, err = strconv.Atoi(strings.TrimSpace(arr[2]))
+	if err != nil || index < 0 || index >= cols*rows {
+		return 0, 0, 0, imagor.NewError(fmt.Sprintf("invalid sprite cell index %s", arr[2]), http.StatusBadRequest)
+	}
+	return
+}
+
 func findTrim(
 	_ context.Context, img *Image, pos string, tolerance int,
 ) (l, t, w, h int, err error) {
@@ -711,3 +1282,149 @@ func findTrim(
 	l, t, w, h, err = img.FindTrim(float64(tolerance), x, y)
 	return
 }
+
+// getTrim returns trim position and tolerance of trim path segment or trim filter
+func (v *Processor) getTrim(p imagorpath.Params) (pos string, tolerance int, ok bool) {
+	if p.Trim {
+		pos, tolerance, ok = p.TrimBy, p.TrimTolerance, true
+	}
+	for _, f := range p.Filters {
+		if f.Name == "trim" && !v.disableFilters[f.Name] {
+			args := strings.Split(f.Args, ",")
+			tolerance, _ = strconv.Atoi(strings.TrimSpace(args[0]))
+			pos = ""
+			if len(args) > 1 {
+				pos = strings.TrimSpace(args[1])
+			}
+			ok = true
+		}
+	}
+	return
+}
+
+func (v *Processor) getMaxDistortion(p imagorpath.Params) (pct float64, ok bool) {
+	for _, f := range p.Filters {
+		if f.Name == "max_distortion" && !v.disableFilters[f.Name] {
+			if n, err := strconv.ParseFloat(f.Args, 64); err == nil {
+				pct = math.Max(n, 0)
+				ok = true
+			}
+		}
+	}
+	return
+}
+
+// getDistortion returns aspect ratio distortion in percentage
+// of forcing source dimensions into target dimensions
+func getDistortion(w, h, srcW, srcH int) float64 {
+	if w <= 0 || h <= 0 || srcW <= 0 || srcH <= 0 {
+		return 0
+	}
+	r := (float64(w) / float64(h)) / (float64(srcW) / float64(srcH))
+	return (math.Max(r, 1/r) - 1) * 100
+}
+
+func (v *Processor) isPixelUpscale(p imagorpath.Params) (pixel bool) {
+	for _, f := range p.Filters {
+		if f.Name == "upscale_mode" && !v.disableFilters[f.Name] {
+			pixel = strings.ToLower(f.Args) == "pixel"
+		}
+	}
+	return
+}
+
+// getSmartInterest returns smart crop interest of smart path segment or smart([mode]) filter,
+// attention by default or entropy
+func (v *Processor) getSmartInterest(p imagorpath.Params) (interest Interesting, ok bool) {
+	if p.Smart {
+		interest, ok = InterestingAttention, true
+	}
+	for _, f := range p.Filters {
+		if f.Name == "smart" && !v.disableFilters[f.Name] {
+			switch strings.ToLower(strings.TrimSpace(f.Args)) {
+			case "", "attention":
+				interest, ok = InterestingAttention, true
+			case "entropy":
+				interest, ok = InterestingEntropy, true
+			default:
+				if v.Debug {
+					v.Logger.Debug("invalid smart", zap.String("args", f.Args))
+				}
+			}
+		}
+	}
+	return
+}
+
+// getKernel returns resize kernel of kernel filter, fallback to auto if invalid
+func (v *Processor) getKernel(p imagorpath.Params) (kernel Kernel) {
+	kernel = KernelAuto
+	for _, f := range p.Filters {
+		if f.Name == "kernel" && !v.disableFilters[f.Name] {
+			if k, ok := parseKernel(f.Args); ok {
+				kernel = k
+			} else if v.Debug {
+				v.Logger.Debug("invalid kernel", zap.String("args", f.Args))
+			}
+		}
+	}
+	return
+}
+
+// parseKernel parses kernel name e.g. lanczos3 or VipsKernel enum number
+func parseKernel(s string) (Kernel, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if kernel, ok := kernelMap[s]; ok {
+		return kernel, true
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, kernel := range kernelMap {
+			if kernel == Kernel(n) {
+				return kernel, true
+			}
+		}
+	}
+	return KernelAuto, false
+}
+
+// kernelResize resizes image towards target dimensions with the given kernel
+func kernelResize(img *Image, w, h int, fitIn, stretch, upscale bool, kernel Kernel) error {
+	var (
+		scaleX = float64(w) / float64(img.Width())
+		scaleY = float64(h) / float64(img.PageHeight())
+	)
+	if fitIn {
+		scaleX = math.Min(scaleX, scaleY)
+		scaleY = scaleX
+	} else if !stretch {
+		scaleX = math.Max(scaleX, scaleY)
+		scaleY = scaleX
+	}
+	if !upscale {
+		scaleX = math.Min(scaleX, 1)
+		scaleY = math.Min(scaleY, 1)
+	}
+	if scaleX == 1 && scaleY == 1 {
+		return nil
+	}
+	return img.Resize(scaleX, scaleY, kernel)
+}
+
+// pixelUpscale upscales image towards target dimensions with nearest-neighbor kernel
+func pixelUpscale(img *Image, w, h int, fitIn, stretch bool) error {
+	var (
+		scaleX = float64(w) / float64(img.Width())
+		scaleY = float64(h) / float64(img.PageHeight())
+	)
+	if fitIn {
+		scaleX = math.Min(scaleX, scaleY)
+		scaleY = scaleX
+	} else if !stretch {
+		scaleX = math.Max(scaleX, scaleY)
+		scaleY = scaleX
+	}
+	if scaleX <= 1 && scaleY <= 1 {
+		return nil
+	}
+	return img.Resize(math.Max(scaleX, 1), math.Max(scaleY, 1), KernelNearest)
+}
@@ -2,10 +2,14 @@ package vips
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"net/http"
 	"runtime"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/cshum/imagor"
 	"go.uber.org/zap"
@@ -22,23 +26,25 @@ var processorCount int
 
 // Processor implements imagor.Processor interface
 type Processor struct {
-	Filters            FilterMap
-	DisableBlur        bool
-	DisableFilters     []string
-	MaxFilterOps       int
-	Logger             *zap.Logger
-	Concurrency        int
-	MaxCacheFiles      int
-	MaxCacheMem        int
-	MaxCacheSize       int
-	MaxWidth           int
-	MaxHeight          int
-	MaxResolution      int
-	MaxAnimationFrames int
-	MozJPEG            bool
-	StripMetadata      bool
-	AvifSpeed          int
-	Debug              bool
+	Filters                  FilterMap
+	DisableBlur              bool
+	DisableFilters           []string
+	MaxFilterOps             int
+	Logger                   *zap.Logger
+	Concurrency              int
+	MaxCacheFiles            int
+	MaxCacheMem              int
+	MaxCacheSize             int
+	MaxWidth                 int
+	MaxHeight                int
+	MaxResolution            int
+	MaxAnimationFrames       int
+	MaxAnimationFramesStrict bool
+	MaxDPI                   int
+	MozJPEG                  bool
+	StripMetadata            bool
+	AvifSpeed                int
+	Debug                    bool
 
 	disableFilters map[string]bool
 }
@@ -52,6 +58,7 @@ func NewProcessor(options ...Option) *Processor {
 		Concurrency:        1,
 		MaxFilterOps:       -1,
 		MaxAnimationFrames: -1,
+		MaxDPI:             1200,
 		Logger:             zap.NewNop(),
 		disableFilters:     map[string]bool{},
 	}
@@ -61,6 +68,7 @@ func NewProcessor(options ...Option) *Processor {
 		"rotate":           rotate,
 		"label":            label,
 		"grayscale":        grayscale,
+		"sepia":            sepia,
 		"brightness":       brightness,
 		"background_color": backgroundColor,
 		"contrast":         contrast,
@@ -72,10 +80,15 @@ func NewProcessor(options ...Option) *Processor {
 		"sharpen":          sharpen,
 		"strip_icc":        stripIcc,
 		"strip_exif":       stripExif,
-		"trim":             trim,
 		"set_frames":       setFrames,
+		"delay":            frameDelay,
+		"fps":              fps,
 		"padding":          v.padding,
+		"fit_pad":          v.fitPad,
 		"proportion":       proportion,
+		"replace_color":    replaceColor,
+		"sprite":           v.sprite,
+		"channel":          channel,
 	}
 	for _, option := range options {
 		option(v)
@@ -149,6 +162,13 @@ func newImageFromBlob(
 		buf, width, height, bands, _ := blob.Memory()
 		return LoadImageFromMemory(buf, width, height, bands)
 	}
+	if blob.BlobType() == imagor.BlobTypeICO {
+		buf, err := blob.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		return loadImageFromICO(buf, params)
+	}
 	reader, _, err := blob.NewReader()
 	if err != nil {
 		return nil, err
@@ -168,7 +188,10 @@ func newImageFromBlob(
 		}()
 		return loadImageFromBMP(r)
 	}
-	return img, err
+	if err != nil {
+		return nil, newDecodeError(ctx, err)
+	}
+	return img, nil
 }
 
 func newThumbnailFromBlob(
@@ -184,7 +207,11 @@ func newThumbnailFromBlob(
 	}
 	src := NewSource(reader)
 	contextDefer(ctx, src.Close)
-	return src.LoadThumbnail(width, height, crop, size, params)
+	img, err := src.LoadThumbnail(width, height, crop, size, params)
+	if err != nil {
+		return nil, newDecodeError(ctx, err)
+	}
+	return img, nil
 }
 
 // NewThumbnail creates new thumbnail with resize and crop from imagor.Blob
@@ -196,6 +223,9 @@ func (v *Processor) NewThumbnail(
 	if dpi > 0 {
 		params.Density.Set(dpi)
 	}
+	if isNoRotate(ctx) {
+		params.NoRotate.Set(true)
+	}
 	var err error
 	var img *Image
 	params.FailOnError.Set(false)
@@ -206,6 +236,12 @@ func (v *Processor) NewThumbnail(
 				return nil, WrapErr(err)
 			}
 			if n > 1 || page > 1 {
+				if err = v.checkFrames(img, n); err != nil {
+					return nil, err
+				}
+				if err = checkPage(blob, img, page); err != nil {
+					return nil, err
+				}
 				// reload image to restrict frames loaded
 				n, page = recalculateImage(img, n, page)
 				return v.NewThumbnail(ctx, blob, width, height, crop, size, -n, -page, dpi)
@@ -222,6 +258,12 @@ func (v *Processor) NewThumbnail(
 				return nil, WrapErr(err)
 			}
 			if n > 1 || page > 1 {
+				if err = v.checkFrames(img, n); err != nil {
+					return nil, err
+				}
+				if err = checkPage(blob, img, page); err != nil {
+					return nil, err
+				}
 				// reload image to restrict frames loaded
 				n, page = recalculateImage(img, n, page)
 				return v.NewThumbnail(ctx, blob, width, height, crop, size, -n, -page, dpi)
@@ -262,6 +304,9 @@ func (v *Processor) NewImage(ctx context.Context, blob *imagor.Blob, n, page int
 	if dpi > 0 {
 		params.Density.Set(dpi)
 	}
+	if isNoRotate(ctx) {
+		params.NoRotate.Set(true)
+	}
 	params.FailOnError.Set(false)
 	if isMultiPage(blob, n, page) {
 		applyMultiPageParams(params, n, page)
@@ -271,6 +316,12 @@ func (v *Processor) NewImage(ctx context.Context, blob *imagor.Blob, n, page int
 		}
 		// reload image to restrict frames loaded
 		if n > 1 || page > 1 {
+			if err = v.checkFrames(img, n); err != nil {
+				return nil, err
+			}
+			if err = checkPage(blob, img, page); err != nil {
+				return nil, err
+			}
 			n, page = recalculateImage(img, n, page)
 			return v.NewImage(ctx, blob, -n, -page, dpi)
 		}
@@ -362,6 +413,31 @@ func (v *Processor) CheckResolution(img *Image, err error) (*Image, error) {
 	return img, nil
 }
 
+// checkFrames rejects image exceeding max animation frames if strict,
+// instead of truncating the extra frames. Image is closed if rejected
+func (v *Processor) checkFrames(img *Image, n int) error {
+	if n > 1 && v.MaxAnimationFramesStrict && v.MaxAnimationFrames > 0 &&
+		img.Pages() > v.MaxAnimationFrames {
+		img.Close()
+		return imagor.ErrMaxFramesExceeded
+	}
+	return nil
+}
+
+// checkPage rejects PDF page out of range,
+// whereas animation frame is clamped to the last frame. Image is closed if rejected
+func checkPage(blob *imagor.Blob, img *Image, page int) error {
+	if page > 1 && blob.BlobType() == imagor.BlobTypePDF {
+		if numPages := img.Pages(); page > numPages {
+			img.Close()
+			return imagor.NewError(
+				fmt.Sprintf("page %d out of range, document has %d pages", page, numPages),
+				http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
 func isMultiPage(blob *imagor.Blob, n, page int) bool {
 	return blob != nil && (blob.SupportsAnimation() || blob.BlobType() == imagor.BlobTypePDF) && ((n != 1 && n != 0) || (page != 1 && page != 0))
 }
@@ -403,3 +479,33 @@ func WrapErr(err error) error {
 	}
 	return imagor.NewError(msg, 406)
 }
+
+// maxDecodeErrorDetail max length of decode error detail
+const maxDecodeErrorDetail = 200
+
+// newDecodeError wraps vips load error into imagor.ErrDecodeFailed
+// with sanitized vips error message as diagnostic detail.
+// imagor.Error and context errors are passed through unchanged
+func newDecodeError(ctx context.Context, err error) error {
+	var e imagor.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// vips load interrupted by request cancel or timeout, not a decode failure
+		return ctxErr
+	}
+	if e := WrapErr(err); e == imagor.ErrUnsupportedFormat {
+		return e
+	}
+	detail := strings.Join(strings.FieldsFunc(err.Error(), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+	if r := []rune(detail); len(r) > maxDecodeErrorDetail {
+		detail = string(r[:maxDecodeErrorDetail])
+	}
+	return imagor.ErrDecodeFailed.WithDetail(detail)
+}
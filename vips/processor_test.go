@@ -1,8 +1,15 @@
 package vips
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +18,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cshum/imagor"
@@ -73,6 +81,8 @@ func TestProcessor(t *testing.T) {
 			{name: "meta format no animate", path: "meta/fit-in/100x100/filters:format(jpg)/dancing-banana.gif"},
 			{name: "meta exif", path: "meta/Canon_40D.jpg"},
 			{name: "meta strip exif", path: "meta/filters:strip_exif()/Canon_40D.jpg"},
+			{name: "meta even", path: "meta/fit-in/101x101/filters:even()/gopher.png"},
+			{name: "meta even animated", path: "meta/fit-in/51x51/filters:even(4)/dancing-banana.gif"},
 		}, WithDebug(true), WithLogger(zap.NewExample()))
 	})
 	t.Run("vips strip metadata config", func(t *testing.T) {
@@ -101,16 +111,23 @@ func TestProcessor(t *testing.T) {
 		var resultDir = filepath.Join(testDataDir, "golden")
 		doGoldenTests(t, resultDir, []test{
 			{name: "no-ops", path: "filters:background_color():set_frames():set_frames(0):round_corner():padding():rotate():proportion():proportion(9999):proportion(0.0000000001):proportion(-10)/gopher-front.png"},
+			{name: "no-ops fit_pad", path: "filters:fit_pad():fit_pad(0,100)/gopher-front.png"},
 			{name: "no-ops 2", path: "trim/filters:watermark():blur(2):sharpen(2):brightness():contrast():hue():saturation():rgb():modulate()/dancing-banana.gif"},
 			{name: "no-ops 3", path: "filters:proportion():proportion(9999):proportion(0.0000000001):proportion(-10):sharpen(-1)/gopher-front.png"},
 			{name: "resize center", path: "100x100/filters:quality(70):format(jpeg)/gopher.png"},
 			{name: "resize smart", path: "100x100/smart/filters:autojpg()/gopher.png"},
+			{name: "resize smart filter", path: "100x100/filters:smart():autojpg()/gopher.png"},
+			{name: "resize smart entropy", path: "200x50/filters:smart(entropy):autojpg()/gopher.png"},
+			{name: "resize smart entropy no thumbnail", path: "50x200/filters:smart(entropy):rotate(90):autojpg()/gopher.png"},
 			{name: "resize focal", path: "300x100/filters:fill(white):format(jpeg):focal(589x401:1000x814)/gopher.png"},
 			{name: "resize focal vertical", path: "100x300/filters:fill(white):format(jpeg):focal(589x401:1000x814)/gopher.png"},
 			{name: "resize focal with crop", path: "0x100:9999x9999/300x100/filters:fill(white):format(jpeg):focal(589x401:1000x814)/gopher.png"},
 			{name: "resize focal float", path: "300x100/filters:fill(white):format(jpeg):focal(0.35x0.25:0.6x0.3)/gopher.png"},
 			{name: "resize focal point", path: "300x100/filters:fill(white):format(jpeg):focal(589x401):focal(1000x814)/gopher.png"},
 			{name: "resize focal point edge", path: "300x100/filters:fill(white):format(jpeg):focal(9999x9999)/gopher.png"},
+			{name: "resize focal point top left", path: "300x100/filters:fill(white):format(jpeg):focal(0,0)/gopher.png"},
+			{name: "resize focal point bottom right", path: "300x100/filters:fill(white):format(jpeg):focal(1.0,1.0)/gopher.png"},
+			{name: "resize focal point over smart", path: "300x100/smart/filters:fill(white):format(jpeg):focal(0.0,1.0)/gopher.png"},
 			{name: "resize focal point exif orientation cw90", path: "300x300/filters:format(jpeg):focal(150:150)/gopher-exif-orientation-cw90.png"},
 			{name: "resize top", path: "200x100/top/filters:quality(70):format(tiff)/gopher.png"},
 			{name: "resize top", path: "200x100/right/top/gopher.png"},
@@ -129,6 +146,9 @@ func TestProcessor(t *testing.T) {
 			{name: "fit-in unspecified width", path: "fit-in/0x50/filters:fill(white):format(jpg)/Canon_40D.jpg"},
 			{name: "resize unspecified width", path: "0x50/filters:fill(white):format(jpg)/Canon_40D.jpg"},
 			{name: "stretch", path: "stretch/100x100/filters:modulate(-10,30,20)/gopher.png"},
+			{name: "stretch max distortion within", path: "stretch/100x80/filters:max_distortion(50)/gopher.png"},
+			{name: "stretch max distortion exceeded", path: "stretch/300x50/filters:max_distortion(10)/gopher.png"},
+			{name: "stretch filter max distortion exceeded", path: "300x50/filters:stretch():max_distortion(10)/gopher.png"},
 			{name: "fit-in flip hue", path: "fit-in/-200x0/filters:hue(290):saturation(100):fill(FFO):upscale()/gopher.png"},
 			{name: "fit-in padding", path: "fit-in/100x100/10x5/filters:fill(white)/gopher.png"},
 			{name: "fit-in padding transparent", path: "fit-in/100x100/10x5/filters:fill(none)/gopher.png"},
@@ -137,29 +157,52 @@ func TestProcessor(t *testing.T) {
 			{name: "resize padding", path: "100x100/10x5/top/filters:fill(white)/gopher.png"},
 			{name: "stretch padding", path: "stretch/100x100/10x5/filters:fill(white)/gopher.png"},
 			{name: "padding", path: "0x0/40x50/filters:fill(white)/gopher-front.png"},
+			{name: "fit pad", path: "filters:fit_pad(300,100,white):format(jpeg)/gopher.png"},
+			{name: "fit pad vertical", path: "filters:fit_pad(100,300,ff0000):format(jpeg)/gopher.png"},
+			{name: "fit pad transparent", path: "filters:fit_pad(300,100,none)/demo1.jpg"},
+			{name: "fit pad no upscale", path: "filters:fit_pad(2000,2000,white):format(jpeg)/gopher-front.png"},
 			{name: "max_bytes", path: "filters:max_bytes(60000):format(jpg):fill(white)/gopher.png"},
 			{name: "max_bytes 2", path: "filters:max_bytes(6000):format(jpg):fill(white)/gopher.png"},
 			{name: "fill auto", path: "fit-in/400x400/filters:fill(auto)/find_trim.png"},
 			{name: "fill auto bottom-right", path: "fit-in/400x400/filters:fill(auto,bottom-right)/find_trim.png"},
 			{name: "resize top flip blur", path: "200x-210/top/filters:blur(5):sharpen(5):background_color(ffff00):format(jpeg):quality(70)/gopher.png"},
+			{name: "blur animated", path: "fit-in/100x100/filters:blur(5)/dancing-banana.gif"},
+			{name: "blur max sigma", path: "fit-in/100x100/filters:blur(9999)/gopher.png"},
+			{name: "sharpen animated", path: "fit-in/100x100/filters:sharpen(2)/dancing-banana.gif"},
+			{name: "sharpen radius flat jagged", path: "fit-in/100x100/filters:sharpen(0.5,2,10)/gopher.png"},
 			{name: "blur sharpen 2", path: "200x-210/top/filters:blur(1,2):sharpen(1,2):background_color(ff0):format(jpeg):quality(70)/gopher.png"},
 			{name: "crop stretch top flip", path: "10x20:3000x5000/stretch/100x200/filters:brightness(-20):contrast(50):rgb(10,-50,30):fill(black)/gopher.png"},
 			{name: "crop-percent stretch top flip", path: "0.006120x0.008993:1.0x1.0/stretch/100x200/filters:brightness(-20):contrast(50):rgb(10,-50,30):fill(black)/gopher.png"},
 			{name: "padding rotation fill blur grayscale", path: "/fit-in/200x210/20x20/filters:rotate(90):rotate(270):rotate(180):fill(blur):grayscale()/gopher.png"},
 			{name: "fill round_corner", path: "fit-in/0x210/filters:fill(yellow):round_corner(40,60,green)/gopher.png"},
+			{name: "sepia", path: "fit-in/100x100/filters:sepia():format(png)/gopher.png"},
+			{name: "sepia intensity animated", path: "fit-in/100x100/filters:sepia(50)/dancing-banana.gif"},
+			{name: "sepia grayscale alpha", path: "fit-in/100x100/filters:sepia(80)/2bands.png", checkTypeOnly: true},
 			{name: "grayscale fill none", path: "fit-in/100x100/filters:fill(none)/2bands.png", checkTypeOnly: true},
 			{name: "trim alpha", path: "trim/find_trim_alpha.png"},
 			{name: "trim with crop", path: "trim:bottom-right/50x50:0x0/find_trim.png"},
 			{name: "trim right", path: "trim:bottom-right/500x500/filters:strip_exif():upscale():no_upscale()/find_trim.png"},
 			{name: "trim upscale", path: "trim/fit-in/1000x1000/filters:upscale():strip_icc()/find_trim.png"},
+			{name: "upscale mode pixel fit-in", path: "fit-in/400x400/filters:upscale():upscale_mode(pixel)/gopher-front.png"},
+			{name: "upscale mode pixel fill", path: "400x300/filters:upscale_mode(pixel)/gopher-front.png"},
+			{name: "upscale mode pixel stretch", path: "stretch/400x100/filters:upscale_mode(pixel)/gopher-front.png"},
+			{name: "upscale mode pixel animated", path: "fit-in/300x300/filters:upscale():upscale_mode(pixel)/dancing-banana.gif"},
+			{name: "kernel lanczos3", path: "fit-in/100x100/filters:kernel(lanczos3)/gopher-front.png"},
+			{name: "kernel nearest fill", path: "100x50/filters:kernel(nearest)/gopher-front.png"},
+			{name: "kernel numeric stretch", path: "stretch/100x50/filters:kernel(3)/gopher-front.png"},
+			{name: "kernel mitchell animated", path: "fit-in/80x80/filters:kernel(mitchell)/dancing-banana.gif"},
+			{name: "kernel invalid", path: "fit-in/100x100/filters:kernel(foo)/gopher-front.png"},
+			{name: "upscale mode smooth", path: "fit-in/400x400/filters:upscale():upscale_mode(smooth)/gopher-front.png"},
 			{name: "trim tolerance", path: "trim:50/500x500/filters:stretch()/find_trim.png"},
 			{name: "trim position tolerance filter", path: "50x50:0x0/filters:trim(50,bottom-right)/find_trim.png"},
 			{name: "trim filter", path: "/fit-in/100x100/filters:fill(auto):trim(50)/find_trim.png"},
+			{name: "trim filter before resize", path: "fit-in/100x100/filters:trim(50,bottom-right)/find_trim.png"},
 			{name: "watermark", path: "fit-in/500x500/filters:fill(white):watermark(gopher.png,10p,repeat,30,20,20):watermark(gopher.png,repeat,bottom,30,30,30):watermark(gopher-front.png,center,-10p)/gopher.png"},
 			{name: "watermark non alpha", path: "filters:watermark(demo1.jpg,repeat,repeat,40,25,50)/demo1.jpg"},
 			{name: "background color non alpha", path: "filters:background_color(yellow)/demo1.jpg"},
 			{name: "watermark 2 bands", path: "filters:watermark(2bands.png,repeat,bottom,40,25,50)/demo1.jpg"},
 			{name: "watermark float", path: "fit-in/500x500/filters:fill(white):watermark(gopher.png,0.1,repeat,30,20,20):watermark(gopher.png,repeat,bottom,30,30,30):watermark(gopher-front.png,center,-0.1)/gopher.png"},
+			{name: "watermark tiled", path: "fit-in/500x500/filters:fill(white):watermark(gopher-front.png,10,20,50,10,10,repeat):watermark(gopher-front.png,-10,-20,50,10,10,repeat)/gopher.png"},
 			{name: "watermark align", path: "fit-in/500x500/filters:fill(white):watermark(gopher.png,left,top,30,20,20):watermark(gopher.png,right,center,30,30,30):watermark(gopher-front.png,-20,-10)/gopher.png"},
 
 			{name: "original no animate", path: "filters:fill(white):format(jpeg)/dancing-banana.gif"},
@@ -168,6 +211,21 @@ func TestProcessor(t *testing.T) {
 			{name: "original animated max_frames", path: "filters:max_frames(3)/dancing-banana.gif"},
 			{name: "original animated page", path: "filters:page(5)/dancing-banana.gif"},
 			{name: "original animated page exceeded", path: "filters:page(999)/dancing-banana.gif"},
+			{name: "animated frame", path: "fit-in/100x100/filters:frame(3)/dancing-banana.gif"},
+			{name: "animated still", path: "fit-in/100x100/filters:still()/dancing-banana.gif"},
+			{name: "sprite animated", path: "fit-in/50x50/filters:sprite(4,3)/dancing-banana.gif"},
+			{name: "sprite animated interval", path: "fit-in/50x50/filters:sprite(3,2,200):format(png)/dancing-banana.gif"},
+			{name: "sprite static", path: "fit-in/50x50/filters:sprite(2,2)/gopher.png"},
+			{name: "placeholder", path: "100x50/filters:placeholder()/gopher.png"},
+			{name: "placeholder source dimensions", path: "filters:placeholder():format(jpeg)/gopher-front.png"},
+			{name: "placeholder gradient", path: "80x0/filters:placeholder(gradient)/demo1.jpg"},
+			{name: "placeholder animated", path: "0x40/filters:placeholder(gradient)/dancing-banana.gif"},
+			{name: "even", path: "fit-in/101x101/filters:even()/gopher.png"},
+			{name: "even multiple fill", path: "fit-in/99x77/10x10/filters:fill(white):even(16):format(jpeg)/gopher.png"},
+			{name: "even animated", path: "fit-in/51x51/filters:even()/dancing-banana.gif"},
+			{name: "lottie", path: "filters:lottie()/lottie.json"},
+			{name: "lottie frame", path: "fit-in/100x100/filters:lottie(30)/lottie.json"},
+			{name: "lottie frame jpeg", path: "filters:lottie(59):format(jpeg)/lottie.json"},
 			{name: "original animated strip_exif retain metadata", path: "filters:strip_exif()/dancing-banana.gif"},
 			{name: "rotate animated", path: "fit-in/100x150/filters:rotate(90):fill(yellow)/dancing-banana.gif", arm64Golden: true},
 			{name: "crop animated", path: "30x20:100x150/dancing-banana.gif"},
@@ -208,6 +266,13 @@ func TestProcessor(t *testing.T) {
 			{name: "label animated with font", path: "fit-in/150x200/10x00:10x50/filters:fill(cyan):label(IMAGOR,center,-30,25,white,0,monospace)/dancing-banana.gif", arm64Golden: true},
 			{name: "label grayscale", path: "fit-in/filters:label(imagor,-1,0,50)/2bands.png", checkTypeOnly: true},
 			{name: "strip exif", path: "filters:strip_exif()/Canon_40D.jpg"},
+			{name: "channel red", path: "fit-in/100x100/filters:channel(r)/gopher.png"},
+			{name: "channel alpha", path: "fit-in/100x100/filters:channel(a)/gopher-front.png"},
+			{name: "channel index", path: "fit-in/100x100/filters:channel(2)/demo1.jpg"},
+			{name: "ico png frame", path: "fit-in/100x100/favicon.ico"},
+			{name: "ico bmp frame", path: "fit-in/100x100/filters:format(png)/favicon-bmp.ico"},
+			{name: "replace color", path: "fit-in/100x100/filters:replace_color(00add8,ff0000,40)/gopher-front.png"},
+			{name: "replace color default tolerance", path: "fit-in/100x100/filters:replace_color(f00,00f)/demo1.jpg"},
 			{name: "bmp 24bit", path: "100x100/bmp_24.bmp"},
 			{name: "bmp 8bit", path: "100x100/lena_gray.bmp"},
 			{name: "svg", path: "test.svg", checkTypeOnly: true},
@@ -290,6 +355,26 @@ func TestProcessor(t *testing.T) {
 		assert.Equal(t, 406, w.Code)
 		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 	})
+	t.Run("decode error status", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		for name, c := range map[string]struct {
+			ctx  context.Context
+			err  error
+			code int
+		}{
+			"vips error":     {context.Background(), fmt.Errorf("VipsJpeg: premature end of input file"), 422},
+			"imagor error":   {context.Background(), imagor.ErrNotFound, 404},
+			"wrapped imagor": {context.Background(), fmt.Errorf("load: %w", imagor.ErrTooManyRequests), 429},
+			"deadline":       {context.Background(), context.DeadlineExceeded, 408},
+		} {
+			assert.Equal(t, c.code, imagor.WrapError(newDecodeError(c.ctx, c.err)).Code, name)
+		}
+		assert.ErrorIs(t, newDecodeError(context.Background(), context.Canceled), context.Canceled)
+		err := newDecodeError(canceled, fmt.Errorf("VipsForeignLoad: read error"))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, errors.Is(err, imagor.ErrDecodeFailed))
+	})
 	t.Run("resolution exceeded", func(t *testing.T) {
 		app := imagor.New(
 			imagor.WithLoaders(filestorage.New(testDataDir)),
@@ -319,6 +404,11 @@ func TestProcessor(t *testing.T) {
 		app.ServeHTTP(w, httptest.NewRequest(
 			http.MethodGet, "/unsafe/trim/1000x0/gopher-front.png", nil))
 		assert.Equal(t, 422, w.Code)
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/filters:fit_pad(1000,1000)/gopher-front.png", nil))
+		assert.Equal(t, 422, w.Code)
 	})
 	t.Run("resolution exceeded max frames within", func(t *testing.T) {
 		app := imagor.New(
@@ -341,6 +431,33 @@ func TestProcessor(t *testing.T) {
 			http.MethodGet, "/unsafe/dancing-banana.gif", nil))
 		assert.Equal(t, 200, w.Code)
 	})
+	t.Run("max frames strict", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor(
+				WithMaxAnimationFrames(3),
+				WithMaxAnimationFramesStrict(true),
+			)),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for path, code := range map[string]int{
+			"/unsafe/dancing-banana.gif":                           422,
+			"/unsafe/fit-in/100x100/dancing-banana.gif":            422,
+			"/unsafe/100x100/smart/dancing-banana.gif":             422,
+			"/unsafe/filters:frame(5)/dancing-banana.gif":          200,
+			"/unsafe/filters:still()/dancing-banana.gif":           200,
+			"/unsafe/filters:format(jpeg)/dancing-banana.gif":      200,
+			"/unsafe/fit-in/100x100/filters:rotate(90)/gopher.png": 200,
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, code, w.Code, path)
+		}
+	})
 	t.Run("resolution exceeded max frames", func(t *testing.T) {
 		app := imagor.New(
 			imagor.WithLoaders(filestorage.New(testDataDir)),
@@ -362,6 +479,968 @@ func TestProcessor(t *testing.T) {
 			http.MethodGet, "/unsafe/dancing-banana.gif", nil))
 		assert.Equal(t, 422, w.Code)
 	})
+	t.Run("channel out of range", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithDebug(true),
+			imagor.WithLogger(zap.NewExample()),
+			imagor.WithProcessors(NewProcessor(WithDebug(true))),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/filters:channel(a)/demo1.jpg", nil))
+		assert.Equal(t, 400, w.Code)
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/filters:channel(5)/gopher-front.png", nil))
+		assert.Equal(t, 400, w.Code)
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/filters:channel(x)/gopher-front.png", nil))
+		assert.Equal(t, 400, w.Code)
+	})
+	t.Run("replace color invalid", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for _, path := range []string{
+			"/unsafe/filters:replace_color(zz0000,0000ff)/gopher-front.png",
+			"/unsafe/filters:replace_color(ff0000,00ff)/gopher-front.png",
+			"/unsafe/filters:replace_color(ff0000,0000ff,0)/gopher-front.png",
+			"/unsafe/filters:replace_color(ff0000,0000ff,181)/gopher-front.png",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 400, w.Code, path)
+		}
+	})
+	t.Run("sprite", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/50x50/filters:sprite(4,3):format(png)/dancing-banana.gif", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "47x50", w.Header().Get("Imagor-Sprite-Cell"))
+		assert.Equal(t, "8", w.Header().Get("Imagor-Sprite-Count"))
+
+		// 3x3 sheet of 8 frames, last cell left blank
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/50x50/filters:sprite(3,3):format(png)/dancing-banana.gif", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "47x50", w.Header().Get("Imagor-Sprite-Cell"))
+		assert.Equal(t, "8", w.Header().Get("Imagor-Sprite-Count"))
+		img, err := LoadImageFromBuffer(w.Body.Bytes(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{141, 150}, [2]int{img.Width(), img.Height()})
+		img.Close()
+
+		// max frames cap
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/50x50/filters:max_frames(4):sprite(3,3):format(png)/dancing-banana.gif", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "4", w.Header().Get("Imagor-Sprite-Count"))
+		capped := w.Body.Bytes()
+
+		// 2x2 sheet of 8 frames, keeps the first 4 frames and drops the rest
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/50x50/filters:max_frames(4):sprite(2,2):format(png)/dancing-banana.gif", nil))
+		assert.Equal(t, 200, w.Code)
+		first4 := w.Body.Bytes()
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/50x50/filters:sprite(2,2):format(png)/dancing-banana.gif", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "4", w.Header().Get("Imagor-Sprite-Count"))
+		assert.Equal(t, first4, w.Body.Bytes(), "should drop frames beyond cols x rows")
+		assert.NotEqual(t, capped, first4)
+		assert.Equal(t, []int{0, 1, 2, 3}, spriteFrames(8, 4, nil, 0))
+		assert.Equal(t, []int{0, 1, 2}, spriteFrames(3, 4, nil, 0))
+
+		for _, path := range []string{
+			"/unsafe/filters:sprite(0,3)/dancing-banana.gif",
+			"/unsafe/filters:sprite(4,3,-1)/dancing-banana.gif",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 400, w.Code, path)
+		}
+	})
+	t.Run("dpi", func(t *testing.T) {
+		p := NewProcessor(WithMaxDPI(144))
+		meta := func(image, filters string) (m Metadata) {
+			buf, err := os.ReadFile(filepath.Join(testDataDir, image))
+			require.NoError(t, err)
+			path := "meta/" + image
+			if filters != "" {
+				path = "meta/filters:" + filters + "/" + image
+			}
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(buf), imagorpath.Parse(path), nil)
+			require.NoError(t, err, path)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(out, &m))
+			return
+		}
+		pdf := meta("sample.pdf", "")
+		pdf2x := meta("sample.pdf", "dpi(144)")
+		assert.InDelta(t, pdf.Width*2, pdf2x.Width, 1)
+		assert.InDelta(t, pdf.Height*2, pdf2x.Height, 1)
+		assert.Equal(t, pdf2x, meta("sample.pdf", "dpi(999999)"), "capped at max dpi")
+		assert.Equal(t, meta("gopher.png", ""), meta("gopher.png", "dpi(300)"), "ignored for raster")
+	})
+	t.Run("meta", func(t *testing.T) {
+		p := NewProcessor()
+		meta := func(image string) (m Metadata) {
+			buf, err := os.ReadFile(filepath.Join(testDataDir, image))
+			require.NoError(t, err)
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(buf), imagorpath.Parse("meta/"+image), nil)
+			require.NoError(t, err, image)
+			assert.Equal(t, "application/json", blob.ContentType())
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(out, &m))
+			return
+		}
+		jpeg := meta("Canon_40D.jpg")
+		assert.Equal(t, "jpeg", jpeg.Format)
+		assert.Equal(t, 1, jpeg.Pages)
+		assert.Equal(t, 1, jpeg.Orientation)
+		assert.False(t, jpeg.HasAlpha)
+		assert.Equal(t, "srgb", jpeg.Space)
+		assert.NotEmpty(t, jpeg.Exif)
+
+		gif := meta("dancing-banana.gif")
+		assert.Equal(t, "gif", gif.Format)
+		assert.Equal(t, "image/gif", gif.ContentType)
+		assert.Greater(t, gif.Pages, 1)
+		assert.Equal(t, gif.Bands == 2 || gif.Bands == 4, gif.HasAlpha)
+		assert.Equal(t, "srgb", gif.Space)
+	})
+	t.Run("pdf page", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for path, code := range map[string]int{
+			"/unsafe/fit-in/100x100/filters:page(1)/sample.pdf":   200,
+			"/unsafe/fit-in/100x100/filters:page(999)/sample.pdf": 400,
+			"/unsafe/filters:page(999)/sample.pdf":                400,
+			"/unsafe/filters:page(999)/dancing-banana.gif":        200,
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, code, w.Code, path)
+			if code == 400 {
+				assert.Contains(t, w.Body.String(), "page 999 out of range", path)
+			}
+		}
+	})
+	t.Run("frame delay", func(t *testing.T) {
+		buf, err := os.ReadFile(filepath.Join(testDataDir, "dancing-banana.gif"))
+		require.NoError(t, err)
+		p := NewProcessor()
+		for filters, delay := range map[string]int{
+			"delay(200)":           200,
+			"delay(5)":             20,
+			"delay(9999999)":       655350,
+			"fps(4)":               250,
+			"fps(100)":             20,
+			"fps(10):format(webp)": 100,
+			"delay(300):fps(0)":    300,
+		} {
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(buf),
+				imagorpath.Parse("fit-in/50x50/filters:"+filters+"/dancing-banana.gif"), nil)
+			require.NoError(t, err, filters)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			params := NewImportParams()
+			params.NumPages.Set(-1)
+			img, err := LoadImageFromBuffer(out, params)
+			require.NoError(t, err, filters)
+			delays := img.PageDelay()
+			require.NotEmpty(t, delays, filters)
+			for _, d := range delays {
+				assert.Equal(t, delay, d, filters)
+			}
+			img.Close()
+		}
+		// non-animated image untouched
+		blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(buf),
+			imagorpath.Parse("filters:frame(2):delay(200):format(gif)/dancing-banana.gif"), nil)
+		require.NoError(t, err)
+		out, err := blob.ReadAll()
+		require.NoError(t, err)
+		img, err := LoadImageFromBuffer(out, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, img.Pages())
+		img.Close()
+	})
+	t.Run("still frame", func(t *testing.T) {
+		buf, err := os.ReadFile(filepath.Join(testDataDir, "dancing-banana.gif"))
+		require.NoError(t, err)
+		p := NewProcessor(WithMaxAnimationFrames(-1))
+		for _, filters := range []string{"frame(0)", "frame(3)", "frame(999)", "frame(-1)", "still()", "still():max_frames(5)"} {
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(buf),
+				imagorpath.Parse("fit-in/100x100/filters:"+filters+"/dancing-banana.gif"), nil)
+			require.NoError(t, err, filters)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			params := NewImportParams()
+			params.NumPages.Set(-1)
+			img, err := LoadImageFromBuffer(out, params)
+			require.NoError(t, err, filters)
+			assert.Equal(t, img.Height(), img.PageHeight(), filters)
+			assert.Equal(t, 1, img.Pages(), filters)
+			img.Close()
+		}
+	})
+	t.Run("sprite cell", func(t *testing.T) {
+		// 3x2 grid of 10x10 cells, cell color by index
+		buf := make([]byte, 30*20*3)
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 30; x++ {
+				buf[(y*30+x)*3] = byte((y/10*3 + x/10) * 40)
+			}
+		}
+		p := NewProcessor()
+		for index := 0; index < 6; index++ {
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromMemory(buf, 30, 20, 3),
+				imagorpath.Parse(fmt.Sprintf("filters:sprite_cell(3,2,%d):format(png)/image", index)), nil)
+			require.NoError(t, err)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			img, err := LoadImageFromBuffer(out, nil)
+			require.NoError(t, err)
+			assert.Equal(t, [2]int{10, 10}, [2]int{img.Width(), img.Height()})
+			pt, err := img.GetPoint(5, 5)
+			require.NoError(t, err)
+			assert.Equal(t, float64(index*40), pt[0], "cell %d", index)
+			img.Close()
+		}
+		blob, err := p.Process(context.Background(), imagor.NewBlobFromMemory(buf, 30, 20, 3),
+			imagorpath.Parse("fit-in/5x5/filters:sprite_cell(3,2,4):format(png)/image"), nil)
+		require.NoError(t, err)
+		out, err := blob.ReadAll()
+		require.NoError(t, err)
+		img, err := LoadImageFromBuffer(out, nil)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{5, 5}, [2]int{img.Width(), img.Height()})
+		img.Close()
+
+		for _, filter := range []string{
+			"sprite_cell(3,2,6)",
+			"sprite_cell(3,2,-1)",
+			"sprite_cell(0,2,0)",
+			"sprite_cell(3,2)",
+			"sprite_cell(31,1,0)",
+		} {
+			_, err := p.Process(context.Background(), imagor.NewBlobFromMemory(buf, 30, 20, 3),
+				imagorpath.Parse("filters:"+filter+"/image"), nil)
+			e, ok := err.(imagor.Error)
+			require.True(t, ok, filter)
+			assert.Equal(t, 400, e.Code, filter)
+		}
+	})
+	t.Run("auto format animated", func(t *testing.T) {
+		for _, tt := range []struct {
+			name        string
+			option      imagor.Option
+			accept      string
+			contentType string
+		}{
+			{name: "auto webp", option: imagor.WithAutoWebP(true),
+				accept: "image/avif,image/webp,*/*", contentType: "image/webp"},
+			{name: "auto avif keeps gif", option: imagor.WithAutoAVIF(true),
+				accept: "image/avif,image/webp,*/*", contentType: "image/gif"},
+			{name: "auto formats webp over avif", option: imagor.WithAutoFormats("avif", "webp"),
+				accept: "image/avif,image/webp,*/*", contentType: "image/webp"},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				app := imagor.New(
+					imagor.WithLoaders(filestorage.New(testDataDir)),
+					imagor.WithUnsafe(true),
+					imagor.WithProcessors(NewProcessor()),
+					tt.option,
+				)
+				require.NoError(t, app.Startup(context.Background()))
+				t.Cleanup(func() {
+					assert.NoError(t, app.Shutdown(context.Background()))
+				})
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/unsafe/fit-in/50x50/dancing-banana.gif", nil)
+				r.Header.Set("Accept", tt.accept)
+				app.ServeHTTP(w, r)
+				assert.Equal(t, 200, w.Code)
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+				params := NewImportParams()
+				params.NumPages.Set(-1)
+				img, err := LoadImageFromBuffer(w.Body.Bytes(), params)
+				require.NoError(t, err)
+				assert.Equal(t, 8, img.Height()/img.PageHeight(), "animation frames preserved")
+				img.Close()
+			})
+		}
+	})
+	t.Run("watermark context cache", func(t *testing.T) {
+		var mu sync.Mutex
+		var counts = map[string]int{}
+		loader := loaderFunc(func(r *http.Request, image string) (*imagor.Blob, error) {
+			image, ok := strings.CutPrefix(image, "counted/")
+			if !ok {
+				return nil, imagor.ErrNotFound
+			}
+			mu.Lock()
+			counts[image]++
+			mu.Unlock()
+			buf, err := os.ReadFile(filepath.Join(testDataDir, image))
+			return imagor.NewBlobFromBytes(buf), err
+		})
+		path := "/unsafe/fit-in/100x100/filters:watermark(counted/gopher-front.png,0,0):" +
+			"watermark(counted/gopher.png,10,10):watermark(counted/gopher-front.png,20,20)/demo1.jpg"
+		for _, tt := range []struct {
+			name       string
+			maxEntries int
+			maxBytes   int64
+			expected   map[string]int
+		}{
+			{"unbounded", 0, 0, map[string]int{"gopher-front.png": 1, "gopher.png": 1}},
+			{"max entries evicted", 1, 0, map[string]int{"gopher-front.png": 2, "gopher.png": 1}},
+			{"max bytes evicted", 0, 1, map[string]int{"gopher-front.png": 2, "gopher.png": 1}},
+			{"within limit", 2, 10 << 20, map[string]int{"gopher-front.png": 1, "gopher.png": 1}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				counts = map[string]int{}
+				app := imagor.New(
+					imagor.WithLoaders(filestorage.New(testDataDir), loader),
+					imagor.WithUnsafe(true),
+					imagor.WithProcessors(NewProcessor()),
+					imagor.WithContextCacheLimit(tt.maxEntries, tt.maxBytes),
+				)
+				require.NoError(t, app.Startup(context.Background()))
+				t.Cleanup(func() {
+					assert.NoError(t, app.Shutdown(context.Background()))
+				})
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, 200, w.Code)
+				assert.Equal(t, tt.expected, counts)
+			})
+		}
+	})
+	t.Run("loop", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for _, tt := range []struct {
+			path        string
+			contentType string
+			loop        int
+		}{
+			{"/unsafe/fit-in/50x50/filters:format(webp):loop(1)/dancing-banana.gif", "image/webp", 1},
+			{"/unsafe/fit-in/50x50/filters:format(webp):loop(3)/dancing-banana.gif", "image/webp", 3},
+			{"/unsafe/fit-in/50x50/filters:format(webp):loop(0)/dancing-banana.gif", "image/webp", 0},
+			{"/unsafe/fit-in/50x50/filters:format(gif):loop(2)/dancing-banana.gif", "image/gif", 2},
+			{"/unsafe/fit-in/50x50/filters:loop(5)/dancing-banana.gif", "image/gif", 5},
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, 200, w.Code, tt.path)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"), tt.path)
+			params := NewImportParams()
+			params.NumPages.Set(-1)
+			img, err := LoadImageFromBuffer(w.Body.Bytes(), params)
+			require.NoError(t, err, tt.path)
+			assert.Equal(t, 8, img.Height()/img.PageHeight(), tt.path)
+			assert.Equal(t, tt.loop, img.Loop(), tt.path)
+			img.Close()
+		}
+
+		// ignored for still output
+		for _, path := range []string{
+			"/unsafe/fit-in/50x50/filters:format(webp):loop(1)/gopher.png",
+			"/unsafe/fit-in/50x50/filters:format(webp):still():loop(1)/dancing-banana.gif",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			assert.Equal(t, "image/webp", w.Header().Get("Content-Type"), path)
+			params := NewImportParams()
+			params.NumPages.Set(-1)
+			img, err := LoadImageFromBuffer(w.Body.Bytes(), params)
+			require.NoError(t, err, path)
+			assert.Equal(t, img.Height(), img.PageHeight(), path)
+			assert.Equal(t, 0, img.Loop(), path)
+			img.Close()
+		}
+	})
+	t.Run("pdf output", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		if !IsSaveSupported(ImageTypePDF) {
+			// without PDF save, falls back to jpeg
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "/unsafe/fit-in/100x100/filters:format(pdf)/demo1.jpg", nil))
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+			t.Skip("libvips built without magick save")
+		}
+		for _, path := range []string{
+			"/unsafe/fit-in/100x100/filters:format(pdf)/demo1.jpg",
+			"/unsafe/fit-in/100x100/filters:format(pdf)/gopher.png",
+			"/unsafe/fit-in/100x100/filters:format(pdf)/dancing-banana.gif",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"), path)
+			assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF")), path)
+			assert.Equal(t, imagor.BlobTypePDF, imagor.NewBlobFromBytes(w.Body.Bytes()).BlobType(), path)
+		}
+		// pdf source rasterized unless pdf output specified
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "/unsafe/fit-in/100x100/sample.pdf", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	})
+	t.Run("dztile", func(t *testing.T) {
+		// 1000x600 gradient, red by x and green by y
+		buf := make([]byte, 1000*600*3)
+		for y := 0; y < 600; y++ {
+			for x := 0; x < 1000; x++ {
+				buf[(y*1000+x)*3] = byte(x / 4)
+				buf[(y*1000+x)*3+1] = byte(y / 4)
+			}
+		}
+		p := NewProcessor()
+		for _, tt := range []struct {
+			filter string
+			size   [2]int
+			pixel  [2]float64
+		}{
+			// max level 10 full resolution, source 256,512
+			{filter: "dztile(10,1,2)", size: [2]int{256, 88}, pixel: [2]float64{64, 128}},
+			{filter: "dztile(10,3,0)", size: [2]int{232, 256}, pixel: [2]float64{192, 0}},
+			// overlap extends left and top by 2 pixels, source 254,254
+			{filter: "dztile(10,1,1,256,2)", size: [2]int{260, 260}, pixel: [2]float64{63, 63}},
+			// level 9 half resolution, source 512,0
+			{filter: "dztile(9,1,0)", size: [2]int{244, 256}, pixel: [2]float64{128, 0}},
+			{filter: "dztile(9,0,0,100)", size: [2]int{100, 100}, pixel: [2]float64{0, 0}},
+			{filter: "dztile(1,0,0)", size: [2]int{2, 2}, pixel: [2]float64{62, 37}},
+			{filter: "dztile(0,0,0)", size: [2]int{1, 1}, pixel: [2]float64{125, 75}},
+		} {
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromMemory(buf, 1000, 600, 3),
+				imagorpath.Parse("filters:"+tt.filter+":format(png)/image"), nil)
+			require.NoError(t, err, tt.filter)
+			out, err := blob.ReadAll()
+			require.NoError(t, err, tt.filter)
+			img, err := LoadImageFromBuffer(out, nil)
+			require.NoError(t, err, tt.filter)
+			assert.Equal(t, tt.size, [2]int{img.Width(), img.Height()}, tt.filter)
+			pt, err := img.GetPoint(0, 0)
+			require.NoError(t, err, tt.filter)
+			assert.InDelta(t, tt.pixel[0], pt[0], 3, tt.filter)
+			assert.InDelta(t, tt.pixel[1], pt[1], 3, tt.filter)
+			img.Close()
+		}
+		for _, filter := range []string{
+			"dztile(11,0,0)",
+			"dztile(10,4,0)",
+			"dztile(10,0,3)",
+			"dztile(9,0,0,0)",
+			"dztile(10,-1,0)",
+			"dztile(10,0)",
+		} {
+			_, err := p.Process(context.Background(), imagor.NewBlobFromMemory(buf, 1000, 600, 3),
+				imagorpath.Parse("filters:"+filter+"/image"), nil)
+			e, ok := err.(imagor.Error)
+			require.True(t, ok, filter)
+			assert.Equal(t, 400, e.Code, filter)
+		}
+	})
+	t.Run("dominant color", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for _, path := range []string{
+			"/unsafe/filters:dominant_color()/demo1.jpg",
+			"/unsafe/fit-in/100x100/filters:dominant_color()/dancing-banana.gif",
+			"/unsafe/filters:dominant_color()/lena_gray.bmp",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"), path)
+			var c DominantColor
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &c), path)
+			assert.Equal(t, fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B), c.Color, path)
+		}
+	})
+	t.Run("thumbhash", func(t *testing.T) {
+		// thumbHashAverage decodes average color from thumbhash header
+		thumbHashAverage := func(hash []byte) (r, g, b, a float64) {
+			header := int(hash[0]) | int(hash[1])<<8 | int(hash[2])<<16
+			l := float64(header&63) / 63
+			p := float64((header>>6)&63)/31.5 - 1
+			q := float64((header>>12)&63)/31.5 - 1
+			a = 1
+			if header>>23 != 0 {
+				a = float64(hash[5]&15) / 15
+			}
+			b = l - 2.0/3*p
+			r = (3*l - b + q) / 2
+			g = r - q
+			return
+		}
+		w, h := 24, 16
+		pixels := make([]byte, w*h*4)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := (y*w + x) * 4
+				pixels[i], pixels[i+1], pixels[i+2], pixels[i+3] = byte(x*10), byte(y*15), 128, 255
+			}
+		}
+		hash := rgbaToThumbHash(w, h, pixels)
+		assert.Equal(t, "nQcKNZhwd3dweHh3iHiHh3BwB/eI", base64.StdEncoding.EncodeToString(hash))
+		assert.Equal(t, hash, rgbaToThumbHash(w, h, pixels), "stable output")
+		r, g, b, a := thumbHashAverage(hash)
+		assert.InDelta(t, 115.0/255, r, 0.05)
+		assert.InDelta(t, 112.5/255, g, 0.05)
+		assert.InDelta(t, 128.0/255, b, 0.05)
+		assert.Equal(t, 1.0, a)
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				pixels[(y*w+x)*4+3] = byte(x * 10)
+			}
+		}
+		hash = rgbaToThumbHash(w, h, pixels)
+		assert.Equal(t, "IViGG4o3s4ewiIezsDj7hHB3eIiIiHc=", base64.StdEncoding.EncodeToString(hash))
+		_, _, _, a = thumbHashAverage(hash)
+		assert.InDelta(t, 115.0/255, a, 0.05, "alpha encoded")
+
+		// sunrise example of the reference implementation, hash as listed on https://evanw.github.io/thumbhash/
+		file, err := os.Open(filepath.Join(testDataDir, "sunrise.jpg"))
+		require.NoError(t, err)
+		defer file.Close()
+		src, err := jpeg.Decode(file)
+		require.NoError(t, err)
+		nrgba := image.NewNRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(nrgba, nrgba.Bounds(), src, src.Bounds().Min, draw.Src)
+		assert.Equal(t, "1QcSHQRnh493V4dIh4eXh1h4kJUI", base64.StdEncoding.EncodeToString(
+			rgbaToThumbHash(nrgba.Bounds().Dx(), nrgba.Bounds().Dy(), nrgba.Pix)))
+
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for _, path := range []string{
+			"/unsafe/filters:thumbhash()/demo1.jpg",
+			"/unsafe/fit-in/100x100/filters:thumbhash()/dancing-banana.gif",
+			"/unsafe/filters:thumbhash()/gopher.png",
+		} {
+			var hashes []string
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, 200, w.Code, path)
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"), path)
+				var res ThumbHash
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), path)
+				buf, err := base64.StdEncoding.DecodeString(res.ThumbHash)
+				require.NoError(t, err, path)
+				assert.GreaterOrEqual(t, len(buf), 5, path)
+				hashes = append(hashes, res.ThumbHash)
+			}
+			assert.Equal(t, hashes[0], hashes[1], "stable output "+path)
+		}
+	})
+	t.Run("lottie", func(t *testing.T) {
+		buf, err := os.ReadFile(filepath.Join(testDataDir, "lottie.json"))
+		require.NoError(t, err)
+		assert.True(t, isLottie(buf))
+		assert.False(t, isLottie([]byte(`{"foo":"bar"}`)))
+		assert.Equal(t, imagor.BlobTypeJSON, imagor.NewBlobFromBytes(buf).BlobType())
+
+		svg, err := lottieToSVG(buf, 30, true)
+		require.NoError(t, err)
+		assert.Contains(t, string(svg), `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="120"`)
+		assert.Contains(t, string(svg), `translate(100 60) rotate(0)`, "ball position interpolated")
+		assert.Contains(t, string(svg), `translate(100 60) rotate(45)`, "shapes rotation interpolated")
+		assert.Contains(t, string(svg), `fill="#f0e6d2"`)
+		assert.Contains(t, string(svg), `<path d="M-20 -40 C-20 -40 20 -40 20 -40`)
+
+		svg, err = lottieToSVG(buf, 0, false)
+		require.NoError(t, err)
+		assert.Contains(t, string(svg), `translate(40 60) rotate(0)`, "defaults to in point")
+
+		svg, err = lottieToSVG(bytes.Replace(buf, []byte(`"#f0e6d2"`),
+			[]byte(`"red\"/><script>alert(1)</script><rect fill=\"red"`), 1), 30, true)
+		require.NoError(t, err)
+		assert.NotContains(t, string(svg), `<script>`, "invalid solid color skipped")
+		assert.True(t, isHexColor("#abc"))
+		assert.True(t, isHexColor("#F0E6D2"))
+		assert.False(t, isHexColor("red"))
+		assert.False(t, isHexColor("#f0e6dz"))
+
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unsafe/filters:lottie(10)/lottie.json", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	})
+	t.Run("jpeg lossless strip", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		src, err := os.ReadFile(filepath.Join(testDataDir, "Canon_40D.jpg"))
+		require.NoError(t, err)
+		// start of scan of the main image, after the Exif thumbnail
+		scan := src[bytes.LastIndex(src, []byte{0xFF, 0xDA}):]
+		for path, hasICC := range map[string]bool{
+			"/unsafe/filters:strip_exif()/Canon_40D.jpg":                  true,
+			"/unsafe/filters:strip_icc()/Canon_40D.jpg":                   false,
+			"/unsafe/filters:strip_metadata()/Canon_40D.jpg":              false,
+			"/unsafe/filters:strip_exif():strip_metadata()/Canon_40D.jpg": false,
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			assert.True(t, bytes.HasSuffix(w.Body.Bytes(), scan), "entropy coded data should be untouched %s", path)
+			assert.Equal(t, hasICC, bytes.Contains(w.Body.Bytes(), []byte("ICC_PROFILE")), path)
+		}
+		// golden of strip_exif is the source without the Exif segment
+		golden, err := os.ReadFile(filepath.Join(testDataDir, "golden", "filters%3Astrip_exif%28%29", "Canon_40D.jpg"))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasSuffix(golden, scan))
+		assert.False(t, bytes.Contains(golden, []byte("Exif\x00\x00")))
+		assert.Equal(t, len(src)-len(golden), 2+2476, "only APP1 Exif segment removed")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unsafe/50x0/filters:strip_exif()/Canon_40D.jpg", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.False(t, bytes.HasSuffix(w.Body.Bytes(), scan), "resize should re-encode")
+	})
+	t.Run("focal point pixels", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		get := func(path string) []byte {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			return w.Body.Bytes()
+		}
+		for _, size := range []string{"100x300", "300x100"} {
+			topLeft := get("/unsafe/" + size + "/filters:format(png):focal(0,0)/gopher.png")
+			assert.Equal(t, topLeft, get("/unsafe/"+size+"/filters:format(png):focal(0x1)/gopher.png"), size)
+			assert.Equal(t, topLeft, get("/unsafe/"+size+"/filters:format(png):focal(1x0)/gopher.png"), size)
+			assert.NotEqual(t, topLeft, get("/unsafe/"+size+"/filters:format(png):focal(1.0x1.0)/gopher.png"), size)
+		}
+	})
+	t.Run("keep metadata", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor(WithStripMetadata(true))),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		for path, keep := range map[string]bool{
+			"/unsafe/50x0/Canon_40D.jpg":                                        false,
+			"/unsafe/50x0/filters:strip_metadata(0)/Canon_40D.jpg":              true,
+			"/unsafe/50x0/filters:strip_metadata(keep)/Canon_40D.jpg":           true,
+			"/unsafe/filters:strip_metadata(false)/Canon_40D.jpg":               true,
+			"/unsafe/50x0/filters:format(webp):strip_metadata(0)/Canon_40D.jpg": true,
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			img, err := LoadImageFromBuffer(w.Body.Bytes(), nil)
+			require.NoError(t, err, path)
+			meta := metadata(img, img.Format(), false)
+			icc := img.ICCProfile()
+			img.Close()
+			assert.Equal(t, keep, len(meta.Exif) > 0, path)
+			assert.Equal(t, keep, len(icc) > 0, path)
+		}
+		for path, hasICC := range map[string]bool{
+			"/unsafe/filters:strip_metadata(keep)/gopher.png":                true,
+			"/unsafe/filters:strip_metadata(keep):format(png)/Canon_40D.jpg": true,
+			"/unsafe/filters:strip_metadata(keep):strip_icc()/Canon_40D.jpg": false,
+			"/unsafe/filters:strip_metadata(keep):format(webp)/gopher.png":   true,
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, 200, w.Code, path)
+			img, err := LoadImageFromBuffer(w.Body.Bytes(), nil)
+			require.NoError(t, err, path)
+			icc := img.ICCProfile()
+			img.Close()
+			if assert.Equal(t, hasICC, len(icc) >= 20, path) && hasICC {
+				assert.Equal(t, "RGB ", string(icc[16:20]), "sRGB profile embedded %s", path)
+			}
+		}
+	})
+	t.Run("no autorotate", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil))
+		// insert exif APP1 segment of orientation 6 after SOI
+		exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+			"\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00\x00\x00\x00\x00")
+		src := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, byte(len(exif) + 2)}, exif...)
+		src = append(src, buf.Bytes()[2:]...)
+		p := NewProcessor()
+		for filters, size := range map[string][2]int{
+			"":                            {20, 40},
+			"no_autorotate()":             {40, 20},
+			"orient(0)":                   {40, 20},
+			"no_autorotate():format(png)": {40, 20},
+			"no_autorotate():fill(white)": {40, 20},
+			"format(png)":                 {20, 40},
+		} {
+			params := imagorpath.Parse("filters:" + filters + "/image.jpg")
+			if filters == "" {
+				params = imagorpath.Parse("image.jpg")
+			}
+			blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(src), params, nil)
+			require.NoError(t, err, filters)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			img, err := LoadImageFromBuffer(out, nil)
+			require.NoError(t, err, filters)
+			assert.Equal(t, size, [2]int{img.Width(), img.Height()}, filters)
+			img.Close()
+		}
+		// fit-in resize also respects no autorotate
+		blob, err := p.Process(context.Background(), imagor.NewBlobFromBytes(src),
+			imagorpath.Parse("fit-in/20x20/filters:no_autorotate()/image.jpg"), nil)
+		require.NoError(t, err)
+		out, err := blob.ReadAll()
+		require.NoError(t, err)
+		img, err := LoadImageFromBuffer(out, nil)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{20, 10}, [2]int{img.Width(), img.Height()})
+		img.Close()
+	})
+	t.Run("trim filter", func(t *testing.T) {
+		// nothing to trim returns original
+		buf := bytes.Repeat([]byte{255}, 10*8*3)
+		blob, err := NewProcessor().Process(context.Background(),
+			imagor.NewBlobFromMemory(buf, 10, 8, 3), imagorpath.Parse("filters:trim():format(png)/image"), nil)
+		require.NoError(t, err)
+		out, err := blob.ReadAll()
+		require.NoError(t, err)
+		img, err := LoadImageFromBuffer(out, nil)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{10, 8}, [2]int{img.Width(), img.Height()})
+		img.Close()
+
+		// trim computed on full resolution image same as trim path segment,
+		// differs from trimming the resized image
+		p := NewProcessor()
+		source := imagor.NewBlobFromFile(filepath.Join(testDataDir, "find_trim.png"))
+		process := func(blob *imagor.Blob, path string) []byte {
+			out, err := p.Process(context.Background(), blob, imagorpath.Parse(path), nil)
+			require.NoError(t, err, path)
+			buf, err := out.ReadAll()
+			require.NoError(t, err, path)
+			return buf
+		}
+		filtered := process(source, "fit-in/100x100/filters:trim(50,bottom-right)/find_trim.png")
+		assert.Equal(t, process(source, "trim:bottom-right:50/fit-in/100x100/find_trim.png"), filtered)
+		resized := process(source, "fit-in/100x100/find_trim.png")
+		assert.NotEqual(t, process(imagor.NewBlobFromBytes(resized), "trim:bottom-right:50/find_trim.png"), filtered)
+	})
+	t.Run("background", func(t *testing.T) {
+		// fully transparent image
+		buf := make([]byte, 10*8*4)
+		p := NewProcessor()
+		for filters, bands := range map[string]int{
+			"background(ff0000):format(jpeg)":           3,
+			"background(white):format(png)":             4,
+			"background(white,force):format(png)":       3,
+			"background(white):format(webp)":            4,
+			"background(white,force):format(webp)":      3,
+			"format(png)":                               4,
+			"background(f00):background(00f):autojpg()": 3,
+		} {
+			blob, err := p.Process(context.Background(),
+				imagor.NewBlobFromMemory(buf, 10, 8, 4), imagorpath.Parse("filters:"+filters+"/image"), nil)
+			require.NoError(t, err, filters)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			img, err := LoadImageFromBuffer(out, nil)
+			require.NoError(t, err, filters)
+			assert.Equal(t, bands, img.Bands(), filters)
+			img.Close()
+		}
+		blob, err := p.Process(context.Background(),
+			imagor.NewBlobFromMemory(buf, 10, 8, 4), imagorpath.Parse("filters:background(ff0000):format(jpeg)/image"), nil)
+		require.NoError(t, err)
+		out, err := blob.ReadAll()
+		require.NoError(t, err)
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		require.NoError(t, err)
+		for _, pt := range []image.Point{{0, 0}, {9, 0}, {0, 7}, {9, 7}} {
+			r, g, b, _ := img.At(pt.X, pt.Y).RGBA()
+			assert.InDelta(t, 255, r>>8, 2, "corner %v", pt)
+			assert.InDelta(t, 0, g>>8, 2, "corner %v", pt)
+			assert.InDelta(t, 0, b>>8, 2, "corner %v", pt)
+		}
+
+		// opaque image no-op
+		blob, err = p.Process(context.Background(),
+			imagor.NewBlobFromMemory(bytes.Repeat([]byte{0, 255, 0}, 10*8), 10, 8, 3),
+			imagorpath.Parse("filters:background(ff0000):format(jpeg)/image"), nil)
+		require.NoError(t, err)
+		out, err = blob.ReadAll()
+		require.NoError(t, err)
+		img, err = jpeg.Decode(bytes.NewReader(out))
+		require.NoError(t, err)
+		r, g, _, _ := img.At(0, 0).RGBA()
+		assert.InDelta(t, 0, r>>8, 2)
+		assert.InDelta(t, 255, g>>8, 2)
+	})
+	t.Run("encoder effort", func(t *testing.T) {
+		app := imagor.New(
+			imagor.WithLoaders(filestorage.New(testDataDir)),
+			imagor.WithUnsafe(true),
+			imagor.WithProcessors(NewProcessor()),
+		)
+		require.NoError(t, app.Startup(context.Background()))
+		t.Cleanup(func() {
+			assert.NoError(t, app.Shutdown(context.Background()))
+		})
+		size := func(path string) int {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			require.Equal(t, 200, w.Code, path)
+			return w.Body.Len()
+		}
+		assert.Less(t,
+			size("/unsafe/filters:format(webp):webp_effort(6)/gopher-front.png"),
+			size("/unsafe/filters:format(webp):webp_effort(0)/gopher-front.png"))
+		assert.Less(t,
+			size("/unsafe/filters:format(png):png_compression(9)/gopher-front.png"),
+			size("/unsafe/filters:format(png):png_compression(0)/gopher-front.png"))
+		// only applies to the matching output format
+		assert.Equal(t,
+			size("/unsafe/filters:format(png):webp_effort(0):avif_speed(0)/gopher-front.png"),
+			size("/unsafe/filters:format(png)/gopher-front.png"))
+	})
+	t.Run("lossless", func(t *testing.T) {
+		p := NewProcessor()
+		buf := make([]byte, 16*16*3)
+		for i := range buf {
+			buf[i] = byte(i * 7)
+		}
+		export := func(filters string) *imagor.Blob {
+			blob, err := p.Process(context.Background(),
+				imagor.NewBlobFromMemory(buf, 16, 16, 3), imagorpath.Parse("filters:"+filters+"/image"), nil)
+			require.NoError(t, err, filters)
+			return blob
+		}
+		for filters, typ := range map[string]imagor.BlobType{
+			"format(webp):lossless(1)":            imagor.BlobTypeWEBP,
+			"format(webp):lossless():quality(10)": imagor.BlobTypeWEBP,
+			"format(avif):lossless(true)":         imagor.BlobTypeAVIF,
+		} {
+			blob := export(filters)
+			assert.Equal(t, typ, blob.BlobType(), filters)
+			out, err := blob.ReadAll()
+			require.NoError(t, err)
+			again, err := export(filters).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, out, again, "reproducible %s", filters)
+		}
+		lossless, err := export("format(webp):lossless(1)").ReadAll()
+		require.NoError(t, err)
+		lossy, err := export("format(webp):quality(10)").ReadAll()
+		require.NoError(t, err)
+		assert.NotEqual(t, lossy, lossless)
+		// ignored for jpeg
+		out, err := export("format(jpeg):lossless(1)").ReadAll()
+		require.NoError(t, err)
+		expected, err := export("format(jpeg)").ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, expected, out)
+	})
 	t.Run("invalid BMP", func(t *testing.T) {
 		ctx := context.Background()
 		blob := imagor.NewBlobFromBytes([]byte("BMabcdasdfasdfasdfasdfasdfasdfasdfasdfasdfasdf"))
@@ -138,6 +138,11 @@ func Startup(config *Config) {
 				v, IsLoadSupported(k), IsSaveSupported(k)))
 		}
 	}
+	// PDF saved through ImageMagick if available
+	supportedSaveImageTypes[ImageTypePDF] = supportedSaveImageTypes[ImageTypeMagick]
+	if supportedSaveImageTypes[ImageTypePDF] {
+		log("vips", LogLevelInfo, "registered image type=pdf save=true via magick")
+	}
 	isStarted = true
 }
 
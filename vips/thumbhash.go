@@ -0,0 +1,166 @@
+package vips
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+
+	"github.com/cshum/imagor"
+)
+
+// thumbHashMaxSize maximum dimensions of the thumbhash sample
+const thumbHashMaxSize = 100
+
+// ThumbHash compact placeholder of the image
+type ThumbHash struct {
+	ThumbHash string `json:"thumbhash"`
+}
+
+// thumbHash downscales the first frame of image for the thumbhash
+func (v *Processor) thumbHash(
+	ctx context.Context, blob *imagor.Blob, page, dpi int,
+) (*ThumbHash, error) {
+	img, err := v.NewThumbnail(
+		ctx, blob, thumbHashMaxSize, thumbHashMaxSize, InterestingNone, SizeDown, 1, page, dpi)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	pixels, err := img.RGBAPixels()
+	if err != nil {
+		return nil, err
+	}
+	hash := rgbaToThumbHash(img.Width(), img.PageHeight(), pixels)
+	return &ThumbHash{ThumbHash: base64.StdEncoding.EncodeToString(hash)}, nil
+}
+
+// rgbaToThumbHash encodes RGBA pixels of at most 100x100 into thumbhash bytes,
+// following the reference implementation https://github.com/evanw/thumbhash
+func rgbaToThumbHash(w, h int, rgba []byte) []byte {
+	n := w * h
+	var avgR, avgG, avgB, avgA float64
+	for i, j := 0, 0; i < n; i, j = i+1, j+4 {
+		alpha := float64(rgba[j+3]) / 255
+		avgR += alpha / 255 * float64(rgba[j])
+		avgG += alpha / 255 * float64(rgba[j+1])
+		avgB += alpha / 255 * float64(rgba[j+2])
+		avgA += alpha
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(n)
+	lLimit := 7.0
+	if hasAlpha {
+		lLimit = 5
+	}
+	maxWH := float64(max(w, h))
+	lx := max(1, int(jsRound(lLimit*float64(w)/maxWH)))
+	ly := max(1, int(jsRound(lLimit*float64(h)/maxWH)))
+
+	l := make([]float64, n)
+	p := make([]float64, n)
+	q := make([]float64, n)
+	a := make([]float64, n)
+	for i, j := 0, 0; i < n; i, j = i+1, j+4 {
+		alpha := float64(rgba[j+3]) / 255
+		r := avgR*(1-alpha) + alpha/255*float64(rgba[j])
+		g := avgG*(1-alpha) + alpha/255*float64(rgba[j+1])
+		b := avgB*(1-alpha) + alpha/255*float64(rgba[j+2])
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	encodeChannel := func(channel []float64, nx, ny int) (dc float64, ac []float64, scale float64) {
+		fx := make([]float64, w)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				var f float64
+				for x := 0; x < w; x++ {
+					fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+				}
+				for y := 0; y < h; y++ {
+					fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+					for x := 0; x < w; x++ {
+						f += channel[x+y*w] * fx[x] * fy
+					}
+				}
+				f /= float64(n)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return
+	}
+
+	lDC, lAC, lScale := encodeChannel(l, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+	acs := [][]float64{lAC, pAC, qAC}
+	var aDC, aScale float64
+	if hasAlpha {
+		var aAC []float64
+		aDC, aAC, aScale = encodeChannel(a, 5, 5)
+		acs = append(acs, aAC)
+	}
+
+	isLandscape := w > h
+	header24 := int(jsRound(63*lDC)) |
+		int(jsRound(31.5+31.5*pDC))<<6 |
+		int(jsRound(31.5+31.5*qDC))<<12 |
+		int(jsRound(31*lScale))<<18
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	header16 := int(jsRound(63*pScale))<<3 | int(jsRound(63*qScale))<<9
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+
+	acStart := 5
+	if hasAlpha {
+		acStart = 6
+	}
+	acCount := 0
+	for _, ac := range acs {
+		acCount += len(ac)
+	}
+	hash := make([]byte, acStart+(acCount+1)/2)
+	hash[0] = byte(header24)
+	hash[1] = byte(header24 >> 8)
+	hash[2] = byte(header24 >> 16)
+	hash[3] = byte(header16)
+	hash[4] = byte(header16 >> 8)
+	if hasAlpha {
+		hash[5] = byte(int(jsRound(15*aDC)) | int(jsRound(15*aScale))<<4)
+	}
+	acIndex := 0
+	for _, ac := range acs {
+		for _, f := range ac {
+			hash[acStart+acIndex>>1] |= byte(int(jsRound(15*f)) << ((acIndex & 1) << 2))
+			acIndex++
+		}
+	}
+	return hash
+}
+
+// jsRound rounds half up as Math.round of the reference implementation
+func jsRound(f float64) float64 {
+	return math.Floor(f + 0.5)
+}
@@ -146,6 +146,29 @@ const (
 	InterpretationHSV       Interpretation = C.VIPS_INTERPRETATION_HSV
 )
 
+// InterpretationNames maps Interpretation to vips nickname
+var InterpretationNames = map[Interpretation]string{
+	InterpretationMultiband: "multiband",
+	InterpretationBW:        "b-w",
+	InterpretationHistogram: "histogram",
+	InterpretationXYZ:       "xyz",
+	InterpretationLAB:       "lab",
+	InterpretationCMYK:      "cmyk",
+	InterpretationLABQ:      "labq",
+	InterpretationRGB:       "rgb",
+	InterpretationRGB16:     "rgb16",
+	InterpretationCMC:       "cmc",
+	InterpretationLCH:       "lch",
+	InterpretationLABS:      "labs",
+	InterpretationSRGB:      "srgb",
+	InterpretationYXY:       "yxy",
+	InterpretationFourier:   "fourier",
+	InterpretationGrey16:    "grey16",
+	InterpretationMatrix:    "matrix",
+	InterpretationScRGB:     "scrgb",
+	InterpretationHSV:       "hsv",
+}
+
 // Intent represents VIPS_INTENT type
 type Intent int
 
@@ -318,6 +341,31 @@ const (
 	SizeLast  Size = C.VIPS_SIZE_LAST
 )
 
+// Kernel represents VipsKernel type
+type Kernel int
+
+// Kernel enum
+const (
+	KernelNearest  Kernel = C.VIPS_KERNEL_NEAREST
+	KernelLinear   Kernel = C.VIPS_KERNEL_LINEAR
+	KernelCubic    Kernel = C.VIPS_KERNEL_CUBIC
+	KernelMitchell Kernel = C.VIPS_KERNEL_MITCHELL
+	KernelLanczos2 Kernel = C.VIPS_KERNEL_LANCZOS2
+	KernelLanczos3 Kernel = C.VIPS_KERNEL_LANCZOS3
+	// KernelAuto resize kernel chosen by thumbnail
+	KernelAuto Kernel = -1
+)
+
+var kernelMap = map[string]Kernel{
+	"auto":     KernelAuto,
+	"nearest":  KernelNearest,
+	"linear":   KernelLinear,
+	"cubic":    KernelCubic,
+	"mitchell": KernelMitchell,
+	"lanczos2": KernelLanczos2,
+	"lanczos3": KernelLanczos3,
+}
+
 // Align represents VIPS_ALIGN
 type Align int
 
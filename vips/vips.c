@@ -15,16 +15,16 @@ int image_new_from_source_with_option(VipsSourceCustom *source, VipsImage **out,
 
 int thumbnail_source_with_option(VipsSourceCustom *source, VipsImage **out,
                     int width, int height, int crop, int size,
-                    const char *option_string) {
+                    const char *option_string, gboolean no_rotate) {
   return vips_thumbnail_source((VipsSource*) source, out, width, "height", height,
-                              "crop", crop, "size", size,
+                              "crop", crop, "size", size, "no_rotate", no_rotate,
                               "option_string", option_string, NULL);
 }
 
 int thumbnail_source(VipsSourceCustom *source, VipsImage **out,
-                    int width, int height, int crop, int size) {
+                    int width, int height, int crop, int size, gboolean no_rotate) {
   return vips_thumbnail_source((VipsSource*) source, out, width, "height", height,
-                              "crop", crop, "size", size, NULL);
+                              "crop", crop, "size", size, "no_rotate", no_rotate, NULL);
 }
 
 void clear_source(VipsSourceCustom **source_custom) {
@@ -67,6 +67,11 @@ int thumbnail_image(VipsImage *in, VipsImage **out, int width, int height,
                               "size", size, NULL);
 }
 
+int resize_image(VipsImage *in, VipsImage **out, double scale, double vscale,
+                 int kernel) {
+  return vips_resize(in, out, scale, "vscale", vscale, "kernel", kernel, NULL);
+}
+
 int thumbnail_buffer_with_option(void *buf, size_t len, VipsImage **out,
                     int width, int height, int crop, int size,
                     const char *option_string) {
@@ -374,10 +379,107 @@ int replicate(VipsImage *in, VipsImage **out, int across, int down) {
   return vips_replicate(in, out, across, down, NULL);
 }
 
+int sprite_image(VipsImage *in, VipsImage **out, const int *frames, int n,
+                 int cols, int rows) {
+  int page_height = vips_image_get_page_height(in);
+  int i, ret = 0;
+  VipsImage *joined = NULL;
+  VipsImage **cells = (VipsImage **)g_malloc0(n * sizeof(VipsImage *));
+  for (i = 0; i < n; i++) {
+    if (vips_extract_area(in, &cells[i], 0, frames[i] * page_height,
+                          in->Xsize, page_height, NULL)) {
+      ret = 1;
+      break;
+    }
+  }
+  if (!ret) {
+    ret = vips_arrayjoin(cells, &joined, n, "across", cols, NULL);
+  }
+  for (i = 0; i < n; i++) {
+    if (cells[i]) {
+      g_object_unref(cells[i]);
+    }
+  }
+  g_free(cells);
+  if (ret) {
+    return ret;
+  }
+  // pad empty cells so that the sheet always has the full grid
+  ret = vips_embed(joined, out, 0, 0, in->Xsize * cols, page_height * rows,
+                   NULL);
+  g_object_unref(joined);
+  return ret;
+}
+
+int extract_band(VipsImage *in, VipsImage **out, int band, int num) {
+  return vips_extract_band(in, out, band, "n", num, NULL);
+}
+
+int replace_color(VipsImage *in, VipsImage **out, double from_hue,
+                  double to_hue, double tolerance, double min_chroma) {
+  // expects LCh image, with optional alpha band
+  double a[4] = {1, 1, 1, 1};
+  double b[4] = {0, 0, to_hue - from_hue, 0};
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **)vips_object_local_array(VIPS_OBJECT(base), 10);
+  // hue distance wrapped to [0, 360)
+  if (vips_extract_band(in, &t[0], 2, NULL) ||
+      vips_linear1(t[0], &t[1], 1.0, 360.0 - from_hue, NULL) ||
+      vips_remainder_const1(t[1], &t[2], 360.0, NULL) ||
+      vips_relational_const1(t[2], &t[3], VIPS_OPERATION_RELATIONAL_LESSEQ,
+                             tolerance, NULL) ||
+      vips_relational_const1(t[2], &t[4], VIPS_OPERATION_RELATIONAL_MOREEQ,
+                             360.0 - tolerance, NULL) ||
+      vips_boolean(t[3], t[4], &t[5], VIPS_OPERATION_BOOLEAN_OR, NULL) ||
+      // skip near grey pixels where hue is unstable
+      vips_extract_band(in, &t[6], 1, NULL) ||
+      vips_relational_const1(t[6], &t[7], VIPS_OPERATION_RELATIONAL_MORE,
+                             min_chroma, NULL) ||
+      vips_boolean(t[5], t[7], &t[8], VIPS_OPERATION_BOOLEAN_AND, NULL) ||
+      vips_linear(in, &t[9], a, b, in->Bands, NULL) ||
+      vips_ifthenelse(t[8], t[9], in, out, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  g_object_unref(base);
+  return 0;
+}
+
 int linear(VipsImage *in, VipsImage **out, double *a, double *b, int n) {
   return vips_linear(in, out, a, b, n, NULL);
 }
 
+int recomb_image(VipsImage *in, VipsImage **out, double *matrix, int n) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+
+  if (!(t[0] = vips_image_new_matrix_from_array(n, n, matrix, n * n))) {
+    clear_image(&base);
+    return 1;
+  }
+  // recombine color bands only, alpha band is preserved
+  if (vips_image_hasalpha(in)) {
+    if (
+      vips_extract_band(in, &t[1], 0, "n", in->Bands - 1, NULL) ||
+      vips_extract_band(in, &t[2], in->Bands - 1, NULL) ||
+      vips_recomb(t[1], &t[3], t[0], NULL) ||
+      vips_cast(t[3], &t[4], in->BandFmt, NULL) ||
+      vips_bandjoin2(t[4], t[2], out, NULL)
+    ) {
+      clear_image(&base);
+      return 1;
+    }
+  } else if (
+    vips_recomb(in, &t[3], t[0], NULL) ||
+    vips_cast(t[3], out, in->BandFmt, NULL)
+  ) {
+    clear_image(&base);
+    return 1;
+  }
+  clear_image(&base);
+  return 0;
+}
+
 int find_trim(VipsImage *in, int *left, int *top, int *width, int *height,
   double threshold, int x, int y) {
   VipsImage *base = vips_image_new();
@@ -426,12 +528,63 @@ int getpoint(VipsImage *in, double **vector, int n, int x, int y) {
   return vips_getpoint(in, vector, &n, x, y, NULL);
 }
 
+int rgba_pixels(VipsImage *in, void **buf, size_t *len) {
+  VipsImage *srgb = NULL, *rgba = NULL;
+  if (vips_colourspace(in, &srgb, VIPS_INTERPRETATION_sRGB, NULL)) {
+    return 1;
+  }
+  if (vips_image_hasalpha(srgb)) {
+    rgba = srgb;
+  } else {
+    int err = vips_addalpha(srgb, &rgba, NULL);
+    g_object_unref(srgb);
+    if (err) {
+      return 1;
+    }
+  }
+  *buf = vips_image_write_to_memory(rgba, len);
+  g_object_unref(rgba);
+  return *buf == NULL;
+}
+
 int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space) {
   return vips_colourspace(in, out, space, NULL);
 }
 
-int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma) {
-  return vips_gaussblur(in, out, sigma, NULL);
+int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma, double min_ampl) {
+  return vips_gaussblur(in, out, sigma, "min_ampl", min_ampl, NULL);
+}
+
+int gaussian_blur_multi_page_image(VipsImage *in, VipsImage **out, double sigma, double min_ampl) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  int page_height = vips_image_get_page_height(in);
+  int in_width = in->Xsize;
+  int n_pages = in->Ysize / page_height;
+
+  VipsImage **page = (VipsImage **) vips_object_local_array(base, n_pages);
+  VipsImage **copy = (VipsImage **) vips_object_local_array(base, 1);
+
+  // split image into frames so that blur does not bleed across frames
+  for (int i = 0; i < n_pages; i++) {
+    if (
+      vips_extract_area(in, &page[i], 0, page_height * i, in_width, page_height, NULL) ||
+      vips_gaussblur(page[i], &page[i], sigma, "min_ampl", min_ampl, NULL)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+  }
+  // reassemble frames, copy before modifying metadata
+  if(
+    vips_arrayjoin(page, &copy[0], n_pages, "across", 1, NULL) ||
+    vips_copy(copy[0], out, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+  vips_image_set_int(*out, VIPS_META_PAGE_HEIGHT, page_height);
+  g_object_unref(base);
+  return 0;
 }
 
 int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
@@ -439,10 +592,68 @@ int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
   return vips_sharpen(in, out, "sigma", sigma, "x1", x1, "m2", m2, NULL);
 }
 
+int sharpen_multi_page_image(VipsImage *in, VipsImage **out, double sigma, double x1,
+                             double m2) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  int page_height = vips_image_get_page_height(in);
+  int in_width = in->Xsize;
+  int n_pages = in->Ysize / page_height;
+
+  VipsImage **page = (VipsImage **) vips_object_local_array(base, n_pages);
+  VipsImage **copy = (VipsImage **) vips_object_local_array(base, 1);
+
+  // split image into frames so that sharpen does not bleed across frames
+  for (int i = 0; i < n_pages; i++) {
+    if (
+      vips_extract_area(in, &page[i], 0, page_height * i, in_width, page_height, NULL) ||
+      vips_sharpen(page[i], &page[i], "sigma", sigma, "x1", x1, "m2", m2, NULL)
+    ) {
+      g_object_unref(base);
+      return -1;
+    }
+  }
+  // reassemble frames, copy before modifying metadata
+  if(
+    vips_arrayjoin(page, &copy[0], n_pages, "across", 1, NULL) ||
+    vips_copy(copy[0], out, NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+  vips_image_set_int(*out, VIPS_META_PAGE_HEIGHT, page_height);
+  g_object_unref(base);
+  return 0;
+}
+
 gboolean remove_icc_profile(VipsImage *in) {
   return vips_image_remove(in, VIPS_META_ICC_NAME);
 }
 
+gboolean get_icc_profile(VipsImage *in, const void **data, size_t *length) {
+  if (vips_image_get_typeof(in, VIPS_META_ICC_NAME) == 0) {
+    return FALSE;
+  }
+  return vips_image_get_blob(in, VIPS_META_ICC_NAME, data, length) == 0;
+}
+
+int set_icc_profile_srgb(VipsImage *in) {
+  VipsBlob *profile = NULL;
+  if (vips_profile_load("srgb", &profile, NULL)) {
+    return 1;
+  }
+  if (profile != NULL) {
+    size_t length;
+    const void *data = vips_blob_get(profile, &length);
+    vips_image_set_blob_copy(in, VIPS_META_ICC_NAME, data, length);
+    vips_area_unref(VIPS_AREA(profile));
+  }
+  return 0;
+}
+
+gboolean remove_orientation(VipsImage *in) {
+  return vips_image_remove(in, VIPS_META_ORIENTATION);
+}
+
 int get_meta_orientation(VipsImage *in) {
   int orientation = 0;
   if (vips_image_get_typeof(in, VIPS_META_ORIENTATION) != 0) {
@@ -478,6 +689,27 @@ void set_image_delay(VipsImage *in, const int *array, int n) {
   return vips_image_set_array_int(in, "delay", array, n);
 }
 
+void set_image_loop(VipsImage *in, int loop) {
+  vips_image_set_int(in, "loop", loop);
+}
+
+int get_image_loop(VipsImage *in) {
+  int loop = 0;
+  if (vips_image_get_typeof(in, "loop") != 0) {
+    vips_image_get_int(in, "loop", &loop);
+  }
+  return loop;
+}
+
+int get_image_delay(VipsImage *in, int **out) {
+  int n = 0;
+  if (vips_image_get_typeof(in, "delay") == 0 ||
+      vips_image_get_array_int(in, "delay", out, &n)) {
+    return 0;
+  }
+  return n;
+}
+
 const char * get_meta_string(const VipsImage *image, const char *name) {
 	const char *val;
 	if (
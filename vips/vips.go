@@ -84,17 +84,19 @@ func vipsThumbnailFromSource(
 	var out *C.VipsImage
 	var code C.int
 	var optionString string
+	var noRotate bool
 
 	if params != nil {
 		optionString = params.OptionString()
+		noRotate = params.NoRotate.IsSet() && params.NoRotate.Get()
 	}
 	if optionString == "" {
-		code = C.thumbnail_source(src, &out, C.int(width), C.int(height), C.int(crop), C.int(size))
+		code = C.thumbnail_source(src, &out, C.int(width), C.int(height), C.int(crop), C.int(size), toGboolean(noRotate))
 	} else {
 		cOptionString := C.CString(optionString)
 		defer freeCString(cOptionString)
 
-		code = C.thumbnail_source_with_option(src, &out, C.int(width), C.int(height), C.int(crop), C.int(size), cOptionString)
+		code = C.thumbnail_source_with_option(src, &out, C.int(width), C.int(height), C.int(crop), C.int(size), cOptionString, toGboolean(noRotate))
 	}
 	if code != 0 {
 		return nil, ImageTypeUnknown, handleImageError(out)
@@ -133,6 +135,17 @@ func vipsThumbnail(in *C.VipsImage, width, height int, crop Interesting, size Si
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-resize
+func vipsResize(in *C.VipsImage, scale, vscale float64, kernel Kernel) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.resize_image(in, &out, C.double(scale), C.double(vscale), C.int(kernel)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-embed
 func vipsEmbed(in *C.VipsImage, left, top, width, height int, extend ExtendStrategy) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -298,6 +311,40 @@ func vipsReplicate(in *C.VipsImage, across int, down int) (*C.VipsImage, error)
 	return out, nil
 }
 
+// https://www.libvips.org/API/current/libvips-conversion.html#vips-arrayjoin
+func vipsSprite(in *C.VipsImage, frames []int, cols, rows int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	data := make([]C.int, len(frames))
+	for i, f := range frames {
+		data[i] = C.int(f)
+	}
+	if err := C.sprite_image(in, &out, &data[0], C.int(len(data)), C.int(cols), C.int(rows)); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
+// https://www.libvips.org/API/current/libvips-conversion.html#vips-extract-band
+func vipsExtractBand(in *C.VipsImage, band, num int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.extract_band(in, &out, C.int(band), C.int(num)); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
+func vipsReplaceColor(in *C.VipsImage, fromHue, toHue, tolerance, minChroma float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.replace_color(
+		in, &out, C.double(fromHue), C.double(toHue), C.double(tolerance), C.double(minChroma),
+	); err != 0 {
+		return nil, handleImageError(out)
+	}
+	return out, nil
+}
+
 //  https://libvips.github.io/libvips/API/current/libvips-arithmetic.html#vips-linear
 func vipsLinear(in *C.VipsImage, a, b []float64, n int) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -309,6 +356,17 @@ func vipsLinear(in *C.VipsImage, a, b []float64, n int) (*C.VipsImage, error) {
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-recomb
+func vipsRecomb(in *C.VipsImage, matrix []float64, n int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.recomb_image(in, &out, (*C.double)(&matrix[0]), C.int(n)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-arithmetic.html#vips-find-trim
 func vipsFindTrim(in *C.VipsImage, threshold float64, x, y int) (int, int, int, int, error) {
 	var left, top, width, height C.int
@@ -334,6 +392,19 @@ func vipsGetPoint(in *C.VipsImage, n int, x int, y int) ([]float64, error) {
 	return (*[4]float64)(unsafe.Pointer(out))[:n:n], nil
 }
 
+// rgba pixels of image as 8-bit sRGB with alpha
+func vipsRGBAPixels(in *C.VipsImage) ([]byte, error) {
+	var ptr unsafe.Pointer
+	var size C.size_t
+
+	if err := C.rgba_pixels(in, &ptr, &size); err != 0 {
+		return nil, handleVipsError()
+	}
+	defer gFreePointer(ptr)
+
+	return C.GoBytes(ptr, C.int(size)), nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-colour.html#vips-colourspace
 func vipsToColorSpace(in *C.VipsImage, interpretation Interpretation) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -348,10 +419,20 @@ func vipsToColorSpace(in *C.VipsImage, interpretation Interpretation) (*C.VipsIm
 }
 
 // https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-gaussblur
-func vipsGaussianBlur(in *C.VipsImage, sigma float64) (*C.VipsImage, error) {
+func vipsGaussBlur(in *C.VipsImage, sigma, minAmpl float64) (*C.VipsImage, error) {
 	var out *C.VipsImage
 
-	if err := C.gaussian_blur_image(in, &out, C.double(sigma)); err != 0 {
+	if err := C.gaussian_blur_image(in, &out, C.double(sigma), C.double(minAmpl)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsGaussBlurMultiPage(in *C.VipsImage, sigma, minAmpl float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.gaussian_blur_multi_page_image(in, &out, C.double(sigma), C.double(minAmpl)); err != 0 {
 		return nil, handleImageError(out)
 	}
 
@@ -369,10 +450,40 @@ func vipsSharpen(in *C.VipsImage, sigma float64, x1 float64, m2 float64) (*C.Vip
 	return out, nil
 }
 
+func vipsSharpenMultiPage(in *C.VipsImage, sigma float64, x1 float64, m2 float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.sharpen_multi_page_image(in, &out, C.double(sigma), C.double(x1), C.double(m2)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 func vipsRemoveICCProfile(in *C.VipsImage) bool {
 	return fromGboolean(C.remove_icc_profile(in))
 }
 
+func vipsGetICCProfile(in *C.VipsImage) []byte {
+	var data unsafe.Pointer
+	var length C.size_t
+	if !fromGboolean(C.get_icc_profile(in, &data, &length)) {
+		return nil
+	}
+	return C.GoBytes(data, C.int(length))
+}
+
+func vipsSetICCProfileSRGB(in *C.VipsImage) error {
+	if err := C.set_icc_profile_srgb(in); err != 0 {
+		return handleVipsError()
+	}
+	return nil
+}
+
+func vipsRemoveOrientation(in *C.VipsImage) bool {
+	return fromGboolean(C.remove_orientation(in))
+}
+
 func vipsRemoveExif(in *C.VipsImage) (*C.VipsImage, error) {
 	var out *C.VipsImage
 
@@ -413,6 +524,27 @@ func vipsImageSetDelay(in *C.VipsImage, data []C.int) error {
 	return nil
 }
 
+func vipsImageSetLoop(in *C.VipsImage, loop int) {
+	C.set_image_loop(in, C.int(loop))
+}
+
+func vipsImageGetLoop(in *C.VipsImage) int {
+	return int(C.get_image_loop(in))
+}
+
+func vipsImageGetDelay(in *C.VipsImage) []int {
+	var out *C.int
+	n := int(C.get_image_delay(in, &out))
+	if n == 0 || out == nil {
+		return nil
+	}
+	delays := make([]int, n)
+	for i, d := range unsafe.Slice(out, n) {
+		delays[i] = int(d)
+	}
+	return delays
+}
+
 func vipsGetMetaString(image *C.VipsImage, name string) string {
 	return C.GoString(C.get_meta_string(image, cachedCString(name)))
 }
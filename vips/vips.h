@@ -8,10 +8,10 @@ int image_new_from_source_with_option(VipsSourceCustom *source, VipsImage **out,
 
 int thumbnail_source_with_option(VipsSourceCustom *source, VipsImage **out,
                     int width, int height, int crop, int size,
-                    const char *option_string);
+                    const char *option_string, gboolean no_rotate);
 
 int thumbnail_source(VipsSourceCustom *source, VipsImage **out,
-                    int width, int height, int crop, int size);
+                    int width, int height, int crop, int size, gboolean no_rotate);
 
 int image_new_from_file(const char *name, VipsImage **out);
 
@@ -23,6 +23,8 @@ int image_new_from_memory(const void *buf, size_t len, int width, int height, in
 
 int thumbnail(const char *filename, VipsImage **out, int width, int height,
                     int crop, int size);
+int resize_image(VipsImage *in, VipsImage **out, double scale, double vscale,
+                 int kernel);
 int thumbnail_image(VipsImage *in, VipsImage **out, int width, int height,
                     int crop, int size);
 int thumbnail_buffer(void *buf, size_t len, VipsImage **out, int width, int height,
@@ -69,20 +71,35 @@ int composite2_image(VipsImage *base, VipsImage *overlay, VipsImage **out,
 int is_16bit(VipsInterpretation interpretation);
 
 int replicate(VipsImage *in, VipsImage **out, int across, int down);
+int sprite_image(VipsImage *in, VipsImage **out, const int *frames, int n,
+                 int cols, int rows);
+
+int extract_band(VipsImage *in, VipsImage **out, int band, int num);
 
 
 int linear(VipsImage *in, VipsImage **out, double *a, double *b, int n);
+int recomb_image(VipsImage *in, VipsImage **out, double *matrix, int n);
+int replace_color(VipsImage *in, VipsImage **out, double from_hue,
+                  double to_hue, double tolerance, double min_chroma);
+
 int find_trim(VipsImage *in, int *left, int *top, int *width, int *height,
   double threshold, int x, int y);
 int getpoint(VipsImage *in, double **vector, int n, int x, int y);
+int rgba_pixels(VipsImage *in, void **buf, size_t *len);
 
 int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space);
 
-int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma);
+int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma, double min_ampl);
+int gaussian_blur_multi_page_image(VipsImage *in, VipsImage **out, double sigma, double min_ampl);
 int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
                   double m2);
+int sharpen_multi_page_image(VipsImage *in, VipsImage **out, double sigma, double x1,
+                             double m2);
 
 int remove_icc_profile(VipsImage *in);
+gboolean get_icc_profile(VipsImage *in, const void **data, size_t *length);
+int set_icc_profile_srgb(VipsImage *in);
+int remove_orientation(VipsImage *in);
 
 int get_meta_orientation(VipsImage *in);
 int get_image_n_pages(VipsImage *in);
@@ -90,5 +107,8 @@ int get_page_height(VipsImage *in);
 void set_page_height(VipsImage *in, int height);
 int get_meta_loader(const VipsImage *in, const char **out);
 void set_image_delay(VipsImage *in, const int *array, int n);
+void set_image_loop(VipsImage *in, int loop);
+int get_image_loop(VipsImage *in);
+int get_image_delay(VipsImage *in, int **out);
 const char * get_meta_string(const VipsImage *image, const char *name);
 int remove_exif(VipsImage *in, VipsImage **out);